@@ -50,6 +50,11 @@ func (esSvc *ElasticSearchService) FlushData() error {
 	return esSvc.EsClient.FlushBulk()
 }
 
+// CheckConnection checks that ElasticSearch is reachable with the configured credentials.
+func (esSvc *ElasticSearchService) CheckConnection() error {
+	return esSvc.EsClient.Ping(esSvc.Index(time.Now()))
+}
+
 // SaveDataIntoES save metrics and events to ES by using ES client
 func (esSvc *ElasticSearchService) SaveData(date time.Time, typeName string, sinkData []interface{}) error {
 	if typeName == "" || len(sinkData) == 0 {
@@ -89,6 +89,13 @@ func (es *esClient) IndexExists(indices ...string) (bool, error) {
 	}
 }
 
+// Ping checks that the cluster is reachable and the credentials are accepted, by checking
+// whether the given index exists.
+func (es *esClient) Ping(index string) error {
+	_, err := es.IndexExists(index)
+	return err
+}
+
 func (es *esClient) CreateIndex(name string, mapping string) (interface{}, error) {
 	switch es.version {
 	case 2:
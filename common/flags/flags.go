@@ -35,14 +35,111 @@ func (u *Uri) String() string {
 	return fmt.Sprintf("%s:%s", u.Key, val)
 }
 
+// redactedValue replaces passwords and sensitive query parameter values in redacted URIs.
+const redactedValue = "xxxxx"
+
+// sensitiveQueryParams lists the lower case names of the sink and source options holding
+// credentials, whose values are redacted.
+var sensitiveQueryParams = map[string]bool{
+	"pass":         true,
+	"password":     true,
+	"pw":           true,
+	"secret":       true,
+	"token":        true,
+	"writekey":     true,
+	"esusersecret": true,
+}
+
+// Redacted returns the URI like String, but with the password and sensitive query parameters
+// replaced, so that it can be logged or shown to users.
+func (u *Uri) Redacted() string {
+	val := u.Val
+	if val.User != nil {
+		if _, hasPassword := val.User.Password(); hasPassword {
+			val.User = url.UserPassword(val.User.Username(), redactedValue)
+		}
+	}
+	val.RawQuery = redactQuery(val.RawQuery)
+	redacted := Uri{Key: u.Key, Val: val}
+	return redacted.String()
+}
+
+// RedactUri parses value as a Uri, without expanding environment variables, and returns it
+// redacted. Values that cannot be parsed are reduced to their key, since their contents cannot be
+// checked for credentials.
+func RedactUri(value string) string {
+	var u Uri
+	if err := u.SetLiteral(value); err != nil {
+		return strings.SplitN(value, ":", 2)[0]
+	}
+	return u.Redacted()
+}
+
+// redactQuery replaces the values of sensitive parameters in the raw query, keeping the other
+// parameters as given.
+func redactQuery(rawQuery string) string {
+	if _, err := url.ParseQuery(rawQuery); err != nil {
+		// The values can't be told apart, so none of them is shown.
+		return redactedValue
+	}
+	params := strings.Split(rawQuery, "&")
+	for i, param := range params {
+		name := strings.SplitN(param, "=", 2)[0]
+		if unescaped, err := url.QueryUnescape(name); err == nil && sensitiveQueryParams[strings.ToLower(unescaped)] {
+			params[i] = name + "=" + redactedValue
+		}
+	}
+	return strings.Join(params, "&")
+}
+
+// RedactArgs returns the command line arguments with the values of the given uri flags redacted,
+// so that they can be logged. Both the -name=value and -name value forms are recognized, with one
+// or two dashes.
+func RedactArgs(args []string, uriFlags ...string) []string {
+	isUriFlag := func(arg string) bool {
+		name := strings.TrimLeft(arg, "-")
+		if name == arg {
+			return false
+		}
+		for _, uriFlag := range uriFlags {
+			if name == uriFlag {
+				return true
+			}
+		}
+		return false
+	}
+	result := make([]string, len(args))
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		result[i] = arg
+		if kv := strings.SplitN(arg, "=", 2); len(kv) == 2 && isUriFlag(kv[0]) {
+			result[i] = kv[0] + "=" + RedactUri(kv[1])
+		} else if isUriFlag(arg) && i+1 < len(args) {
+			i++
+			result[i] = RedactUri(args[i])
+		}
+	}
+	return result
+}
+
 func (u *Uri) Set(value string) error {
+	return u.set(value, os.ExpandEnv)
+}
+
+// SetLiteral parses value like Set, but without expanding environment variables, for uris that
+// don't come from the command line.
+func (u *Uri) SetLiteral(value string) error {
+	return u.set(value, func(s string) string { return s })
+}
+
+func (u *Uri) set(value string, expand func(string) string) error {
 	s := strings.SplitN(value, ":", 2)
 	if s[0] == "" {
 		return fmt.Errorf("missing uri key in '%s'", value)
 	}
 	u.Key = s[0]
 	if len(s) > 1 && s[1] != "" {
-		e := os.ExpandEnv(s[1])
+		e := expand(s[1])
 		uri, err := url.Parse(e)
 		if err != nil {
 			return err
@@ -0,0 +1,122 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package flags
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// UriOptions gives typed access to the query options of a source or sink uri, with the same
+// error messages for all of them. Only the first value of an option given several times is used.
+type UriOptions struct {
+	values url.Values
+}
+
+func NewUriOptions(uri *url.URL) UriOptions {
+	return UriOptions{values: uri.Query()}
+}
+
+// Has returns whether the option is set.
+func (this UriOptions) Has(key string) bool {
+	return len(this.values[key]) > 0
+}
+
+// String returns the value of the option, or def if it isn't set.
+func (this UriOptions) String(key, def string) string {
+	if !this.Has(key) {
+		return def
+	}
+	return this.values[key][0]
+}
+
+func invalidOption(key, value, kind string) error {
+	return fmt.Errorf("invalid value %q of option %s, expected %s", value, key, kind)
+}
+
+// Bool returns the value of the option parsed with strconv.ParseBool, or def if it isn't set.
+func (this UriOptions) Bool(key string, def bool) (bool, error) {
+	if !this.Has(key) {
+		return def, nil
+	}
+	value, err := strconv.ParseBool(this.values[key][0])
+	if err != nil {
+		return def, invalidOption(key, this.values[key][0], "true or false")
+	}
+	return value, nil
+}
+
+// Int returns the integer value of the option, or def if it isn't set.
+func (this UriOptions) Int(key string, def int) (int, error) {
+	if !this.Has(key) {
+		return def, nil
+	}
+	value, err := strconv.Atoi(this.values[key][0])
+	if err != nil {
+		return def, invalidOption(key, this.values[key][0], "an integer")
+	}
+	return value, nil
+}
+
+// Float returns the floating point value of the option, or def if it isn't set.
+func (this UriOptions) Float(key string, def float64) (float64, error) {
+	if !this.Has(key) {
+		return def, nil
+	}
+	value, err := strconv.ParseFloat(this.values[key][0], 64)
+	if err != nil {
+		return def, invalidOption(key, this.values[key][0], "a number")
+	}
+	return value, nil
+}
+
+// Duration returns the value of the option parsed with time.ParseDuration, e.g. 30s, or def if
+// it isn't set.
+func (this UriOptions) Duration(key string, def time.Duration) (time.Duration, error) {
+	if !this.Has(key) {
+		return def, nil
+	}
+	value, err := time.ParseDuration(this.values[key][0])
+	if err != nil {
+		return def, invalidOption(key, this.values[key][0], "a duration like 30s")
+	}
+	return value, nil
+}
+
+// UnknownKeys returns the sorted names of the options that are not in known. Known names ending
+// with * match all the options starting with the rest of the name.
+func (this UriOptions) UnknownKeys(known []string) []string {
+	var result []string
+	for key := range this.values {
+		if !isKnownKey(key, known) {
+			result = append(result, key)
+		}
+	}
+	sort.Strings(result)
+	return result
+}
+
+func isKnownKey(key string, known []string) bool {
+	for _, name := range known {
+		if name == key || (strings.HasSuffix(name, "*") && strings.HasPrefix(key, strings.TrimSuffix(name, "*"))) {
+			return true
+		}
+	}
+	return false
+}
@@ -51,6 +51,8 @@ type KafkaClient interface {
 }
 
 type kafkaSink struct {
+	// The client of the producer, used to check the brokers are reachable.
+	client    kafka.Client
 	producer  kafka.SyncProducer
 	dataTopic string
 }
@@ -81,6 +83,12 @@ func (sink *kafkaSink) Name() string {
 
 func (sink *kafkaSink) Stop() {
 	sink.producer.Close()
+	sink.client.Close()
+}
+
+// CheckConnection fetches the metadata of the topic from the brokers.
+func (sink *kafkaSink) CheckConnection() error {
+	return sink.client.RefreshMetadata(sink.dataTopic)
 }
 
 func getTopic(opts map[string][]string, topicType string) (string, error) {
@@ -233,13 +241,19 @@ func NewKafkaClient(uri *url.URL, topicType string) (KafkaClient, error) {
 
 	// set up producer of kafka server.
 	glog.V(3).Infof("attempting to setup kafka sink")
-	sinkProducer, err := kafka.NewSyncProducer(kafkaBrokers, config)
+	client, err := kafka.NewClient(kafkaBrokers, config)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to setup Producer: - %v", err)
+	}
+	sinkProducer, err := kafka.NewSyncProducerFromClient(client)
 	if err != nil {
+		client.Close()
 		return nil, fmt.Errorf("Failed to setup Producer: - %v", err)
 	}
 
 	glog.V(3).Infof("kafka sink setup successfully")
 	return &kafkaSink{
+		client:    client,
 		producer:  sinkProducer,
 		dataTopic: topic,
 	}, nil
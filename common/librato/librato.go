@@ -69,6 +69,26 @@ func (c *LibratoClient) Write(measurements []Measurement) error {
 	return err
 }
 
+// CheckConnection lists a single metric, to check that the API is reachable and accepts the
+// credentials.
+func (c *LibratoClient) CheckConnection() error {
+	req, err := http.NewRequest("GET", c.config.API+"/v1/metrics?length=1", nil)
+	if nil != err {
+		return err
+	}
+	req.Header.Set("User-Agent", "heapster")
+	req.SetBasicAuth(c.config.Username, c.config.Token)
+	resp, err := c.httpClient.Do(req)
+	if nil != err {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("request to %s failed with status %s", c.config.API, resp.Status)
+	}
+	return nil
+}
+
 type LibratoConfig struct {
 	Username string
 	Token    string
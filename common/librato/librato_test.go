@@ -95,3 +95,25 @@ func TestLibratoClientWriteWithTags(t *testing.T) {
 
 	handler.ValidateRequest(t, "/v1/measurements", "POST", &expectedBody)
 }
+
+func TestLibratoClientCheckConnection(t *testing.T) {
+	handler := util.FakeHandler{
+		StatusCode:   200,
+		ResponseBody: "",
+		T:            t,
+	}
+	server := httptest.NewServer(&handler)
+	defer server.Close()
+
+	stubLibratoURL, err := url.Parse("?username=stub&token=stub&api=" + server.URL)
+	assert.NoError(t, err)
+	config, err := BuildConfig(stubLibratoURL)
+	assert.NoError(t, err)
+
+	assert.NoError(t, NewClient(*config).CheckConnection())
+	handler.ValidateRequest(t, "/v1/metrics?length=1", "GET", nil)
+
+	// Rejected credentials fail the check.
+	handler.StatusCode = 401
+	assert.Error(t, NewClient(*config).CheckConnection())
+}
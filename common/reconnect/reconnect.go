@@ -0,0 +1,227 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package reconnect
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/prometheus/client_golang/prometheus"
+	"k8s.io/heapster/common/flags"
+)
+
+const (
+	resultSuccess = "success"
+	resultFailure = "failure"
+)
+
+var (
+	// Whether each stream sink is connected.
+	connected = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: "heapster",
+			Subsystem: "exporter",
+			Name:      "connected",
+			Help:      "Whether the sink is connected to its backend, 1 if it is and 0 otherwise.",
+		},
+		[]string{"sink_id"},
+	)
+	// Number of connection attempts of each stream sink.
+	connectionAttempts = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "heapster",
+			Subsystem: "exporter",
+			Name:      "connection_attempts_total",
+			Help:      "Number of attempts of the sink to connect to its backend, by result.",
+		},
+		[]string{"sink_id", "result"},
+	)
+)
+
+var (
+	reconnectorsLock sync.Mutex
+	// Reconnectors of all the sinks, by sink id.
+	reconnectors = make(map[string]*Reconnector)
+)
+
+func init() {
+	prometheus.MustRegister(connected)
+	prometheus.MustRegister(connectionAttempts)
+}
+
+// Options are the sink uri options configuring reconnection.
+var Options = []string{"reconnectAttempts", "reconnectBackoff", "reconnectMaxBackoff"}
+
+// Config configures how a sink reconnects.
+type Config struct {
+	// Number of connection attempts when the sink needs a connection, e.g. at every export.
+	Attempts int
+	// Wait after the first failed attempt, doubled after each of the following ones.
+	InitialBackoff time.Duration
+	// Maximum wait between attempts.
+	MaxBackoff time.Duration
+}
+
+// DefaultConfig tries to connect once per export, the export interval being the backoff.
+func DefaultConfig() Config {
+	return Config{
+		Attempts:       1,
+		InitialBackoff: time.Second,
+		MaxBackoff:     10 * time.Second,
+	}
+}
+
+// ParseConfig returns the configuration given by the reconnectAttempts, reconnectBackoff and
+// reconnectMaxBackoff options of a sink uri, with the defaults for the missing ones.
+func ParseConfig(uri *url.URL) (Config, error) {
+	config := DefaultConfig()
+	opts := flags.NewUriOptions(uri)
+	var err error
+	if config.Attempts, err = opts.Int("reconnectAttempts", config.Attempts); err != nil {
+		return config, err
+	}
+	if config.InitialBackoff, err = opts.Duration("reconnectBackoff", config.InitialBackoff); err != nil {
+		return config, err
+	}
+	if config.MaxBackoff, err = opts.Duration("reconnectMaxBackoff", config.MaxBackoff); err != nil {
+		return config, err
+	}
+	if config.Attempts < 1 {
+		return config, fmt.Errorf("reconnectAttempts should be at least 1, got %d", config.Attempts)
+	}
+	return config, nil
+}
+
+// State describes the connection of a sink.
+type State struct {
+	// Identifies the sink instance, e.g. graphite:localhost:2003.
+	SinkId    string
+	Connected bool
+	// Number of failed connection attempts since the last successful one.
+	ConsecutiveFailures int
+	// The last connection or write error, cleared when connected.
+	LastError   error
+	LastAttempt time.Time
+}
+
+// Reconnector keeps track of the connection of a stream sink and reconnects it with backoff.
+// The sink passes the function opening its connection, calls Connect before writing and
+// Disconnected when a write fails.
+type Reconnector struct {
+	id      string
+	config  Config
+	connect func() error
+	// for testing
+	sleep func(time.Duration)
+
+	lock  sync.Mutex
+	state State
+}
+
+// NewReconnector creates a reconnector for the sink instance with the given id, made of the
+// kind of sink and its backend address so that sinks of the same kind are told apart. It starts
+// disconnected unless the sink already opened its connection and calls Connected.
+func NewReconnector(id string, config Config, connect func() error) *Reconnector {
+	connected.WithLabelValues(id).Set(0)
+	reconnector := &Reconnector{
+		id:      id,
+		config:  config,
+		connect: connect,
+		sleep:   time.Sleep,
+		state:   State{SinkId: id},
+	}
+	reconnectorsLock.Lock()
+	defer reconnectorsLock.Unlock()
+	reconnectors[id] = reconnector
+	return reconnector
+}
+
+// States returns the state of the connections of all the sinks, sorted by sink id.
+func States() []State {
+	reconnectorsLock.Lock()
+	defer reconnectorsLock.Unlock()
+	result := make([]State, 0, len(reconnectors))
+	for _, reconnector := range reconnectors {
+		result = append(result, reconnector.State())
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].SinkId < result[j].SinkId })
+	return result
+}
+
+// Connect opens the connection if it isn't open, making up to the configured number of
+// attempts, and returns the last error if all of them fail.
+func (this *Reconnector) Connect() error {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+	if this.state.Connected {
+		return nil
+	}
+	backoff := this.config.InitialBackoff
+	for attempt := 1; ; attempt++ {
+		this.state.LastAttempt = time.Now()
+		err := this.connect()
+		if err == nil {
+			connectionAttempts.WithLabelValues(this.id, resultSuccess).Inc()
+			glog.V(2).Infof("%s connected after %d failed attempts", this.id, this.state.ConsecutiveFailures)
+			this.setConnected()
+			return nil
+		}
+		connectionAttempts.WithLabelValues(this.id, resultFailure).Inc()
+		this.state.ConsecutiveFailures++
+		this.state.LastError = err
+		glog.Warningf("%s failed to connect: %v", this.id, err)
+		if attempt >= this.config.Attempts {
+			return err
+		}
+		this.sleep(backoff)
+		if backoff *= 2; backoff > this.config.MaxBackoff {
+			backoff = this.config.MaxBackoff
+		}
+	}
+}
+
+func (this *Reconnector) setConnected() {
+	this.state.Connected = true
+	this.state.ConsecutiveFailures = 0
+	this.state.LastError = nil
+	connected.WithLabelValues(this.id).Set(1)
+}
+
+// Connected records that the sink opened its connection by itself.
+func (this *Reconnector) Connected() {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+	this.setConnected()
+}
+
+// Disconnected records that the connection broke, the next Connect reopens it.
+func (this *Reconnector) Disconnected(err error) {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+	this.state.Connected = false
+	this.state.LastError = err
+	connected.WithLabelValues(this.id).Set(0)
+}
+
+// State returns the current state of the connection.
+func (this *Reconnector) State() State {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+	return this.state
+}
@@ -0,0 +1,99 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package reconnect
+
+import (
+	"errors"
+	"net/url"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReconnectorBacksOff(t *testing.T) {
+	failures := 3
+	connects := 0
+	reconnector := NewReconnector("test", Config{Attempts: 5, InitialBackoff: time.Second, MaxBackoff: 3 * time.Second}, func() error {
+		connects++
+		if connects <= failures {
+			return errors.New("refused")
+		}
+		return nil
+	})
+	var waits []time.Duration
+	reconnector.sleep = func(d time.Duration) { waits = append(waits, d) }
+
+	assert.NoError(t, reconnector.Connect())
+	assert.Equal(t, 4, connects)
+	assert.Equal(t, []time.Duration{time.Second, 2 * time.Second, 3 * time.Second}, waits)
+	assert.True(t, reconnector.State().Connected)
+	assert.Equal(t, 0, reconnector.State().ConsecutiveFailures)
+
+	// Connected sinks don't reconnect.
+	assert.NoError(t, reconnector.Connect())
+	assert.Equal(t, 4, connects)
+
+	reconnector.Disconnected(errors.New("broken pipe"))
+	assert.False(t, reconnector.State().Connected)
+	assert.EqualError(t, reconnector.State().LastError, "broken pipe")
+	assert.NoError(t, reconnector.Connect())
+	assert.Equal(t, 5, connects)
+}
+
+func TestReconnectorGivesUp(t *testing.T) {
+	connects := 0
+	reconnector := NewReconnector("test", Config{Attempts: 2, InitialBackoff: time.Second, MaxBackoff: time.Second}, func() error {
+		connects++
+		return errors.New("refused")
+	})
+	reconnector.sleep = func(time.Duration) {}
+
+	assert.EqualError(t, reconnector.Connect(), "refused")
+	assert.Equal(t, 2, connects)
+	state := reconnector.State()
+	assert.False(t, state.Connected)
+	assert.Equal(t, 2, state.ConsecutiveFailures)
+}
+
+func TestStates(t *testing.T) {
+	NewReconnector("graphite:b:2003", DefaultConfig(), func() error { return nil }).Connected()
+	NewReconnector("graphite:a:2003", DefaultConfig(), func() error { return nil })
+
+	var ids []string
+	for _, state := range States() {
+		ids = append(ids, state.SinkId)
+		if state.SinkId == "graphite:b:2003" {
+			assert.True(t, state.Connected)
+		}
+	}
+	assert.Contains(t, ids, "graphite:a:2003")
+	assert.Contains(t, ids, "graphite:b:2003")
+	assert.True(t, sort.StringsAreSorted(ids))
+}
+
+func TestParseConfig(t *testing.T) {
+	uri, err := url.Parse("?reconnectAttempts=3&reconnectBackoff=500ms")
+	assert.NoError(t, err)
+	config, err := ParseConfig(uri)
+	assert.NoError(t, err)
+	assert.Equal(t, Config{Attempts: 3, InitialBackoff: 500 * time.Millisecond, MaxBackoff: 10 * time.Second}, config)
+
+	uri, err = url.Parse("?reconnectAttempts=0")
+	assert.NoError(t, err)
+	_, err = ParseConfig(uri)
+	assert.Error(t, err)
+}
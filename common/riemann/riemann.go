@@ -17,12 +17,12 @@ package riemann
 import (
 	"net/url"
 	"runtime"
-	"strconv"
 	"sync"
 	"time"
 
 	"github.com/golang/glog"
 	"github.com/riemann/riemann-go-client"
+	"k8s.io/heapster/common/flags"
 )
 
 // Used to store the Riemann configuration specified in the Heapster cli
@@ -56,21 +56,16 @@ func CreateRiemannSink(uri *url.URL) (*RiemannSink, error) {
 		c.Host = uri.Host
 	}
 	options := uri.Query()
+	opts := flags.NewUriOptions(uri)
 	// check ttl
-	if len(options["ttl"]) > 0 {
-		var ttl, err = strconv.ParseFloat(options["ttl"][0], 32)
-		if err != nil {
-			return nil, err
-		}
-		c.Ttl = float32(ttl)
+	ttl, err := opts.Float("ttl", float64(c.Ttl))
+	if err != nil {
+		return nil, err
 	}
+	c.Ttl = float32(ttl)
 	// check batch size
-	if len(options["batchsize"]) > 0 {
-		var batchSize, err = strconv.Atoi(options["batchsize"][0])
-		if err != nil {
-			return nil, err
-		}
-		c.BatchSize = batchSize
+	if c.BatchSize, err = opts.Int("batchsize", c.BatchSize); err != nil {
+		return nil, err
 	}
 	// check state
 	if len(options["state"]) > 0 {
@@ -0,0 +1,116 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package udp
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+
+	"github.com/golang/glog"
+)
+
+// DefaultMaxDatagramSize keeps datagrams from being fragmented on a standard
+// Ethernet MTU (1500 bytes minus the IP and UDP headers, with some slack for
+// IPv6 and tunneling).
+const DefaultMaxDatagramSize = 1432
+
+// LineWriter sends lines of a line protocol over UDP, packing as many
+// newline terminated lines in a datagram as allowed by its limits.
+type LineWriter struct {
+	host                string
+	maxDatagramSize     int
+	maxLinesPerDatagram int
+	conn                net.Conn
+}
+
+// NewLineWriter creates a LineWriter sending to the given host:port. Datagrams
+// hold at most maxLinesPerDatagram lines and maxDatagramSize bytes, a zero
+// value disables the respective limit.
+func NewLineWriter(host string, maxDatagramSize, maxLinesPerDatagram int) (*LineWriter, error) {
+	if maxDatagramSize < 0 {
+		return nil, fmt.Errorf("maxDatagramSize can't be negative: %d", maxDatagramSize)
+	}
+	if maxLinesPerDatagram < 0 {
+		return nil, fmt.Errorf("maxLinesPerDatagram can't be negative: %d", maxLinesPerDatagram)
+	}
+	return &LineWriter{
+		host:                host,
+		maxDatagramSize:     maxDatagramSize,
+		maxLinesPerDatagram: maxLinesPerDatagram,
+	}, nil
+}
+
+// Open opens the UDP connection. Write opens it on demand if needed.
+func (this *LineWriter) Open() error {
+	conn, err := net.Dial("udp", this.host)
+	if err != nil {
+		return err
+	}
+	this.conn = conn
+	glog.V(2).Infof("UDP connection to %s opened", this.host)
+	return nil
+}
+
+// Close closes the UDP connection, it is a no-op if the connection isn't open.
+func (this *LineWriter) Close() error {
+	if this.conn == nil {
+		return nil
+	}
+	err := this.conn.Close()
+	this.conn = nil
+	glog.V(2).Infof("UDP connection to %s closed", this.host)
+	return err
+}
+
+// Write sends the lines, flushing a datagram whenever the next line wouldn't
+// fit and once all lines are packed. A line longer than the maximum datagram
+// size is sent on its own. The last error is returned, the remaining lines are
+// still sent after a failed datagram.
+func (this *LineWriter) Write(lines []string) error {
+	if this.conn == nil {
+		if err := this.Open(); err != nil {
+			return fmt.Errorf("failed to open UDP connection to %s: %v", this.host, err)
+		}
+	}
+	var err error
+	flush := func(buf *bytes.Buffer) {
+		if buf.Len() == 0 {
+			return
+		}
+		if _, writeErr := this.conn.Write(buf.Bytes()); writeErr != nil {
+			err = writeErr
+		}
+		buf.Reset()
+	}
+
+	buf := &bytes.Buffer{}
+	numLines := 0
+	for _, line := range lines {
+		if this.maxDatagramSize > 0 && buf.Len() > 0 && buf.Len()+len(line)+1 > this.maxDatagramSize {
+			flush(buf)
+			numLines = 0
+		}
+		buf.WriteString(line)
+		buf.WriteByte('\n')
+		numLines++
+		if this.maxLinesPerDatagram > 0 && numLines >= this.maxLinesPerDatagram {
+			flush(buf)
+			numLines = 0
+		}
+	}
+	flush(buf)
+	return err
+}
@@ -0,0 +1,52 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package udp
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewLineWriterInvalidLimits(t *testing.T) {
+	_, err := NewLineWriter("localhost:8125", -1, 0)
+	assert.Error(t, err)
+	_, err = NewLineWriter("localhost:8125", 0, -1)
+	assert.Error(t, err)
+}
+
+func TestLineWriterPacksLines(t *testing.T) {
+	server, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.ParseIP("127.0.0.1")})
+	require.NoError(t, err)
+	defer server.Close()
+	server.SetReadDeadline(time.Now().Add(10 * time.Second))
+
+	writer, err := NewLineWriter(server.LocalAddr().String(), 10, 0)
+	require.NoError(t, err)
+	defer writer.Close()
+
+	// The line longer than the limit is sent on its own.
+	assert.NoError(t, writer.Write([]string{"aaa", "bbb", "cccccccccccc", "ddd"}))
+
+	buf := make([]byte, 1024)
+	for _, expected := range []string{"aaa\nbbb\n", "cccccccccccc\n", "ddd\n"} {
+		n, _, err := server.ReadFromUDP(buf)
+		require.NoError(t, err)
+		assert.Equal(t, expected, string(buf[:n]))
+	}
+}
@@ -65,7 +65,7 @@ func main() {
 
 	setMaxProcs()
 
-	glog.Infof(strings.Join(os.Args, " "))
+	glog.Infof("%s", strings.Join(flags.RedactArgs(os.Args, "source", "sink"), " "))
 	glog.Infof("Eventer version %v", version.HeapsterVersion)
 	if err := validateFlags(); err != nil {
 		glog.Fatal(err)
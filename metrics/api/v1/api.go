@@ -15,11 +15,16 @@
 package v1
 
 import (
+	"fmt"
+	"net/http"
+	"strings"
 	"time"
 
 	restful "github.com/emicklei/go-restful"
 	"github.com/golang/glog"
+	v1listers "k8s.io/client-go/listers/core/v1"
 
+	"k8s.io/heapster/common/flags"
 	"k8s.io/heapster/metrics/api/v1/types"
 	"k8s.io/heapster/metrics/core"
 	metricsink "k8s.io/heapster/metrics/sinks/metric"
@@ -27,11 +32,25 @@ import (
 
 type Api struct {
 	runningInKubernetes bool
-	metricSink          *metricsink.MetricSink
+	metricSink          metricsink.ModelStore
 	historicalSource    core.HistoricalSource
 	gkeMetrics          map[string]core.MetricDescriptor
 	gkeLabels           map[string]core.LabelDescriptor
 	disabled            bool
+	namespaceAuthorizer NamespaceAuthorizer
+	pipeline            *types.Pipeline
+	scrapeBackoffs      func() []types.ScrapeBackoff
+	sinkConnections     func() []types.SinkConnection
+	scrapeNow           func() (types.ScrapeSummary, error)
+	clearCache          func()
+	validateSink        func(uri flags.Uri) ([]string, error)
+	podSelector         *podSelectorCache
+	maxModelRequests    int
+}
+
+// NamespaceAuthorizer decides whether the caller of a request is allowed to read metrics of a namespace.
+type NamespaceAuthorizer interface {
+	AuthorizeNamespace(req *http.Request, namespace string) (bool, error)
 }
 
 var (
@@ -39,7 +58,7 @@ var (
 )
 
 // Create a new Api to serve from the specified cache.
-func NewApi(runningInKubernetes bool, metricSink *metricsink.MetricSink, historicalSource core.HistoricalSource, disableMetricExport bool) *Api {
+func NewApi(runningInKubernetes bool, metricSink metricsink.ModelStore, historicalSource core.HistoricalSource, disableMetricExport bool) *Api {
 	gkeMetrics := make(map[string]core.MetricDescriptor)
 	gkeLabels := make(map[string]core.LabelDescriptor)
 	for _, val := range core.StandardMetrics {
@@ -60,6 +79,9 @@ func NewApi(runningInKubernetes bool, metricSink *metricsink.MetricSink, histori
 	for _, val := range core.PodLabels() {
 		gkeLabels[val.Key] = val
 	}
+	for _, val := range core.NodeLabels() {
+		gkeLabels[val.Key] = val
+	}
 
 	return &Api{
 		runningInKubernetes: runningInKubernetes,
@@ -71,16 +93,70 @@ func NewApi(runningInKubernetes bool, metricSink *metricsink.MetricSink, histori
 	}
 }
 
+// SetNamespaceAuthorizer makes the model API check access to the namespace of each
+// namespace scoped request. All namespaces are served to any caller if not set.
+func (a *Api) SetNamespaceAuthorizer(authorizer NamespaceAuthorizer) {
+	a.namespaceAuthorizer = authorizer
+}
+
+// SetPodLister makes the model API serve metrics of the pods matching a label selector, resolved
+// with the given lister, at /api/v1/model/namespaces/{namespace-name}/pod-metrics/{metric-name}.
+func (a *Api) SetPodLister(podLister v1listers.PodLister) {
+	a.podSelector = newPodSelectorCache(podLister, podSelectorCacheTTL)
+}
+
+// SetMaxModelRequests limits the number of model API requests served concurrently, further
+// requests are rejected with 429 Too Many Requests. 0 for no limit.
+func (a *Api) SetMaxModelRequests(maxModelRequests int) {
+	a.maxModelRequests = maxModelRequests
+}
+
+// SetPipeline makes the API serve the given description of the processing pipeline at /api/v1/debug/pipeline.
+func (a *Api) SetPipeline(pipeline types.Pipeline) {
+	a.pipeline = &pipeline
+}
+
+// SetScrapeBackoffs makes the API serve the sources listed by the given function, which are skipped
+// after repeated scrape failures, at /api/v1/debug/scrape-backoffs.
+func (a *Api) SetScrapeBackoffs(scrapeBackoffs func() []types.ScrapeBackoff) {
+	a.scrapeBackoffs = scrapeBackoffs
+}
+
+// SetSinkConnections makes the API serve the connections of the sinks listed by the given function
+// at /api/v1/debug/sink-connections.
+func (a *Api) SetSinkConnections(sinkConnections func() []types.SinkConnection) {
+	a.sinkConnections = sinkConnections
+}
+
+// SetScrapeTrigger makes the API run an immediate scrape with the given function, which returns a
+// summary of the exported batch, on POST requests to /api/v1/debug/scrape.
+func (a *Api) SetScrapeTrigger(scrapeNow func() (types.ScrapeSummary, error)) {
+	a.scrapeNow = scrapeNow
+}
+
+// SetCacheClearer makes the API drop the data of the model with the given function on POST requests
+// to /api/v1/debug/clear-cache.
+func (a *Api) SetCacheClearer(clearCache func()) {
+	a.clearCache = clearCache
+}
+
+// SetSinkValidator makes the API validate sink URIs posted to /api/v1/sinks/validate with the given
+// function, which returns the options unknown to the sink.
+func (a *Api) SetSinkValidator(validateSink func(uri flags.Uri) ([]string, error)) {
+	a.validateSink = validateSink
+}
+
 // Register the mainApi on the specified endpoint.
 func (a *Api) Register(container *restful.Container) {
 	ws := new(restful.WebService)
 	ws.Path("/api/v1/metric-export").
 		Doc("Exports the latest point for all Heapster metrics").
-		Produces(restful.MIME_JSON)
+		Produces(restful.MIME_JSON, MIME_OPENMETRICS)
 	ws.Route(ws.GET("").
 		To(a.exportMetrics).
 		Doc("export the latest data point for all metrics").
 		Operation("exportMetrics").
+		Param(ws.QueryParameter("format", "openmetrics to get the points in the OpenMetrics text format instead of JSON").DataType("string")).
 		Writes([]*types.Timeseries{}))
 	container.Add(ws)
 	ws = new(restful.WebService)
@@ -101,6 +177,122 @@ func (a *Api) Register(container *restful.Container) {
 	if a.historicalSource != nil {
 		a.RegisterHistorical(container)
 	}
+
+	if a.pipeline != nil || a.scrapeBackoffs != nil || a.sinkConnections != nil || a.scrapeNow != nil || a.clearCache != nil {
+		ws = new(restful.WebService)
+		ws.Path("/api/v1/debug").
+			Doc("Debugging information about Heapster").
+			Produces(restful.MIME_JSON)
+		if a.pipeline != nil {
+			ws.Route(ws.GET("/pipeline").
+				To(a.getPipeline).
+				Doc("get the active data processors and sinks").
+				Operation("getPipeline").
+				Writes(types.Pipeline{}))
+		}
+		if a.scrapeBackoffs != nil {
+			ws.Route(ws.GET("/scrape-backoffs").
+				To(a.getScrapeBackoffs).
+				Doc("get the sources skipped after repeated scrape failures, with their next retry time").
+				Operation("getScrapeBackoffs").
+				Writes([]types.ScrapeBackoff{}))
+		}
+		if a.sinkConnections != nil {
+			ws.Route(ws.GET("/sink-connections").
+				To(a.getSinkConnections).
+				Doc("get the connections of the sinks keeping one to their backend").
+				Operation("getSinkConnections").
+				Writes([]types.SinkConnection{}))
+		}
+		if a.scrapeNow != nil {
+			ws.Route(ws.POST("/scrape").
+				To(a.scrape).
+				Doc("scrape, process and export the metrics right away, without waiting for the next resolution tick").
+				Operation("scrape").
+				Writes(types.ScrapeSummary{}))
+		}
+		if a.clearCache != nil {
+			ws.Route(ws.POST("/clear-cache").
+				To(a.clearModelCache).
+				Doc("drop the metrics stored for the model, which then only serves the batches exported afterwards").
+				Operation("clearCache"))
+		}
+		container.Add(ws)
+	}
+
+	if a.validateSink != nil {
+		ws = new(restful.WebService)
+		ws.Path("/api/v1/sinks").
+			Doc("Sinks of Heapster").
+			Consumes(restful.MIME_JSON).
+			Produces(restful.MIME_JSON)
+		ws.Route(ws.POST("/validate").
+			To(a.validateSinks).
+			Doc("validate a list of sink URIs, in the format of the --sink flag, without creating the sinks").
+			Operation("validateSinks").
+			Reads([]string{}).
+			Writes([]types.SinkValidation{}))
+		container.Add(ws)
+	}
+}
+
+func (a *Api) getPipeline(_ *restful.Request, response *restful.Response) {
+	response.WriteEntity(a.pipeline)
+}
+
+func (a *Api) getScrapeBackoffs(_ *restful.Request, response *restful.Response) {
+	response.WriteEntity(a.scrapeBackoffs())
+}
+
+func (a *Api) getSinkConnections(_ *restful.Request, response *restful.Response) {
+	response.WriteEntity(a.sinkConnections())
+}
+
+func (a *Api) scrape(_ *restful.Request, response *restful.Response) {
+	summary, err := a.scrapeNow()
+	if err != nil {
+		response.WriteError(http.StatusInternalServerError, err)
+		return
+	}
+	response.WriteEntity(summary)
+}
+
+func (a *Api) clearModelCache(_ *restful.Request, response *restful.Response) {
+	glog.Infof("Clearing the model data on request")
+	a.clearCache()
+	response.WriteHeader(http.StatusNoContent)
+}
+
+// validateSinks validates each posted sink URI. Environment variables are not expanded in them,
+// so that the results can't reveal the environment of Heapster.
+func (a *Api) validateSinks(request *restful.Request, response *restful.Response) {
+	var uris []string
+	if err := request.ReadEntity(&uris); err != nil {
+		response.WriteError(http.StatusBadRequest, fmt.Errorf("expected a list of sink uris: %v", err))
+		return
+	}
+	results := make([]types.SinkValidation, 0, len(uris))
+	for _, value := range uris {
+		var uri flags.Uri
+		if err := uri.SetLiteral(value); err != nil {
+			// The parsing error may contain the credentials of the uri.
+			results = append(results, types.SinkValidation{
+				Uri:   strings.SplitN(value, ":", 2)[0],
+				Error: "invalid uri",
+			})
+			continue
+		}
+		result := types.SinkValidation{Uri: uri.Redacted()}
+		unknownOptions, err := a.validateSink(uri)
+		if err != nil {
+			result.Error = err.Error()
+		} else {
+			result.Valid = true
+			result.UnknownOptions = unknownOptions
+		}
+		results = append(results, result)
+	}
+	response.WriteEntity(results)
 }
 
 func convertLabelDescriptor(ld core.LabelDescriptor) types.LabelDescriptor {
@@ -181,6 +373,11 @@ func (a *Api) exportMetricsSchema(_ *restful.Request, response *restful.Response
 			result.CommonLabels = append(result.CommonLabels, convertLabelDescriptor(label))
 		}
 	}
+	for _, label := range core.NodeLabels() {
+		if _, found := a.gkeLabels[label.Key]; found {
+			result.CommonLabels = append(result.CommonLabels, convertLabelDescriptor(label))
+		}
+	}
 	for _, label := range core.PodLabels() {
 		if _, found := a.gkeLabels[label.Key]; found {
 			result.PodLabels = append(result.PodLabels, convertLabelDescriptor(label))
@@ -189,7 +386,10 @@ func (a *Api) exportMetricsSchema(_ *restful.Request, response *restful.Response
 	response.WriteEntity(result)
 }
 
-func (a *Api) exportMetrics(_ *restful.Request, response *restful.Response) {
+func (a *Api) exportMetrics(request *restful.Request, response *restful.Response) {
+	if request.QueryParameter("format") == "openmetrics" {
+		response.SetRequestAccepts(MIME_OPENMETRICS)
+	}
 	response.PrettyPrint(false)
 	err := response.WriteEntity(a.getMetricsResponse())
 	if err != nil {
@@ -0,0 +1,70 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v1
+
+import (
+	"encoding/csv"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	restful "github.com/emicklei/go-restful"
+
+	"k8s.io/heapster/metrics/api/v1/types"
+)
+
+const MIME_CSV = "text/csv"
+
+func init() {
+	restful.RegisterEntityAccessor(MIME_CSV, entityCSVAccess{})
+}
+
+// entityCSVAccess writes metric timeseries as CSV, with a header row and one
+// timestamp,value row per point.
+type entityCSVAccess struct{}
+
+func (entityCSVAccess) Read(req *restful.Request, v interface{}) error {
+	return fmt.Errorf("%s request bodies are not supported", MIME_CSV)
+}
+
+func (entityCSVAccess) Write(resp *restful.Response, status int, v interface{}) error {
+	result, ok := v.(types.MetricResult)
+	if !ok {
+		// The routes producing CSV also write other entities, e.g. metric schemas, which
+		// must not end up as an empty successful response.
+		err := fmt.Errorf("%T can not be written as %s", v, MIME_CSV)
+		resp.WriteErrorString(http.StatusNotAcceptable, err.Error())
+		return err
+	}
+	resp.Header().Set("Content-Type", MIME_CSV)
+	resp.WriteHeader(status)
+
+	writer := csv.NewWriter(resp)
+	if err := writer.Write([]string{"timestamp", "value"}); err != nil {
+		return err
+	}
+	for _, point := range result.Metrics {
+		value := strconv.FormatUint(point.Value, 10)
+		if point.FloatValue != nil {
+			value = strconv.FormatFloat(*point.FloatValue, 'f', -1, 64)
+		}
+		if err := writer.Write([]string{point.Timestamp.UTC().Format(time.RFC3339), value}); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
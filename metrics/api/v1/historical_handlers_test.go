@@ -640,12 +640,15 @@ func TestFetchMetrics(t *testing.T) {
 
 	// doesn't particularly correspond to the query -- we're just using it to
 	// test conversion between internal types
+	// The fake source returns float values, which also have their floatValue.
+	floatVal := 33.0
 	expectedNormalVals := types.MetricResult{
 		LatestTimestamp: nowTime.Add(-10 * time.Second),
 		Metrics: []types.MetricPoint{
 			{
-				Timestamp: nowTime.Add(-10 * time.Second),
-				Value:     33,
+				Timestamp:  nowTime.Add(-10 * time.Second),
+				Value:      33,
+				FloatValue: &floatVal,
 			},
 		},
 	}
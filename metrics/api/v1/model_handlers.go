@@ -17,7 +17,10 @@ package v1
 import (
 	"errors"
 	"fmt"
+	"math"
 	"net/http"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
 
@@ -25,6 +28,7 @@ import (
 
 	"k8s.io/heapster/metrics/api/v1/types"
 	"k8s.io/heapster/metrics/core"
+	metricsink "k8s.io/heapster/metrics/sinks/metric"
 	"k8s.io/heapster/metrics/util/metrics"
 )
 
@@ -35,6 +39,9 @@ var nowFunc = time.Now
 // when manager.model has not been initialized.
 var errModelNotActivated = errors.New("the model is not activated")
 
+// allNodes is the node-list value selecting every node with metrics.
+const allNodes = "all"
+
 // Deprecated - clients should switch to full metric names ASAP.
 var deprecatedMetricNamesConversion = map[string]string{
 	"cpu-usage":      "cpu/usage_rate",
@@ -92,6 +99,10 @@ func addClusterMetricsRoutes(a clusterMetricsFetcher, ws *restful.WebService) {
 		Param(ws.QueryParameter("start", "Start time for requested metric").DataType("string")).
 		Param(ws.QueryParameter("end", "End time for requested metric").DataType("string")).
 		Param(ws.QueryParameter("labels", "A comma-separated list of key:values pairs to use to search for a labeled metric").DataType("string")).
+		Param(ws.QueryParameter("step", "Return at most one point per step, e.g. 5m").DataType("string")).
+		Param(ws.QueryParameter("unit", "Unit to convert the values to, e.g. MiB or cores").DataType("string")).
+		Param(ws.QueryParameter("includeCoverage", "Whether to return the share of the expected points that are stored").DataType("boolean")).
+		Produces(restful.MIME_JSON, MIME_CSV, MIME_PROTOBUF).
 		Writes(types.MetricResult{}))
 
 	// The /nodes/{node-name}/metrics endpoint returns a list of all nodes with some metrics.
@@ -118,6 +129,10 @@ func addClusterMetricsRoutes(a clusterMetricsFetcher, ws *restful.WebService) {
 		Param(ws.QueryParameter("start", "Start time for requested metric").DataType("string")).
 		Param(ws.QueryParameter("end", "End time for requested metric").DataType("string")).
 		Param(ws.QueryParameter("labels", "A comma-separated list of key:values pairs to use to search for a labeled metric").DataType("string")).
+		Param(ws.QueryParameter("step", "Return at most one point per step, e.g. 5m").DataType("string")).
+		Param(ws.QueryParameter("unit", "Unit to convert the values to, e.g. MiB or cores").DataType("string")).
+		Param(ws.QueryParameter("includeCoverage", "Whether to return the share of the expected points that are stored").DataType("boolean")).
+		Produces(restful.MIME_JSON, MIME_CSV, MIME_PROTOBUF).
 		Writes(types.MetricResult{}))
 
 	if a.isRunningInKubernetes() {
@@ -145,6 +160,10 @@ func addClusterMetricsRoutes(a clusterMetricsFetcher, ws *restful.WebService) {
 			Param(ws.QueryParameter("start", "Start time for requested metrics").DataType("string")).
 			Param(ws.QueryParameter("end", "End time for requested metric").DataType("string")).
 			Param(ws.QueryParameter("labels", "A comma-separated list of key:values pairs to use to search for a labeled metric").DataType("string")).
+			Param(ws.QueryParameter("step", "Return at most one point per step, e.g. 5m").DataType("string")).
+			Param(ws.QueryParameter("unit", "Unit to convert the values to, e.g. MiB or cores").DataType("string")).
+			Param(ws.QueryParameter("includeCoverage", "Whether to return the share of the expected points that are stored").DataType("boolean")).
+			Produces(restful.MIME_JSON, MIME_CSV, MIME_PROTOBUF).
 			Writes(types.MetricResult{}))
 
 		ws.Route(ws.GET("/namespaces/{namespace-name}/pods/").
@@ -173,6 +192,10 @@ func addClusterMetricsRoutes(a clusterMetricsFetcher, ws *restful.WebService) {
 			Param(ws.QueryParameter("start", "Start time for requested metrics").DataType("string")).
 			Param(ws.QueryParameter("end", "End time for requested metric").DataType("string")).
 			Param(ws.QueryParameter("labels", "A comma-separated list of key:values pairs to use to search for a labeled metric").DataType("string")).
+			Param(ws.QueryParameter("step", "Return at most one point per step, e.g. 5m").DataType("string")).
+			Param(ws.QueryParameter("unit", "Unit to convert the values to, e.g. MiB or cores").DataType("string")).
+			Param(ws.QueryParameter("includeCoverage", "Whether to return the share of the expected points that are stored").DataType("boolean")).
+			Produces(restful.MIME_JSON, MIME_CSV, MIME_PROTOBUF).
 			Writes(types.MetricResult{}))
 
 		// The /namespaces/{namespace-name}/pods/{pod-name}/containers endpoint
@@ -207,6 +230,10 @@ func addClusterMetricsRoutes(a clusterMetricsFetcher, ws *restful.WebService) {
 			Param(ws.QueryParameter("start", "Start time for requested metrics").DataType("string")).
 			Param(ws.QueryParameter("end", "End time for requested metric").DataType("string")).
 			Param(ws.QueryParameter("labels", "A comma-separated list of key:values pairs to use to search for a labeled metric").DataType("string")).
+			Param(ws.QueryParameter("step", "Return at most one point per step, e.g. 5m").DataType("string")).
+			Param(ws.QueryParameter("unit", "Unit to convert the values to, e.g. MiB or cores").DataType("string")).
+			Param(ws.QueryParameter("includeCoverage", "Whether to return the share of the expected points that are stored").DataType("boolean")).
+			Produces(restful.MIME_JSON, MIME_CSV, MIME_PROTOBUF).
 			Writes(types.MetricResult{}))
 	}
 
@@ -237,6 +264,10 @@ func addClusterMetricsRoutes(a clusterMetricsFetcher, ws *restful.WebService) {
 		Param(ws.QueryParameter("start", "Start time for requested metrics").DataType("string")).
 		Param(ws.QueryParameter("end", "End time for requested metric").DataType("string")).
 		Param(ws.QueryParameter("labels", "A comma-separated list of key:values pairs to use to search for a labeled metric").DataType("string")).
+		Param(ws.QueryParameter("step", "Return at most one point per step, e.g. 5m").DataType("string")).
+		Param(ws.QueryParameter("unit", "Unit to convert the values to, e.g. MiB or cores").DataType("string")).
+		Param(ws.QueryParameter("includeCoverage", "Whether to return the share of the expected points that are stored").DataType("boolean")).
+		Produces(restful.MIME_JSON, MIME_CSV, MIME_PROTOBUF).
 		Writes(types.MetricResult{}))
 
 	if a.isRunningInKubernetes() {
@@ -252,7 +283,11 @@ func addClusterMetricsRoutes(a clusterMetricsFetcher, ws *restful.WebService) {
 			Param(ws.QueryParameter("start", "Start time for requested metrics").DataType("string")).
 			Param(ws.QueryParameter("end", "End time for requested metric").DataType("string")).
 			Param(ws.QueryParameter("labels", "A comma-separated list of key:values pairs to use to search for a labeled metric").DataType("string")).
-			Writes(types.MetricResult{}))
+			Param(ws.QueryParameter("step", "Return at most one point per step, e.g. 5m").DataType("string")).
+			Param(ws.QueryParameter("unit", "Unit to convert the values to, e.g. MiB or cores").DataType("string")).
+			Param(ws.QueryParameter("includeCoverage", "Whether to return the share of the expected points that are stored").DataType("boolean")).
+			Produces(restful.MIME_JSON, MIME_PROTOBUF).
+			Writes(types.MetricResultList{}))
 	}
 }
 
@@ -268,10 +303,100 @@ func (a *Api) RegisterModel(container *restful.Container) {
 	ws.Path("/api/v1/model").
 		Doc("Root endpoint of the stats model").
 		Consumes("*/*").
-		Produces(restful.MIME_JSON)
+		Produces(restful.MIME_JSON).
+		Filter(metrics.InstrumentRouteFilter).
+		Filter(newRequestLimiter(a.maxModelRequests).filter)
+	if a.namespaceAuthorizer != nil {
+		ws.Filter(a.authorizeNamespace)
+	}
 
 	addClusterMetricsRoutes(a, ws)
 
+	// The /metric-schema/{metric-name} endpoint returns the descriptor of a metric.
+	ws.Route(ws.GET("/metric-schema/{metric-name:*}").
+		To(metrics.InstrumentRouteFunc("metricSchema", a.metricSchema)).
+		Doc("Get the descriptor of a metric").
+		Operation("metricSchema").
+		Param(ws.PathParameter("metric-name", "The name of the requested metric").DataType("string")).
+		Writes(types.MetricDescriptor{}))
+
+	if a.isRunningInKubernetes() {
+		// The /namespaces/metrics/{metric-name} endpoint exposes an aggregated metric
+		// for each namespace from the given list.
+		ws.Route(ws.GET("/namespaces/metrics/{metric-name:*}").
+			To(metrics.InstrumentRouteFunc("namespaceListMetric", a.namespaceListMetrics)).
+			Doc("Export an aggregated metric for all namespaces from the given list").
+			Operation("namespaceListMetric").
+			Param(ws.PathParameter("metric-name", "The name of the requested metric").DataType("string")).
+			Param(ws.QueryParameter("namespaces", "Comma separated list of namespace names to lookup").DataType("string")).
+			Param(ws.QueryParameter("start", "Start time for requested metrics").DataType("string")).
+			Param(ws.QueryParameter("end", "End time for requested metric").DataType("string")).
+			Param(ws.QueryParameter("labels", "A comma-separated list of key:values pairs to use to search for a labeled metric").DataType("string")).
+			Param(ws.QueryParameter("step", "Return at most one point per step, e.g. 5m").DataType("string")).
+			Param(ws.QueryParameter("unit", "Unit to convert the values to, e.g. MiB or cores").DataType("string")).
+			Param(ws.QueryParameter("includeCoverage", "Whether to return the share of the expected points that are stored").DataType("boolean")).
+			Produces(restful.MIME_JSON, MIME_PROTOBUF).
+			Writes(types.MetricResultList{}))
+
+		// The /namespaces/{namespace-name}/pod-usage endpoint returns the latest cpu and memory
+		// usage of every pod of the namespace, without their timeseries.
+		ws.Route(ws.GET("/namespaces/{namespace-name}/pod-usage").
+			To(metrics.InstrumentRouteFunc("namespacePodUsage", a.namespacePodUsage)).
+			Doc("Get the latest cpu and memory usage of the pods from the given namespace").
+			Operation("namespacePodUsage").
+			Param(ws.PathParameter("namespace-name", "The name of the namespace to lookup").DataType("string")).
+			Param(ws.QueryParameter("sort", "Sort the pods by name (default), or by decreasing cpu or memory usage").DataType("string")).
+			Param(ws.QueryParameter("limit", "Return at most this many pods").DataType("integer")).
+			Writes([]types.ExternalEntityListEntry{}))
+	}
+
+	// The /node-list/{node-list}/metrics/{metric-name} endpoint exposes metrics for a list of nodes,
+	// or for all of them.
+	ws.Route(ws.GET("/node-list/{node-list}/metrics/{metric-name:*}").
+		To(metrics.InstrumentRouteFunc("nodeListMetric", a.nodeListMetrics)).
+		Doc("Export a metric for all nodes from the given list").
+		Operation("nodeListMetric").
+		Param(ws.PathParameter("node-list", "Comma separated list of node names to lookup, or "+allNodes+" for every node").DataType("string")).
+		Param(ws.PathParameter("metric-name", "The name of the requested metric").DataType("string")).
+		Param(ws.QueryParameter("start", "Start time for requested metrics").DataType("string")).
+		Param(ws.QueryParameter("end", "End time for requested metric").DataType("string")).
+		Param(ws.QueryParameter("labels", "A comma-separated list of key:values pairs to use to search for a labeled metric").DataType("string")).
+		Param(ws.QueryParameter("step", "Return at most one point per step, e.g. 5m").DataType("string")).
+		Param(ws.QueryParameter("unit", "Unit to convert the values to, e.g. MiB or cores").DataType("string")).
+		Param(ws.QueryParameter("includeCoverage", "Whether to return the share of the expected points that are stored").DataType("boolean")).
+		Produces(restful.MIME_JSON, MIME_PROTOBUF).
+		Writes(types.MetricResultList{}))
+
+	if a.isRunningInKubernetes() && a.podSelector != nil {
+		// The /namespaces/{namespace-name}/pod-metrics/{metric-name} endpoint exposes
+		// metrics for the pods matching a label selector.
+		ws.Route(ws.GET("/namespaces/{namespace-name}/pod-metrics/{metric-name:*}").
+			To(metrics.InstrumentRouteFunc("podSelectorMetric", a.podSelectorMetrics)).
+			Doc("Export a metric for all pods matching the given label selector").
+			Operation("podSelectorMetric").
+			Param(ws.PathParameter("namespace-name", "The name of the namespace to lookup").DataType("string")).
+			Param(ws.PathParameter("metric-name", "The name of the requested metric").DataType("string")).
+			Param(ws.QueryParameter("selector", "Label selector of the pods, e.g. app=web. All pods of the namespace if empty").DataType("string")).
+			Param(ws.QueryParameter("start", "Start time for requested metrics").DataType("string")).
+			Param(ws.QueryParameter("end", "End time for requested metric").DataType("string")).
+			Param(ws.QueryParameter("labels", "A comma-separated list of key:values pairs to use to search for a labeled metric").DataType("string")).
+			Param(ws.QueryParameter("step", "Return at most one point per step, e.g. 5m").DataType("string")).
+			Param(ws.QueryParameter("unit", "Unit to convert the values to, e.g. MiB or cores").DataType("string")).
+			Param(ws.QueryParameter("includeCoverage", "Whether to return the share of the expected points that are stored").DataType("boolean")).
+			Produces(restful.MIME_JSON, MIME_PROTOBUF).
+			Writes(types.MetricResultList{}))
+	}
+
+	// The /latest endpoint returns the latest value of a metric for each of the posted entities,
+	// for clients polling the current usage of many entities, e.g. autoscalers.
+	ws.Route(ws.POST("/latest").
+		To(metrics.InstrumentRouteFunc("latestMetric", a.latestMetric)).
+		Doc("Get the latest value of a metric for each entity from the given list of metric set keys").
+		Operation("latestMetric").
+		Consumes(restful.MIME_JSON).
+		Reads(types.LatestMetricRequest{}).
+		Writes(types.LatestMetricList{}))
+
 	ws.Route(ws.GET("/debug/allkeys").
 		To(metrics.InstrumentRouteFunc("debugAllKeys", a.allKeys)).
 		Doc("Get keys of all metric sets available").
@@ -279,6 +404,50 @@ func (a *Api) RegisterModel(container *restful.Container) {
 	container.Add(ws)
 }
 
+// authorizeNamespace rejects namespace scoped requests for namespaces the caller has no access to.
+func (a *Api) authorizeNamespace(request *restful.Request, response *restful.Response, chain *restful.FilterChain) {
+	var namespaces []string
+	if namespace := request.PathParameter("namespace-name"); namespace != "" {
+		namespaces = append(namespaces, namespace)
+	}
+	if namespacesRaw := request.QueryParameter("namespaces"); namespacesRaw != "" {
+		namespaces = append(namespaces, strings.Split(namespacesRaw, ",")...)
+	}
+	for _, namespace := range namespaces {
+		allowed, err := a.namespaceAuthorizer.AuthorizeNamespace(request.Request, namespace)
+		if err != nil {
+			response.WriteError(http.StatusInternalServerError, err)
+			return
+		}
+		if !allowed {
+			response.WriteError(http.StatusForbidden, fmt.Errorf("access to namespace %s is forbidden", namespace))
+			return
+		}
+	}
+	chain.ProcessFilter(request, response)
+}
+
+// authorizedNamespaces returns which of the namespaces the caller has access to. All of them are
+// allowed if there is no namespace authorizer.
+func (a *Api) authorizedNamespaces(request *restful.Request, namespaces []string) (map[string]bool, error) {
+	allowed := make(map[string]bool, len(namespaces))
+	for _, namespace := range namespaces {
+		if _, found := allowed[namespace]; found {
+			continue
+		}
+		if a.namespaceAuthorizer == nil {
+			allowed[namespace] = true
+			continue
+		}
+		authorized, err := a.namespaceAuthorizer.AuthorizeNamespace(request.Request, namespace)
+		if err != nil {
+			return nil, err
+		}
+		allowed[namespace] = authorized
+	}
+	return allowed, nil
+}
+
 // availableMetrics returns a list of available cluster metric names.
 func (a *Api) availableClusterMetrics(request *restful.Request, response *restful.Response) {
 	a.processMetricNamesRequest(core.ClusterKey(), response)
@@ -323,15 +492,146 @@ func (a *Api) nodeList(request *restful.Request, response *restful.Response) {
 }
 
 func (a *Api) namespaceList(request *restful.Request, response *restful.Response) {
-	response.WriteEntity(a.metricSink.GetNamespaces())
+	namespaces := a.metricSink.GetNamespaces()
+	allowed, err := a.authorizedNamespaces(request, namespaces)
+	if err != nil {
+		response.WriteError(http.StatusInternalServerError, err)
+		return
+	}
+	result := make([]string, 0, len(namespaces))
+	for _, namespace := range namespaces {
+		if allowed[namespace] {
+			result = append(result, namespace)
+		}
+	}
+	response.WriteEntity(result)
 }
 
 func (a *Api) namespacePodList(request *restful.Request, response *restful.Response) {
 	response.WriteEntity(a.metricSink.GetPodsFromNamespace(request.PathParameter("namespace-name")))
 }
 
+// namespacePodUsage returns the latest cpu and memory usage of the pods of a namespace, taken
+// from the latest batch rather than from their timeseries.
+func (a *Api) namespacePodUsage(request *restful.Request, response *restful.Response) {
+	limit := -1
+	if limitParam := request.QueryParameter("limit"); limitParam != "" {
+		var err error
+		if limit, err = strconv.Atoi(limitParam); err != nil || limit < 0 {
+			response.WriteError(http.StatusBadRequest, fmt.Errorf("invalid limit %q, expected a non-negative integer", limitParam))
+			return
+		}
+	}
+	sortBy := request.QueryParameter("sort")
+	switch sortBy {
+	case "", "name", "cpu", "memory":
+	default:
+		response.WriteError(http.StatusBadRequest, fmt.Errorf("invalid sort %q, expected name, cpu or memory", sortBy))
+		return
+	}
+
+	ns := request.PathParameter("namespace-name")
+	result := []types.ExternalEntityListEntry{}
+	if batch := a.metricSink.GetLatestDataBatch(); batch != nil {
+		for _, ms := range batch.MetricSets {
+			if ms.Labels[core.LabelMetricSetType.Key] != core.MetricSetTypePod || ms.Labels[core.LabelNamespaceName.Key] != ns {
+				continue
+			}
+			result = append(result, types.ExternalEntityListEntry{
+				Name:     ms.Labels[core.LabelPodName.Key],
+				CPUUsage: uint64(ms.MetricValues[core.MetricCpuUsageRate.Name].IntValue),
+				MemUsage: uint64(ms.MetricValues[core.MetricMemoryUsage.Name].IntValue),
+			})
+		}
+	}
+	// Pods with the same usage are sorted by name.
+	sort.Slice(result, func(i, j int) bool {
+		switch {
+		case sortBy == "cpu" && result[i].CPUUsage != result[j].CPUUsage:
+			return result[i].CPUUsage > result[j].CPUUsage
+		case sortBy == "memory" && result[i].MemUsage != result[j].MemUsage:
+			return result[i].MemUsage > result[j].MemUsage
+		}
+		return result[i].Name < result[j].Name
+	})
+	if limit >= 0 && limit < len(result) {
+		result = result[:limit]
+	}
+	response.WriteEntity(result)
+}
+
+// latestMetric returns the latest value of a metric for the posted keys. Keys without a value are
+// left out of the result.
+func (a *Api) latestMetric(request *restful.Request, response *restful.Response) {
+	var latestRequest types.LatestMetricRequest
+	if err := request.ReadEntity(&latestRequest); err != nil {
+		response.WriteError(http.StatusBadRequest, fmt.Errorf("expected a metric name and a list of keys: %v", err))
+		return
+	}
+	if latestRequest.MetricName == "" {
+		response.WriteError(http.StatusBadRequest, fmt.Errorf("metricName is required"))
+		return
+	}
+	// The namespaces of the keys are not part of the path, so the authorizeNamespace filter
+	// doesn't see them.
+	namespaces := make(map[string]bool)
+	for _, key := range latestRequest.Keys {
+		historicalKey, err := core.HistoricalKeyFromMetricSetKey(key)
+		if err != nil {
+			response.WriteError(http.StatusBadRequest, err)
+			return
+		}
+		if historicalKey.NamespaceName != "" {
+			namespaces[historicalKey.NamespaceName] = true
+		}
+	}
+	if a.namespaceAuthorizer != nil {
+		for namespace := range namespaces {
+			allowed, err := a.namespaceAuthorizer.AuthorizeNamespace(request.Request, namespace)
+			if err != nil {
+				response.WriteError(http.StatusInternalServerError, err)
+				return
+			}
+			if !allowed {
+				response.WriteError(http.StatusForbidden, fmt.Errorf("access to namespace %s is forbidden", namespace))
+				return
+			}
+		}
+	}
+
+	latestBatch := a.metricSink.GetLatestDataBatch()
+	values := a.metricSink.GetLatestMetric(convertMetricName(latestRequest.MetricName), latestRequest.Keys)
+	result := types.LatestMetricList{Items: make([]types.LatestMetricValue, 0, len(values))}
+	for _, key := range latestRequest.Keys {
+		value, found := values[key]
+		if !found {
+			continue
+		}
+		// Keys listed more than once are returned once.
+		delete(values, key)
+		// Values are stale if they are older than the point the latest batch would have given.
+		var latestScrape time.Time
+		if latestBatch != nil {
+			latestScrape = core.PointTimestamp(latestBatch, latestBatch.MetricSets[key])
+		}
+		result.Items = append(result.Items, types.LatestMetricValue{
+			Key:         key,
+			MetricPoint: exportMetricPoint(value),
+			Stale:       value.Timestamp.Before(latestScrape),
+		})
+	}
+	response.WriteEntity(result)
+}
+
 func (a *Api) podContainerList(request *restful.Request, response *restful.Response) {
-	response.WriteEntity(a.metricSink.GetContainersForPodFromNamespace(request.PathParameter("namespace-name"), request.PathParameter("pod-name")))
+	// Containers are matched by key, so that all the listed containers can be queried by name.
+	namespace, pod := request.PathParameter("namespace-name"), request.PathParameter("pod-name")
+	keys := a.metricSink.ListEntities(core.MetricSetTypePodContainer, core.PodKey(namespace, pod))
+	containers := make([]string, 0, len(keys))
+	for _, key := range keys {
+		containers = append(containers, strings.TrimPrefix(key, core.PodContainerKey(namespace, pod, "")))
+	}
+	response.WriteEntity(containers)
 }
 
 func (a *Api) nodeSystemContainerList(request *restful.Request, response *restful.Response) {
@@ -339,7 +639,37 @@ func (a *Api) nodeSystemContainerList(request *restful.Request, response *restfu
 }
 
 func (a *Api) allKeys(request *restful.Request, response *restful.Response) {
-	response.WriteEntity(a.metricSink.GetMetricSetKeys())
+	keys := a.metricSink.GetMetricSetKeys()
+	if a.namespaceAuthorizer == nil {
+		response.WriteEntity(keys)
+		return
+	}
+	// The keys are only listed if they are not namespaced, or if the caller has access to
+	// their namespace.
+	keyNamespaces := make(map[string]string, len(keys))
+	namespaces := []string{}
+	for _, key := range keys {
+		historicalKey, err := core.HistoricalKeyFromMetricSetKey(key)
+		if err != nil {
+			continue
+		}
+		keyNamespaces[key] = historicalKey.NamespaceName
+		if historicalKey.NamespaceName != "" {
+			namespaces = append(namespaces, historicalKey.NamespaceName)
+		}
+	}
+	allowed, err := a.authorizedNamespaces(request, namespaces)
+	if err != nil {
+		response.WriteError(http.StatusInternalServerError, err)
+		return
+	}
+	result := make([]string, 0, len(keys))
+	for _, key := range keys {
+		if namespace, found := keyNamespaces[key]; found && (namespace == "" || allowed[namespace]) {
+			result = append(result, key)
+		}
+	}
+	response.WriteEntity(result)
 }
 
 // clusterMetrics returns a metric timeseries for a metric of the Cluster entity.
@@ -347,6 +677,42 @@ func (a *Api) clusterMetrics(request *restful.Request, response *restful.Respons
 	a.processMetricRequest(core.ClusterKey(), request, response)
 }
 
+// metricSchema returns the descriptor of the requested metric, so that clients know
+// how to render its values.
+func (a *Api) metricSchema(request *restful.Request, response *restful.Response) {
+	metricName := request.PathParameter("metric-name")
+	descriptor, found := a.findMetricDescriptor(convertMetricName(metricName))
+	if !found {
+		response.WriteError(http.StatusNotFound, fmt.Errorf("unknown metric %s", metricName))
+		return
+	}
+	response.WriteEntity(convertMetricDescriptor(descriptor))
+}
+
+// findMetricDescriptor returns the descriptor of the metric. Metrics without one of their own,
+// e.g. custom metrics or requests of extended resources, are looked up in the metric sink, and
+// described by the type of their stored values.
+func (a *Api) findMetricDescriptor(metricName string) (core.MetricDescriptor, bool) {
+	for _, metric := range core.AllMetrics {
+		if metric.Name == metricName {
+			return metric.MetricDescriptor, true
+		}
+	}
+	if a.metricSink == nil {
+		return core.MetricDescriptor{}, false
+	}
+	value, found := a.metricSink.FindMetricValue(metricName)
+	if !found {
+		return core.MetricDescriptor{}, false
+	}
+	return core.MetricDescriptor{
+		Name:      metricName,
+		Type:      value.MetricType,
+		ValueType: value.ValueType,
+		Units:     core.UnitsCount,
+	}, true
+}
+
 // nodeMetrics returns a metric timeseries for a metric of the Node entity.
 func (a *Api) nodeMetrics(request *restful.Request, response *restful.Response) {
 	a.processMetricRequest(core.NodeKey(request.PathParameter("node-name")),
@@ -367,19 +733,75 @@ func (a *Api) podMetrics(request *restful.Request, response *restful.Response) {
 		request, response)
 }
 
-func (a *Api) podListMetrics(request *restful.Request, response *restful.Response) {
-	start, end, err := getStartEndTime(request)
+// namespaceListMetrics returns a metric timeseries for each namespace from the namespaces
+// query parameter, in the same order. Namespaces without metrics get an empty result.
+func (a *Api) namespaceListMetrics(request *restful.Request, response *restful.Response) {
+	namespaces, err := getNamespaces(request)
 	if err != nil {
 		response.WriteError(http.StatusBadRequest, err)
 		return
 	}
+	keys := make([]string, 0, len(namespaces))
+	for _, namespace := range namespaces {
+		keys = append(keys, core.NamespaceKey(namespace))
+	}
+	a.processMetricListRequest(keys, nil, request, response)
+}
+
+func (a *Api) podListMetrics(request *restful.Request, response *restful.Response) {
 	ns := request.PathParameter("namespace-name")
 	keys := []string{}
-	metricName := request.PathParameter("metric-name")
-	convertedMetricName := convertMetricName(metricName)
 	for _, podName := range strings.Split(request.PathParameter("pod-list"), ",") {
 		keys = append(keys, core.PodKey(ns, podName))
 	}
+	a.processMetricListRequest(keys, nil, request, response)
+}
+
+// podSelectorMetrics returns a metric timeseries for each pod of the namespace matching the
+// selector query parameter. Results are named after the pods.
+func (a *Api) podSelectorMetrics(request *restful.Request, response *restful.Response) {
+	ns := request.PathParameter("namespace-name")
+	podNames, err := a.podSelector.getPodNames(ns, request.QueryParameter("selector"))
+	if err != nil {
+		response.WriteError(http.StatusBadRequest, err)
+		return
+	}
+	keys := make([]string, 0, len(podNames))
+	for _, podName := range podNames {
+		keys = append(keys, core.PodKey(ns, podName))
+	}
+	a.processMetricListRequest(keys, podNames, request, response)
+}
+
+// nodeListMetrics returns a metric timeseries for each node from the node-list path parameter,
+// or for every node if it is "all". Results are named after the nodes.
+func (a *Api) nodeListMetrics(request *restful.Request, response *restful.Response) {
+	nodeList := request.PathParameter("node-list")
+	var keys, names []string
+	if nodeList == allNodes {
+		keys = a.metricSink.ListEntities(core.MetricSetTypeNode, "")
+		for _, key := range keys {
+			names = append(names, strings.TrimPrefix(key, core.NodeKey("")))
+		}
+	} else {
+		for _, nodeName := range strings.Split(nodeList, ",") {
+			keys = append(keys, core.NodeKey(nodeName))
+			names = append(names, nodeName)
+		}
+	}
+	a.processMetricListRequest(keys, names, request, response)
+}
+
+// processMetricListRequest writes a metric timeseries for each of the keys, in the same order.
+// If names are given, each timeseries is named after the name at the same index.
+func (a *Api) processMetricListRequest(keys []string, names []string, request *restful.Request, response *restful.Response) {
+	start, end, err := getStartEndTime(request)
+	if err != nil {
+		response.WriteError(http.StatusBadRequest, err)
+		return
+	}
+	metricName := request.PathParameter("metric-name")
+	convertedMetricName := convertMetricName(metricName)
 
 	labels, err := getLabels(request)
 	if err != nil {
@@ -387,18 +809,49 @@ func (a *Api) podListMetrics(request *restful.Request, response *restful.Respons
 		return
 	}
 
+	step, err := getStep(request)
+	if err != nil {
+		response.WriteError(http.StatusBadRequest, err)
+		return
+	}
+
+	unitDivisor, err := a.getUnitDivisor(request, convertedMetricName)
+	if err != nil {
+		response.WriteError(http.StatusBadRequest, err)
+		return
+	}
+
+	includeCoverage, err := getIncludeCoverage(request)
+	if err != nil {
+		response.WriteError(http.StatusBadRequest, err)
+		return
+	}
+
 	var metrics map[string][]core.TimestampedMetricValue
 	if labels != nil {
-		metrics = a.metricSink.GetLabeledMetric(convertedMetricName, labels, keys, start, end)
+		metrics = a.metricSink.GetLabeledMetricWithStep(convertedMetricName, labels, keys, start, end, step)
 	} else {
-		metrics = a.metricSink.GetMetric(convertedMetricName, keys, start, end)
+		metrics = a.metricSink.GetMetricWithStep(convertedMetricName, keys, start, end, step)
+	}
+	var coverage map[string]metricsink.Coverage
+	if includeCoverage {
+		coverage = a.metricSink.GetCoverage(convertedMetricName, labels, keys, start, end)
 	}
 
 	result := types.MetricResultList{
 		Items: make([]types.MetricResult, 0, len(keys)),
 	}
-	for _, key := range keys {
-		result.Items = append(result.Items, exportTimestampedMetricValue(metrics[key]))
+	for i, key := range keys {
+		converted := exportTimestampedMetricValue(metrics[key])
+		convertUnits(&converted, unitDivisor)
+		converted.Terminated = a.metricSink.IsTerminated(key)
+		if includeCoverage {
+			converted.Coverage = exportCoverage(coverage[key])
+		}
+		if names != nil {
+			converted.Name = names[i]
+		}
+		result.Items = append(result.Items, converted)
 	}
 	response.PrettyPrint(false)
 	response.WriteEntity(result)
@@ -451,13 +904,37 @@ func (a *Api) processMetricRequest(key string, request *restful.Request, respons
 		return
 	}
 
+	step, err := getStep(request)
+	if err != nil {
+		response.WriteError(http.StatusBadRequest, err)
+		return
+	}
+
+	unitDivisor, err := a.getUnitDivisor(request, convertedMetricName)
+	if err != nil {
+		response.WriteError(http.StatusBadRequest, err)
+		return
+	}
+
+	includeCoverage, err := getIncludeCoverage(request)
+	if err != nil {
+		response.WriteError(http.StatusBadRequest, err)
+		return
+	}
+
 	var metrics map[string][]core.TimestampedMetricValue
 	if labels != nil {
-		metrics = a.metricSink.GetLabeledMetric(convertedMetricName, labels, []string{key}, start, end)
+		metrics = a.metricSink.GetLabeledMetricWithStep(convertedMetricName, labels, []string{key}, start, end, step)
 	} else {
-		metrics = a.metricSink.GetMetric(convertedMetricName, []string{key}, start, end)
+		metrics = a.metricSink.GetMetricWithStep(convertedMetricName, []string{key}, start, end, step)
 	}
 	converted := exportTimestampedMetricValue(metrics[key])
+	convertUnits(&converted, unitDivisor)
+	converted.Terminated = a.metricSink.IsTerminated(key)
+	if includeCoverage {
+		coverage := a.metricSink.GetCoverage(convertedMetricName, labels, []string{key}, start, end)
+		converted.Coverage = exportCoverage(coverage[key])
+	}
 	response.WriteEntity(converted)
 }
 
@@ -485,6 +962,46 @@ func getStartEndTime(request *restful.Request) (time.Time, time.Time, error) {
 	return start, end, nil
 }
 
+// getStep parses the optional step query parameter, zero means all points are returned.
+func getStep(request *restful.Request) (time.Duration, error) {
+	stepRaw := request.QueryParameter("step")
+	if stepRaw == "" {
+		return 0, nil
+	}
+	step, err := time.ParseDuration(stepRaw)
+	if err != nil {
+		return 0, fmt.Errorf("step argument cannot be parsed: %s", err)
+	}
+	if step < 0 {
+		return 0, fmt.Errorf("step argument should not be negative: %s", stepRaw)
+	}
+	return step, nil
+}
+
+// getIncludeCoverage parses the optional includeCoverage query parameter.
+func getIncludeCoverage(request *restful.Request) (bool, error) {
+	raw := request.QueryParameter("includeCoverage")
+	if raw == "" {
+		return false, nil
+	}
+	includeCoverage, err := strconv.ParseBool(raw)
+	if err != nil {
+		return false, fmt.Errorf("includeCoverage argument cannot be parsed: %s", err)
+	}
+	return includeCoverage, nil
+}
+
+func exportCoverage(coverage metricsink.Coverage) *types.MetricCoverage {
+	result := &types.MetricCoverage{
+		ExpectedPoints: coverage.Expected,
+		StoredPoints:   coverage.Stored,
+	}
+	if coverage.Expected > 0 {
+		result.Fraction = math.Min(1, float64(coverage.Stored)/float64(coverage.Expected))
+	}
+	return result
+}
+
 func exportTimestampedMetricValue(values []core.TimestampedMetricValue) types.MetricResult {
 	result := types.MetricResult{
 		Metrics: make([]types.MetricPoint, 0, len(values)),
@@ -493,20 +1010,40 @@ func exportTimestampedMetricValue(values []core.TimestampedMetricValue) types.Me
 		if result.LatestTimestamp.Before(value.Timestamp) {
 			result.LatestTimestamp = value.Timestamp
 		}
-		// TODO: clean up types in model api
-		var intValue int64
-		if value.ValueType == core.ValueInt64 {
-			intValue = value.IntValue
-		} else {
-			intValue = int64(value.FloatValue)
+		result.Metrics = append(result.Metrics, exportMetricPoint(value))
+	}
+	return result
+}
+
+func exportMetricPoint(value core.TimestampedMetricValue) types.MetricPoint {
+	// TODO: clean up types in model api
+	if value.ValueType == core.ValueFloat {
+		// value keeps the truncated number returned before floatValue existed, for older clients.
+		floatValue := value.FloatValue
+		return types.MetricPoint{
+			Timestamp:  value.Timestamp,
+			Value:      uint64(int64(floatValue)),
+			FloatValue: &floatValue,
 		}
+	}
+	return types.MetricPoint{
+		Timestamp: value.Timestamp,
+		Value:     uint64(value.IntValue),
+	}
+}
 
-		result.Metrics = append(result.Metrics, types.MetricPoint{
-			Timestamp: value.Timestamp,
-			Value:     uint64(intValue),
-		})
+func getNamespaces(request *restful.Request) ([]string, error) {
+	namespacesRaw := request.QueryParameter("namespaces")
+	if namespacesRaw == "" {
+		return nil, fmt.Errorf("namespaces argument is required")
 	}
-	return result
+	namespaces := strings.Split(namespacesRaw, ",")
+	for _, namespace := range namespaces {
+		if namespace == "" {
+			return nil, fmt.Errorf("invalid namespace list %q", namespacesRaw)
+		}
+	}
+	return namespaces, nil
 }
 
 func getLabels(request *restful.Request) (map[string]string, error) {
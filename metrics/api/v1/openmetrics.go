@@ -0,0 +1,195 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v1
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	restful "github.com/emicklei/go-restful"
+
+	"k8s.io/heapster/metrics/api/v1/types"
+	"k8s.io/heapster/metrics/core"
+)
+
+const MIME_OPENMETRICS = "application/openmetrics-text"
+
+// traceIdLabel is the label that is exported as the exemplar of a sample instead of a label.
+const traceIdLabel = "trace_id"
+
+func init() {
+	restful.RegisterEntityAccessor(MIME_OPENMETRICS, entityOpenMetricsAccess{})
+}
+
+// entityOpenMetricsAccess writes exported timeseries in the OpenMetrics text format, with the
+// metadata of every metric family taken from its descriptor.
+type entityOpenMetricsAccess struct{}
+
+func (entityOpenMetricsAccess) Read(req *restful.Request, v interface{}) error {
+	return fmt.Errorf("%s request bodies are not supported", MIME_OPENMETRICS)
+}
+
+func (entityOpenMetricsAccess) Write(resp *restful.Response, status int, v interface{}) error {
+	timeseries, ok := v.([]*types.Timeseries)
+	if !ok {
+		return fmt.Errorf("%T can not be written as %s", v, MIME_OPENMETRICS)
+	}
+	resp.Header().Set("Content-Type", MIME_OPENMETRICS+"; version=1.0.0; charset=utf-8")
+	resp.WriteHeader(status)
+	_, err := resp.Write(formatOpenMetrics(timeseries))
+	return err
+}
+
+// openMetricsFamily is the name and metadata of the family of a metric.
+type openMetricsFamily struct {
+	name    string
+	typ     string
+	unit    string
+	help    string
+	samples []string
+}
+
+var openMetricsNameReplacer = strings.NewReplacer("/", "_", "-", "_", ".", "_")
+
+func newOpenMetricsFamily(md core.MetricDescriptor) *openMetricsFamily {
+	family := &openMetricsFamily{
+		name: openMetricsNameReplacer.Replace(md.Name),
+		typ:  "unknown",
+		help: md.Description,
+	}
+	switch md.Units {
+	case core.UnitsBytes:
+		family.unit = "bytes"
+	case core.UnitsMilliseconds:
+		family.unit = "milliseconds"
+	case core.UnitsNanoseconds:
+		family.unit = "nanoseconds"
+	case core.UnitsMillicores:
+		family.unit = "millicores"
+	}
+	// The name of a family with a unit has to end with it.
+	if family.unit != "" && !strings.HasSuffix(family.name, "_"+family.unit) {
+		family.name += "_" + family.unit
+	}
+	switch md.Type {
+	case core.MetricCumulative:
+		family.typ = "counter"
+	case core.MetricGauge:
+		family.typ = "gauge"
+	}
+	return family
+}
+
+var openMetricsEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+
+func formatOpenMetricsLabels(labels map[string]string) string {
+	if len(labels) == 0 {
+		return ""
+	}
+	names := make([]string, 0, len(labels))
+	for name := range labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	pairs := make([]string, 0, len(names))
+	for _, name := range names {
+		pairs = append(pairs, fmt.Sprintf(`%s="%s"`, openMetricsNameReplacer.Replace(name), openMetricsEscaper.Replace(labels[name])))
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+func formatOpenMetricsValue(value interface{}) (string, bool) {
+	switch v := value.(type) {
+	case int64:
+		return strconv.FormatInt(v, 10), true
+	case float64:
+		return strconv.FormatFloat(v, 'g', -1, 64), true
+	}
+	return "", false
+}
+
+// formatOpenMetrics returns the points of the timeseries in the OpenMetrics text format. Metrics
+// without a descriptor are left out. A trace_id label of a counter sample is moved to its exemplar,
+// OpenMetrics only allowing exemplars on counters.
+func formatOpenMetrics(timeseries []*types.Timeseries) []byte {
+	descriptors := make(map[string]core.MetricDescriptor, len(core.AllMetrics))
+	for _, metric := range core.AllMetrics {
+		descriptors[metric.Name] = metric.MetricDescriptor
+	}
+
+	families := make(map[string]*openMetricsFamily)
+	for _, ts := range timeseries {
+		for metricName, points := range ts.Metrics {
+			md, found := descriptors[metricName]
+			if !found {
+				continue
+			}
+			family, found := families[metricName]
+			if !found {
+				family = newOpenMetricsFamily(md)
+				families[metricName] = family
+			}
+			for _, point := range points {
+				value, ok := formatOpenMetricsValue(point.Value)
+				if !ok {
+					continue
+				}
+				labels := make(map[string]string, len(ts.Labels)+len(point.Labels))
+				for k, v := range ts.Labels {
+					labels[k] = v
+				}
+				for k, v := range point.Labels {
+					labels[k] = v
+				}
+				timestamp := strconv.FormatFloat(float64(point.End.UnixNano())/1e9, 'f', -1, 64)
+				sampleName := family.name
+				exemplar := ""
+				if family.typ == "counter" {
+					sampleName += "_total"
+					if traceId, found := labels[traceIdLabel]; found {
+						delete(labels, traceIdLabel)
+						exemplar = fmt.Sprintf(" # {%s=\"%s\"} %s %s", traceIdLabel, openMetricsEscaper.Replace(traceId), value, timestamp)
+					}
+				}
+				family.samples = append(family.samples,
+					fmt.Sprintf("%s%s %s %s%s\n", sampleName, formatOpenMetricsLabels(labels), value, timestamp, exemplar))
+			}
+		}
+	}
+
+	names := make([]string, 0, len(families))
+	for name := range families {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var buffer bytes.Buffer
+	for _, name := range names {
+		family := families[name]
+		buffer.WriteString(fmt.Sprintf("# TYPE %s %s\n", family.name, family.typ))
+		if family.unit != "" {
+			buffer.WriteString(fmt.Sprintf("# UNIT %s %s\n", family.name, family.unit))
+		}
+		buffer.WriteString(fmt.Sprintf("# HELP %s %s\n", family.name, openMetricsEscaper.Replace(family.help)))
+		sort.Strings(family.samples)
+		for _, sample := range family.samples {
+			buffer.WriteString(sample)
+		}
+	}
+	buffer.WriteString("# EOF\n")
+	return buffer.Bytes()
+}
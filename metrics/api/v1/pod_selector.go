@@ -0,0 +1,84 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v1
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/labels"
+	v1listers "k8s.io/client-go/listers/core/v1"
+)
+
+// For how long the pods matching a label selector are cached.
+const podSelectorCacheTTL = 30 * time.Second
+
+// podSelectorCache resolves label selectors to the names of the matching pods, caching the
+// result briefly, so that dashboards refreshing many metrics don't repeat the same lookups.
+type podSelectorCache struct {
+	lock      sync.Mutex
+	podLister v1listers.PodLister
+	ttl       time.Duration
+	entries   map[string]podSelectorCacheEntry
+}
+
+type podSelectorCacheEntry struct {
+	podNames []string
+	expires  time.Time
+}
+
+// getPodNames returns the sorted names of the pods of the namespace matching the selector.
+func (this *podSelectorCache) getPodNames(namespace, rawSelector string) ([]string, error) {
+	selector, err := labels.Parse(rawSelector)
+	if err != nil {
+		return nil, fmt.Errorf("selector argument cannot be parsed: %s", err)
+	}
+
+	cacheKey := namespace + "/" + selector.String()
+	now := nowFunc()
+	this.lock.Lock()
+	defer this.lock.Unlock()
+	if entry, found := this.entries[cacheKey]; found && now.Before(entry.expires) {
+		return entry.podNames, nil
+	}
+
+	pods, err := this.podLister.Pods(namespace).List(selector)
+	if err != nil {
+		return nil, fmt.Errorf("unable to list pods: %s", err)
+	}
+	podNames := make([]string, 0, len(pods))
+	for _, pod := range pods {
+		podNames = append(podNames, pod.Name)
+	}
+	sort.Strings(podNames)
+
+	for key, entry := range this.entries {
+		if !now.Before(entry.expires) {
+			delete(this.entries, key)
+		}
+	}
+	this.entries[cacheKey] = podSelectorCacheEntry{podNames: podNames, expires: now.Add(this.ttl)}
+	return podNames, nil
+}
+
+func newPodSelectorCache(podLister v1listers.PodLister, ttl time.Duration) *podSelectorCache {
+	return &podSelectorCache{
+		podLister: podLister,
+		ttl:       ttl,
+		entries:   make(map[string]podSelectorCacheEntry),
+	}
+}
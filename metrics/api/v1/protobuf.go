@@ -0,0 +1,136 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v1
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	restful "github.com/emicklei/go-restful"
+	"github.com/golang/protobuf/proto"
+
+	"k8s.io/heapster/metrics/api/v1/types"
+)
+
+const (
+	MIME_PROTOBUF = "application/x-protobuf"
+
+	// protobufVersion is the version of types/model_types.proto, sent in the Content-Type
+	// so that clients can detect incompatible schema changes.
+	protobufVersion = 1
+)
+
+// Wire types of the protobuf encoding.
+const (
+	wireVarint  = 0
+	wireFixed64 = 1
+	wireBytes   = 2
+)
+
+func init() {
+	restful.RegisterEntityAccessor(MIME_PROTOBUF, entityProtobufAccess{})
+}
+
+// entityProtobufAccess writes metric timeseries with the messages of types/model_types.proto.
+type entityProtobufAccess struct{}
+
+func (entityProtobufAccess) Read(req *restful.Request, v interface{}) error {
+	return fmt.Errorf("%s request bodies are not supported", MIME_PROTOBUF)
+}
+
+func (entityProtobufAccess) Write(resp *restful.Response, status int, v interface{}) error {
+	var message string
+	var data []byte
+	switch result := v.(type) {
+	case types.MetricResult:
+		message, data = "MetricResult", encodeMetricResult(&result)
+	case types.MetricResultList:
+		message, data = "MetricResultList", encodeMetricResultList(&result)
+	default:
+		return fmt.Errorf("%T can not be written as %s", v, MIME_PROTOBUF)
+	}
+	resp.Header().Set("Content-Type", fmt.Sprintf("%s; proto=heapster.api.v1.%s; version=%d", MIME_PROTOBUF, message, protobufVersion))
+	resp.WriteHeader(status)
+	_, err := resp.Write(data)
+	return err
+}
+
+func encodeMetricResultList(list *types.MetricResultList) []byte {
+	buf := proto.NewBuffer(nil)
+	for i := range list.Items {
+		encodeTag(buf, 1, wireBytes)
+		buf.EncodeRawBytes(encodeMetricResult(&list.Items[i]))
+	}
+	return buf.Bytes()
+}
+
+func encodeMetricResult(result *types.MetricResult) []byte {
+	buf := proto.NewBuffer(nil)
+	for i := range result.Metrics {
+		encodeTag(buf, 1, wireBytes)
+		buf.EncodeRawBytes(encodeMetricPoint(&result.Metrics[i]))
+	}
+	if !result.LatestTimestamp.IsZero() {
+		encodeTag(buf, 2, wireVarint)
+		buf.EncodeVarint(uint64(unixMillis(result.LatestTimestamp)))
+	}
+	if result.Terminated {
+		encodeTag(buf, 3, wireVarint)
+		buf.EncodeVarint(1)
+	}
+	if result.Name != "" {
+		encodeTag(buf, 4, wireBytes)
+		buf.EncodeStringBytes(result.Name)
+	}
+	if result.Coverage != nil {
+		encodeTag(buf, 5, wireBytes)
+		buf.EncodeRawBytes(encodeMetricCoverage(result.Coverage))
+	}
+	return buf.Bytes()
+}
+
+func encodeMetricCoverage(coverage *types.MetricCoverage) []byte {
+	buf := proto.NewBuffer(nil)
+	encodeTag(buf, 1, wireVarint)
+	buf.EncodeVarint(uint64(coverage.ExpectedPoints))
+	encodeTag(buf, 2, wireVarint)
+	buf.EncodeVarint(uint64(coverage.StoredPoints))
+	encodeTag(buf, 3, wireFixed64)
+	buf.EncodeFixed64(math.Float64bits(coverage.Fraction))
+	return buf.Bytes()
+}
+
+func encodeMetricPoint(point *types.MetricPoint) []byte {
+	buf := proto.NewBuffer(nil)
+	encodeTag(buf, 1, wireVarint)
+	buf.EncodeVarint(uint64(unixMillis(point.Timestamp)))
+	if point.FloatValue != nil {
+		encodeTag(buf, 3, wireFixed64)
+		buf.EncodeFixed64(math.Float64bits(*point.FloatValue))
+	} else {
+		encodeTag(buf, 2, wireVarint)
+		buf.EncodeVarint(point.Value)
+	}
+	return buf.Bytes()
+}
+
+func encodeTag(buf *proto.Buffer, field int, wireType int) {
+	buf.EncodeVarint(uint64(field<<3 | wireType))
+}
+
+func unixMillis(t time.Time) int64 {
+	return t.UnixNano() / int64(time.Millisecond)
+}
@@ -0,0 +1,65 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v1
+
+import (
+	"fmt"
+	"net/http"
+
+	restful "github.com/emicklei/go-restful"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var modelRequestsInFlight = prometheus.NewGauge(
+	prometheus.GaugeOpts{
+		Namespace: "heapster",
+		Subsystem: "api",
+		Name:      "model_requests_in_flight",
+		Help:      "Number of model API requests being served.",
+	},
+)
+
+func init() {
+	prometheus.MustRegister(modelRequestsInFlight)
+}
+
+// requestLimiter is a go-restful filter that rejects requests with 429 Too Many Requests while
+// the maximum number of them are already being served. A maximum of 0 serves any number.
+type requestLimiter struct {
+	slots chan struct{}
+}
+
+func newRequestLimiter(maxRequests int) *requestLimiter {
+	limiter := &requestLimiter{}
+	if maxRequests > 0 {
+		limiter.slots = make(chan struct{}, maxRequests)
+	}
+	return limiter
+}
+
+func (this *requestLimiter) filter(request *restful.Request, response *restful.Response, chain *restful.FilterChain) {
+	if this.slots != nil {
+		select {
+		case this.slots <- struct{}{}:
+			defer func() { <-this.slots }()
+		default:
+			response.WriteError(http.StatusTooManyRequests, fmt.Errorf("too many concurrent model requests, at most %d are served", cap(this.slots)))
+			return
+		}
+	}
+	modelRequestsInFlight.Inc()
+	defer modelRequestsInFlight.Dec()
+	chain.ProcessFilter(request, response)
+}
@@ -30,12 +30,50 @@ type MetricPoint struct {
 type MetricResult struct {
 	Metrics         []MetricPoint `json:"metrics"`
 	LatestTimestamp time.Time     `json:"latestTimestamp"`
+	// Set if the entity no longer exists, but its metrics are still retained.
+	Terminated bool `json:"terminated,omitempty"`
+	// Name of the entity. Only set by the node list and pod selector endpoints, the results of
+	// the other endpoints have no name and are in the order of the requested entities.
+	Name string `json:"name,omitempty"`
+	// Set with includeCoverage=true.
+	Coverage *MetricCoverage `json:"coverage,omitempty"`
+}
+
+// MetricCoverage tells how many of the points expected in the requested time range are stored,
+// so that missed scrapes can be told apart from a range before the data kept by Heapster.
+type MetricCoverage struct {
+	// Points expected from the metric resolution, in the part of the range covered by the store.
+	ExpectedPoints int `json:"expectedPoints"`
+	// Points stored, before any downsampling to the requested step.
+	StoredPoints int `json:"storedPoints"`
+	// StoredPoints divided by ExpectedPoints, at most 1, and 0 when no point is expected.
+	Fraction float64 `json:"fraction"`
 }
 
 type MetricResultList struct {
 	Items []MetricResult `json:"items"`
 }
 
+// LatestMetricRequest asks for the latest value of a metric for a set of entities.
+type LatestMetricRequest struct {
+	MetricName string `json:"metricName"`
+	// Metric set keys of the entities, as listed by /api/v1/model/debug/allkeys.
+	Keys []string `json:"keys"`
+}
+
+// LatestMetricValue is the latest value of a metric for an entity.
+type LatestMetricValue struct {
+	Key string `json:"key"`
+	MetricPoint
+	// Stale is set if the value is older than the latest scrape, e.g. because the entity was
+	// missing from it.
+	Stale bool `json:"stale"`
+}
+
+type LatestMetricList struct {
+	Items []LatestMetricValue `json:"items"`
+}
+
 type Stats struct {
 	Average     uint64 `json:"average"`
 	NinetyFifth uint64 `json:"percentile"`
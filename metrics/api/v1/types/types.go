@@ -81,3 +81,67 @@ type LabelDescriptor struct {
 	// Description of the label.
 	Description string `json:"description,omitempty"`
 }
+
+// Pipeline describes how Heapster processes and exports the scraped metrics.
+type Pipeline struct {
+	// Names of the data processors, in the order in which they process each batch.
+	Processors []string `json:"processors"`
+	// Names of the sinks to which the processed batches are exported.
+	Sinks []string `json:"sinks"`
+	// Sink URIs set in the flags, with credentials redacted.
+	SinkUris []string `json:"sink_uris"`
+}
+
+// ScrapeBackoff describes a source that is not scraped every interval after repeatedly failing.
+type ScrapeBackoff struct {
+	// Name of the source, e.g. kubelet:10.0.0.1:10255.
+	Source string `json:"source"`
+	// Number of consecutive failed scrapes.
+	ConsecutiveFailures int `json:"consecutive_failures"`
+	// End of the first scrape interval in which the source is scraped again.
+	NextRetry time.Time `json:"next_retry"`
+}
+
+// SinkConnection describes the connection of a sink to its backend.
+type SinkConnection struct {
+	// Identifies the sink instance, e.g. graphite:localhost:2003.
+	SinkId    string `json:"sink_id"`
+	Connected bool   `json:"connected"`
+	// Number of failed connection attempts since the last successful one.
+	ConsecutiveFailures int `json:"consecutive_failures"`
+	// The last connection or write error, empty when connected.
+	LastError   string    `json:"last_error,omitempty"`
+	LastAttempt time.Time `json:"last_attempt"`
+}
+
+// ScrapeSummary describes the batch of a scrape triggered through /api/v1/debug/scrape.
+type ScrapeSummary struct {
+	// The scraped period.
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+	// Number of metric sets of each type after processing, e.g. the scraped nodes.
+	MetricSets map[string]int `json:"metric_sets"`
+	// Number of metric values, including labeled metrics, after processing.
+	Metrics int `json:"metrics"`
+	// Whether each sink took the batch.
+	Sinks []SinkExport `json:"sinks"`
+}
+
+// SinkExport tells whether a sink took an exported batch.
+type SinkExport struct {
+	// Name of the sink.
+	Sink string `json:"sink"`
+	// False if the sink was still exporting the previous batch when the export timeout passed.
+	Accepted bool `json:"accepted"`
+}
+
+// SinkValidation is the result of validating a sink URI without creating the sink.
+type SinkValidation struct {
+	// The validated sink URI, with credentials redacted.
+	Uri   string `json:"uri"`
+	Valid bool   `json:"valid"`
+	// Why the URI is not valid.
+	Error string `json:"error,omitempty"`
+	// Options of the URI that the sink doesn't understand, they are ignored.
+	UnknownOptions []string `json:"unknown_options,omitempty"`
+}
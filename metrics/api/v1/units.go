@@ -0,0 +1,101 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v1
+
+import (
+	"fmt"
+
+	restful "github.com/emicklei/go-restful"
+
+	"k8s.io/heapster/metrics/api/v1/types"
+	"k8s.io/heapster/metrics/core"
+)
+
+// Units that values can be converted to, by base unit of the metric.
+// Values are divided by the given factor, so only units not smaller than the base one are listed.
+var unitConversions = map[core.UnitsType]map[string]uint64{
+	core.UnitsBytes: {
+		"bytes": 1,
+		"KB":    1000,
+		"MB":    1000 * 1000,
+		"GB":    1000 * 1000 * 1000,
+		"KiB":   1 << 10,
+		"MiB":   1 << 20,
+		"GiB":   1 << 30,
+	},
+	core.UnitsNanoseconds: {
+		"ns": 1,
+		"us": 1000,
+		"ms": 1000 * 1000,
+		"s":  1000 * 1000 * 1000,
+	},
+	core.UnitsMilliseconds: {
+		"ms": 1,
+		"s":  1000,
+	},
+	core.UnitsMillicores: {
+		"millicores": 1,
+		"cores":      1000,
+	},
+}
+
+// The CPU metrics described as counts, which are counts of millicores.
+var millicoreCountMetrics = map[string]bool{
+	core.MetricCpuRequest.Name:         true,
+	core.MetricCpuLimit.Name:           true,
+	core.MetricCpuUsageRate.Name:       true,
+	core.MetricNodeCpuCapacity.Name:    true,
+	core.MetricNodeCpuAllocatable.Name: true,
+}
+
+// getUnitDivisor returns by how much values of the metric have to be divided to get the
+// unit from the unit query parameter, or 1 if the parameter is not set. The metric can be
+// labeled, its descriptor is resolved like the one served by the schema endpoint.
+func (a *Api) getUnitDivisor(request *restful.Request, metricName string) (uint64, error) {
+	unit := request.QueryParameter("unit")
+	if unit == "" {
+		return 1, nil
+	}
+	descriptor, found := a.findMetricDescriptor(metricName)
+	if !found {
+		return 0, fmt.Errorf("unit conversion is not supported for metric %s", metricName)
+	}
+	units := descriptor.Units
+	if millicoreCountMetrics[descriptor.Name] {
+		units = core.UnitsMillicores
+	}
+	if divisor, found := unitConversions[units][unit]; found {
+		return divisor, nil
+	}
+	return 0, fmt.Errorf("unit %s is not compatible with metric %s", unit, metricName)
+}
+
+// convertUnits divides all values of the result by the divisor. The converted values are
+// returned as floats, so e.g. 500 millicores are 0.5 cores.
+func convertUnits(result *types.MetricResult, divisor uint64) {
+	if divisor == 1 {
+		return
+	}
+	for i := range result.Metrics {
+		point := &result.Metrics[i]
+		value := float64(point.Value)
+		if point.FloatValue != nil {
+			value = *point.FloatValue
+		}
+		value /= float64(divisor)
+		point.Value = 0
+		point.FloatValue = &value
+	}
+}
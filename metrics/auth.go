@@ -21,9 +21,11 @@ import (
 	"net/http"
 	"strings"
 
+	authorizationv1 "k8s.io/api/authorization/v1"
 	"k8s.io/apiserver/pkg/authentication/authenticator"
 	x509request "k8s.io/apiserver/pkg/authentication/request/x509"
 	"k8s.io/apiserver/pkg/authentication/user"
+	authorizationclient "k8s.io/client-go/kubernetes/typed/authorization/v1"
 	"k8s.io/heapster/metrics/options"
 )
 
@@ -114,3 +116,50 @@ type userAuthorizer struct {
 func (a *userAuthorizer) AuthorizeRequest(req *http.Request, user user.Info) (bool, error) {
 	return a.allowedUsers[user.GetName()], nil
 }
+
+// subjectAccessReviewAuthorizer allows access to a namespace to users that are allowed
+// to get pods in it, as decided by the Kubernetes authorizer.
+type subjectAccessReviewAuthorizer struct {
+	authn  authenticator.Request
+	client authorizationclient.SubjectAccessReviewInterface
+}
+
+func newSubjectAccessReviewAuthorizer(clientCAFile string, client authorizationclient.SubjectAccessReviewInterface) (*subjectAccessReviewAuthorizer, error) {
+	authn, err := newAuthenticatorFromClientCAFile(clientCAFile)
+	if err != nil {
+		return nil, err
+	}
+	return &subjectAccessReviewAuthorizer{
+		authn:  authn,
+		client: client,
+	}, nil
+}
+
+func (a *subjectAccessReviewAuthorizer) AuthorizeNamespace(req *http.Request, namespace string) (bool, error) {
+	user, ok, err := a.authn.AuthenticateRequest(req)
+	if err != nil || !ok {
+		return false, err
+	}
+
+	extra := make(map[string]authorizationv1.ExtraValue)
+	for key, value := range user.GetExtra() {
+		extra[key] = authorizationv1.ExtraValue(value)
+	}
+	review, err := a.client.Create(&authorizationv1.SubjectAccessReview{
+		Spec: authorizationv1.SubjectAccessReviewSpec{
+			ResourceAttributes: &authorizationv1.ResourceAttributes{
+				Namespace: namespace,
+				Verb:      "get",
+				Resource:  "pods",
+			},
+			User:   user.GetName(),
+			Groups: user.GetGroups(),
+			UID:    user.GetUID(),
+			Extra:  extra,
+		},
+	})
+	if err != nil {
+		return false, fmt.Errorf("failed to check access of %s to namespace %s: %v", user.GetName(), namespace, err)
+	}
+	return review.Status.Allowed, nil
+}
@@ -41,6 +41,12 @@ func init() {
 func installMetricsAPIs(s *options.HeapsterRunOptions, g *genericapiserver.GenericAPIServer,
 	metricSink *metricsink.MetricSink, nodeLister v1listers.NodeLister, podLister v1listers.PodLister) {
 
+	// Node and pod metrics are served from the metric sink, there is nothing to serve without it.
+	if metricSink == nil {
+		glog.Warningf("Metric sink is disabled, not installing %s API group", metrics.GroupName)
+		return
+	}
+
 	apiGroupInfo := genericapiserver.NewDefaultAPIGroupInfo(metrics.GroupName, legacyscheme.Registry, legacyscheme.Scheme, legacyscheme.ParameterCodec, legacyscheme.Codecs)
 
 	nodemetricsStorage := nodemetricsstorage.NewStorage(metrics.Resource("nodemetrics"), metricSink, nodeLister)
@@ -0,0 +1,271 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package core
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/golang/glog"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Maximum number of colliding metric set keys listed in the warning logged by MergeDataBatches.
+const maxLoggedCollisions = 5
+
+var (
+	// Number of metrics present in more than one merged batch for the same metric set.
+	mergeConflicts = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "heapster",
+			Subsystem: "merge",
+			Name:      "conflicts_total",
+			Help:      "Number of metrics present in more than one merged batch for the same metric set, by metric name.",
+		},
+		[]string{"metric"},
+	)
+)
+
+func init() {
+	prometheus.MustRegister(mergeConflicts)
+}
+
+// MergeConflictPolicy decides which value is kept when a metric, or a labeled metric with the
+// same labels, is present in more than one merged batch for the same metric set.
+type MergeConflictPolicy string
+
+const (
+	// Fail the merge.
+	MergeConflictError MergeConflictPolicy = "error"
+	// Keep the value from the first batch written.
+	MergeConflictPreferFirst MergeConflictPolicy = "prefer-first"
+	// Keep the value from the last batch written.
+	MergeConflictPreferLast MergeConflictPolicy = "prefer-last"
+	// Add the values up. Values of different types can't be summed and fail the merge.
+	MergeConflictSum MergeConflictPolicy = "sum"
+)
+
+// ParseMergeConflictPolicy returns the policy with the given name.
+func ParseMergeConflictPolicy(name string) (MergeConflictPolicy, error) {
+	switch policy := MergeConflictPolicy(name); policy {
+	case MergeConflictError, MergeConflictPreferFirst, MergeConflictPreferLast, MergeConflictSum:
+		return policy, nil
+	}
+	return "", fmt.Errorf("unknown merge conflict policy %q, expected one of %s, %s, %s or %s", name,
+		MergeConflictError, MergeConflictPreferFirst, MergeConflictPreferLast, MergeConflictSum)
+}
+
+// MergeOptions configure how MergeDataBatchesWithOptions resolves conflicting metrics.
+type MergeOptions struct {
+	// Policy for the metrics without an override, prefer-last if empty.
+	Policy MergeConflictPolicy
+	// Policies overriding Policy, by metric name.
+	MetricPolicies map[string]MergeConflictPolicy
+}
+
+func (this MergeOptions) policy(metricName string) MergeConflictPolicy {
+	if policy, found := this.MetricPolicies[metricName]; found {
+		return policy
+	}
+	if this.Policy == "" {
+		return MergeConflictPreferLast
+	}
+	return this.Policy
+}
+
+// ParseMergeOptions returns the options with the given default policy, prefer-last if empty, and
+// the given metric=policy overrides, e.g. "cpu/usage=sum".
+func ParseMergeOptions(policy string, metricPolicies []string) (MergeOptions, error) {
+	options := MergeOptions{Policy: MergeConflictPreferLast}
+	if policy != "" {
+		parsed, err := ParseMergeConflictPolicy(policy)
+		if err != nil {
+			return MergeOptions{}, err
+		}
+		options.Policy = parsed
+	}
+	if len(metricPolicies) > 0 {
+		options.MetricPolicies = make(map[string]MergeConflictPolicy, len(metricPolicies))
+	}
+	for _, spec := range metricPolicies {
+		pos := strings.LastIndex(spec, "=")
+		if pos <= 0 {
+			return MergeOptions{}, fmt.Errorf("invalid merge conflict policy override %q, expected metric=policy", spec)
+		}
+		parsed, err := ParseMergeConflictPolicy(spec[pos+1:])
+		if err != nil {
+			return MergeOptions{}, err
+		}
+		options.MetricPolicies[spec[:pos]] = parsed
+	}
+	return options, nil
+}
+
+// MergeDataBatches combines batches scraped from different sources in the same resolution window
+// into a single batch, with the timestamp of the newest batch. Metric sets are united by key.
+//
+// When a key is present in more than one batch, the metric sets are merged: the union of their
+// metric values, labels and labeled metrics is taken, and on conflicts the last writer wins.
+// Batches are written in the order of their timestamps, and in the order of the arguments for
+// equal timestamps, so values from the newest batch are kept. A warning is logged on collisions.
+//
+// Nil batches are skipped. The given batches and their metric sets are not modified.
+func MergeDataBatches(batches ...*DataBatch) *DataBatch {
+	// The prefer-last policy never fails.
+	result, _ := MergeDataBatchesWithOptions(MergeOptions{}, batches...)
+	return result
+}
+
+// MergeDataBatchesWithOptions works like MergeDataBatches, but conflicting metric values and
+// labeled metrics are resolved with the policies of the options. Labels and times are still
+// taken from the last writer. Conflicts are counted by metric name in the
+// heapster_merge_conflicts_total metric, whatever the policy.
+func MergeDataBatchesWithOptions(options MergeOptions, batches ...*DataBatch) (*DataBatch, error) {
+	ordered := make([]*DataBatch, 0, len(batches))
+	for _, batch := range batches {
+		if batch != nil {
+			ordered = append(ordered, batch)
+		}
+	}
+	sort.SliceStable(ordered, func(i, j int) bool {
+		return ordered[i].Timestamp.Before(ordered[j].Timestamp)
+	})
+
+	result := &DataBatch{
+		MetricSets: make(map[string]*MetricSet),
+	}
+	// Merged metric sets are copies owned by the result, so they can be modified in place.
+	merged := make(map[string]bool)
+	for _, batch := range ordered {
+		result.Timestamp = batch.Timestamp
+		for key, ms := range batch.MetricSets {
+			existing, found := result.MetricSets[key]
+			if !found {
+				result.MetricSets[key] = ms
+				continue
+			}
+			if !merged[key] {
+				existing = copyMetricSet(existing)
+				result.MetricSets[key] = existing
+				merged[key] = true
+			}
+			if err := mergeMetricSet(existing, ms, options); err != nil {
+				return nil, fmt.Errorf("failed to merge metric set %s: %v", key, err)
+			}
+		}
+	}
+
+	if len(merged) > 0 {
+		keys := make([]string, 0, len(merged))
+		for key := range merged {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		if len(keys) > maxLoggedCollisions {
+			keys = append(keys[:maxLoggedCollisions], "...")
+		}
+		glog.Warningf("Merged %d metric sets present in more than one batch: %s", len(merged), strings.Join(keys, ", "))
+	}
+	return result, nil
+}
+
+func copyMetricSet(ms *MetricSet) *MetricSet {
+	result := &MetricSet{
+		CollectionStartTime: ms.CollectionStartTime,
+		EntityCreateTime:    ms.EntityCreateTime,
+		ScrapeTime:          ms.ScrapeTime,
+		MetricValues:        make(map[string]MetricValue, len(ms.MetricValues)),
+		Labels:              make(map[string]string, len(ms.Labels)),
+		LabeledMetrics:      make([]LabeledMetric, len(ms.LabeledMetrics)),
+	}
+	for name, value := range ms.MetricValues {
+		result.MetricValues[name] = value
+	}
+	for name, value := range ms.Labels {
+		result.Labels[name] = value
+	}
+	copy(result.LabeledMetrics, ms.LabeledMetrics)
+	return result
+}
+
+// mergeMetricSet writes the contents of from into into, resolving the values with the same names
+// and the labeled metrics with the same names and labels with the policies of the options.
+// Labels are overwritten, and times are overwritten when set.
+func mergeMetricSet(into, from *MetricSet, options MergeOptions) error {
+	if !from.CollectionStartTime.IsZero() {
+		into.CollectionStartTime = from.CollectionStartTime
+	}
+	if !from.EntityCreateTime.IsZero() {
+		into.EntityCreateTime = from.EntityCreateTime
+	}
+	if !from.ScrapeTime.IsZero() {
+		into.ScrapeTime = from.ScrapeTime
+	}
+	for name, value := range from.MetricValues {
+		if existing, found := into.MetricValues[name]; found {
+			resolved, err := resolveConflict(name, existing, value, options)
+			if err != nil {
+				return err
+			}
+			value = resolved
+		}
+		into.MetricValues[name] = value
+	}
+	for name, value := range from.Labels {
+		into.Labels[name] = value
+	}
+
+	indices := make(map[string]int, len(into.LabeledMetrics))
+	for i := range into.LabeledMetrics {
+		indices[LabeledMetricKey(&into.LabeledMetrics[i])] = i
+	}
+	for _, metric := range from.LabeledMetrics {
+		if i, found := indices[LabeledMetricKey(&metric)]; found {
+			resolved, err := resolveConflict(metric.Name, into.LabeledMetrics[i].MetricValue, metric.MetricValue, options)
+			if err != nil {
+				return err
+			}
+			into.LabeledMetrics[i].MetricValue = resolved
+		} else {
+			indices[LabeledMetricKey(&metric)] = len(into.LabeledMetrics)
+			into.LabeledMetrics = append(into.LabeledMetrics, metric)
+		}
+	}
+	return nil
+}
+
+// resolveConflict returns the value of the metric to keep when existing was written first and
+// value last.
+func resolveConflict(metricName string, existing, value MetricValue, options MergeOptions) (MetricValue, error) {
+	mergeConflicts.WithLabelValues(metricName).Inc()
+	switch policy := options.policy(metricName); policy {
+	case MergeConflictError:
+		return MetricValue{}, fmt.Errorf("metric %s is present in more than one batch", metricName)
+	case MergeConflictPreferFirst:
+		return existing, nil
+	case MergeConflictPreferLast:
+		return value, nil
+	case MergeConflictSum:
+		if existing.ValueType != value.ValueType {
+			return MetricValue{}, fmt.Errorf("can't sum values of metric %s with different types", metricName)
+		}
+		value.IntValue += existing.IntValue
+		value.FloatValue += existing.FloatValue
+		return value, nil
+	default:
+		return MetricValue{}, fmt.Errorf("unknown merge conflict policy %q of metric %s", policy, metricName)
+	}
+}
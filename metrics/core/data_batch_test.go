@@ -0,0 +1,190 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package core
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMergeDataBatchesDisjointKeys(t *testing.T) {
+	now := time.Now()
+	key1 := NodeKey("node1")
+	key2 := NodeKey("node2")
+	ms1 := &MetricSet{MetricValues: map[string]MetricValue{"m1": {ValueType: ValueInt64, IntValue: 1}}}
+	ms2 := &MetricSet{MetricValues: map[string]MetricValue{"m1": {ValueType: ValueInt64, IntValue: 2}}}
+
+	result := MergeDataBatches(
+		&DataBatch{Timestamp: now, MetricSets: map[string]*MetricSet{key1: ms1}},
+		nil,
+		&DataBatch{Timestamp: now, MetricSets: map[string]*MetricSet{key2: ms2}})
+
+	assert.Equal(t, now, result.Timestamp)
+	assert.Len(t, result.MetricSets, 2)
+	assert.Equal(t, ms1, result.MetricSets[key1])
+	assert.Equal(t, ms2, result.MetricSets[key2])
+}
+
+func TestMergeDataBatchesOverlappingKeys(t *testing.T) {
+	now := time.Now()
+	key := PodKey("ns1", "pod1")
+	first := &MetricSet{
+		ScrapeTime: now.Add(-time.Second),
+		Labels:     map[string]string{LabelPodName.Key: "pod1"},
+		MetricValues: map[string]MetricValue{
+			"m1": {ValueType: ValueInt64, IntValue: 1},
+			"m2": {ValueType: ValueInt64, IntValue: 2},
+		},
+		LabeledMetrics: []LabeledMetric{
+			{Name: "l1", Labels: map[string]string{"a": "1"}, MetricValue: MetricValue{IntValue: 1}},
+			{Name: "l1", Labels: map[string]string{"a": "2"}, MetricValue: MetricValue{IntValue: 2}},
+		},
+	}
+	second := &MetricSet{
+		ScrapeTime: now,
+		Labels:     map[string]string{LabelNamespaceName.Key: "ns1"},
+		MetricValues: map[string]MetricValue{
+			"m2": {ValueType: ValueInt64, IntValue: 20},
+			"m3": {ValueType: ValueInt64, IntValue: 30},
+		},
+		LabeledMetrics: []LabeledMetric{
+			{Name: "l1", Labels: map[string]string{"a": "2"}, MetricValue: MetricValue{IntValue: 20}},
+			{Name: "l2", Labels: map[string]string{"a": "1"}, MetricValue: MetricValue{IntValue: 3}},
+		},
+	}
+
+	result := MergeDataBatches(
+		&DataBatch{Timestamp: now, MetricSets: map[string]*MetricSet{key: first}},
+		&DataBatch{Timestamp: now, MetricSets: map[string]*MetricSet{key: second}})
+
+	ms := result.MetricSets[key]
+	assert.Equal(t, now, ms.ScrapeTime)
+	assert.Equal(t, map[string]string{LabelPodName.Key: "pod1", LabelNamespaceName.Key: "ns1"}, ms.Labels)
+	assert.Equal(t, int64(1), ms.MetricValues["m1"].IntValue)
+	assert.Equal(t, int64(20), ms.MetricValues["m2"].IntValue)
+	assert.Equal(t, int64(30), ms.MetricValues["m3"].IntValue)
+	assert.Equal(t, []LabeledMetric{
+		{Name: "l1", Labels: map[string]string{"a": "1"}, MetricValue: MetricValue{IntValue: 1}},
+		{Name: "l1", Labels: map[string]string{"a": "2"}, MetricValue: MetricValue{IntValue: 20}},
+		{Name: "l2", Labels: map[string]string{"a": "1"}, MetricValue: MetricValue{IntValue: 3}},
+	}, ms.LabeledMetrics)
+
+	// The input metric sets are not modified.
+	assert.Len(t, first.MetricValues, 2)
+	assert.Equal(t, int64(2), first.MetricValues["m2"].IntValue)
+	assert.Len(t, first.LabeledMetrics, 2)
+}
+
+func TestMergeDataBatchesTimestampMismatch(t *testing.T) {
+	now := time.Now()
+	key := NodeKey("node1")
+	newer := &DataBatch{
+		Timestamp: now,
+		MetricSets: map[string]*MetricSet{
+			key: {MetricValues: map[string]MetricValue{"m1": {ValueType: ValueInt64, IntValue: 2}}},
+		},
+	}
+	older := &DataBatch{
+		Timestamp: now.Add(-time.Minute),
+		MetricSets: map[string]*MetricSet{
+			key: {MetricValues: map[string]MetricValue{"m1": {ValueType: ValueInt64, IntValue: 1}}},
+		},
+	}
+
+	// Values from the newest batch win, regardless of the order of the arguments.
+	result := MergeDataBatches(newer, older)
+	assert.Equal(t, now, result.Timestamp)
+	assert.Equal(t, int64(2), result.MetricSets[key].MetricValues["m1"].IntValue)
+
+	assert.Empty(t, MergeDataBatches().MetricSets)
+}
+
+func TestMergeDataBatchesWithOptions(t *testing.T) {
+	now := time.Now()
+	key := PodKey("ns1", "pod1")
+	batch := func(timestamp time.Time, value int64) *DataBatch {
+		return &DataBatch{
+			Timestamp: timestamp,
+			MetricSets: map[string]*MetricSet{
+				key: {
+					MetricValues: map[string]MetricValue{
+						"m1": {ValueType: ValueInt64, IntValue: value},
+						"m2": {ValueType: ValueInt64, IntValue: value},
+					},
+					LabeledMetrics: []LabeledMetric{
+						{Name: "l1", Labels: map[string]string{"a": "1"}, MetricValue: MetricValue{ValueType: ValueFloat, FloatValue: float64(value)}},
+					},
+				},
+			},
+		}
+	}
+	older, newer := batch(now.Add(-time.Second), 1), batch(now, 2)
+
+	result, err := MergeDataBatchesWithOptions(MergeOptions{
+		Policy:         MergeConflictPreferFirst,
+		MetricPolicies: map[string]MergeConflictPolicy{"m2": MergeConflictSum, "l1": MergeConflictSum},
+	}, newer, older)
+	assert.NoError(t, err)
+	ms := result.MetricSets[key]
+	assert.Equal(t, int64(1), ms.MetricValues["m1"].IntValue)
+	assert.Equal(t, int64(3), ms.MetricValues["m2"].IntValue)
+	assert.Equal(t, 3.0, ms.LabeledMetrics[0].FloatValue)
+
+	// Only the metrics with the error policy fail the merge.
+	_, err = MergeDataBatchesWithOptions(MergeOptions{MetricPolicies: map[string]MergeConflictPolicy{"m1": MergeConflictError}}, older, newer)
+	assert.Error(t, err)
+	result, err = MergeDataBatchesWithOptions(MergeOptions{MetricPolicies: map[string]MergeConflictPolicy{"m3": MergeConflictError}}, older, newer)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), result.MetricSets[key].MetricValues["m1"].IntValue)
+
+	mixed := batch(now, 2)
+	mixed.MetricSets[key].MetricValues["m1"] = MetricValue{ValueType: ValueFloat, FloatValue: 2}
+	_, err = MergeDataBatchesWithOptions(MergeOptions{Policy: MergeConflictSum}, older, mixed)
+	assert.Error(t, err)
+
+	// The input batches are not modified.
+	assert.Equal(t, int64(1), older.MetricSets[key].MetricValues["m2"].IntValue)
+	assert.Equal(t, 1.0, older.MetricSets[key].LabeledMetrics[0].FloatValue)
+}
+
+func TestParseMergeConflictPolicy(t *testing.T) {
+	policy, err := ParseMergeConflictPolicy("sum")
+	assert.NoError(t, err)
+	assert.Equal(t, MergeConflictSum, policy)
+	_, err = ParseMergeConflictPolicy("average")
+	assert.Error(t, err)
+}
+
+func TestParseMergeOptions(t *testing.T) {
+	options, err := ParseMergeOptions("", nil)
+	assert.NoError(t, err)
+	assert.Equal(t, MergeOptions{Policy: MergeConflictPreferLast}, options)
+
+	options, err = ParseMergeOptions("prefer-first", []string{"cpu/usage=sum", "memory/usage=error"})
+	assert.NoError(t, err)
+	assert.Equal(t, MergeOptions{
+		Policy:         MergeConflictPreferFirst,
+		MetricPolicies: map[string]MergeConflictPolicy{"cpu/usage": MergeConflictSum, "memory/usage": MergeConflictError},
+	}, options)
+
+	_, err = ParseMergeOptions("average", nil)
+	assert.Error(t, err)
+	_, err = ParseMergeOptions("", []string{"cpu/usage"})
+	assert.Error(t, err)
+	_, err = ParseMergeOptions("", []string{"cpu/usage=average"})
+	assert.Error(t, err)
+}
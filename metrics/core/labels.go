@@ -14,6 +14,8 @@
 
 package core
 
+import "strings"
+
 // Definition of labels supported in MetricSet.
 
 var (
@@ -48,6 +50,14 @@ var (
 		Key:         "container_name",
 		Description: "User-provided name of the container or full container name for system containers",
 	}
+	LabelQosClass = LabelDescriptor{
+		Key:         "qos_class",
+		Description: "Quality of service class of the pod (Guaranteed, Burstable, BestEffort)",
+	}
+	LabelPodLabelValues = LabelDescriptor{
+		Key:         "pod_label_values",
+		Description: "Comma-separated values of the pod label set with --node_pod_label among the pods of the node",
+	}
 	LabelLabels = LabelDescriptor{
 		Key:         "labels",
 		Description: "Comma-separated list of user-provided labels",
@@ -72,6 +82,17 @@ var (
 		Key:         "container_base_image",
 		Description: "User-defined image name that is run inside the container",
 	}
+	LabelContainerImageID = LabelDescriptor{
+		Key:         "image_id",
+		Description: "ID of the image the container runs, as resolved by the container runtime (usually a digest). Set only with --label_image_ids",
+	}
+	LabelContainerType = LabelDescriptor{
+		Key:         "container_type",
+		Description: "Type of the container in its pod: init for init containers, app for the other ones",
+	}
+	ContainerTypeInit = "init"
+	ContainerTypeApp  = "app"
+
 	// The label is populated only for GCM
 	LabelCustomMetricName = LabelDescriptor{
 		Key:         "custom_metric_name",
@@ -124,15 +145,22 @@ var commonLabels = []LabelDescriptor{
 var containerLabels = []LabelDescriptor{
 	LabelContainerName,
 	LabelContainerBaseImage,
+	LabelContainerImageID,
+	LabelContainerType,
 }
 
 var podLabels = []LabelDescriptor{
 	LabelPodName,
 	LabelPodId,
 	LabelPodNamespaceUID,
+	LabelQosClass,
 	LabelLabels,
 }
 
+var nodeLabels = []LabelDescriptor{
+	LabelPodLabelValues,
+}
+
 var metricLabels = []LabelDescriptor{
 	LabelResourceID,
 }
@@ -147,6 +175,14 @@ var acceleratorLabels = []LabelDescriptor{
 	LabelAcceleratorID,
 }
 
+// Labels set on some types of metric sets only, which are not in the lists above.
+var metricSetLabels = []LabelDescriptor{
+	LabelMetricSetType,
+	LabelNamespaceName,
+	LabelNodeSchedulable,
+	LabelVolumeName,
+}
+
 // Labels exported to GCM. The number of labels that can be exported to GCM is limited by 10.
 var gcmLabels = []LabelDescriptor{
 	LabelMetricSetType,
@@ -184,6 +220,12 @@ func PodLabels() []LabelDescriptor {
 	return result
 }
 
+func NodeLabels() []LabelDescriptor {
+	result := make([]LabelDescriptor, len(nodeLabels))
+	copy(result, nodeLabels)
+	return result
+}
+
 func MetricLabels() []LabelDescriptor {
 	result := make([]LabelDescriptor, len(metricLabels)+len(customMetricLabels))
 	copy(result, metricLabels)
@@ -197,6 +239,23 @@ func SupportedLabels() []LabelDescriptor {
 	return append(result, MetricLabels()...)
 }
 
+// AllLabels returns the labels of all the lists, i.e. every label set by Heapster, without duplicates.
+func AllLabels() []LabelDescriptor {
+	lists := [][]LabelDescriptor{commonLabels, containerLabels, podLabels, nodeLabels, metricLabels, customMetricLabels,
+		acceleratorLabels, metricSetLabels, gcmLabels, gcmNodeAutoscalingLabels}
+	result := []LabelDescriptor{}
+	seen := make(map[string]bool)
+	for _, list := range lists {
+		for _, label := range list {
+			if !seen[label.Key] {
+				seen[label.Key] = true
+				result = append(result, label)
+			}
+		}
+	}
+	return result
+}
+
 func GcmLabels() map[string]LabelDescriptor {
 	result := make(map[string]LabelDescriptor, len(gcmLabels))
 	for _, l := range gcmLabels {
@@ -211,3 +270,16 @@ func GcmNodeAutoscalingLabels() map[string]LabelDescriptor {
 	}
 	return result
 }
+
+// SystemSliceContainerName is part of the container_name label of the containers systemd runs
+// services in on the nodes, e.g. system.slice/docker.service.
+const SystemSliceContainerName = "system.slice/"
+
+// DefaultSystemContainers are the names of the system containers reported by the kubelet for
+// the node management daemons.
+var DefaultSystemContainers = []string{"kubelet", "kube-proxy", "docker-daemon", "system", "runtime", "misc"}
+
+// IsSystemSliceContainer returns whether the metric set belongs to a system.slice container.
+func IsSystemSliceContainer(metricSet *MetricSet) bool {
+	return strings.Contains(metricSet.Labels[LabelContainerName.Key], SystemSliceContainerName)
+}
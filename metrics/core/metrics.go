@@ -38,10 +38,12 @@ var StandardMetrics = []Metric{
 	MetricMemoryWorkingSet,
 	MetricMemoryPageFaults,
 	MetricMemoryMajorPageFaults,
+	MetricMemoryOomEvents,
 	MetricNetworkRx,
 	MetricNetworkRxErrors,
 	MetricNetworkTx,
-	MetricNetworkTxErrors}
+	MetricNetworkTxErrors,
+	MetricProcesses}
 
 // Metrics computed based on cluster state using Kubernetes API.
 var AdditionalMetrics = []Metric{
@@ -50,7 +52,21 @@ var AdditionalMetrics = []Metric{
 	MetricMemoryRequest,
 	MetricMemoryLimit,
 	MetricEphemeralStorageRequest,
-	MetricEphemeralStorageLimit}
+	MetricEphemeralStorageLimit,
+	MetricPodReadyContainers,
+	MetricPodTotalContainers,
+	MetricResourceRequest,
+	MetricResourceLimit,
+	MetricResourceRequestBytes,
+	MetricResourceLimitBytes}
+
+// Labeled metrics computed by the processors, summed by the aggregators per label values.
+var AggregatedLabeledMetrics = []Metric{
+	MetricResourceRequest,
+	MetricResourceLimit,
+	MetricResourceRequestBytes,
+	MetricResourceLimitBytes,
+}
 
 // Computed based on corresponding StandardMetrics.
 var RateMetrics = []Metric{
@@ -85,6 +101,8 @@ var LabeledMetrics = []Metric{
 	MetricFilesystemAvailable,
 	MetricFilesystemInodes,
 	MetricFilesystemInodesFree,
+	MetricNetworkRxByInterface,
+	MetricNetworkTxByInterface,
 	MetricAcceleratorMemoryTotal,
 	MetricAcceleratorMemoryUsed,
 	MetricAcceleratorDutyCycle,
@@ -105,6 +123,37 @@ var NodeAutoscalingMetrics = []Metric{
 	MetricNodeEphemeralStorageReservation,
 }
 
+// Computed for the cluster by summing the capacity metrics of its nodes.
+var ClusterCapacityMetrics = []Metric{
+	MetricClusterCpuCapacity,
+	MetricClusterMemoryCapacity,
+	MetricClusterEphemeralStorageCapacity,
+	MetricClusterCpuAllocatable,
+	MetricClusterMemoryAllocatable,
+	MetricClusterEphemeralStorageAllocatable,
+}
+
+// Maps from the name of the node metric to the cluster metric it is summed into.
+var ClusterCapacityMetricsMapping = map[string]Metric{
+	MetricNodeCpuCapacity.MetricDescriptor.Name:                 MetricClusterCpuCapacity,
+	MetricNodeMemoryCapacity.MetricDescriptor.Name:              MetricClusterMemoryCapacity,
+	MetricNodeEphemeralStorageCapacity.MetricDescriptor.Name:    MetricClusterEphemeralStorageCapacity,
+	MetricNodeCpuAllocatable.MetricDescriptor.Name:              MetricClusterCpuAllocatable,
+	MetricNodeMemoryAllocatable.MetricDescriptor.Name:           MetricClusterMemoryAllocatable,
+	MetricNodeEphemeralStorageAllocatable.MetricDescriptor.Name: MetricClusterEphemeralStorageAllocatable,
+}
+
+// Computed for nodes from their system containers.
+var NodeSystemContainerMetrics = []Metric{
+	MetricNodeSystemCpu,
+	MetricNodeSystemMemory,
+}
+
+// Computed from the memory usage and limits.
+var MemorySaturationMetrics = []Metric{
+	MetricMemorySaturation,
+}
+
 var CpuMetrics = []Metric{
 	MetricCpuLimit,
 	MetricCpuRequest,
@@ -115,6 +164,8 @@ var CpuMetrics = []Metric{
 	MetricNodeCpuCapacity,
 	MetricNodeCpuReservation,
 	MetricNodeCpuUtilization,
+	MetricClusterCpuAllocatable,
+	MetricClusterCpuCapacity,
 }
 var FilesystemMetrics = []Metric{
 	MetricFilesystemAvailable,
@@ -127,6 +178,7 @@ var MemoryMetrics = []Metric{
 	MetricMemoryLimit,
 	MetricMemoryMajorPageFaults,
 	MetricMemoryMajorPageFaultsRate,
+	MetricMemoryOomEvents,
 	MetricMemoryPageFaults,
 	MetricMemoryPageFaultsRate,
 	MetricMemoryRequest,
@@ -134,10 +186,13 @@ var MemoryMetrics = []Metric{
 	MetricMemoryRSS,
 	MetricMemoryCache,
 	MetricMemoryWorkingSet,
+	MetricMemorySaturation,
 	MetricNodeMemoryAllocatable,
 	MetricNodeMemoryCapacity,
 	MetricNodeMemoryUtilization,
 	MetricNodeMemoryReservation,
+	MetricClusterMemoryAllocatable,
+	MetricClusterMemoryCapacity,
 }
 var NetworkMetrics = []Metric{
 	MetricNetworkRx,
@@ -148,6 +203,8 @@ var NetworkMetrics = []Metric{
 	MetricNetworkTxErrors,
 	MetricNetworkTxErrorsRate,
 	MetricNetworkTxRate,
+	MetricNetworkRxByInterface,
+	MetricNetworkTxByInterface,
 }
 
 // Maps from resource name to the metric that tracks container resource request
@@ -187,8 +244,71 @@ func MetricFamilyForName(metricName string) MetricFamily {
 	return MetricFamilyGeneral
 }
 
-var AllMetrics = append(append(append(append(StandardMetrics, AdditionalMetrics...), RateMetrics...), LabeledMetrics...),
-	NodeAutoscalingMetrics...)
+// Groups of metrics whose collection from the sources can be turned off together.
+const (
+	CollectionGroupNetwork      = "network"
+	CollectionGroupDisk         = "disk"
+	CollectionGroupMemoryDetail = "memory_detail"
+	CollectionGroupProcesses    = "processes"
+)
+
+var CollectionGroups = map[string][]Metric{
+	CollectionGroupNetwork: NetworkMetrics,
+	CollectionGroupDisk: {
+		MetricFilesystemAvailable,
+		MetricFilesystemLimit,
+		MetricFilesystemUsage,
+		MetricFilesystemInodes,
+		MetricFilesystemInodesFree,
+		MetricDiskIORead,
+		MetricDiskIOReadRate,
+		MetricDiskIOWrite,
+		MetricDiskIOWriteRate,
+	},
+	// Memory usage and working set are kept, as they are needed by the metrics API and autoscaling.
+	CollectionGroupMemoryDetail: {
+		MetricMemoryRSS,
+		MetricMemoryCache,
+		MetricMemoryPageFaults,
+		MetricMemoryPageFaultsRate,
+		MetricMemoryMajorPageFaults,
+		MetricMemoryMajorPageFaultsRate,
+	},
+	// Only counted by cadvisor with its load reader enabled, zero otherwise.
+	CollectionGroupProcesses: {
+		MetricProcesses,
+	},
+}
+
+// Names of the metrics which are not collected from the sources.
+var uncollectedMetrics = map[string]bool{}
+
+// DisableCollectionGroup stops the sources from collecting the metrics of the given group.
+// It is not safe to call once the sources are scraped.
+func DisableCollectionGroup(group string) error {
+	metrics, found := CollectionGroups[group]
+	if !found {
+		return fmt.Errorf("unknown metric collection group %q", group)
+	}
+	for _, metric := range metrics {
+		uncollectedMetrics[metric.Name] = true
+	}
+	return nil
+}
+
+// IsCollected returns whether the sources should collect the given metric.
+func IsCollected(metric *Metric) bool {
+	return !uncollectedMetrics[metric.Name]
+}
+
+// Set by the source manager for the nodes and pods of every source, from the result of its scrape.
+var UpMetrics = []Metric{
+	MetricNodeUp,
+	MetricPodUp,
+}
+
+var AllMetrics = append(append(append(append(append(append(append(append(StandardMetrics, AdditionalMetrics...), RateMetrics...), LabeledMetrics...),
+	NodeAutoscalingMetrics...), ClusterCapacityMetrics...), NodeSystemContainerMetrics...), MemorySaturationMetrics...), UpMetrics...)
 
 // Definition of Standard Metrics.
 var MetricUptime = Metric{
@@ -220,6 +340,26 @@ var MetricRestartCount = Metric{
 	},
 }
 
+var MetricPodReadyContainers = Metric{
+	MetricDescriptor: MetricDescriptor{
+		Name:        "pod/ready_containers",
+		Description: "Number of ready containers of the pod, not counting init containers. This metric is Kubernetes specific.",
+		Type:        MetricGauge,
+		ValueType:   ValueInt64,
+		Units:       UnitsCount,
+	},
+}
+
+var MetricPodTotalContainers = Metric{
+	MetricDescriptor: MetricDescriptor{
+		Name:        "pod/total_containers",
+		Description: "Number of containers of the pod, not counting init containers. This metric is Kubernetes specific.",
+		Type:        MetricGauge,
+		ValueType:   ValueInt64,
+		Units:       UnitsCount,
+	},
+}
+
 var MetricCpuLoad = Metric{
 	MetricDescriptor: MetricDescriptor{
 		Name:        "cpu/load",
@@ -239,6 +379,26 @@ var MetricCpuLoad = Metric{
 	},
 }
 
+var MetricProcesses = Metric{
+	MetricDescriptor: MetricDescriptor{
+		Name:        "container/processes",
+		Description: "Number of tasks (processes and threads) in the container, as counted by the cadvisor load reader",
+		Type:        MetricGauge,
+		ValueType:   ValueInt64,
+		Units:       UnitsCount,
+	},
+	HasValue: func(spec *cadvisor.ContainerSpec) bool {
+		return spec.HasCpu
+	},
+	GetValue: func(spec *cadvisor.ContainerSpec, stat *cadvisor.ContainerStats) MetricValue {
+		tasks := stat.TaskStats
+		return MetricValue{
+			ValueType:  ValueInt64,
+			MetricType: MetricGauge,
+			IntValue:   int64(tasks.NrRunning + tasks.NrSleeping + tasks.NrStopped + tasks.NrUninterruptible + tasks.NrIoWait)}
+	},
+}
+
 var MetricCpuUsage = Metric{
 	MetricDescriptor: MetricDescriptor{
 		Name:        "cpu/usage",
@@ -381,6 +541,28 @@ var MetricMemoryMajorPageFaults = Metric{
 	},
 }
 
+// cadvisor doesn't report OOM kills in the container stats, the failcnt of the memory cgroup,
+// which counts the times the usage hit the limit, is the closest value. Not every hit of
+// the limit ends with an OOM kill, the kernel reclaims memory first.
+var MetricMemoryOomEvents = Metric{
+	MetricDescriptor: MetricDescriptor{
+		Name:        "memory/oom_events",
+		Description: "Number of times the memory usage hit the limit (memory cgroup failcnt), an upper bound of the OOM kills",
+		Type:        MetricCumulative,
+		ValueType:   ValueInt64,
+		Units:       UnitsCount,
+	},
+	HasValue: func(spec *cadvisor.ContainerSpec) bool {
+		return spec.HasMemory
+	},
+	GetValue: func(spec *cadvisor.ContainerSpec, stat *cadvisor.ContainerStats) MetricValue {
+		return MetricValue{
+			ValueType:  ValueInt64,
+			MetricType: MetricCumulative,
+			IntValue:   int64(stat.Memory.Failcnt)}
+	},
+}
+
 var MetricNetworkRx = Metric{
 	MetricDescriptor: MetricDescriptor{
 		Name:        "network/rx",
@@ -480,61 +662,118 @@ var MetricNetworkTxErrors = Metric{
 // Definition of Additional Metrics.
 var MetricCpuRequest = Metric{
 	MetricDescriptor: MetricDescriptor{
-		Name:        "cpu/request",
-		Description: "CPU request (the guaranteed amount of resources) in millicores. This metric is Kubernetes specific.",
-		Type:        MetricGauge,
-		ValueType:   ValueInt64,
-		Units:       UnitsCount,
+		Name:                "cpu/request",
+		Description:         "CPU request (the guaranteed amount of resources) in millicores. This metric is Kubernetes specific.",
+		Type:                MetricGauge,
+		ValueType:           ValueInt64,
+		Units:               UnitsCount,
+		OnlyExportIfChanged: true,
 	},
 }
 
 var MetricCpuLimit = Metric{
 	MetricDescriptor: MetricDescriptor{
-		Name:        "cpu/limit",
-		Description: "CPU hard limit in millicores.",
-		Type:        MetricGauge,
-		ValueType:   ValueInt64,
-		Units:       UnitsCount,
+		Name:                "cpu/limit",
+		Description:         "CPU hard limit in millicores.",
+		Type:                MetricGauge,
+		ValueType:           ValueInt64,
+		Units:               UnitsCount,
+		OnlyExportIfChanged: true,
+	},
+}
+
+// Requests and limits of the resources without a dedicated metric, e.g. extended resources
+// like nvidia.com/gpu, labeled by the resource name. Resources measured in bytes, like hugepages,
+// have their own metrics, so that every metric has a single unit.
+var MetricResourceRequest = Metric{
+	MetricDescriptor: MetricDescriptor{
+		Name:                "resource/request",
+		Description:         "Request of a resource other than cpu, memory, ephemeral storage and the resources measured in bytes, by resource name in resource_id. This metric is Kubernetes specific.",
+		Type:                MetricGauge,
+		ValueType:           ValueInt64,
+		Units:               UnitsCount,
+		Labels:              metricLabels,
+		OnlyExportIfChanged: true,
+	},
+}
+
+var MetricResourceLimit = Metric{
+	MetricDescriptor: MetricDescriptor{
+		Name:                "resource/limit",
+		Description:         "Limit of a resource other than cpu, memory, ephemeral storage and the resources measured in bytes, by resource name in resource_id. This metric is Kubernetes specific.",
+		Type:                MetricGauge,
+		ValueType:           ValueInt64,
+		Units:               UnitsCount,
+		Labels:              metricLabels,
+		OnlyExportIfChanged: true,
+	},
+}
+
+var MetricResourceRequestBytes = Metric{
+	MetricDescriptor: MetricDescriptor{
+		Name:                "resource/request_bytes",
+		Description:         "Request in bytes of a resource measured in bytes other than memory and ephemeral storage, e.g. hugepages, by resource name in resource_id. This metric is Kubernetes specific.",
+		Type:                MetricGauge,
+		ValueType:           ValueInt64,
+		Units:               UnitsBytes,
+		Labels:              metricLabels,
+		OnlyExportIfChanged: true,
+	},
+}
+
+var MetricResourceLimitBytes = Metric{
+	MetricDescriptor: MetricDescriptor{
+		Name:                "resource/limit_bytes",
+		Description:         "Limit in bytes of a resource measured in bytes other than memory and ephemeral storage, e.g. hugepages, by resource name in resource_id. This metric is Kubernetes specific.",
+		Type:                MetricGauge,
+		ValueType:           ValueInt64,
+		Units:               UnitsBytes,
+		Labels:              metricLabels,
+		OnlyExportIfChanged: true,
 	},
 }
 
 var MetricMemoryRequest = Metric{
 	MetricDescriptor: MetricDescriptor{
-		Name:        "memory/request",
-		Description: "Memory request (the guaranteed amount of resources) in bytes. This metric is Kubernetes specific.",
-		Type:        MetricGauge,
-		ValueType:   ValueInt64,
-		Units:       UnitsBytes,
+		Name:                "memory/request",
+		Description:         "Memory request (the guaranteed amount of resources) in bytes. This metric is Kubernetes specific.",
+		Type:                MetricGauge,
+		ValueType:           ValueInt64,
+		Units:               UnitsBytes,
+		OnlyExportIfChanged: true,
 	},
 }
 
 var MetricMemoryLimit = Metric{
 	MetricDescriptor: MetricDescriptor{
-		Name:        "memory/limit",
-		Description: "Memory hard limit in bytes.",
-		Type:        MetricGauge,
-		ValueType:   ValueInt64,
-		Units:       UnitsBytes,
+		Name:                "memory/limit",
+		Description:         "Memory hard limit in bytes.",
+		Type:                MetricGauge,
+		ValueType:           ValueInt64,
+		Units:               UnitsBytes,
+		OnlyExportIfChanged: true,
 	},
 }
 
 var MetricEphemeralStorageRequest = Metric{
 	MetricDescriptor: MetricDescriptor{
-		Name:        "ephemeral_storage/request",
-		Description: "ephemeral storage request (the guaranteed amount of resources) in bytes. This metric is Kubernetes specific.",
-		Type:        MetricGauge,
-		ValueType:   ValueInt64,
-		Units:       UnitsBytes,
+		Name:                "ephemeral_storage/request",
+		Description:         "ephemeral storage request (the guaranteed amount of resources) in bytes. This metric is Kubernetes specific.",
+		Type:                MetricGauge,
+		ValueType:           ValueInt64,
+		Units:               UnitsBytes,
+		OnlyExportIfChanged: true,
 	},
 }
 
 var MetricEphemeralStorageLimit = Metric{
 	MetricDescriptor: MetricDescriptor{
-		Name:        "ephemeral_storage/limit",
-		Description: "ephemeral storage hard limit in bytes.",
-		Type:        MetricGauge,
-		ValueType:   ValueInt64,
-		Units:       UnitsBytes,
+		Name:                "ephemeral_storage/limit",
+		Description:         "ephemeral storage hard limit in bytes.",
+		Type:                MetricGauge,
+		ValueType:           ValueInt64,
+		Units:               UnitsBytes,
+		OnlyExportIfChanged: true,
 	},
 }
 
@@ -609,6 +848,56 @@ var MetricNetworkTxErrorsRate = Metric{
 	},
 }
 
+var MetricNodeSystemCpu = Metric{
+	MetricDescriptor: MetricDescriptor{
+		Name:        "node/system_cpu",
+		Description: "CPU usage rate of the system containers of a node, e.g. the kubelet and the container runtime",
+		Type:        MetricGauge,
+		ValueType:   ValueInt64,
+		Units:       UnitsMillicores,
+	},
+}
+
+var MetricNodeSystemMemory = Metric{
+	MetricDescriptor: MetricDescriptor{
+		Name:        "node/system_memory",
+		Description: "Memory usage of the system containers of a node, e.g. the kubelet and the container runtime",
+		Type:        MetricGauge,
+		ValueType:   ValueInt64,
+		Units:       UnitsBytes,
+	},
+}
+
+var MetricNodeUp = Metric{
+	MetricDescriptor: MetricDescriptor{
+		Name:        "node/up",
+		Description: "1 if the last scrape of the node succeeded, 0 otherwise",
+		Type:        MetricGauge,
+		ValueType:   ValueInt64,
+		Units:       UnitsCount,
+	},
+}
+
+var MetricPodUp = Metric{
+	MetricDescriptor: MetricDescriptor{
+		Name:        "pod/up",
+		Description: "1 if the last scrape of the node of the pod succeeded, 0 otherwise",
+		Type:        MetricGauge,
+		ValueType:   ValueInt64,
+		Units:       UnitsCount,
+	},
+}
+
+var MetricMemorySaturation = Metric{
+	MetricDescriptor: MetricDescriptor{
+		Name:        "memory/saturation",
+		Description: "Memory working set as a share of the memory limit, or of the node capacity without a limit",
+		Type:        MetricGauge,
+		ValueType:   ValueFloat,
+		Units:       UnitsCount,
+	},
+}
+
 var MetricNodeCpuCapacity = Metric{
 	MetricDescriptor: MetricDescriptor{
 		Name:        "cpu/node_capacity",
@@ -729,8 +1018,130 @@ var MetricNodeEphemeralStorageReservation = Metric{
 	},
 }
 
+var MetricClusterCpuCapacity = Metric{
+	MetricDescriptor: MetricDescriptor{
+		Name:        "cpu/cluster_capacity",
+		Description: "Cpu capacity of all the nodes of the cluster",
+		Type:        MetricGauge,
+		ValueType:   ValueFloat,
+		Units:       UnitsMillicores,
+	},
+}
+
+var MetricClusterMemoryCapacity = Metric{
+	MetricDescriptor: MetricDescriptor{
+		Name:        "memory/cluster_capacity",
+		Description: "Memory capacity of all the nodes of the cluster",
+		Type:        MetricGauge,
+		ValueType:   ValueFloat,
+		Units:       UnitsCount,
+	},
+}
+
+var MetricClusterEphemeralStorageCapacity = Metric{
+	MetricDescriptor: MetricDescriptor{
+		Name:        "ephemeral_storage/cluster_capacity",
+		Description: "Ephemeral storage capacity of all the nodes of the cluster",
+		Type:        MetricGauge,
+		ValueType:   ValueFloat,
+		Units:       UnitsCount,
+	},
+}
+
+var MetricClusterCpuAllocatable = Metric{
+	MetricDescriptor: MetricDescriptor{
+		Name:        "cpu/cluster_allocatable",
+		Description: "Cpu allocatable of all the nodes of the cluster",
+		Type:        MetricGauge,
+		ValueType:   ValueFloat,
+		Units:       UnitsMillicores,
+	},
+}
+
+var MetricClusterMemoryAllocatable = Metric{
+	MetricDescriptor: MetricDescriptor{
+		Name:        "memory/cluster_allocatable",
+		Description: "Memory allocatable of all the nodes of the cluster",
+		Type:        MetricGauge,
+		ValueType:   ValueFloat,
+		Units:       UnitsCount,
+	},
+}
+
+var MetricClusterEphemeralStorageAllocatable = Metric{
+	MetricDescriptor: MetricDescriptor{
+		Name:        "ephemeral_storage/cluster_allocatable",
+		Description: "Ephemeral storage allocatable of all the nodes of the cluster",
+		Type:        MetricGauge,
+		ValueType:   ValueFloat,
+		Units:       UnitsCount,
+	},
+}
+
 // Labeled metrics
 
+var MetricNetworkRxByInterface = Metric{
+	MetricDescriptor: MetricDescriptor{
+		Name:        "network/rx_by_interface",
+		Description: "Cumulative number of bytes received over a network interface",
+		Type:        MetricCumulative,
+		ValueType:   ValueInt64,
+		Units:       UnitsBytes,
+		Labels:      metricLabels,
+	},
+	HasLabeledMetric: func(spec *cadvisor.ContainerSpec, stat *cadvisor.ContainerStats) bool {
+		return spec.HasNetwork
+	},
+	GetLabeledMetric: func(spec *cadvisor.ContainerSpec, stat *cadvisor.ContainerStats) []LabeledMetric {
+		result := make([]LabeledMetric, 0, len(stat.Network.Interfaces))
+		for _, interfaceStat := range stat.Network.Interfaces {
+			result = append(result, LabeledMetric{
+				Name: "network/rx_by_interface",
+				Labels: map[string]string{
+					LabelResourceID.Key: interfaceStat.Name,
+				},
+				MetricValue: MetricValue{
+					ValueType:  ValueInt64,
+					MetricType: MetricCumulative,
+					IntValue:   int64(interfaceStat.RxBytes),
+				},
+			})
+		}
+		return result
+	},
+}
+
+var MetricNetworkTxByInterface = Metric{
+	MetricDescriptor: MetricDescriptor{
+		Name:        "network/tx_by_interface",
+		Description: "Cumulative number of bytes sent over a network interface",
+		Type:        MetricCumulative,
+		ValueType:   ValueInt64,
+		Units:       UnitsBytes,
+		Labels:      metricLabels,
+	},
+	HasLabeledMetric: func(spec *cadvisor.ContainerSpec, stat *cadvisor.ContainerStats) bool {
+		return spec.HasNetwork
+	},
+	GetLabeledMetric: func(spec *cadvisor.ContainerSpec, stat *cadvisor.ContainerStats) []LabeledMetric {
+		result := make([]LabeledMetric, 0, len(stat.Network.Interfaces))
+		for _, interfaceStat := range stat.Network.Interfaces {
+			result = append(result, LabeledMetric{
+				Name: "network/tx_by_interface",
+				Labels: map[string]string{
+					LabelResourceID.Key: interfaceStat.Name,
+				},
+				MetricValue: MetricValue{
+					ValueType:  ValueInt64,
+					MetricType: MetricCumulative,
+					IntValue:   int64(interfaceStat.TxBytes),
+				},
+			})
+		}
+		return result
+	},
+}
+
 var MetricFilesystemUsage = Metric{
 	MetricDescriptor: MetricDescriptor{
 		Name:        "filesystem/usage",
@@ -764,12 +1175,13 @@ var MetricFilesystemUsage = Metric{
 
 var MetricFilesystemLimit = Metric{
 	MetricDescriptor: MetricDescriptor{
-		Name:        "filesystem/limit",
-		Description: "The total size of filesystem in bytes",
-		Type:        MetricGauge,
-		ValueType:   ValueInt64,
-		Units:       UnitsBytes,
-		Labels:      metricLabels,
+		Name:                "filesystem/limit",
+		Description:         "The total size of filesystem in bytes",
+		Type:                MetricGauge,
+		ValueType:           ValueInt64,
+		Units:               UnitsBytes,
+		Labels:              metricLabels,
+		OnlyExportIfChanged: true,
 	},
 	HasLabeledMetric: func(spec *cadvisor.ContainerSpec, stat *cadvisor.ContainerStats) bool {
 		return spec.HasFilesystem
@@ -1128,6 +1540,10 @@ type MetricDescriptor struct {
 	Type      MetricType `json:"type,omitempty"`
 	ValueType ValueType  `json:"value_type,omitempty"`
 	Units     UnitsType  `json:"units,omitempty"`
+
+	// Whether the metric changes rarely, so its values can be skipped when they
+	// are equal to the previously exported one.
+	OnlyExportIfChanged bool `json:"only_export_if_changed,omitempty"`
 }
 
 // Metric represents a resource usage stat metric.
@@ -0,0 +1,64 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package core
+
+import (
+	"testing"
+
+	cadvisor "github.com/google/cadvisor/info/v1"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDisableCollectionGroup(t *testing.T) {
+	defer func() { uncollectedMetrics = map[string]bool{} }()
+
+	assert.True(t, IsCollected(&MetricNetworkRx))
+	assert.NoError(t, DisableCollectionGroup(CollectionGroupNetwork))
+	for _, metric := range NetworkMetrics {
+		assert.False(t, IsCollected(&metric), metric.Name)
+	}
+	assert.True(t, IsCollected(&MetricFilesystemUsage))
+
+	assert.NoError(t, DisableCollectionGroup(CollectionGroupMemoryDetail))
+	assert.False(t, IsCollected(&MetricMemoryRSS))
+	assert.True(t, IsCollected(&MetricMemoryUsage))
+	assert.True(t, IsCollected(&MetricMemoryWorkingSet))
+
+	assert.NoError(t, DisableCollectionGroup(CollectionGroupProcesses))
+	assert.False(t, IsCollected(&MetricProcesses))
+
+	assert.Error(t, DisableCollectionGroup("gpu"))
+}
+
+func TestMetricProcesses(t *testing.T) {
+	stats := &cadvisor.ContainerStats{
+		TaskStats: cadvisor.LoadStats{NrRunning: 2, NrSleeping: 10, NrUninterruptible: 1},
+	}
+	assert.False(t, MetricProcesses.HasValue(&cadvisor.ContainerSpec{}))
+	assert.True(t, MetricProcesses.HasValue(&cadvisor.ContainerSpec{HasCpu: true}))
+	assert.Equal(t, int64(13), MetricProcesses.GetValue(&cadvisor.ContainerSpec{HasCpu: true}, stats).IntValue)
+}
+
+func TestMetricMemoryOomEvents(t *testing.T) {
+	stats := &cadvisor.ContainerStats{
+		Memory: cadvisor.MemoryStats{Failcnt: 3},
+	}
+	assert.False(t, MetricMemoryOomEvents.HasValue(&cadvisor.ContainerSpec{}))
+	assert.True(t, MetricMemoryOomEvents.HasValue(&cadvisor.ContainerSpec{HasMemory: true}))
+	value := MetricMemoryOomEvents.GetValue(&cadvisor.ContainerSpec{HasMemory: true}, stats)
+	assert.Equal(t, int64(3), value.IntValue)
+	assert.Equal(t, MetricCumulative, value.MetricType)
+	assert.Equal(t, MetricFamily(MetricFamilyMemory), MetricFamilyForName(MetricMemoryOomEvents.Name))
+}
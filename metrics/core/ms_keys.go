@@ -16,6 +16,7 @@ package core
 
 import (
 	"fmt"
+	"strings"
 )
 
 // MetricsSet keys are inside of DataBatch. The structure of the returned string is
@@ -46,3 +47,44 @@ func NodeContainerKey(node, container string) string {
 func ClusterKey() string {
 	return "cluster"
 }
+
+// HistoricalKeyFromMetricSetKey returns the HistoricalKey of the entity with the given
+// metric set key, built with one of the key functions above.
+func HistoricalKeyFromMetricSetKey(key string) (HistoricalKey, error) {
+	if key == ClusterKey() {
+		return HistoricalKey{ObjectType: MetricSetTypeCluster}, nil
+	}
+	parts := make(map[string]string)
+	for _, part := range strings.Split(key, "/") {
+		nameValue := strings.SplitN(part, ":", 2)
+		if len(nameValue) != 2 {
+			return HistoricalKey{}, fmt.Errorf("invalid metric set key %q", key)
+		}
+		parts[nameValue[0]] = nameValue[1]
+	}
+	result := HistoricalKey{
+		NodeName:      parts["node"],
+		NamespaceName: parts["namespace"],
+		PodName:       parts["pod"],
+		ContainerName: parts["container"],
+	}
+	_, hasNode := parts["node"]
+	_, hasNamespace := parts["namespace"]
+	_, hasPod := parts["pod"]
+	_, hasContainer := parts["container"]
+	switch {
+	case hasNamespace && hasPod && hasContainer && len(parts) == 3:
+		result.ObjectType = MetricSetTypePodContainer
+	case hasNamespace && hasPod && len(parts) == 2:
+		result.ObjectType = MetricSetTypePod
+	case hasNamespace && len(parts) == 1:
+		result.ObjectType = MetricSetTypeNamespace
+	case hasNode && hasContainer && len(parts) == 2:
+		result.ObjectType = MetricSetTypeSystemContainer
+	case hasNode && len(parts) == 1:
+		result.ObjectType = MetricSetTypeNode
+	default:
+		return HistoricalKey{}, fmt.Errorf("invalid metric set key %q", key)
+	}
+	return result, nil
+}
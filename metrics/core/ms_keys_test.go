@@ -0,0 +1,41 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package core
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHistoricalKeyFromMetricSetKey(t *testing.T) {
+	for key, expected := range map[string]HistoricalKey{
+		ClusterKey():                        {ObjectType: MetricSetTypeCluster},
+		NodeKey("n1"):                       {ObjectType: MetricSetTypeNode, NodeName: "n1"},
+		NodeContainerKey("n1", "kubelet"):   {ObjectType: MetricSetTypeSystemContainer, NodeName: "n1", ContainerName: "kubelet"},
+		NamespaceKey("ns1"):                 {ObjectType: MetricSetTypeNamespace, NamespaceName: "ns1"},
+		PodKey("ns1", "pod1"):               {ObjectType: MetricSetTypePod, NamespaceName: "ns1", PodName: "pod1"},
+		PodContainerKey("ns1", "pod1", "c"): {ObjectType: MetricSetTypePodContainer, NamespaceName: "ns1", PodName: "pod1", ContainerName: "c"},
+	} {
+		historicalKey, err := HistoricalKeyFromMetricSetKey(key)
+		assert.NoError(t, err, key)
+		assert.Equal(t, expected, historicalKey, key)
+	}
+
+	for _, key := range []string{"", "pod:pod1", "namespace:ns1/node:n1", "namespace"} {
+		_, err := HistoricalKeyFromMetricSetKey(key)
+		assert.Error(t, err, key)
+	}
+}
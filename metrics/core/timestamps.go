@@ -0,0 +1,51 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package core
+
+import (
+	"fmt"
+	"time"
+)
+
+// Sources of the timestamps of the exported points.
+const (
+	// The time the source sampled the metric set, e.g. the timestamp of the cadvisor stats.
+	TimestampSourceSample = "sample"
+	// The end of the scrape interval, the timestamp of the batch.
+	TimestampSourceScrape = "scrape"
+)
+
+var timestampSource = TimestampSourceSample
+
+// SetTimestampSource sets which timestamp the sinks and the model API give to the points of a
+// metric set. It is not safe to call once the batches are exported.
+func SetTimestampSource(source string) error {
+	switch source {
+	case TimestampSourceSample, TimestampSourceScrape:
+		timestampSource = source
+		return nil
+	}
+	return fmt.Errorf("unknown timestamp source %q, expected %s or %s", source, TimestampSourceSample, TimestampSourceScrape)
+}
+
+// PointTimestamp returns the timestamp of the points of the metric set of the batch: its scrape
+// time with the sample timestamp source, or the batch timestamp with the scrape one. Metric sets
+// without a scrape time, like the ones computed by the aggregators, get the batch timestamp.
+func PointTimestamp(batch *DataBatch, ms *MetricSet) time.Time {
+	if timestampSource == TimestampSourceScrape || ms == nil || ms.ScrapeTime.IsZero() {
+		return batch.Timestamp
+	}
+	return ms.ScrapeTime
+}
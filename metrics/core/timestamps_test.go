@@ -0,0 +1,44 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package core
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPointTimestamp(t *testing.T) {
+	defer SetTimestampSource(TimestampSourceSample)
+
+	now := time.Now()
+	sampled := &MetricSet{ScrapeTime: now.Add(-5 * time.Second)}
+	aggregated := &MetricSet{}
+	batch := &DataBatch{
+		Timestamp:  now,
+		MetricSets: map[string]*MetricSet{"pod": sampled, "namespace": aggregated},
+	}
+
+	assert.Equal(t, sampled.ScrapeTime, PointTimestamp(batch, sampled))
+	assert.Equal(t, now, PointTimestamp(batch, aggregated))
+	assert.Equal(t, now, PointTimestamp(batch, nil))
+
+	assert.NoError(t, SetTimestampSource(TimestampSourceScrape))
+	assert.Equal(t, now, PointTimestamp(batch, sampled))
+
+	assert.Error(t, SetTimestampSource("cadvisor"))
+	assert.Equal(t, now, PointTimestamp(batch, sampled))
+}
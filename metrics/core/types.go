@@ -15,6 +15,8 @@
 package core
 
 import (
+	"sort"
+	"strings"
 	"time"
 )
 
@@ -89,6 +91,10 @@ type MetricValue struct {
 	FloatValue float64
 	MetricType MetricType
 	ValueType  ValueType
+	// Set on cumulative values lower than in the previous batch, i.e. the counter was reset.
+	Reset bool
+	// Set on values carried forward from a previous batch because the scrape was missed.
+	Interpolated bool
 }
 
 func (this *MetricValue) GetValue() interface{} {
@@ -101,12 +107,30 @@ func (this *MetricValue) GetValue() interface{} {
 	}
 }
 
+// GetFloatValue returns the value as a float, whatever its type.
+func (this *MetricValue) GetFloatValue() float64 {
+	if ValueInt64 == this.ValueType {
+		return float64(this.IntValue)
+	}
+	return this.FloatValue
+}
+
 type LabeledMetric struct {
 	Name   string
 	Labels map[string]string
 	MetricValue
 }
 
+// LabeledMetricKey identifies a labeled metric of a metric set by its name and labels.
+func LabeledMetricKey(metric *LabeledMetric) string {
+	labels := make([]string, 0, len(metric.Labels))
+	for key, value := range metric.Labels {
+		labels = append(labels, key+"="+value)
+	}
+	sort.Strings(labels)
+	return metric.Name + "{" + strings.Join(labels, ",") + "}"
+}
+
 func (this *LabeledMetric) GetValue() interface{} {
 	if ValueInt64 == this.ValueType {
 		return this.IntValue
@@ -154,11 +178,71 @@ type DataSink interface {
 	// Exports data to the external storage. The function should be synchronous/blocking and finish only
 	// after the given DataBatch was written. This will allow sink manager to push data only to these
 	// sinks that finished writing the previous data.
+	// The same DataBatch is passed to all the sinks at the same time, so it must be treated as read only:
+	// a sink that needs different labels or values has to work on its own copy.
 	ExportData(*DataBatch)
+	// Stops the sink. Sinks that buffer data between exports should write it before returning,
+	// so Stop is allowed to block while the pending data is flushed.
 	Stop()
 }
 
+// Optionally implemented by sinks that can check whether their backend is reachable, so that
+// misconfigured sinks are found on startup rather than at the first export.
+type DataSinkConnectionChecker interface {
+	// Connects to the backend, or checks it is reachable, and returns the error if it isn't.
+	CheckConnection() error
+}
+
+// Optionally implemented by sinks distributing batches to other sinks, such as the sink manager,
+// to report which of them took a batch.
+type DataSinkExportReporter interface {
+	// Exports the batch like ExportData and returns whether each of the sinks took it.
+	ExportDataWithResults(*DataBatch) []SinkExportResult
+}
+
+// Optionally implemented by sinks distributing batches to other sinks, such as the sink manager,
+// to tell when every one of them finished exporting a batch, rather than only took it.
+type DataSinkExportTracker interface {
+	// Sets the function called with each batch that all the sinks took and finished exporting.
+	// Must be called before the first export.
+	OnBatchExported(func(*DataBatch))
+}
+
+type SinkExportResult struct {
+	// Name of the sink.
+	Sink string
+	// Whether the sink took the batch within the export timeout, rather than still exporting
+	// the previous one.
+	Accepted bool
+}
+
 type DataProcessor interface {
 	Name() string
 	Process(*DataBatch) (*DataBatch, error)
 }
+
+// Optionally implemented by data processors that rely on labels set by other processors,
+// so that their order can be validated on startup. Label keys can be followed by "=value"
+// to refer to metric sets with the given label value, e.g. "type=pod".
+type DataProcessorDependencies interface {
+	// Labels that have to be set by one of the previous processors, if any processor sets them.
+	RequiredLabels() []string
+	// Labels set by this processor.
+	ProducedLabels() []string
+}
+
+// Implemented by providers and processors that serve objects from a local cache of the API server,
+// e.g. a node lister.
+type CacheSyncer interface {
+	// Returns whether the initial list of the cached objects was received.
+	HasSynced() bool
+}
+
+// Implemented by storages that can drop all the stored metrics of an entity, e.g. of a deleted pod.
+type MetricSetEvictor interface {
+	// Removes the metric sets with the given keys, and their children, e.g. containers of a pod.
+	EvictMetricSets(keys []string)
+	// Marks whether the entity of the metric set with the given key, and its children, no longer
+	// exists. Metrics of terminated entities are kept until they are evicted.
+	SetTerminated(key string, terminated bool)
+}
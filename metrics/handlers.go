@@ -15,14 +15,18 @@
 package main
 
 import (
+	"fmt"
 	"net/http"
 	"net/http/pprof"
 	"strings"
 
 	restful "github.com/emicklei/go-restful"
+	"k8s.io/heapster/common/flags"
 	"k8s.io/heapster/metrics/api/v1"
+	"k8s.io/heapster/metrics/api/v1/types"
 	metricsApi "k8s.io/heapster/metrics/apis/metrics"
 	"k8s.io/heapster/metrics/core"
+	"k8s.io/heapster/metrics/options"
 	metricsink "k8s.io/heapster/metrics/sinks/metric"
 	"k8s.io/heapster/metrics/util/metrics"
 
@@ -31,7 +35,23 @@ import (
 
 const pprofBasePath = "/debug/pprof/"
 
-func setupHandlers(metricSink *metricsink.MetricSink, podLister v1listers.PodLister, nodeLister v1listers.NodeLister, historicalSource core.HistoricalSource, disableMetricExport bool) http.Handler {
+// handlerSources are the parts of Heapster served by the API handlers. The optional ones are nil
+// if they are not enabled.
+type handlerSources struct {
+	metricSink          *metricsink.MetricSink
+	modelStore          metricsink.ModelStore
+	podLister           v1listers.PodLister
+	nodeLister          v1listers.NodeLister
+	historicalSource    core.HistoricalSource
+	namespaceAuthorizer v1.NamespaceAuthorizer
+	pipeline            types.Pipeline
+	scrapeBackoffs      func() []types.ScrapeBackoff
+	scrapeNow           func() (types.ScrapeSummary, error)
+	clearCache          func()
+	validateSink        func(uri flags.Uri) ([]string, error)
+}
+
+func setupHandlers(opt *options.HeapsterRunOptions, sources handlerSources) http.Handler {
 
 	runningInKubernetes := true
 
@@ -39,10 +59,27 @@ func setupHandlers(metricSink *metricsink.MetricSink, podLister v1listers.PodLis
 	wsContainer := restful.NewContainer()
 	wsContainer.EnableContentEncoding(true)
 	wsContainer.Router(restful.CurlyRouter{})
-	a := v1.NewApi(runningInKubernetes, metricSink, historicalSource, disableMetricExport)
+	a := v1.NewApi(runningInKubernetes, sources.modelStore, sources.historicalSource, opt.DisableMetricExport)
+	if sources.namespaceAuthorizer != nil {
+		a.SetNamespaceAuthorizer(sources.namespaceAuthorizer)
+	}
+	if sources.podLister != nil {
+		a.SetPodLister(sources.podLister)
+	}
+	a.SetPipeline(sources.pipeline)
+	a.SetScrapeBackoffs(sources.scrapeBackoffs)
+	a.SetSinkConnections(sinkConnections)
+	if sources.scrapeNow != nil {
+		a.SetScrapeTrigger(sources.scrapeNow)
+	}
+	if sources.clearCache != nil {
+		a.SetCacheClearer(sources.clearCache)
+	}
+	a.SetSinkValidator(sources.validateSink)
+	a.SetMaxModelRequests(opt.MaxModelRequests)
 	a.Register(wsContainer)
 	// Metrics API
-	m := metricsApi.NewApi(metricSink, podLister, nodeLister)
+	m := metricsApi.NewApi(sources.metricSink, sources.podLister, sources.nodeLister)
 	m.Register(wsContainer)
 
 	handlePprofEndpoint := func(req *restful.Request, resp *restful.Response) {
@@ -53,7 +90,7 @@ func setupHandlers(metricSink *metricsink.MetricSink, podLister v1listers.PodLis
 		case "symbol":
 			pprof.Symbol(resp, req.Request)
 		case "cmdline":
-			pprof.Cmdline(resp, req.Request)
+			redactedCmdline(resp)
 		default:
 			pprof.Index(resp, req.Request)
 		}
@@ -66,3 +103,11 @@ func setupHandlers(metricSink *metricsink.MetricSink, podLister v1listers.PodLis
 
 	return wsContainer
 }
+
+// redactedCmdline serves the command line like pprof.Cmdline, with the credentials of the source
+// and sink URIs redacted.
+func redactedCmdline(w http.ResponseWriter) {
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	fmt.Fprint(w, strings.Join(redactedArgs(), "\x00"))
+}
@@ -24,9 +24,11 @@ import (
 	"net/http"
 	"net/url"
 	"os"
+	"os/signal"
 	"runtime"
 	"strconv"
 	"strings"
+	"syscall"
 	"time"
 
 	"github.com/golang/glog"
@@ -44,6 +46,9 @@ import (
 	"k8s.io/client-go/tools/cache"
 	"k8s.io/heapster/common/flags"
 	kube_config "k8s.io/heapster/common/kubernetes"
+	"k8s.io/heapster/common/reconnect"
+	"k8s.io/heapster/metrics/api/v1"
+	"k8s.io/heapster/metrics/api/v1/types"
 	"k8s.io/heapster/metrics/cmd/heapster-apiserver/app"
 	"k8s.io/heapster/metrics/core"
 	"k8s.io/heapster/metrics/manager"
@@ -52,6 +57,7 @@ import (
 	"k8s.io/heapster/metrics/sinks"
 	metricsink "k8s.io/heapster/metrics/sinks/metric"
 	"k8s.io/heapster/metrics/sources"
+	"k8s.io/heapster/metrics/sources/kubelet"
 	"k8s.io/heapster/metrics/util"
 	"k8s.io/heapster/version"
 )
@@ -76,21 +82,63 @@ func main() {
 	}
 
 	setMaxProcs(opt)
-	glog.Infof(strings.Join(os.Args, " "))
+	glog.Infof("%s", strings.Join(redactedArgs(), " "))
 	glog.Infof("Heapster version %v", version.HeapsterVersion)
 	if err := validateFlags(opt); err != nil {
 		glog.Fatal(err)
 	}
+	disableCollectionGroups(opt)
+	if err := core.SetTimestampSource(opt.MetricTimestampSource); err != nil {
+		glog.Fatalf("Failed to set the timestamp source: %v", err)
+	}
+	kubelet.SetLabelScrapesByNode(opt.LabelScrapesByNode)
 
 	kubernetesUrl, err := getKubernetesAddress(opt.Sources)
 	if err != nil {
 		glog.Fatalf("Failed to get kubernetes address: %v", err)
 	}
-	sourceManager := createSourceManagerOrDie(opt.Sources)
-	sinkManager, metricSink, historicalSource := createAndInitSinksOrDie(opt.Sinks, opt.HistoricalSource, opt.SinkExportDataTimeout, opt.DisableMetricSink)
+	mergeOptions, err := core.ParseMergeOptions(opt.MergeConflictPolicy, opt.MergeConflictMetricPolicies)
+	if err != nil {
+		glog.Fatalf("Failed to parse the merge conflict policies: %v", err)
+	}
+	sourceProvider, sourceManager := createSourceManagerOrDie(opt.Sources, opt.ScrapeBackoffThreshold, opt.MaxScrapeBackoff, opt.EmitUpMetrics, mergeOptions)
+	decimationPolicies, err := getDecimationPolicies(opt)
+	if err != nil {
+		glog.Fatalf("Failed to parse metric decimation flags: %v", err)
+	}
+	sinkManager, sinkList, metricSink, historicalSource := createAndInitSinksOrDie(opt.Sinks, opt.HistoricalSource, opt.SinkExportDataTimeout, opt.DisableMetricSink,
+		opt.MetricResolution, opt.RequireSinks, decimationPolicies)
 
-	podLister, nodeLister := getListersOrDie(kubernetesUrl)
-	dataProcessors := createDataProcessorsOrDie(kubernetesUrl, podLister, labelCopier)
+	if metricSink != nil {
+		warnOnMetricSinkResolutionMismatch(metricSink, opt.MetricResolution)
+		metricSink.SetMetricResolution(opt.MetricResolution)
+		metricSink.SetMaxLongStorePoints(opt.MaxMetricPoints)
+		downsamplingTiers, err := metricsink.ParseDownsamplingTiers(opt.MetricSinkDownsamplingTiers)
+		if err != nil {
+			glog.Fatalf("Failed to parse metric sink downsampling tiers: %v", err)
+		}
+		if err := metricSink.SetDownsamplingTiers(downsamplingTiers); err != nil {
+			glog.Fatalf("Failed to set metric sink downsampling tiers: %v", err)
+		}
+		if opt.MetricSinkWriteBufferInterval > 0 {
+			metricSink.EnableWriteBuffering(opt.MetricSinkWriteBufferInterval)
+		}
+	}
+
+	podLister, nodeLister, listersSynced := getListersOrDie(kubernetesUrl)
+	dataProcessors := createDataProcessorsOrDie(opt, kubernetesUrl, podLister, labelCopier, metricSink)
+	if err := processors.ValidateProcessorOrder(dataProcessors); err != nil {
+		glog.Fatalf("Invalid order of data processors: %v", err)
+	}
+
+	if opt.SelfTest {
+		if err := runSelfTest(sourceProvider, dataProcessors, listersSynced, opt.MetricResolution); err != nil {
+			glog.Fatalf("Self test failed: %v", err)
+		}
+		glog.Infof("Self test passed")
+		logs.FlushLogs()
+		os.Exit(0)
+	}
 
 	man, err := manager.NewManager(sourceManager, dataProcessors, sinkManager,
 		opt.MetricResolution, manager.DefaultScrapeOffset, manager.DefaultMaxParallelism)
@@ -98,6 +146,7 @@ func main() {
 		glog.Fatalf("Failed to create main manager: %v", err)
 	}
 	man.Start()
+	stopOnSigterm(man, sinks.DefaultSinkStopTimeout)
 
 	if opt.EnableAPIServer {
 		// Run API server in a separate goroutine
@@ -106,7 +155,31 @@ func main() {
 
 	mux := http.NewServeMux()
 	promHandler := prometheus.Handler()
-	handler := setupHandlers(metricSink, podLister, nodeLister, historicalSource, opt.DisableMetricExport)
+	var namespaceAuthorizer v1.NamespaceAuthorizer
+	if opt.AuthorizeModelNamespaces {
+		kubeClient := createKubeClientOrDie(kubernetesUrl)
+		namespaceAuthorizer, err = newSubjectAccessReviewAuthorizer(opt.TLSClientCAFile, kubeClient.AuthorizationV1().SubjectAccessReviews())
+		if err != nil {
+			glog.Fatalf("Failed to create namespace authorizer: %v", err)
+		}
+	}
+	pipeline := describePipeline(dataProcessors, sinkList, opt.Sinks)
+	modelStore := createModelStore(opt.ModelBackend, metricSink, historicalSource)
+	sinkValidator := sinks.NewSinkFactory()
+	sinkValidator.MetricResolution = opt.MetricResolution
+	handler := setupHandlers(opt, handlerSources{
+		metricSink:          metricSink,
+		modelStore:          modelStore,
+		podLister:           podLister,
+		nodeLister:          nodeLister,
+		historicalSource:    historicalSource,
+		namespaceAuthorizer: namespaceAuthorizer,
+		pipeline:            pipeline,
+		scrapeBackoffs:      scrapeBackoffs(sourceManager),
+		scrapeNow:           scrapeTrigger(man, opt.EnableDebugScrape),
+		clearCache:          cacheClearer(metricSink, opt.EnableDebugClearCache),
+		validateSink:        sinkValidator.Validate,
+	})
 	healthz.InstallHandler(mux, healthzChecker(metricSink))
 
 	addr := net.JoinHostPort(opt.Ip, strconv.Itoa(opt.Port))
@@ -118,7 +191,7 @@ func main() {
 		mux.Handle("/", handler)
 		mux.Handle("/metrics", promHandler)
 
-		glog.Fatal(http.ListenAndServe(addr, mux))
+		glog.Fatal(newHTTPServer(opt, addr, mux).ListenAndServe())
 	}
 }
 func createAndRunAPIServer(opt *options.HeapsterRunOptions, metricSink *metricsink.MetricSink,
@@ -162,19 +235,27 @@ func startSecureServing(opt *options.HeapsterRunOptions, handler http.Handler, p
 	mux.Handle("/metrics", promHandler)
 
 	// If allowed users is set, then we need to enable Client Authentication
+	server := newHTTPServer(opt, address, mux)
 	if len(opt.AllowedUsers) > 0 {
-		server := &http.Server{
-			Addr:      address,
-			Handler:   mux,
-			TLSConfig: &tls.Config{ClientAuth: tls.RequestClientCert},
-		}
-		glog.Fatal(server.ListenAndServeTLS(opt.TLSCertFile, opt.TLSKeyFile))
-	} else {
-		glog.Fatal(http.ListenAndServeTLS(address, opt.TLSCertFile, opt.TLSKeyFile, mux))
+		server.TLSConfig = &tls.Config{ClientAuth: tls.RequestClientCert}
+	}
+	glog.Fatal(server.ListenAndServeTLS(opt.TLSCertFile, opt.TLSKeyFile))
+}
+
+// newHTTPServer creates the server of the Heapster-specific APIs, with timeouts, so that slow
+// clients cannot hold connections open indefinitely.
+func newHTTPServer(opt *options.HeapsterRunOptions, address string, handler http.Handler) *http.Server {
+	return &http.Server{
+		Addr:         address,
+		Handler:      handler,
+		ReadTimeout:  opt.HTTPReadTimeout,
+		WriteTimeout: opt.HTTPWriteTimeout,
+		IdleTimeout:  opt.HTTPIdleTimeout,
 	}
 }
 
-func createSourceManagerOrDie(src flags.Uris) core.MetricsSource {
+func createSourceManagerOrDie(src flags.Uris, backoffThreshold int, maxBackoff time.Duration, emitUpMetrics bool,
+	mergeOptions core.MergeOptions) (core.MetricsSourceProvider, sources.BackoffSource) {
 	if len(src) != 1 {
 		glog.Fatal("Wrong number of sources specified")
 	}
@@ -183,15 +264,19 @@ func createSourceManagerOrDie(src flags.Uris) core.MetricsSource {
 	if err != nil {
 		glog.Fatalf("Failed to create source provide: %v", err)
 	}
-	sourceManager, err := sources.NewSourceManager(sourceProvider, sources.DefaultMetricsScrapeTimeout)
+	sourceManager, err := sources.NewSourceManagerWithBackoff(sourceProvider, sources.DefaultMetricsScrapeTimeout, backoffThreshold, maxBackoff, emitUpMetrics, mergeOptions)
 	if err != nil {
 		glog.Fatalf("Failed to create source manager: %v", err)
 	}
-	return sourceManager
+	return sourceProvider, sourceManager
 }
 
-func createAndInitSinksOrDie(sinkAddresses flags.Uris, historicalSource string, sinkExportDataTimeout time.Duration, disableMetricSink bool) (core.DataSink, *metricsink.MetricSink, core.HistoricalSource) {
+func createAndInitSinksOrDie(sinkAddresses flags.Uris, historicalSource string, sinkExportDataTimeout time.Duration, disableMetricSink bool,
+	metricResolution time.Duration, requireSinks bool, decimationPolicies map[string]sinks.DecimationPolicy) (core.DataSink, []core.DataSink, *metricsink.MetricSink, core.HistoricalSource) {
 	sinksFactory := sinks.NewSinkFactory()
+	sinksFactory.MetricResolution = metricResolution
+	sinksFactory.RequireReachableSinks = requireSinks
+	sinksFactory.DecimationPolicies = decimationPolicies
 	metricSink, sinkList, histSource := sinksFactory.BuildAll(sinkAddresses, historicalSource, disableMetricSink)
 	if metricSink == nil && !disableMetricSink {
 		glog.Fatal("Failed to create metric sink")
@@ -202,25 +287,155 @@ func createAndInitSinksOrDie(sinkAddresses flags.Uris, historicalSource string,
 	for _, sink := range sinkList {
 		glog.Infof("Starting with %s", sink.Name())
 	}
-	sinkManager, err := sinks.NewDataSinkManager(sinkList, sinkExportDataTimeout, sinks.DefaultSinkStopTimeout)
+	sinkManager, err := sinks.NewDataSinkManagerWithFlushOnStop(sinkList, sinkExportDataTimeout, sinks.DefaultSinkStopTimeout)
 	if err != nil {
 		glog.Fatalf("Failed to create sink manager: %v", err)
 	}
-	return sinkManager, metricSink, histSource
+	return sinkManager, sinkList, metricSink, histSource
 }
 
-func getListersOrDie(kubernetesUrl *url.URL) (v1listers.PodLister, v1listers.NodeLister) {
+// stopOnSigterm stops the manager, and with it the sink manager so that the sinks flush their
+// buffered data, when Heapster receives SIGTERM, and exits once they stopped or after stopTimeout.
+func stopOnSigterm(man manager.Manager, stopTimeout time.Duration) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGTERM)
+	go func() {
+		<-signals
+		glog.Infof("Received SIGTERM, stopping the sinks")
+		stopped := make(chan struct{})
+		go func() {
+			man.Stop()
+			close(stopped)
+		}()
+		select {
+		case <-stopped:
+			glog.Infof("Sinks stopped")
+		case <-time.After(stopTimeout):
+			glog.Warningf("Sinks did not stop within %v", stopTimeout)
+		}
+		logs.FlushLogs()
+		os.Exit(0)
+	}()
+}
+
+// createModelStore returns the storage queried by the model API, or nil if the metric sink is disabled.
+func createModelStore(backend string, metricSink *metricsink.MetricSink, historicalSource core.HistoricalSource) metricsink.ModelStore {
+	if metricSink == nil {
+		return nil
+	}
+	if backend == metricsink.ModelBackendHistorical {
+		// Queries older than the metric sink retention would otherwise be sent to a nil source.
+		if historicalSource == nil {
+			glog.Fatalf("Model backend %s requires a sink usable as a historical source", backend)
+		}
+		return metricsink.NewHistoricalModelStore(metricSink, historicalSource)
+	}
+	return metricSink
+}
+
+// describePipeline lists the active processors and sinks, to be served for debugging.
+func describePipeline(dataProcessors []core.DataProcessor, sinkList []core.DataSink, sinkAddresses flags.Uris) types.Pipeline {
+	pipeline := types.Pipeline{
+		Processors: make([]string, 0, len(dataProcessors)),
+		Sinks:      make([]string, 0, len(sinkList)),
+		SinkUris:   make([]string, 0, len(sinkAddresses)),
+	}
+	for _, processor := range dataProcessors {
+		pipeline.Processors = append(pipeline.Processors, processor.Name())
+	}
+	for _, sink := range sinkList {
+		pipeline.Sinks = append(pipeline.Sinks, sink.Name())
+	}
+	for _, uri := range sinkAddresses {
+		pipeline.SinkUris = append(pipeline.SinkUris, uri.Redacted())
+	}
+	return pipeline
+}
+
+// scrapeTrigger returns a function running an immediate scrape with the manager, to be served for
+// debugging, or nil if it is not enabled.
+func scrapeTrigger(man manager.Manager, enabled bool) func() (types.ScrapeSummary, error) {
+	if !enabled {
+		return nil
+	}
+	return func() (types.ScrapeSummary, error) {
+		summary, err := man.ScrapeNow()
+		if err != nil {
+			return types.ScrapeSummary{}, err
+		}
+		result := types.ScrapeSummary{
+			Start:      summary.Start,
+			End:        summary.End,
+			MetricSets: summary.MetricSets,
+			Metrics:    summary.Metrics,
+			Sinks:      make([]types.SinkExport, 0, len(summary.Sinks)),
+		}
+		for _, sink := range summary.Sinks {
+			result.Sinks = append(result.Sinks, types.SinkExport{Sink: sink.Sink, Accepted: sink.Accepted})
+		}
+		return result, nil
+	}
+}
+
+// cacheClearer returns the function dropping the data of the metric sink, to be served for
+// debugging, or nil if it is not enabled.
+func cacheClearer(metricSink *metricsink.MetricSink, enabled bool) func() {
+	if !enabled || metricSink == nil {
+		return nil
+	}
+	return metricSink.ClearCache
+}
+
+// scrapeBackoffs returns a function listing the sources skipped by the source manager, to be served for debugging.
+func scrapeBackoffs(sourceManager sources.BackoffSource) func() []types.ScrapeBackoff {
+	return func() []types.ScrapeBackoff {
+		backoffs := sourceManager.Backoffs()
+		result := make([]types.ScrapeBackoff, 0, len(backoffs))
+		for _, backoff := range backoffs {
+			result = append(result, types.ScrapeBackoff{
+				Source:              backoff.Source,
+				ConsecutiveFailures: backoff.ConsecutiveFailures,
+				NextRetry:           backoff.NextRetry,
+			})
+		}
+		return result
+	}
+}
+
+// sinkConnections lists the connections of the sinks keeping one to their backend, to be served for debugging.
+func sinkConnections() []types.SinkConnection {
+	states := reconnect.States()
+	result := make([]types.SinkConnection, 0, len(states))
+	for _, state := range states {
+		connection := types.SinkConnection{
+			SinkId:              state.SinkId,
+			Connected:           state.Connected,
+			ConsecutiveFailures: state.ConsecutiveFailures,
+			LastAttempt:         state.LastAttempt,
+		}
+		if state.LastError != nil {
+			connection.LastError = state.LastError.Error()
+		}
+		result = append(result, connection)
+	}
+	return result
+}
+
+func getListersOrDie(kubernetesUrl *url.URL) (v1listers.PodLister, v1listers.NodeLister, []cache.InformerSynced) {
 	kubeClient := createKubeClientOrDie(kubernetesUrl)
 
-	podLister, err := getPodLister(kubeClient)
+	podLister, podListerSynced, err := getPodLister(kubeClient)
 	if err != nil {
 		glog.Fatalf("Failed to create podLister: %v", err)
 	}
-	nodeLister, _, err := util.GetNodeLister(kubeClient)
+	nodeLister, nodeReflector, err := util.GetNodeLister(kubeClient)
 	if err != nil {
 		glog.Fatalf("Failed to create nodeLister: %v", err)
 	}
-	return podLister, nodeLister
+	nodeListerSynced := func() bool {
+		return nodeReflector.LastSyncResourceVersion() != ""
+	}
+	return podLister, nodeLister, []cache.InformerSynced{podListerSynced, nodeListerSynced}
 }
 
 func createKubeClientOrDie(kubernetesUrl *url.URL) *kube_client.Clientset {
@@ -231,17 +446,51 @@ func createKubeClientOrDie(kubernetesUrl *url.URL) *kube_client.Clientset {
 	return kube_client.NewForConfigOrDie(kubeConfig)
 }
 
-func createDataProcessorsOrDie(kubernetesUrl *url.URL, podLister v1listers.PodLister, labelCopier *util.LabelCopier) []core.DataProcessor {
-	dataProcessors := []core.DataProcessor{
-		// Convert cumulative to rate
-		processors.NewRateCalculator(core.RateMetricsMapping),
+func createDataProcessorsOrDie(opt *options.HeapsterRunOptions, kubernetesUrl *url.URL, podLister v1listers.PodLister,
+	labelCopier *util.LabelCopier, metricSink *metricsink.MetricSink) []core.DataProcessor {
+	labeledMetricReductions, err := processors.ParseLabeledMetricReductions(opt.ReducedLabeledMetrics)
+	if err != nil {
+		glog.Fatalf("Failed to parse labeled metric reduction flags: %v", err)
+	}
+	namespaceAverages, err := processors.ParseWeightedAverages(opt.AveragedNamespaceMetrics)
+	if err != nil {
+		glog.Fatalf("Failed to parse namespace average flags: %v", err)
+	}
+
+	dataProcessors := []core.DataProcessor{}
+	var podSelectorFilter *processors.PodSelectorFilter
+	if opt.PodSelector != "" {
+		podSelectorFilter, err = processors.NewPodSelectorFilter(podLister, opt.PodSelector)
+		if err != nil {
+			glog.Fatalf("Failed to parse pod selector %q: %v", opt.PodSelector, err)
+		}
+		if !opt.PodSelectorAggregateAll {
+			// Drop the other pods before any processing, so that the aggregates only cover the selected ones
+			dataProcessors = append(dataProcessors, podSelectorFilter)
+		}
+	}
+	if opt.FillMissedScrapes > 0 {
+		// Carry forward cumulative values of metric sets whose scrape was missed
+		dataProcessors = append(dataProcessors, processors.NewMissedScrapeFiller(opt.MetricResolution, opt.FillMissedScrapes))
 	}
+	dataProcessors = append(dataProcessors,
+		// Mark counter resets, so that they are not converted to negative rates
+		processors.NewCounterResetDetector(),
+		// Convert cumulative to rate
+		processors.NewRateCalculator(core.RateMetricsMapping))
 
-	podBasedEnricher, err := processors.NewPodBasedEnricher(podLister, labelCopier)
+	// Metrics of deleted pods are evicted from the metric sink, so that the model API doesn't list them.
+	var evictor core.MetricSetEvictor
+	if metricSink != nil {
+		evictor = metricSink
+	}
+	podBasedEnricher, err := processors.NewPodBasedEnricher(podLister, labelCopier, evictor, opt.DeletedPodRetention, opt.LabelImageIds)
 	if err != nil {
 		glog.Fatalf("Failed to create PodBasedEnricher: %v", err)
 	}
 	dataProcessors = append(dataProcessors, podBasedEnricher)
+	// Label pods and containers with the QoS class of the pod, after the stubs of missing ones are created.
+	dataProcessors = append(dataProcessors, processors.NewQosClassEnricher(podLister))
 
 	namespaceBasedEnricher, err := processors.NewNamespaceBasedEnricher(kubernetesUrl)
 	if err != nil {
@@ -257,6 +506,10 @@ func createDataProcessorsOrDie(kubernetesUrl *url.URL, podLister v1listers.PodLi
 		core.MetricCpuLimit.Name,
 		core.MetricMemoryRequest.Name,
 		core.MetricMemoryLimit.Name,
+		core.MetricPodReadyContainers.Name,
+		core.MetricPodTotalContainers.Name,
+		core.MetricProcesses.Name,
+		core.MetricMemoryOomEvents.Name,
 	}
 
 	metricsToAggregateForNode := []string{
@@ -268,26 +521,108 @@ func createDataProcessorsOrDie(kubernetesUrl *url.URL, podLister v1listers.PodLi
 		core.MetricEphemeralStorageLimit.Name,
 	}
 
+	labeledMetricsToAggregate := []string{
+		core.MetricResourceRequest.Name,
+		core.MetricResourceLimit.Name,
+		core.MetricResourceRequestBytes.Name,
+		core.MetricResourceLimitBytes.Name,
+	}
+
+	if len(labeledMetricReductions) > 0 {
+		// Sum labeled metrics across the reduced labels, so that the sums are aggregated
+		reducer := processors.NewLabeledMetricReducer(labeledMetricReductions)
+		dataProcessors = append(dataProcessors, reducer)
+	reducedMetrics:
+		for _, name := range reducer.ReducedMetrics() {
+			for _, aggregated := range metricsToAggregate {
+				if aggregated == name {
+					continue reducedMetrics
+				}
+			}
+			metricsToAggregate = append(metricsToAggregate, name)
+		}
+	}
+
 	dataProcessors = append(dataProcessors,
-		processors.NewPodAggregator(),
+		processors.NewPodAggregator(opt.ExcludeInitContainers),
 		&processors.NamespaceAggregator{
-			MetricsToAggregate: metricsToAggregate,
+			MetricsToAggregate:        metricsToAggregate,
+			LabeledMetricsToAggregate: labeledMetricsToAggregate,
+			MetricsToAverage:          namespaceAverages,
 		},
 		&processors.NodeAggregator{
-			MetricsToAggregate: metricsToAggregateForNode,
+			MetricsToAggregate:        metricsToAggregateForNode,
+			LabeledMetricsToAggregate: labeledMetricsToAggregate,
 		},
 		&processors.ClusterAggregator{
-			MetricsToAggregate: metricsToAggregate,
+			MetricsToAggregate:        metricsToAggregate,
+			LabeledMetricsToAggregate: labeledMetricsToAggregate,
+		},
+		&processors.SystemContainerAggregator{
+			ContainerNames: opt.SystemContainers,
 		})
 
+	if opt.NodePodLabel != "" {
+		dataProcessors = append(dataProcessors, processors.NewNodePodLabelEnricher(podLister, opt.NodePodLabel, opt.MaxNodePodLabelValues))
+	}
+
+	if podSelectorFilter != nil && opt.PodSelectorAggregateAll {
+		// Drop the other pods once they have been aggregated
+		dataProcessors = append(dataProcessors, podSelectorFilter)
+	}
+
 	nodeAutoscalingEnricher, err := processors.NewNodeAutoscalingEnricher(kubernetesUrl, labelCopier)
 	if err != nil {
 		glog.Fatalf("Failed to create NodeAutoscalingEnricher: %v", err)
 	}
 	dataProcessors = append(dataProcessors, nodeAutoscalingEnricher)
+	// Sums the node capacities set by the NodeAutoscalingEnricher into the cluster metric set.
+	dataProcessors = append(dataProcessors, processors.NewClusterCapacityAggregator(core.ClusterCapacityMetricsMapping))
+	// Falls back to the node capacity set by the NodeAutoscalingEnricher.
+	dataProcessors = append(dataProcessors, &processors.MemorySaturationCalculator{})
 	return dataProcessors
 }
 
+func getDecimationPolicies(opt *options.HeapsterRunOptions) (map[string]sinks.DecimationPolicy, error) {
+	decimated, err := sinks.ParseDecimationPolicies(opt.DecimatedMetrics, false)
+	if err != nil {
+		return nil, err
+	}
+	changeBased, err := sinks.ParseDecimationPolicies(opt.ChangeBasedMetrics, true)
+	if err != nil {
+		return nil, err
+	}
+	families, err := sinks.ParseFamilyDecimationPolicies(opt.DecimatedMetricFamilies)
+	if err != nil {
+		return nil, err
+	}
+
+	policies := make(map[string]sinks.DecimationPolicy)
+	if opt.UnchangedMetricExportInterval > 0 {
+		policies = sinks.ChangeBasedPolicies(core.AllMetrics, opt.UnchangedMetricExportInterval)
+	}
+	for name, policy := range families {
+		policies[name] = policy
+	}
+	// Policies set explicitly for a metric override the default and the family ones.
+	for name, policy := range decimated {
+		policies[name] = policy
+	}
+	for name, policy := range changeBased {
+		if _, found := decimated[name]; found {
+			return nil, fmt.Errorf("metric %s is set in both --decimate_metric and --export_metric_on_change", name)
+		}
+		policies[name] = policy
+	}
+	return policies, nil
+}
+
+// redactedArgs returns the command line arguments with the credentials of the source and sink
+// URIs redacted, so that they can be logged and served.
+func redactedArgs() []string {
+	return flags.RedactArgs(os.Args, "source", "sink", "historical_source")
+}
+
 const (
 	minMetricsCount = 1
 	maxMetricsDelay = 3 * time.Minute
@@ -324,13 +659,18 @@ func getKubernetesAddress(args flags.Uris) (*url.URL, error) {
 	return nil, fmt.Errorf("No kubernetes source found.")
 }
 
-func getPodLister(kubeClient *kube_client.Clientset) (v1listers.PodLister, error) {
-	lw := cache.NewListWatchFromClient(kubeClient.CoreV1().RESTClient(), "pods", kube_api.NamespaceAll, fields.Everything())
+func getPodLister(kubeClient *kube_client.Clientset) (v1listers.PodLister, cache.InformerSynced, error) {
+	lw := util.NewStalenessTrackingListWatch("pods",
+		cache.NewListWatchFromClient(kubeClient.CoreV1().RESTClient(), "pods", kube_api.NamespaceAll, fields.Everything()))
 	store := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
 	podLister := v1listers.NewPodLister(store)
+	util.RegisterCacheSizeMetric("pods", store)
 	reflector := cache.NewReflector(lw, &kube_api.Pod{}, store, time.Hour)
 	go reflector.Run(wait.NeverStop)
-	return podLister, nil
+	hasSynced := func() bool {
+		return reflector.LastSyncResourceVersion() != ""
+	}
+	return podLister, hasSynced, nil
 }
 
 func validateFlags(opt *options.HeapsterRunOptions) error {
@@ -343,9 +683,98 @@ func validateFlags(opt *options.HeapsterRunOptions) error {
 	if len(opt.TLSClientCAFile) > 0 && len(opt.TLSCertFile) == 0 {
 		return fmt.Errorf("client cert authentication requires TLS certificate & key")
 	}
+	if opt.AuthorizeModelNamespaces && len(opt.TLSClientCAFile) == 0 {
+		return fmt.Errorf("namespace authorization requires client cert authentication")
+	}
+	// Clearing the cache wipes the model data, so it is only served to authenticated users.
+	if opt.EnableDebugClearCache && len(opt.TLSClientCAFile) == 0 {
+		return fmt.Errorf("the clear-cache debug endpoint requires client cert authentication")
+	}
+	// Triggered scrapes load the kubelets and write extra points to the sinks.
+	if opt.EnableDebugScrape && len(opt.TLSClientCAFile) == 0 {
+		return fmt.Errorf("the scrape debug endpoint requires client cert authentication")
+	}
+	switch opt.ModelBackend {
+	case metricsink.ModelBackendMemory:
+	case metricsink.ModelBackendHistorical:
+		if opt.HistoricalSource == "" {
+			return fmt.Errorf("model backend %s requires a historical source", opt.ModelBackend)
+		}
+		if opt.DisableMetricSink {
+			return fmt.Errorf("model backend %s requires the metric sink", opt.ModelBackend)
+		}
+	default:
+		return fmt.Errorf("unknown model backend %q, should be %s or %s", opt.ModelBackend,
+			metricsink.ModelBackendMemory, metricsink.ModelBackendHistorical)
+	}
+	if opt.HTTPReadTimeout < 0 {
+		return fmt.Errorf("http read timeout should not be negative - %v", opt.HTTPReadTimeout)
+	}
+	if opt.HTTPWriteTimeout < 0 {
+		return fmt.Errorf("http write timeout should not be negative - %v", opt.HTTPWriteTimeout)
+	}
+	if opt.HTTPIdleTimeout < 0 {
+		return fmt.Errorf("http idle timeout should not be negative - %v", opt.HTTPIdleTimeout)
+	}
+	if opt.FillMissedScrapes < 0 {
+		return fmt.Errorf("fill missed scrapes should not be negative - %d", opt.FillMissedScrapes)
+	}
+	if opt.ScrapeBackoffThreshold < 0 {
+		return fmt.Errorf("scrape backoff threshold should not be negative - %d", opt.ScrapeBackoffThreshold)
+	}
+	if opt.ScrapeBackoffThreshold > 0 && opt.MaxScrapeBackoff <= 0 {
+		return fmt.Errorf("max scrape backoff should be positive - %v", opt.MaxScrapeBackoff)
+	}
+	if _, err := core.ParseMergeOptions(opt.MergeConflictPolicy, opt.MergeConflictMetricPolicies); err != nil {
+		return fmt.Errorf("invalid merge conflict policy - %v", err)
+	}
+	if opt.MaxMetricPoints < 0 {
+		return fmt.Errorf("max metric points should not be negative - %d", opt.MaxMetricPoints)
+	}
+	if _, err := metricsink.ParseDownsamplingTiers(opt.MetricSinkDownsamplingTiers); err != nil {
+		return fmt.Errorf("invalid metric sink downsampling tiers - %v", err)
+	}
+	if opt.DeletedPodRetention < 0 {
+		return fmt.Errorf("deleted pod retention should not be negative - %v", opt.DeletedPodRetention)
+	}
+	if opt.MaxNodePodLabelValues < 0 {
+		return fmt.Errorf("max node pod label values should not be negative - %d", opt.MaxNodePodLabelValues)
+	}
 	return nil
 }
 
+// disableCollectionGroups stops the sources from collecting the metric groups turned off in the flags.
+func disableCollectionGroups(opt *options.HeapsterRunOptions) {
+	collected := map[string]bool{
+		core.CollectionGroupNetwork:      opt.CollectNetwork,
+		core.CollectionGroupDisk:         opt.CollectDisk,
+		core.CollectionGroupMemoryDetail: opt.CollectMemoryDetail,
+		core.CollectionGroupProcesses:    opt.CollectProcesses,
+	}
+	for group, enabled := range collected {
+		if enabled {
+			continue
+		}
+		if err := core.DisableCollectionGroup(group); err != nil {
+			glog.Fatalf("Failed to disable the collection of %s metrics: %v", group, err)
+		}
+		glog.Infof("Not collecting %s metrics", group)
+	}
+}
+
+// warnOnMetricSinkResolutionMismatch warns if the metric sink keeps less than two scrapes worth
+// of data, in which case the model API returns a single point per metric set.
+func warnOnMetricSinkResolutionMismatch(metricSink *metricsink.MetricSink, resolution time.Duration) {
+	if metricSink.ShortStoreDuration() < 2*resolution {
+		glog.Warningf("Metric resolution %v is longer than half of the metric sink short store duration %v, "+
+			"the model API will return at most one point for most metrics", resolution, metricSink.ShortStoreDuration())
+	}
+	if metricSink.LongStoreDuration() < 2*resolution {
+		glog.Warningf("Metric resolution %v is longer than half of the metric sink long store duration %v, "+
+			"the model API will return at most one point for all metrics", resolution, metricSink.LongStoreDuration())
+	}
+}
+
 func setMaxProcs(opt *options.HeapsterRunOptions) {
 	// Allow as many threads as we have cores unless the user specified a value.
 	var numProcs int
@@ -15,6 +15,8 @@
 package manager
 
 import (
+	"fmt"
+	"sync"
 	"time"
 
 	"k8s.io/heapster/metrics/core"
@@ -39,15 +41,66 @@ var (
 		},
 		[]string{"processor"},
 	)
+
+	// Timestamp of the newest data batch that all the sinks finished exporting, zero before the
+	// first one. The lag is computed when it is collected, so that it keeps growing if the
+	// pipeline stalls.
+	lastExportedBatchLock sync.Mutex
+	lastExportedBatch     time.Time
 )
 
 func init() {
 	prometheus.MustRegister(processorDuration)
+	prometheus.MustRegister(prometheus.NewGaugeFunc(
+		prometheus.GaugeOpts{
+			Namespace: "heapster",
+			Subsystem: "pipeline",
+			Name:      "lag_seconds",
+			Help:      "Time since the timestamp of the newest data batch exported by all sinks in seconds, 0 before the first export.",
+		},
+		func() float64 { return pipelineLag(time.Now()).Seconds() },
+	))
+}
+
+// batchExported records that all the sinks finished exporting the batch. Batches exported out of
+// order don't move the recorded timestamp back.
+func batchExported(data *core.DataBatch) {
+	lastExportedBatchLock.Lock()
+	defer lastExportedBatchLock.Unlock()
+	if data.Timestamp.After(lastExportedBatch) {
+		lastExportedBatch = data.Timestamp
+	}
+}
+
+// pipelineLag returns the time since the timestamp of the newest exported batch, 0 if none was.
+func pipelineLag(now time.Time) time.Duration {
+	lastExportedBatchLock.Lock()
+	defer lastExportedBatchLock.Unlock()
+	if lastExportedBatch.IsZero() {
+		return 0
+	}
+	return now.Sub(lastExportedBatch)
 }
 
 type Manager interface {
 	Start()
 	Stop()
+	// Scrapes, processes and exports the metrics of the last resolution long period right away,
+	// outside of the regular schedule, and returns a summary of the exported batch.
+	ScrapeNow() (*ScrapeSummary, error)
+}
+
+// ScrapeSummary describes the batch of a scrape triggered with ScrapeNow.
+type ScrapeSummary struct {
+	// The scraped period.
+	Start time.Time
+	End   time.Time
+	// Number of metric sets of each type after processing, e.g. the scraped nodes.
+	MetricSets map[string]int
+	// Number of metric values, including labeled metrics, after processing.
+	Metrics int
+	// Whether each sink took the batch, nil if the sink doesn't report it.
+	Sinks []core.SinkExportResult
 }
 
 type realManager struct {
@@ -57,8 +110,13 @@ type realManager struct {
 	resolution             time.Duration
 	scrapeOffset           time.Duration
 	stopChan               chan struct{}
+	stoppedChan            chan struct{}
 	housekeepSemaphoreChan chan struct{}
 	housekeepTimeout       time.Duration
+	// Serializes the processing and export of the scraped batches, since processors keep state
+	// between batches, e.g. the previous batch of the rate calculator. Scheduled and triggered
+	// scrapes still run in parallel.
+	processLock sync.Mutex
 }
 
 func NewManager(source core.MetricsSource, processors []core.DataProcessor, sink core.DataSink, resolution time.Duration,
@@ -70,6 +128,7 @@ func NewManager(source core.MetricsSource, processors []core.DataProcessor, sink
 		resolution:             resolution,
 		scrapeOffset:           scrapeOffset,
 		stopChan:               make(chan struct{}),
+		stoppedChan:            make(chan struct{}),
 		housekeepSemaphoreChan: make(chan struct{}, maxParallelism),
 		housekeepTimeout:       resolution / 2,
 	}
@@ -77,6 +136,9 @@ func NewManager(source core.MetricsSource, processors []core.DataProcessor, sink
 	for i := 0; i < maxParallelism; i++ {
 		manager.housekeepSemaphoreChan <- struct{}{}
 	}
+	if tracker, ok := sink.(core.DataSinkExportTracker); ok {
+		tracker.OnBatchExported(batchExported)
+	}
 
 	return &manager, nil
 }
@@ -85,8 +147,10 @@ func (rm *realManager) Start() {
 	go rm.Housekeep()
 }
 
+// Stop stops scraping and returns once the sink was stopped.
 func (rm *realManager) Stop() {
 	rm.stopChan <- struct{}{}
+	<-rm.stoppedChan
 }
 
 func (rm *realManager) Housekeep() {
@@ -102,6 +166,7 @@ func (rm *realManager) Housekeep() {
 			rm.housekeep(start, end)
 		case <-rm.stopChan:
 			rm.sink.Stop()
+			close(rm.stoppedChan)
 			return
 		}
 	}
@@ -125,26 +190,73 @@ func (rm *realManager) housekeep(start, end time.Time) {
 	go func(rm *realManager) {
 		// should always give back the semaphore
 		defer func() { rm.housekeepSemaphoreChan <- struct{}{} }()
-		data, err := rm.source.ScrapeMetrics(start, end)
-
-		if err != nil {
-			glog.Errorf("Error in scraping metrics for %s: %v", rm.source.Name(), err)
-			return
+		if _, _, err := rm.scrapeAndExport(start, end); err != nil {
+			glog.Errorf("%v", err)
 		}
+	}(rm)
+}
+
+func (rm *realManager) ScrapeNow() (*ScrapeSummary, error) {
+	end := time.Now()
+	start := end.Add(-rm.resolution)
 
-		for _, p := range rm.processors {
-			newData, err := process(p, data)
-			if err == nil {
-				data = newData
-			} else {
-				glog.Errorf("Error in processor: %v", err)
-				return
-			}
+	select {
+	case <-rm.housekeepSemaphoreChan:
+	case <-time.After(rm.housekeepTimeout):
+		return nil, fmt.Errorf("spent too long waiting for the running housekeeping to finish")
+	}
+	defer func() { rm.housekeepSemaphoreChan <- struct{}{} }()
+
+	glog.Infof("Scraping metrics from %s to %s on request", start, end)
+	data, sinkResults, err := rm.scrapeAndExport(start, end)
+	if err != nil {
+		return nil, err
+	}
+	summary := &ScrapeSummary{
+		Start:      start,
+		End:        end,
+		MetricSets: make(map[string]int),
+		Sinks:      sinkResults,
+	}
+	for _, ms := range data.MetricSets {
+		summary.MetricSets[ms.Labels[core.LabelMetricSetType.Key]]++
+		summary.Metrics += len(ms.MetricValues) + len(ms.LabeledMetrics)
+	}
+	return summary, nil
+}
+
+// scrapeAndExport scrapes the given period, runs the batch through the processors and exports it
+// to the sink. The results of the export are returned if the sink reports them.
+func (rm *realManager) scrapeAndExport(start, end time.Time) (*core.DataBatch, []core.SinkExportResult, error) {
+	data, err := rm.source.ScrapeMetrics(start, end)
+	if err != nil {
+		return nil, nil, fmt.Errorf("Error in scraping metrics for %s: %v", rm.source.Name(), err)
+	}
+
+	rm.processLock.Lock()
+	defer rm.processLock.Unlock()
+
+	for _, p := range rm.processors {
+		newData, err := process(p, data)
+		if err == nil {
+			data = newData
+		} else {
+			return nil, nil, fmt.Errorf("Error in processor %s: %v", p.Name(), err)
 		}
+	}
 
-		// Export data to sinks
+	// Export data to sinks
+	var sinkResults []core.SinkExportResult
+	if reporter, ok := rm.sink.(core.DataSinkExportReporter); ok {
+		sinkResults = reporter.ExportDataWithResults(data)
+	} else {
 		rm.sink.ExportData(data)
-	}(rm)
+	}
+	if _, ok := rm.sink.(core.DataSinkExportTracker); !ok {
+		// Other sinks return once they exported the batch.
+		batchExported(data)
+	}
+	return data, sinkResults, nil
 }
 
 func process(p core.DataProcessor, data *core.DataBatch) (*core.DataBatch, error) {
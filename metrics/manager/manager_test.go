@@ -15,10 +15,14 @@
 package manager
 
 import (
+	"sync/atomic"
 	"testing"
 	"time"
 
+	"github.com/stretchr/testify/assert"
+
 	"k8s.io/heapster/metrics/core"
+	"k8s.io/heapster/metrics/sinks"
 	"k8s.io/heapster/metrics/util"
 )
 
@@ -55,3 +59,86 @@ func TestThrottling(t *testing.T) {
 		t.Fatalf("Wrong number of exports executed: %d", sink.GetExportCount())
 	}
 }
+
+func TestScrapeNow(t *testing.T) {
+	source := util.NewDummyMetricsSource("src", time.Millisecond)
+	sink := util.NewDummySink("sink", time.Millisecond)
+	sinkManager, _ := sinks.NewDataSinkManager([]core.DataSink{sink}, time.Second, time.Second)
+	processor := util.NewDummyDataProcessor(time.Millisecond)
+
+	manager, _ := NewManager(source, []core.DataProcessor{processor}, sinkManager, time.Minute, time.Millisecond, 1)
+	summary, err := manager.ScrapeNow()
+	assert.NoError(t, err)
+	assert.Equal(t, time.Minute, summary.End.Sub(summary.Start))
+	assert.Equal(t, map[string]int{"": 1}, summary.MetricSets)
+	assert.Equal(t, 0, summary.Metrics)
+	assert.Equal(t, []core.SinkExportResult{{Sink: "sink", Accepted: true}}, summary.Sinks)
+
+	time.Sleep(100 * time.Millisecond)
+	assert.Equal(t, 1, sink.GetExportCount())
+}
+
+func TestPipelineLag(t *testing.T) {
+	lastExportedBatchLock.Lock()
+	lastExportedBatch = time.Time{}
+	lastExportedBatchLock.Unlock()
+
+	source := util.NewDummyMetricsSource("src", time.Millisecond)
+	sink := util.NewDummySink("sink", 200*time.Millisecond)
+	sinkManager, _ := sinks.NewDataSinkManager([]core.DataSink{sink}, time.Second, time.Second)
+	processor := util.NewDummyDataProcessor(time.Millisecond)
+
+	manager, _ := NewManager(source, []core.DataProcessor{processor}, sinkManager, time.Minute, time.Millisecond, 1)
+	end := time.Now().Add(-time.Hour)
+	_, _, err := manager.(*realManager).scrapeAndExport(end.Add(-time.Minute), end)
+	assert.NoError(t, err)
+
+	// The batch only counts once the sink finished exporting it.
+	assert.Equal(t, time.Duration(0), pipelineLag(time.Now()))
+	time.Sleep(400 * time.Millisecond)
+	now := time.Now()
+	assert.Equal(t, now.Sub(end), pipelineLag(now))
+	// The lag keeps growing until a newer batch is exported.
+	assert.Equal(t, now.Add(time.Minute).Sub(end), pipelineLag(now.Add(time.Minute)))
+}
+
+// overlapDetectingProcessor records whether it processed several batches at the same time.
+type overlapDetectingProcessor struct {
+	running    int32
+	overlapped int32
+	processed  int32
+}
+
+func (this *overlapDetectingProcessor) Name() string {
+	return "overlap_detector"
+}
+
+func (this *overlapDetectingProcessor) Process(data *core.DataBatch) (*core.DataBatch, error) {
+	if atomic.AddInt32(&this.running, 1) > 1 {
+		atomic.StoreInt32(&this.overlapped, 1)
+	}
+	time.Sleep(100 * time.Millisecond)
+	atomic.AddInt32(&this.running, -1)
+	atomic.AddInt32(&this.processed, 1)
+	return data, nil
+}
+
+func TestScrapeNowAlongsideHousekeep(t *testing.T) {
+	source := util.NewDummyMetricsSource("src", time.Millisecond)
+	sink := util.NewDummySink("sink", time.Millisecond)
+	processor := &overlapDetectingProcessor{}
+
+	manager, _ := NewManager(source, []core.DataProcessor{processor}, sink, time.Minute, time.Millisecond, DefaultMaxParallelism)
+	rm := manager.(*realManager)
+	end := time.Now().Truncate(time.Minute)
+	rm.housekeep(end.Add(-time.Minute), end)
+	_, err := manager.ScrapeNow()
+	assert.NoError(t, err)
+
+	for i := 0; i < 50 && atomic.LoadInt32(&processor.processed) < 2; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	assert.Equal(t, int32(2), atomic.LoadInt32(&processor.processed))
+	assert.Equal(t, int32(0), atomic.LoadInt32(&processor.overlapped))
+	assert.Equal(t, 2, sink.GetExportCount())
+}
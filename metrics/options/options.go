@@ -21,6 +21,7 @@ import (
 
 	genericoptions "k8s.io/apiserver/pkg/server/options"
 	"k8s.io/heapster/common/flags"
+	"k8s.io/heapster/metrics/core"
 )
 
 type HeapsterRunOptions struct {
@@ -33,25 +34,64 @@ type HeapsterRunOptions struct {
 	// Only to be used to for testing
 	DisableAuthForTesting bool
 
-	MetricResolution      time.Duration
-	EnableAPIServer       bool
-	Port                  int
-	Ip                    string
-	MaxProcs              int
-	TLSCertFile           string
-	TLSKeyFile            string
-	TLSClientCAFile       string
-	AllowedUsers          string
-	Sources               flags.Uris
-	Sinks                 flags.Uris
-	HistoricalSource      string
-	Version               bool
-	LabelSeparator        string
-	IgnoredLabels         []string
-	StoredLabels          []string
-	DisableMetricExport   bool
-	SinkExportDataTimeout time.Duration
-	DisableMetricSink     bool
+	MetricResolution              time.Duration
+	EnableAPIServer               bool
+	Port                          int
+	Ip                            string
+	MaxProcs                      int
+	TLSCertFile                   string
+	TLSKeyFile                    string
+	TLSClientCAFile               string
+	AllowedUsers                  string
+	Sources                       flags.Uris
+	Sinks                         flags.Uris
+	HistoricalSource              string
+	ModelBackend                  string
+	Version                       bool
+	SelfTest                      bool
+	EnableDebugScrape             bool
+	EnableDebugClearCache         bool
+	LabelSeparator                string
+	IgnoredLabels                 []string
+	StoredLabels                  []string
+	ReducedLabeledMetrics         []string
+	AveragedNamespaceMetrics      []string
+	SystemContainers              []string
+	PodSelector                   string
+	PodSelectorAggregateAll       bool
+	CollectNetwork                bool
+	CollectDisk                   bool
+	CollectMemoryDetail           bool
+	CollectProcesses              bool
+	LabelScrapesByNode            bool
+	DisableMetricExport           bool
+	SinkExportDataTimeout         time.Duration
+	DisableMetricSink             bool
+	RequireSinks                  bool
+	DecimatedMetrics              []string
+	DecimatedMetricFamilies       []string
+	ChangeBasedMetrics            []string
+	UnchangedMetricExportInterval time.Duration
+	AuthorizeModelNamespaces      bool
+	MaxModelRequests              int
+	DeletedPodRetention           time.Duration
+	MaxMetricPoints               int
+	MetricSinkWriteBufferInterval time.Duration
+	MetricSinkDownsamplingTiers   string
+	FillMissedScrapes             int
+	ScrapeBackoffThreshold        int
+	MaxScrapeBackoff              time.Duration
+	EmitUpMetrics                 bool
+	MergeConflictPolicy           string
+	MergeConflictMetricPolicies   []string
+	MetricTimestampSource         string
+	NodePodLabel                  string
+	MaxNodePodLabelValues         int
+	LabelImageIds                 bool
+	ExcludeInitContainers         bool
+	HTTPReadTimeout               time.Duration
+	HTTPWriteTimeout              time.Duration
+	HTTPIdleTimeout               time.Duration
 }
 
 func NewHeapsterRunOptions() *HeapsterRunOptions {
@@ -77,6 +117,9 @@ func (h *HeapsterRunOptions) AddFlags(fs *pflag.FlagSet) {
 	fs.BoolVar(&h.EnableAPIServer, "api-server", false, "Enable API server for the Metrics API. "+
 		"If set, the Metrics API will be served on --insecure-port (internally) and --secure-port (externally).")
 	fs.IntVar(&h.Port, "heapster-port", 8082, "port used by the Heapster-specific APIs")
+	fs.DurationVar(&h.HTTPReadTimeout, "http_read_timeout", 30*time.Second, "maximum duration for reading a request to the Heapster-specific APIs, including the body. 0 for no timeout")
+	fs.DurationVar(&h.HTTPWriteTimeout, "http_write_timeout", 2*time.Minute, "maximum duration for writing a response of the Heapster-specific APIs. 0 for no timeout")
+	fs.DurationVar(&h.HTTPIdleTimeout, "http_idle_timeout", 2*time.Minute, "maximum duration a keep-alive connection to the Heapster-specific APIs is kept open between requests. 0 to use the read timeout")
 
 	fs.StringVar(&h.Ip, "listen_ip", "", "IP to listen on, defaults to all IPs")
 	fs.IntVar(&h.MaxProcs, "max_procs", 0, "max number of CPUs that can be used simultaneously. Less than 1 for default (number of cores)")
@@ -85,11 +128,47 @@ func (h *HeapsterRunOptions) AddFlags(fs *pflag.FlagSet) {
 	fs.StringVar(&h.TLSClientCAFile, "tls_client_ca", "", "file containing TLS client CA for client cert validation")
 	fs.StringVar(&h.AllowedUsers, "allowed_users", "", "comma-separated list of allowed users")
 	fs.StringVar(&h.HistoricalSource, "historical_source", "", "which source type to use for the historical API (should be exactly the same as one of the sink URIs), or empty to disable the historical API")
+	fs.StringVar(&h.ModelBackend, "model_backend", "memory", "storage queried by the model API: memory to use only the metric sink, or historical to query the --historical_source for time ranges starting before the data kept by the metric sink")
 	fs.BoolVar(&h.Version, "version", false, "print version info and exit")
+	fs.BoolVar(&h.EnableDebugScrape, "enable_debug_scrape", false, "serve POST /api/v1/debug/scrape, which scrapes, processes and exports the metrics right away and returns a summary of the batch, for debugging. Requires --tls_client_ca")
+	fs.BoolVar(&h.EnableDebugClearCache, "enable_debug_clear_cache", false, "serve POST /api/v1/debug/clear-cache, which drops the metrics stored by the metric sink for the model API. Requires --tls_client_ca")
+	fs.BoolVar(&h.SelfTest, "self_test", false, "scrape a single node once at startup, run the metrics through the processors, log a summary and exit, with a nonzero status if nothing was produced")
 	fs.StringVar(&h.LabelSeparator, "label_separator", ",", "separator used for joining labels")
 	fs.StringSliceVar(&h.IgnoredLabels, "ignore_label", []string{}, "ignore this label when joining labels")
+	fs.StringSliceVar(&h.ReducedLabeledMetrics, "reduce_labeled_metric", []string{}, "sum this labeled metric across the values of a label into a metric without the label, before aggregating pods, namespaces and the cluster (metric=label, e.g. filesystem/usage=resource_id)")
+	fs.StringSliceVar(&h.AveragedNamespaceMetrics, "namespace_average_metric", []string{}, "average this metric across the pods of a namespace instead of summing it, as needed for ratios, weighting pods equally (metric) or by another metric (metric=weight_metric, e.g. cpu/usage_ratio=cpu/request)")
+	fs.StringSliceVar(&h.SystemContainers, "system_container", core.DefaultSystemContainers, "name of a system container whose cpu and memory usage is summed into the node/system_cpu and node/system_memory metrics of its node")
+	fs.StringVar(&h.PodSelector, "pod_selector", "", "only export the metrics of the pods matching this label selector, and of their containers (e.g. team=monitoring,tier!=test). Empty for all pods")
+	fs.StringVar(&h.NodePodLabel, "node_pod_label", "", "label the node metrics with the values of this pod label among the pods of the node, comma-separated in the pod_label_values label (e.g. workload). Empty to disable")
+	fs.IntVar(&h.MaxNodePodLabelValues, "max_node_pod_label_values", 5, "maximum number of values in the pod_label_values label of a node set with --node_pod_label, keeping the values of the most pods. 0 for unlimited")
+	fs.BoolVar(&h.ExcludeInitContainers, "exclude_init_containers", false, "leave the init containers, labeled with container_type=init, out of the pod metrics aggregated from their containers")
+	fs.BoolVar(&h.LabelImageIds, "label_image_ids", false, "label the container metrics with the ID of their image from the container status (image_id), which changes with every image version")
+	fs.BoolVar(&h.PodSelectorAggregateAll, "pod_selector_aggregate_all", false, "aggregate all pods into the namespace, node and cluster metrics, instead of only the pods matching --pod_selector")
+	fs.BoolVar(&h.CollectNetwork, "collect_network", true, "collect the network metrics (network/rx, network/tx, their errors, rates and per-interface values) from the sources")
+	fs.BoolVar(&h.CollectDisk, "collect_disk", true, "collect the filesystem and disk io metrics from the sources")
+	fs.BoolVar(&h.CollectMemoryDetail, "collect_memory_detail", true, "collect the detailed memory metrics (memory/rss, memory/cache, page faults and their rates) from the sources")
+	fs.BoolVar(&h.CollectProcesses, "collect_processes", false, "collect the container/processes metric, the number of tasks of every container, from the sources; requires cadvisor's load reader, otherwise it is always zero")
+	fs.BoolVar(&h.LabelScrapesByNode, "label_scrapes_by_node", false, "label the heapster_kubelet_node_scrape_* metrics with the node name, which adds series for every node")
 	fs.StringSliceVar(&h.StoredLabels, "store_label", []string{}, "store this label separately from joined labels with the same name (name) or with different name (newName=name)")
 	fs.BoolVar(&h.DisableMetricExport, "disable_export", false, "Disable exporting metrics in api/v1/metric-export")
 	fs.DurationVar(&h.SinkExportDataTimeout, "sink_export_data_timeout", 20*time.Second, "Timeout for exporting data to a sink")
 	fs.BoolVar(&h.DisableMetricSink, "disable_metric_sink", false, "Disable metric sink")
+	fs.BoolVar(&h.RequireSinks, "require_sinks", false, "exit on startup when a sink able to check its connection (influxdb, graphite, riemann, wavefront, elasticsearch, opentsdb, kafka, librato) can't connect to its backend, instead of logging the error and retrying at every export. Other sinks are not checked")
+	fs.DurationVar(&h.DeletedPodRetention, "deleted_pod_retention", 15*time.Minute, "for how long metrics of deleted pods are kept in the metric sink before being evicted")
+	fs.IntVar(&h.FillMissedScrapes, "fill_missed_scrapes", 0, "carry forward the last cumulative values of metric sets missing from up to this many consecutive scrapes, marked as interpolated. 0 to disable")
+	fs.IntVar(&h.ScrapeBackoffThreshold, "scrape_backoff_threshold", 0, "after this many consecutive failed scrapes of a node, skip it for a number of scrape intervals that doubles with every further failure, until a scrape succeeds. 0 to disable")
+	fs.DurationVar(&h.MaxScrapeBackoff, "max_scrape_backoff", 5*time.Minute, "maximum time a failing node is not scraped when --scrape_backoff_threshold is set")
+	fs.StringVar(&h.MetricTimestampSource, "metric_timestamp_source", core.TimestampSourceSample, "timestamp of the points exported to all sinks and served by the model API: sample, the time the source sampled the metric set, or scrape, the end of the scrape interval")
+	fs.StringVar(&h.MergeConflictPolicy, "merge_conflict_policy", string(core.MergeConflictPreferLast), "which value to keep when more than one source returns the same metric of a metric set: error to fail the scrape, prefer-first, prefer-last or sum. Conflicts are counted in heapster_merge_conflicts_total")
+	fs.StringSliceVar(&h.MergeConflictMetricPolicies, "merge_conflict_metric_policy", []string{}, "override --merge_conflict_policy for this metric (metric=policy, e.g. cpu/usage=sum)")
+	fs.BoolVar(&h.EmitUpMetrics, "emit_up_metrics", false, "set the node/up and pod/up metrics of every node and pod to 1 when the scrape of its node succeeded and to 0 when it failed, so that a failed scrape can be told apart from zero usage")
+	fs.IntVar(&h.MaxMetricPoints, "max_metric_points", 0, "maximum number of points kept in the long term store of the metric sink across all series, evicting the least recently queried series first. 0 for unlimited")
+	fs.StringVar(&h.MetricSinkDownsamplingTiers, "metric_sink_downsampling_tiers", "", "keep the cpu and memory usage dropped from the 15 minute store of the metric sink averaged over coarser buckets, as a comma-separated list of resolution:retention tiers from the finest to the coarsest, e.g. 1m:2h,10m:24h. Empty to drop them")
+	fs.DurationVar(&h.MetricSinkWriteBufferInterval, "metric_sink_write_buffer_interval", 0, "buffer the batches exported to the metric sink and apply them once per interval, so that they contend less with the model API reads, at the cost of the model lagging by up to the interval. 0 to apply them right away")
+	fs.BoolVar(&h.AuthorizeModelNamespaces, "authorize_model_namespaces", false, "serve namespace metrics from the model API only to users allowed to get pods in the namespace, as checked with a SubjectAccessReview. Requires --tls_client_ca")
+	fs.IntVar(&h.MaxModelRequests, "max_model_requests", 0, "maximum number of model API requests served concurrently, further requests are rejected with 429 Too Many Requests. 0 for unlimited")
+	fs.StringSliceVar(&h.DecimatedMetrics, "decimate_metric", []string{}, "export this metric to sinks at most once per interval (metric=interval, e.g. memory/limit=5m)")
+	fs.StringSliceVar(&h.DecimatedMetricFamilies, "decimate_metric_family", []string{}, "export the metrics of this family (cpu, memory, network or filesystem) to sinks at most once per interval (family=interval, e.g. network=5m), except the limits and requests taken from the pod specs; --decimate_metric and --export_metric_on_change take precedence for single metrics")
+	fs.StringSliceVar(&h.ChangeBasedMetrics, "export_metric_on_change", []string{}, "export this metric to sinks only when its value changes, or at least once per interval (metric=interval, e.g. cpu/limit=10m)")
+	fs.DurationVar(&h.UnchangedMetricExportInterval, "unchanged_metric_export_interval", 0, "if set, rarely changing metrics (requests and limits) are exported to sinks only when their value changes, or at least once per this interval")
 }
@@ -18,12 +18,22 @@ import "k8s.io/heapster/metrics/core"
 
 type ClusterAggregator struct {
 	MetricsToAggregate []string
+	// Names of the labeled metrics to sum per label values.
+	LabeledMetricsToAggregate []string
 }
 
 func (this *ClusterAggregator) Name() string {
 	return "cluster_aggregator"
 }
 
+func (this *ClusterAggregator) RequiredLabels() []string {
+	return []string{core.LabelMetricSetType.Key + "=" + core.MetricSetTypeNamespace}
+}
+
+func (this *ClusterAggregator) ProducedLabels() []string {
+	return []string{core.LabelMetricSetType.Key + "=" + core.MetricSetTypeCluster}
+}
+
 func (this *ClusterAggregator) Process(batch *core.DataBatch) (*core.DataBatch, error) {
 	clusterKey := core.ClusterKey()
 	cluster := clusterMetricSet()
@@ -33,6 +43,9 @@ func (this *ClusterAggregator) Process(batch *core.DataBatch) (*core.DataBatch,
 			if err := aggregate(metricSet, cluster, this.MetricsToAggregate); err != nil {
 				return nil, err
 			}
+			if err := aggregateLabeled(metricSet, cluster, this.LabeledMetricsToAggregate); err != nil {
+				return nil, err
+			}
 		}
 	}
 	batch.MetricSets[clusterKey] = cluster
@@ -0,0 +1,72 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processors
+
+import "k8s.io/heapster/metrics/core"
+
+// Sums the capacity metrics of the nodes into the cluster metric set, so that the cluster usage
+// can be compared with its capacity. Runs after the NodeAutoscalingEnricher, which sets them.
+type ClusterCapacityAggregator struct {
+	// Maps from the name of the node metric to the cluster metric it is summed into.
+	MetricsMapping map[string]core.Metric
+}
+
+func (this *ClusterCapacityAggregator) Name() string {
+	return "cluster_capacity_aggregator"
+}
+
+func (this *ClusterCapacityAggregator) RequiredLabels() []string {
+	return []string{core.LabelMetricSetType.Key + "=" + core.MetricSetTypeNode}
+}
+
+func (this *ClusterCapacityAggregator) ProducedLabels() []string {
+	return []string{core.LabelMetricSetType.Key + "=" + core.MetricSetTypeCluster}
+}
+
+func (this *ClusterCapacityAggregator) Process(batch *core.DataBatch) (*core.DataBatch, error) {
+	clusterKey := core.ClusterKey()
+	cluster, found := batch.MetricSets[clusterKey]
+	if !found {
+		cluster = clusterMetricSet()
+		batch.MetricSets[clusterKey] = cluster
+	}
+	sums := make(map[string]float64)
+	for _, metricSet := range batch.MetricSets {
+		if metricSet.Labels[core.LabelMetricSetType.Key] != core.MetricSetTypeNode {
+			continue
+		}
+		for nodeMetric, clusterMetric := range this.MetricsMapping {
+			value, found := metricSet.MetricValues[nodeMetric]
+			if !found {
+				continue
+			}
+			sums[clusterMetric.Name] += value.GetFloatValue()
+		}
+	}
+	for name, sum := range sums {
+		cluster.MetricValues[name] = core.MetricValue{
+			MetricType: core.MetricGauge,
+			ValueType:  core.ValueFloat,
+			FloatValue: sum,
+		}
+	}
+	return batch, nil
+}
+
+func NewClusterCapacityAggregator(mapping map[string]core.Metric) *ClusterCapacityAggregator {
+	return &ClusterCapacityAggregator{
+		MetricsMapping: mapping,
+	}
+}
@@ -0,0 +1,74 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processors
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"k8s.io/heapster/metrics/core"
+)
+
+func TestClusterCapacityAggregate(t *testing.T) {
+	nodeMetricSet := func(name string, cpu, memory float64) *core.MetricSet {
+		return &core.MetricSet{
+			Labels: map[string]string{
+				core.LabelMetricSetType.Key: core.MetricSetTypeNode,
+				core.LabelNodename.Key:      name,
+			},
+			MetricValues: map[string]core.MetricValue{
+				core.MetricNodeCpuCapacity.Name:    {ValueType: core.ValueFloat, MetricType: core.MetricGauge, FloatValue: cpu},
+				core.MetricNodeMemoryCapacity.Name: {ValueType: core.ValueFloat, MetricType: core.MetricGauge, FloatValue: memory},
+			},
+		}
+	}
+	batch := core.DataBatch{
+		Timestamp: time.Now(),
+		MetricSets: map[string]*core.MetricSet{
+			core.NodeKey("n1"): nodeMetricSet("n1", 2000, 4e9),
+			core.NodeKey("n2"): nodeMetricSet("n2", 4000, 8e9),
+			// Namespaces aren't summed, even with the same metrics.
+			core.NamespaceKey("ns1"): {
+				Labels: map[string]string{
+					core.LabelMetricSetType.Key: core.MetricSetTypeNamespace,
+				},
+				MetricValues: map[string]core.MetricValue{
+					core.MetricNodeCpuCapacity.Name: {ValueType: core.ValueFloat, MetricType: core.MetricGauge, FloatValue: 100},
+				},
+			},
+			core.ClusterKey(): {
+				Labels: map[string]string{
+					core.LabelMetricSetType.Key: core.MetricSetTypeCluster,
+				},
+				MetricValues: map[string]core.MetricValue{
+					core.MetricCpuUsageRate.Name: {ValueType: core.ValueInt64, MetricType: core.MetricGauge, IntValue: 1500},
+				},
+			},
+		},
+	}
+	processor := NewClusterCapacityAggregator(core.ClusterCapacityMetricsMapping)
+	result, err := processor.Process(&batch)
+	assert.NoError(t, err)
+	cluster := result.MetricSets[core.ClusterKey()]
+
+	assert.Equal(t, float64(6000), cluster.MetricValues[core.MetricClusterCpuCapacity.Name].FloatValue)
+	assert.Equal(t, float64(12e9), cluster.MetricValues[core.MetricClusterMemoryCapacity.Name].FloatValue)
+	assert.Equal(t, int64(1500), cluster.MetricValues[core.MetricCpuUsageRate.Name].IntValue)
+	// No node has the allocatable metrics.
+	_, found := cluster.MetricValues[core.MetricClusterCpuAllocatable.Name]
+	assert.False(t, found)
+}
@@ -0,0 +1,72 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processors
+
+import (
+	"github.com/golang/glog"
+	"k8s.io/heapster/metrics/core"
+)
+
+// CounterResetDetector marks cumulative metric values that decreased since the previous batch,
+// e.g. after a container restart, so that rates are computed from the new baseline.
+type CounterResetDetector struct {
+	// Previous cumulative values by metric set key and then metric key.
+	previousValues map[string]map[string]int64
+}
+
+func (this *CounterResetDetector) Name() string {
+	return "counter_reset_detector"
+}
+
+func (this *CounterResetDetector) Process(batch *core.DataBatch) (*core.DataBatch, error) {
+	// Only values from the latest batch are kept, so metric sets that disappeared are forgotten.
+	values := make(map[string]map[string]int64)
+	for key, ms := range batch.MetricSets {
+		previous := this.previousValues[key]
+		current := make(map[string]int64)
+		for name, value := range ms.MetricValues {
+			if value.MetricType != core.MetricCumulative || value.ValueType != core.ValueInt64 {
+				continue
+			}
+			if old, found := previous[name]; found && value.IntValue < old {
+				glog.V(4).Infof("Counter reset of %s in %s: %d -> %d", name, key, old, value.IntValue)
+				value.Reset = true
+				ms.MetricValues[name] = value
+			}
+			current[name] = value.IntValue
+		}
+		for i := range ms.LabeledMetrics {
+			metric := &ms.LabeledMetrics[i]
+			if metric.MetricType != core.MetricCumulative || metric.ValueType != core.ValueInt64 {
+				continue
+			}
+			name := core.LabeledMetricKey(metric)
+			if old, found := previous[name]; found && metric.IntValue < old {
+				glog.V(4).Infof("Counter reset of %s in %s: %d -> %d", name, key, old, metric.IntValue)
+				metric.Reset = true
+			}
+			current[name] = metric.IntValue
+		}
+		values[key] = current
+	}
+	this.previousValues = values
+	return batch, nil
+}
+
+func NewCounterResetDetector() *CounterResetDetector {
+	return &CounterResetDetector{
+		previousValues: make(map[string]map[string]int64),
+	}
+}
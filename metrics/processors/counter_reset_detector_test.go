@@ -0,0 +1,91 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processors
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"k8s.io/heapster/metrics/core"
+)
+
+func cpuUsageBatch(key string, start, timestamp time.Time, usage int64) *core.DataBatch {
+	return &core.DataBatch{
+		Timestamp: timestamp,
+		MetricSets: map[string]*core.MetricSet{
+			key: {
+				CollectionStartTime: start,
+				ScrapeTime:          timestamp,
+				Labels: map[string]string{
+					core.LabelMetricSetType.Key: core.MetricSetTypePodContainer,
+				},
+				MetricValues: map[string]core.MetricValue{
+					core.MetricCpuUsage.MetricDescriptor.Name: {
+						ValueType:  core.ValueInt64,
+						MetricType: core.MetricCumulative,
+						IntValue:   usage,
+					},
+				},
+				LabeledMetrics: []core.LabeledMetric{
+					{
+						Name:   core.MetricDiskIORead.MetricDescriptor.Name,
+						Labels: map[string]string{core.LabelResourceID.Key: "sda"},
+						MetricValue: core.MetricValue{
+							ValueType:  core.ValueInt64,
+							MetricType: core.MetricCumulative,
+							IntValue:   usage,
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+func TestCounterResetDetector(t *testing.T) {
+	key := core.PodContainerKey("ns1", "pod1", "c")
+	now := time.Now()
+	start := now.Add(-time.Hour)
+	detector := NewCounterResetDetector()
+	rateCalculator := NewRateCalculator(core.RateMetricsMapping)
+
+	for _, batch := range []*core.DataBatch{
+		cpuUsageBatch(key, start, now.Add(-2*time.Minute), 100*1e9),
+		cpuUsageBatch(key, start, now.Add(-time.Minute), 160*1e9),
+	} {
+		detector.Process(batch)
+		rateCalculator.Process(batch)
+		assert.False(t, batch.MetricSets[key].MetricValues[core.MetricCpuUsage.Name].Reset)
+	}
+
+	// The counter is reset and increases by 30s of cpu time in a minute.
+	current := cpuUsageBatch(key, start, now, 30*1e9)
+	detector.Process(current)
+	rateCalculator.Process(current)
+
+	ms := current.MetricSets[key]
+	assert.True(t, ms.MetricValues[core.MetricCpuUsage.Name].Reset)
+	assert.Equal(t, int64(500), ms.MetricValues[core.MetricCpuUsageRate.Name].IntValue)
+	for _, metric := range ms.LabeledMetrics {
+		switch metric.Name {
+		case core.MetricDiskIORead.Name:
+			assert.True(t, metric.Reset)
+		case core.MetricDiskIOReadRate.Name:
+			assert.InEpsilon(t, 5e8, metric.FloatValue, 0.01)
+		}
+	}
+}
@@ -17,6 +17,7 @@ package processors
 import (
 	"fmt"
 
+	"k8s.io/apimachinery/pkg/util/sets"
 	"k8s.io/heapster/metrics/core"
 )
 
@@ -46,3 +47,37 @@ func aggregate(src, dst *core.MetricSet, metricsToAggregate []string) error {
 	}
 	return nil
 }
+
+// aggregateLabeled sums the labeled metrics of src with the given names into the labeled
+// metrics of dst with the same name and labels.
+func aggregateLabeled(src, dst *core.MetricSet, labeledMetricsToAggregate []string) error {
+	names := sets.NewString(labeledMetricsToAggregate...)
+	indices := make(map[string]int, len(dst.LabeledMetrics))
+	for i := range dst.LabeledMetrics {
+		indices[core.LabeledMetricKey(&dst.LabeledMetrics[i])] = i
+	}
+	for _, metric := range src.LabeledMetrics {
+		if !names.Has(metric.Name) {
+			continue
+		}
+		key := core.LabeledMetricKey(&metric)
+		i, found := indices[key]
+		if !found {
+			indices[key] = len(dst.LabeledMetrics)
+			dst.LabeledMetrics = append(dst.LabeledMetrics, metric)
+			continue
+		}
+		aggregated := &dst.LabeledMetrics[i]
+		if aggregated.ValueType != metric.ValueType {
+			return fmt.Errorf("Aggregator: type not supported in %s", metric.Name)
+		}
+		if aggregated.ValueType == core.ValueInt64 {
+			aggregated.IntValue += metric.IntValue
+		} else if aggregated.ValueType == core.ValueFloat {
+			aggregated.FloatValue += metric.FloatValue
+		} else {
+			return fmt.Errorf("Aggregator: type not supported in %s", metric.Name)
+		}
+	}
+	return nil
+}
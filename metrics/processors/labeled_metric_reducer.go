@@ -0,0 +1,132 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processors
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"k8s.io/heapster/metrics/core"
+)
+
+// LabeledMetricReducer sums labeled metrics across the values of one of their labels, e.g. the
+// filesystem metrics of all devices of a container, so that the aggregators can sum them further.
+// The sum of labeled metrics without other labels is stored as a plain metric value, while the
+// labeled metrics with other labels are summed into labeled metrics with only the other labels.
+// The original labeled metrics are kept.
+type LabeledMetricReducer struct {
+	// Label to sum across by metric name.
+	reducedLabels map[string]string
+}
+
+func (this *LabeledMetricReducer) Name() string {
+	return "labeled_metric_reducer"
+}
+
+func (this *LabeledMetricReducer) Process(batch *core.DataBatch) (*core.DataBatch, error) {
+	for _, ms := range batch.MetricSets {
+		// Sums by the name and the other labels of the summed metrics.
+		sums := make(map[string]*core.LabeledMetric)
+		keys := make([]string, 0)
+		for _, metric := range ms.LabeledMetrics {
+			label, found := this.reducedLabels[metric.Name]
+			if !found {
+				continue
+			}
+			if _, found := metric.Labels[label]; !found {
+				continue
+			}
+			otherLabels := make(map[string]string, len(metric.Labels)-1)
+			for key, value := range metric.Labels {
+				if key != label {
+					otherLabels[key] = value
+				}
+			}
+			reduced := core.LabeledMetric{Name: metric.Name, Labels: otherLabels}
+			key := core.LabeledMetricKey(&reduced)
+			sum, found := sums[key]
+			if !found {
+				reduced.MetricType = metric.MetricType
+				reduced.ValueType = metric.ValueType
+				sum = &reduced
+				sums[key] = sum
+				keys = append(keys, key)
+			}
+			sum.IntValue += metric.IntValue
+			sum.FloatValue += metric.FloatValue
+		}
+		if len(keys) == 0 {
+			continue
+		}
+		// Like plain metric values, labeled metrics already in the set with the name and labels
+		// of a sum are kept instead of the sum, so that no labeled metric is emitted twice.
+		existing := make(map[string]bool, len(ms.LabeledMetrics))
+		for i := range ms.LabeledMetrics {
+			existing[core.LabeledMetricKey(&ms.LabeledMetrics[i])] = true
+		}
+		// Sorted, so that the added labeled metrics are in a stable order.
+		sort.Strings(keys)
+		for _, key := range keys {
+			sum := sums[key]
+			if len(sum.Labels) > 0 {
+				if !existing[key] {
+					ms.LabeledMetrics = append(ms.LabeledMetrics, *sum)
+				}
+				continue
+			}
+			if _, found := ms.MetricValues[sum.Name]; found {
+				continue
+			}
+			if ms.MetricValues == nil {
+				ms.MetricValues = make(map[string]core.MetricValue)
+			}
+			ms.MetricValues[sum.Name] = sum.MetricValue
+		}
+	}
+	return batch, nil
+}
+
+// ReducedMetrics returns the sorted names of the reduced metrics.
+func (this *LabeledMetricReducer) ReducedMetrics() []string {
+	result := make([]string, 0, len(this.reducedLabels))
+	for name := range this.reducedLabels {
+		result = append(result, name)
+	}
+	sort.Strings(result)
+	return result
+}
+
+// ParseLabeledMetricReductions parses a list of metric=label pairs, e.g. "filesystem/usage=resource_id".
+func ParseLabeledMetricReductions(specs []string) (map[string]string, error) {
+	reductions := make(map[string]string, len(specs))
+	for _, spec := range specs {
+		pos := strings.LastIndex(spec, "=")
+		if pos <= 0 || pos == len(spec)-1 {
+			return nil, fmt.Errorf("invalid labeled metric reduction %q, expected metric=label", spec)
+		}
+		if _, found := reductions[spec[:pos]]; found {
+			return nil, fmt.Errorf("labeled metric %s is reduced more than once", spec[:pos])
+		}
+		reductions[spec[:pos]] = spec[pos+1:]
+	}
+	return reductions, nil
+}
+
+func NewLabeledMetricReducer(reducedLabels map[string]string) *LabeledMetricReducer {
+	return &LabeledMetricReducer{
+		reducedLabels: reducedLabels,
+	}
+}
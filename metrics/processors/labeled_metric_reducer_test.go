@@ -0,0 +1,143 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processors
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"k8s.io/heapster/metrics/core"
+)
+
+func TestLabeledMetricReducer(t *testing.T) {
+	key := core.PodContainerKey("ns1", "pod1", "c")
+	filesystem := func(device string, value int64) core.LabeledMetric {
+		return core.LabeledMetric{
+			Name:   core.MetricFilesystemUsage.Name,
+			Labels: map[string]string{core.LabelResourceID.Key: device},
+			MetricValue: core.MetricValue{
+				ValueType:  core.ValueInt64,
+				MetricType: core.MetricGauge,
+				IntValue:   value,
+			},
+		}
+	}
+	accelerator := func(vendor, id string, value int64) core.LabeledMetric {
+		return core.LabeledMetric{
+			Name:   "accelerator/memory_used",
+			Labels: map[string]string{"make": vendor, "accelerator_id": id},
+			MetricValue: core.MetricValue{
+				ValueType:  core.ValueInt64,
+				MetricType: core.MetricGauge,
+				IntValue:   value,
+			},
+		}
+	}
+	batch := &core.DataBatch{
+		Timestamp: time.Now(),
+		MetricSets: map[string]*core.MetricSet{
+			key: {
+				Labels: map[string]string{core.LabelMetricSetType.Key: core.MetricSetTypePodContainer},
+				LabeledMetrics: []core.LabeledMetric{
+					filesystem("/dev/sda1", 100),
+					filesystem("/dev/sdb1", 20),
+					accelerator("nvidia", "gpu1", 5),
+					accelerator("nvidia", "gpu2", 7),
+					accelerator("amd", "gpu3", 1),
+					{Name: core.MetricFilesystemLimit.Name, Labels: map[string]string{core.LabelResourceID.Key: "/dev/sda1"}},
+				},
+			},
+		},
+	}
+
+	reductions, err := ParseLabeledMetricReductions([]string{
+		core.MetricFilesystemUsage.Name + "=" + core.LabelResourceID.Key,
+		"accelerator/memory_used=accelerator_id",
+	})
+	assert.NoError(t, err)
+	reducer := NewLabeledMetricReducer(reductions)
+	assert.Equal(t, []string{"accelerator/memory_used", core.MetricFilesystemUsage.Name}, reducer.ReducedMetrics())
+	batch, err = reducer.Process(batch)
+	assert.NoError(t, err)
+
+	ms := batch.MetricSets[key]
+	assert.Equal(t, core.MetricValue{ValueType: core.ValueInt64, MetricType: core.MetricGauge, IntValue: 120},
+		ms.MetricValues[core.MetricFilesystemUsage.Name])
+	assert.NotContains(t, ms.MetricValues, core.MetricFilesystemLimit.Name)
+	assert.Len(t, ms.LabeledMetrics, 8)
+	assert.Equal(t, []core.LabeledMetric{
+		{Name: "accelerator/memory_used", Labels: map[string]string{"make": "amd"},
+			MetricValue: core.MetricValue{ValueType: core.ValueInt64, MetricType: core.MetricGauge, IntValue: 1}},
+		{Name: "accelerator/memory_used", Labels: map[string]string{"make": "nvidia"},
+			MetricValue: core.MetricValue{ValueType: core.ValueInt64, MetricType: core.MetricGauge, IntValue: 12}},
+	}, ms.LabeledMetrics[6:])
+}
+
+func TestLabeledMetricReducerKeepsExistingMetrics(t *testing.T) {
+	key := core.PodContainerKey("ns1", "pod1", "c")
+	accelerator := func(labels map[string]string, value int64) core.LabeledMetric {
+		return core.LabeledMetric{
+			Name:   "accelerator/memory_used",
+			Labels: labels,
+			MetricValue: core.MetricValue{
+				ValueType:  core.ValueInt64,
+				MetricType: core.MetricGauge,
+				IntValue:   value,
+			},
+		}
+	}
+	batch := &core.DataBatch{
+		Timestamp: time.Now(),
+		MetricSets: map[string]*core.MetricSet{
+			key: {
+				LabeledMetrics: []core.LabeledMetric{
+					accelerator(map[string]string{"make": "nvidia", "accelerator_id": "gpu1"}, 5),
+					accelerator(map[string]string{"make": "nvidia", "accelerator_id": "gpu2"}, 7),
+					accelerator(map[string]string{"make": "amd", "accelerator_id": "gpu3"}, 1),
+					accelerator(map[string]string{"make": "nvidia"}, 20),
+				},
+			},
+		},
+	}
+
+	reducer := NewLabeledMetricReducer(map[string]string{"accelerator/memory_used": "accelerator_id"})
+	batch, err := reducer.Process(batch)
+	assert.NoError(t, err)
+	ms := batch.MetricSets[key]
+	assert.Equal(t, []core.LabeledMetric{
+		accelerator(map[string]string{"make": "nvidia"}, 20),
+		accelerator(map[string]string{"make": "amd"}, 1),
+	}, ms.LabeledMetrics[3:])
+
+	// Processing the batch again adds nothing.
+	batch, err = reducer.Process(batch)
+	assert.NoError(t, err)
+	assert.Len(t, batch.MetricSets[key].LabeledMetrics, 5)
+}
+
+func TestParseLabeledMetricReductions(t *testing.T) {
+	reductions, err := ParseLabeledMetricReductions([]string{"filesystem/usage=resource_id"})
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"filesystem/usage": "resource_id"}, reductions)
+
+	for _, spec := range []string{"filesystem/usage", "=resource_id", "filesystem/usage="} {
+		_, err := ParseLabeledMetricReductions([]string{spec})
+		assert.Error(t, err, spec)
+	}
+	_, err = ParseLabeledMetricReductions([]string{"filesystem/usage=a", "filesystem/usage=b"})
+	assert.Error(t, err)
+}
@@ -0,0 +1,80 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processors
+
+import (
+	"k8s.io/heapster/metrics/core"
+)
+
+// MemorySaturationCalculator computes memory/saturation, the working set as a share of the memory
+// limit, for pods and containers. Pods are only limited if all their containers are. Without
+// a limit, and for nodes, the memory capacity of the node set by the NodeAutoscalingEnricher is
+// used instead. Metric sets with neither get no value.
+type MemorySaturationCalculator struct{}
+
+func (this *MemorySaturationCalculator) Name() string {
+	return "memory_saturation_calculator"
+}
+
+func (this *MemorySaturationCalculator) Process(batch *core.DataBatch) (*core.DataBatch, error) {
+	// The pod limit is the sum of the container limits, which doesn't bound the pod if one of
+	// them is unlimited.
+	unlimitedPods := make(map[string]bool)
+	for _, metricSet := range batch.MetricSets {
+		if metricSet.Labels[core.LabelMetricSetType.Key] == core.MetricSetTypePodContainer &&
+			getInt(metricSet, &core.MetricMemoryLimit) <= 0 {
+			unlimitedPods[core.PodKey(metricSet.Labels[core.LabelNamespaceName.Key], metricSet.Labels[core.LabelPodName.Key])] = true
+		}
+	}
+
+	for key, metricSet := range batch.MetricSets {
+		workingSet, found := metricSet.MetricValues[core.MetricMemoryWorkingSet.Name]
+		if !found {
+			continue
+		}
+		var limit float64
+		switch metricSet.Labels[core.LabelMetricSetType.Key] {
+		case core.MetricSetTypePodContainer:
+			limit = float64(getInt(metricSet, &core.MetricMemoryLimit))
+		case core.MetricSetTypePod:
+			if !unlimitedPods[key] {
+				limit = float64(getInt(metricSet, &core.MetricMemoryLimit))
+			}
+		case core.MetricSetTypeNode:
+		default:
+			continue
+		}
+		if limit <= 0 {
+			limit = nodeMemoryCapacity(batch, metricSet)
+		}
+		if limit <= 0 {
+			continue
+		}
+		saturation := float64(workingSet.IntValue) / limit
+		if saturation > 1 {
+			saturation = 1
+		}
+		setFloat(metricSet, &core.MetricMemorySaturation, saturation)
+	}
+	return batch, nil
+}
+
+func nodeMemoryCapacity(batch *core.DataBatch, metricSet *core.MetricSet) float64 {
+	node, found := batch.MetricSets[core.NodeKey(metricSet.Labels[core.LabelNodename.Key])]
+	if !found {
+		return 0
+	}
+	return node.MetricValues[core.MetricNodeMemoryCapacity.Name].FloatValue
+}
@@ -0,0 +1,87 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processors
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"k8s.io/heapster/metrics/core"
+)
+
+func TestMemorySaturationCalculator(t *testing.T) {
+	metricSet := func(setType, pod string, workingSet, limit int64) *core.MetricSet {
+		return &core.MetricSet{
+			Labels: map[string]string{
+				core.LabelMetricSetType.Key: setType,
+				core.LabelNamespaceName.Key: "ns1",
+				core.LabelPodName.Key:       pod,
+				core.LabelNodename.Key:      "node1",
+			},
+			MetricValues: map[string]core.MetricValue{
+				core.MetricMemoryWorkingSet.Name: {ValueType: core.ValueInt64, MetricType: core.MetricGauge, IntValue: workingSet},
+				core.MetricMemoryLimit.Name:      {ValueType: core.ValueInt64, MetricType: core.MetricGauge, IntValue: limit},
+			},
+		}
+	}
+	node := metricSet(core.MetricSetTypeNode, "", 400, 0)
+	node.MetricValues[core.MetricNodeMemoryCapacity.Name] = core.MetricValue{ValueType: core.ValueFloat, MetricType: core.MetricGauge, FloatValue: 1000}
+	batch := &core.DataBatch{
+		MetricSets: map[string]*core.MetricSet{
+			core.NodeKey("node1"):                       node,
+			core.PodKey("ns1", "limited"):               metricSet(core.MetricSetTypePod, "limited", 150, 200),
+			core.PodContainerKey("ns1", "limited", "c"): metricSet(core.MetricSetTypePodContainer, "limited", 150, 200),
+			// The pod limit is the limit of the limited container only.
+			core.PodKey("ns1", "partial"):                metricSet(core.MetricSetTypePod, "partial", 300, 200),
+			core.PodContainerKey("ns1", "partial", "c1"): metricSet(core.MetricSetTypePodContainer, "partial", 100, 200),
+			core.PodContainerKey("ns1", "partial", "c2"): metricSet(core.MetricSetTypePodContainer, "partial", 200, 0),
+			// Above the limit, e.g. with the page cache.
+			core.PodContainerKey("ns1", "limited", "over"): metricSet(core.MetricSetTypePodContainer, "limited", 300, 200),
+			core.NamespaceKey("ns1"):                       metricSet(core.MetricSetTypeNamespace, "", 750, 0),
+		},
+	}
+	calculator := &MemorySaturationCalculator{}
+	_, err := calculator.Process(batch)
+	assert.NoError(t, err)
+
+	expected := map[string]float64{
+		core.NodeKey("node1"):                          0.4,
+		core.PodKey("ns1", "limited"):                  0.75,
+		core.PodContainerKey("ns1", "limited", "c"):    0.75,
+		core.PodKey("ns1", "partial"):                  0.3,
+		core.PodContainerKey("ns1", "partial", "c1"):   0.5,
+		core.PodContainerKey("ns1", "partial", "c2"):   0.2,
+		core.PodContainerKey("ns1", "limited", "over"): 1,
+	}
+	for key, metricSet := range batch.MetricSets {
+		value, found := metricSet.MetricValues[core.MetricMemorySaturation.Name]
+		want, saturated := expected[key]
+		assert.Equal(t, saturated, found, key)
+		if saturated {
+			assert.Equal(t, core.ValueFloat, value.ValueType, key)
+			assert.InDelta(t, want, value.FloatValue, 1e-9, key)
+		}
+	}
+
+	// Without the node capacity, unlimited metric sets get no value.
+	node = metricSet(core.MetricSetTypeNode, "", 400, 0)
+	unlimited := metricSet(core.MetricSetTypePodContainer, "unlimited", 100, 0)
+	batch.MetricSets = map[string]*core.MetricSet{core.NodeKey("node1"): node, "unlimited": unlimited}
+	_, err = calculator.Process(batch)
+	assert.NoError(t, err)
+	assert.NotContains(t, unlimited.MetricValues, core.MetricMemorySaturation.Name)
+	assert.NotContains(t, node.MetricValues, core.MetricMemorySaturation.Name)
+}
@@ -0,0 +1,142 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processors
+
+import (
+	"time"
+
+	"github.com/golang/glog"
+	"k8s.io/heapster/metrics/core"
+)
+
+// MissedScrapeFiller adds the metric sets missing from a batch because their scrape was missed,
+// with the last known cumulative values carried forward and marked as interpolated, so that
+// cumulative series don't appear to stall in sinks that don't interpolate.
+type MissedScrapeFiller struct {
+	resolution time.Duration
+	// Metric sets missing for more than this many scrapes are no longer filled.
+	maxMissedScrapes int
+	// Last scraped metric sets by key.
+	lastScraped map[string]scrapedMetricSet
+}
+
+type scrapedMetricSet struct {
+	timestamp time.Time
+	metricSet *core.MetricSet
+}
+
+func (this *MissedScrapeFiller) Name() string {
+	return "missed_scrape_filler"
+}
+
+func (this *MissedScrapeFiller) Process(batch *core.DataBatch) (*core.DataBatch, error) {
+	for key, scraped := range this.lastScraped {
+		// The metric sets added with only a zero up metric for failed scrapes are filled too.
+		current, found := batch.MetricSets[key]
+		if found && !isDown(current) {
+			continue
+		}
+		missed := int((batch.Timestamp.Sub(scraped.timestamp) + this.resolution/2) / this.resolution)
+		if missed < 1 {
+			continue
+		}
+		if missed > this.maxMissedScrapes {
+			delete(this.lastScraped, key)
+			continue
+		}
+		if filled := carryForward(scraped.metricSet, batch.Timestamp); filled != nil {
+			glog.V(4).Infof("Filling %d missed scrapes of %s", missed, key)
+			if found {
+				for name, value := range current.MetricValues {
+					filled.MetricValues[name] = value
+				}
+			}
+			batch.MetricSets[key] = filled
+		}
+	}
+	for key, ms := range batch.MetricSets {
+		if !isInterpolated(ms) && !isDown(ms) {
+			this.lastScraped[key] = scrapedMetricSet{timestamp: batch.Timestamp, metricSet: ms}
+		}
+	}
+	return batch, nil
+}
+
+// carryForward returns a copy of the metric set with only its cumulative values, marked as
+// interpolated and scraped at the given time, or nil if it has no cumulative values.
+func carryForward(ms *core.MetricSet, timestamp time.Time) *core.MetricSet {
+	filled := &core.MetricSet{
+		CollectionStartTime: ms.CollectionStartTime,
+		EntityCreateTime:    ms.EntityCreateTime,
+		ScrapeTime:          timestamp,
+		MetricValues:        make(map[string]core.MetricValue),
+		Labels:              make(map[string]string, len(ms.Labels)),
+		LabeledMetrics:      make([]core.LabeledMetric, 0),
+	}
+	for name, value := range ms.Labels {
+		filled.Labels[name] = value
+	}
+	for name, value := range ms.MetricValues {
+		if value.MetricType == core.MetricCumulative {
+			value.Interpolated = true
+			filled.MetricValues[name] = value
+		}
+	}
+	for _, metric := range ms.LabeledMetrics {
+		if metric.MetricType == core.MetricCumulative {
+			metric.Interpolated = true
+			filled.LabeledMetrics = append(filled.LabeledMetrics, metric)
+		}
+	}
+	if len(filled.MetricValues) == 0 && len(filled.LabeledMetrics) == 0 {
+		return nil
+	}
+	return filled
+}
+
+// isDown returns whether the metric set has an up metric of 0, which the source manager sets for
+// the nodes and pods it failed to scrape.
+func isDown(ms *core.MetricSet) bool {
+	for _, metric := range core.UpMetrics {
+		if value, found := ms.MetricValues[metric.Name]; found && value.IntValue == 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func isInterpolated(ms *core.MetricSet) bool {
+	for _, value := range ms.MetricValues {
+		if value.Interpolated {
+			return true
+		}
+	}
+	for _, metric := range ms.LabeledMetrics {
+		if metric.Interpolated {
+			return true
+		}
+	}
+	return false
+}
+
+// NewMissedScrapeFiller creates a filler for batches scraped every resolution, which fills metric
+// sets missing from up to maxMissedScrapes consecutive batches.
+func NewMissedScrapeFiller(resolution time.Duration, maxMissedScrapes int) *MissedScrapeFiller {
+	return &MissedScrapeFiller{
+		resolution:       resolution,
+		maxMissedScrapes: maxMissedScrapes,
+		lastScraped:      make(map[string]scrapedMetricSet),
+	}
+}
@@ -0,0 +1,164 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processors
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"k8s.io/heapster/metrics/core"
+)
+
+func TestMissedScrapeFiller(t *testing.T) {
+	key := core.PodContainerKey("ns1", "pod1", "c")
+	otherKey := core.PodContainerKey("ns1", "pod1", "other")
+	now := time.Now()
+
+	makeMetricSet := func(cpu int64) *core.MetricSet {
+		return &core.MetricSet{
+			ScrapeTime: now,
+			Labels: map[string]string{
+				core.LabelMetricSetType.Key: core.MetricSetTypePodContainer,
+			},
+			MetricValues: map[string]core.MetricValue{
+				core.MetricCpuUsage.Name: {
+					ValueType:  core.ValueInt64,
+					MetricType: core.MetricCumulative,
+					IntValue:   cpu,
+				},
+				core.MetricMemoryUsage.Name: {
+					ValueType:  core.ValueInt64,
+					MetricType: core.MetricGauge,
+					IntValue:   100,
+				},
+			},
+		}
+	}
+	batchAt := func(minutes int, metricSets map[string]*core.MetricSet) *core.DataBatch {
+		return &core.DataBatch{
+			Timestamp:  now.Add(time.Duration(minutes) * time.Minute),
+			MetricSets: metricSets,
+		}
+	}
+
+	filler := NewMissedScrapeFiller(time.Minute, 2)
+	filler.Process(batchAt(0, map[string]*core.MetricSet{key: makeMetricSet(10), otherKey: makeMetricSet(5)}))
+
+	batch, err := filler.Process(batchAt(1, map[string]*core.MetricSet{otherKey: makeMetricSet(6)}))
+	assert.NoError(t, err)
+	filled := batch.MetricSets[key]
+	if assert.NotNil(t, filled) {
+		assert.Equal(t, batch.Timestamp, filled.ScrapeTime)
+		assert.Equal(t, core.MetricSetTypePodContainer, filled.Labels[core.LabelMetricSetType.Key])
+		cpu := filled.MetricValues[core.MetricCpuUsage.Name]
+		assert.Equal(t, int64(10), cpu.IntValue)
+		assert.True(t, cpu.Interpolated)
+		// Gauges are not carried forward.
+		assert.NotContains(t, filled.MetricValues, core.MetricMemoryUsage.Name)
+	}
+	assert.False(t, batch.MetricSets[otherKey].MetricValues[core.MetricCpuUsage.Name].Interpolated)
+
+	// The value is carried forward from the last scrape, not from the previous fill.
+	batch, _ = filler.Process(batchAt(2, map[string]*core.MetricSet{otherKey: makeMetricSet(7)}))
+	assert.Equal(t, int64(10), batch.MetricSets[key].MetricValues[core.MetricCpuUsage.Name].IntValue)
+
+	// After more than 2 missed scrapes the metric set is forgotten.
+	batch, _ = filler.Process(batchAt(3, map[string]*core.MetricSet{otherKey: makeMetricSet(8)}))
+	assert.NotContains(t, batch.MetricSets, key)
+	batch, _ = filler.Process(batchAt(4, map[string]*core.MetricSet{otherKey: makeMetricSet(9)}))
+	assert.NotContains(t, batch.MetricSets, key)
+
+	// Scraped metric sets are passed unchanged.
+	scraped := makeMetricSet(20)
+	batch, _ = filler.Process(batchAt(5, map[string]*core.MetricSet{key: scraped}))
+	assert.Equal(t, scraped, batch.MetricSets[key])
+}
+
+func TestMissedScrapeFillerFailedScrape(t *testing.T) {
+	key := core.NodeKey("node1")
+	now := time.Now()
+	up := func(value int64) core.MetricValue {
+		return core.MetricValue{ValueType: core.ValueInt64, MetricType: core.MetricGauge, IntValue: value}
+	}
+	labels := map[string]string{core.LabelMetricSetType.Key: core.MetricSetTypeNode}
+
+	filler := NewMissedScrapeFiller(time.Minute, 2)
+	filler.Process(&core.DataBatch{
+		Timestamp: now,
+		MetricSets: map[string]*core.MetricSet{key: {
+			Labels: labels,
+			MetricValues: map[string]core.MetricValue{
+				core.MetricCpuUsage.Name: {ValueType: core.ValueInt64, MetricType: core.MetricCumulative, IntValue: 10},
+				core.MetricNodeUp.Name:   up(1),
+			},
+		}},
+	})
+
+	// The metric set added by the source manager for the failed scrape is filled, and stays down.
+	batch, _ := filler.Process(&core.DataBatch{
+		Timestamp: now.Add(time.Minute),
+		MetricSets: map[string]*core.MetricSet{key: {
+			Labels:       labels,
+			MetricValues: map[string]core.MetricValue{core.MetricNodeUp.Name: up(0)},
+		}},
+	})
+	filled := batch.MetricSets[key]
+	assert.Equal(t, int64(10), filled.MetricValues[core.MetricCpuUsage.Name].IntValue)
+	assert.True(t, filled.MetricValues[core.MetricCpuUsage.Name].Interpolated)
+	assert.Equal(t, up(0), filled.MetricValues[core.MetricNodeUp.Name])
+}
+
+func TestRatesAfterMissedScrape(t *testing.T) {
+	key := core.PodContainerKey("ns1", "pod1", "c")
+	now := time.Now()
+	batchAt := func(minutes int, cpu int64, scraped bool) *core.DataBatch {
+		timestamp := now.Add(time.Duration(minutes) * time.Minute)
+		batch := &core.DataBatch{Timestamp: timestamp, MetricSets: map[string]*core.MetricSet{}}
+		if scraped {
+			batch.MetricSets[key] = &core.MetricSet{
+				ScrapeTime: timestamp,
+				Labels:     map[string]string{core.LabelMetricSetType.Key: core.MetricSetTypePodContainer},
+				MetricValues: map[string]core.MetricValue{
+					core.MetricCpuUsage.Name: {ValueType: core.ValueInt64, MetricType: core.MetricCumulative, IntValue: cpu},
+				},
+			}
+		}
+		return batch
+	}
+
+	filler := NewMissedScrapeFiller(time.Minute, 2)
+	rateCalculator := NewRateCalculator(core.RateMetricsMapping)
+	process := func(batch *core.DataBatch) *core.DataBatch {
+		batch, err := filler.Process(batch)
+		assert.NoError(t, err)
+		batch, err = rateCalculator.Process(batch)
+		assert.NoError(t, err)
+		return batch
+	}
+
+	// One core used all the time.
+	process(batchAt(0, 0, true))
+	batch := process(batchAt(1, 0, false))
+	// The filled values don't give a zero rate.
+	assert.NotContains(t, batch.MetricSets[key].MetricValues, core.MetricCpuUsageRate.Name)
+
+	// The rate after the fill is computed against the last scrape, so it doesn't spike.
+	batch = process(batchAt(2, int64(2*time.Minute), true))
+	assert.Equal(t, int64(1000), batch.MetricSets[key].MetricValues[core.MetricCpuUsageRate.Name].IntValue)
+	batch = process(batchAt(3, int64(3*time.Minute), true))
+	assert.Equal(t, int64(1000), batch.MetricSets[key].MetricValues[core.MetricCpuUsageRate.Name].IntValue)
+}
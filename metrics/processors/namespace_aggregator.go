@@ -15,20 +15,64 @@
 package processors
 
 import (
+	"fmt"
+	"strings"
+
 	"github.com/golang/glog"
 	"k8s.io/heapster/metrics/core"
 )
 
 type NamespaceAggregator struct {
 	MetricsToAggregate []string
+	// Names of the labeled metrics to sum per label values.
+	LabeledMetricsToAggregate []string
+	// Metrics averaged across the pods of a namespace instead of summed, like ratios, mapped to
+	// the metric weighting the value of each pod, or to "" to give all pods the same weight.
+	MetricsToAverage map[string]string
+}
+
+// weightedAverage accumulates the values of a metric and their weights.
+type weightedAverage struct {
+	valueType core.ValueType
+	sum       float64
+	weight    float64
+}
+
+// ParseWeightedAverages parses a list of metric or metric=weight_metric specs, e.g.
+// "cpu/usage_ratio=cpu/request", into the MetricsToAverage of a NamespaceAggregator.
+func ParseWeightedAverages(specs []string) (map[string]string, error) {
+	averages := make(map[string]string, len(specs))
+	for _, spec := range specs {
+		parts := strings.SplitN(spec, "=", 2)
+		if parts[0] == "" || (len(parts) == 2 && parts[1] == "") {
+			return nil, fmt.Errorf("invalid weighted average %q, expected metric or metric=weight_metric", spec)
+		}
+		if _, found := averages[parts[0]]; found {
+			return nil, fmt.Errorf("metric %s is averaged more than once", parts[0])
+		}
+		averages[parts[0]] = ""
+		if len(parts) == 2 {
+			averages[parts[0]] = parts[1]
+		}
+	}
+	return averages, nil
 }
 
 func (this *NamespaceAggregator) Name() string {
 	return "namespace_aggregator"
 }
 
+func (this *NamespaceAggregator) RequiredLabels() []string {
+	return []string{core.LabelMetricSetType.Key + "=" + core.MetricSetTypePod, core.LabelPodNamespaceUID.Key}
+}
+
+func (this *NamespaceAggregator) ProducedLabels() []string {
+	return []string{core.LabelMetricSetType.Key + "=" + core.MetricSetTypeNamespace}
+}
+
 func (this *NamespaceAggregator) Process(batch *core.DataBatch) (*core.DataBatch, error) {
 	namespaces := make(map[string]*core.MetricSet)
+	averages := make(map[string]map[string]*weightedAverage)
 	for key, metricSet := range batch.MetricSets {
 		if metricSetType, found := metricSet.Labels[core.LabelMetricSetType.Key]; !found || metricSetType != core.MetricSetTypePod {
 			continue
@@ -54,14 +98,67 @@ func (this *NamespaceAggregator) Process(batch *core.DataBatch) (*core.DataBatch
 		if err := aggregate(metricSet, namespace, this.MetricsToAggregate); err != nil {
 			return nil, err
 		}
+		if err := aggregateLabeled(metricSet, namespace, this.LabeledMetricsToAggregate); err != nil {
+			return nil, err
+		}
+		if len(this.MetricsToAverage) > 0 {
+			if _, found := averages[namespaceKey]; !found {
+				averages[namespaceKey] = make(map[string]*weightedAverage)
+			}
+			this.accumulateAverages(metricSet, averages[namespaceKey])
+		}
 
 	}
 	for key, val := range namespaces {
 		batch.MetricSets[key] = val
 	}
+	for key, namespaceAverages := range averages {
+		namespace := batch.MetricSets[key]
+		for metricName, average := range namespaceAverages {
+			if average.weight == 0 {
+				continue
+			}
+			value := core.MetricValue{
+				ValueType:  average.valueType,
+				MetricType: core.MetricGauge,
+			}
+			if average.valueType == core.ValueInt64 {
+				value.IntValue = int64(average.sum / average.weight)
+			} else {
+				value.FloatValue = average.sum / average.weight
+			}
+			namespace.MetricValues[metricName] = value
+		}
+	}
 	return batch, nil
 }
 
+// accumulateAverages adds the values of the averaged metrics of the pod to the averages of its
+// namespace. Pods without the weight metric don't contribute.
+func (this *NamespaceAggregator) accumulateAverages(pod *core.MetricSet, averages map[string]*weightedAverage) {
+	for metricName, weightMetric := range this.MetricsToAverage {
+		metricValue, found := pod.MetricValues[metricName]
+		if !found {
+			continue
+		}
+		weight := 1.0
+		if weightMetric != "" {
+			weightValue, found := pod.MetricValues[weightMetric]
+			if !found {
+				continue
+			}
+			weight = weightValue.GetFloatValue()
+		}
+		average, found := averages[metricName]
+		if !found {
+			average = &weightedAverage{valueType: metricValue.ValueType}
+			averages[metricName] = average
+		}
+		average.sum += metricValue.GetFloatValue() * weight
+		average.weight += weight
+	}
+}
+
 func namespaceMetricSet(namespaceName, uid string) *core.MetricSet {
 	return &core.MetricSet{
 		MetricValues: make(map[string]core.MetricValue),
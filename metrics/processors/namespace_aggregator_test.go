@@ -15,6 +15,7 @@
 package processors
 
 import (
+	"fmt"
 	"testing"
 	"time"
 
@@ -82,3 +83,142 @@ func TestNamespaceAggregate(t *testing.T) {
 	assert.True(t, found)
 	assert.Equal(t, int64(30), m3.IntValue)
 }
+
+func TestNamespaceAggregateLabeledMetrics(t *testing.T) {
+	gpuRequest := func(value int64) core.LabeledMetric {
+		return core.LabeledMetric{
+			Name:        core.MetricResourceRequest.Name,
+			Labels:      map[string]string{core.LabelResourceID.Key: "nvidia.com/gpu"},
+			MetricValue: intValue(value),
+		}
+	}
+	batch := core.DataBatch{
+		Timestamp: time.Now(),
+		MetricSets: map[string]*core.MetricSet{
+			core.PodKey("ns1", "pod1"): {
+				Labels: map[string]string{
+					core.LabelMetricSetType.Key: core.MetricSetTypePod,
+					core.LabelNamespaceName.Key: "ns1",
+				},
+				MetricValues: map[string]core.MetricValue{},
+				LabeledMetrics: []core.LabeledMetric{
+					gpuRequest(1),
+					{
+						Name:        core.MetricResourceRequest.Name,
+						Labels:      map[string]string{core.LabelResourceID.Key: "hugepages-2Mi"},
+						MetricValue: intValue(4194304),
+					},
+				},
+			},
+			core.PodKey("ns1", "pod2"): {
+				Labels: map[string]string{
+					core.LabelMetricSetType.Key: core.MetricSetTypePod,
+					core.LabelNamespaceName.Key: "ns1",
+				},
+				MetricValues:   map[string]core.MetricValue{},
+				LabeledMetrics: []core.LabeledMetric{gpuRequest(2)},
+			},
+		},
+	}
+	processor := NamespaceAggregator{
+		LabeledMetricsToAggregate: []string{core.MetricResourceRequest.Name},
+	}
+	result, err := processor.Process(&batch)
+	assert.NoError(t, err)
+	namespace, found := result.MetricSets[core.NamespaceKey("ns1")]
+	assert.True(t, found)
+	assert.Len(t, namespace.LabeledMetrics, 2)
+	assert.Contains(t, namespace.LabeledMetrics, gpuRequest(3))
+	assert.Contains(t, namespace.LabeledMetrics, core.LabeledMetric{
+		Name:        core.MetricResourceRequest.Name,
+		Labels:      map[string]string{core.LabelResourceID.Key: "hugepages-2Mi"},
+		MetricValue: intValue(4194304),
+	})
+	// The pods keep their own values.
+	assert.Equal(t, int64(1), batch.MetricSets[core.PodKey("ns1", "pod1")].LabeledMetrics[0].IntValue)
+}
+
+// BenchmarkNamespaceAggregate aggregates 5000 pods in 50 namespaces with the metrics aggregated by default.
+func BenchmarkNamespaceAggregate(b *testing.B) {
+	metricsToAggregate := []string{
+		core.MetricCpuUsageRate.Name,
+		core.MetricMemoryUsage.Name,
+		core.MetricCpuRequest.Name,
+		core.MetricCpuLimit.Name,
+		core.MetricMemoryRequest.Name,
+		core.MetricMemoryLimit.Name,
+	}
+	batch := &core.DataBatch{
+		Timestamp:  time.Now(),
+		MetricSets: make(map[string]*core.MetricSet),
+	}
+	for i := 0; i < 5000; i++ {
+		namespace := fmt.Sprintf("ns%d", i%50)
+		metricValues := make(map[string]core.MetricValue)
+		for _, metricName := range metricsToAggregate {
+			metricValues[metricName] = core.MetricValue{ValueType: core.ValueInt64, MetricType: core.MetricGauge, IntValue: int64(i)}
+		}
+		batch.MetricSets[core.PodKey(namespace, fmt.Sprintf("pod%d", i))] = &core.MetricSet{
+			Labels: map[string]string{
+				core.LabelMetricSetType.Key: core.MetricSetTypePod,
+				core.LabelNamespaceName.Key: namespace,
+			},
+			MetricValues: metricValues,
+		}
+	}
+	processor := NamespaceAggregator{
+		MetricsToAggregate: metricsToAggregate,
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for n := 0; n < 50; n++ {
+			delete(batch.MetricSets, core.NamespaceKey(fmt.Sprintf("ns%d", n)))
+		}
+		if _, err := processor.Process(batch); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestNamespaceAggregateWeightedAverages(t *testing.T) {
+	pod := func(ratio float64, request int64) *core.MetricSet {
+		return &core.MetricSet{
+			Labels: map[string]string{
+				core.LabelMetricSetType.Key: core.MetricSetTypePod,
+				core.LabelNamespaceName.Key: "ns1",
+			},
+			MetricValues: map[string]core.MetricValue{
+				"ratio":   {ValueType: core.ValueFloat, MetricType: core.MetricGauge, FloatValue: ratio},
+				"ratio2":  {ValueType: core.ValueFloat, MetricType: core.MetricGauge, FloatValue: ratio},
+				"request": {ValueType: core.ValueInt64, MetricType: core.MetricGauge, IntValue: request},
+			},
+		}
+	}
+	batch := core.DataBatch{
+		Timestamp: time.Now(),
+		MetricSets: map[string]*core.MetricSet{
+			core.PodKey("ns1", "pod1"): pod(0.2, 100),
+			core.PodKey("ns1", "pod2"): pod(0.8, 300),
+		},
+	}
+	averages, err := ParseWeightedAverages([]string{"ratio", "ratio2=request"})
+	assert.NoError(t, err)
+	processor := NamespaceAggregator{
+		MetricsToAggregate: []string{"request"},
+		MetricsToAverage:   averages,
+	}
+	result, err := processor.Process(&batch)
+	assert.NoError(t, err)
+	namespace, found := result.MetricSets[core.NamespaceKey("ns1")]
+	assert.True(t, found)
+
+	assert.InEpsilon(t, 0.5, namespace.MetricValues["ratio"].FloatValue, 0.001)
+	assert.InEpsilon(t, 0.65, namespace.MetricValues["ratio2"].FloatValue, 0.001)
+	assert.Equal(t, int64(400), namespace.MetricValues["request"].IntValue)
+
+	for _, specs := range [][]string{{""}, {"=request"}, {"ratio="}, {"ratio", "ratio=request"}} {
+		_, err := ParseWeightedAverages(specs)
+		assert.Error(t, err, "%v", specs)
+	}
+}
@@ -27,6 +27,7 @@ import (
 	"k8s.io/client-go/tools/cache"
 	kube_config "k8s.io/heapster/common/kubernetes"
 	"k8s.io/heapster/metrics/core"
+	"k8s.io/heapster/metrics/util"
 )
 
 type NamespaceBasedEnricher struct {
@@ -38,6 +39,18 @@ func (this *NamespaceBasedEnricher) Name() string {
 	return "namespace_based_enricher"
 }
 
+func (this *NamespaceBasedEnricher) RequiredLabels() []string {
+	return nil
+}
+
+func (this *NamespaceBasedEnricher) ProducedLabels() []string {
+	return []string{core.LabelPodNamespaceUID.Key}
+}
+
+func (this *NamespaceBasedEnricher) HasSynced() bool {
+	return this.reflector == nil || this.reflector.LastSyncResourceVersion() != ""
+}
+
 func (this *NamespaceBasedEnricher) Process(batch *core.DataBatch) (*core.DataBatch, error) {
 	for _, ms := range batch.MetricSets {
 		this.addNamespaceInfo(ms)
@@ -85,7 +98,8 @@ func NewNamespaceBasedEnricher(url *url.URL) (*NamespaceBasedEnricher, error) {
 	kubeClient := kube_client.NewForConfigOrDie(kubeConfig)
 
 	// watch nodes
-	lw := cache.NewListWatchFromClient(kubeClient.CoreV1().RESTClient(), "namespaces", kube_api.NamespaceAll, fields.Everything())
+	lw := util.NewStalenessTrackingListWatch("namespaces",
+		cache.NewListWatchFromClient(kubeClient.CoreV1().RESTClient(), "namespaces", kube_api.NamespaceAll, fields.Everything()))
 	store := cache.NewStore(cache.MetaNamespaceKeyFunc)
 	reflector := cache.NewReflector(lw, &kube_api.Namespace{}, store, time.Hour)
 	go reflector.Run(wait.NeverStop)
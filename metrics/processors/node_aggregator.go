@@ -22,12 +22,22 @@ import (
 // Does not add any nodes.
 type NodeAggregator struct {
 	MetricsToAggregate []string
+	// Names of the labeled metrics to sum per label values.
+	LabeledMetricsToAggregate []string
 }
 
 func (this *NodeAggregator) Name() string {
 	return "node_aggregator"
 }
 
+func (this *NodeAggregator) RequiredLabels() []string {
+	return []string{core.LabelMetricSetType.Key + "=" + core.MetricSetTypePod}
+}
+
+func (this *NodeAggregator) ProducedLabels() []string {
+	return nil
+}
+
 func (this *NodeAggregator) Process(batch *core.DataBatch) (*core.DataBatch, error) {
 	for key, metricSet := range batch.MetricSets {
 		if metricSetType, found := metricSet.Labels[core.LabelMetricSetType.Key]; !found || metricSetType != core.MetricSetTypePod {
@@ -49,6 +59,8 @@ func (this *NodeAggregator) Process(batch *core.DataBatch) (*core.DataBatch, err
 			glog.V(1).Infof("No metric for node %s, cannot perform node level aggregation.", nodeKey)
 		} else if err := aggregate(metricSet, node, this.MetricsToAggregate); err != nil {
 			return nil, err
+		} else if err := aggregateLabeled(metricSet, node, this.LabeledMetricsToAggregate); err != nil {
+			return nil, err
 		}
 
 	}
@@ -37,6 +37,18 @@ func (this *NodeAutoscalingEnricher) Name() string {
 	return "node_autoscaling_enricher"
 }
 
+func (this *NodeAutoscalingEnricher) RequiredLabels() []string {
+	return []string{core.LabelPodId.Key}
+}
+
+func (this *NodeAutoscalingEnricher) ProducedLabels() []string {
+	return nil
+}
+
+func (this *NodeAutoscalingEnricher) HasSynced() bool {
+	return this.reflector == nil || this.reflector.LastSyncResourceVersion() != ""
+}
+
 func (this *NodeAutoscalingEnricher) Process(batch *core.DataBatch) (*core.DataBatch, error) {
 	nodes, err := this.nodeLister.List(labels.Everything())
 	if err != nil {
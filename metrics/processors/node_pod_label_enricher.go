@@ -0,0 +1,109 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processors
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/golang/glog"
+
+	v1listers "k8s.io/client-go/listers/core/v1"
+	"k8s.io/heapster/metrics/core"
+)
+
+// NodePodLabelEnricher labels node metric sets with the distinct values of a pod label among the
+// pods of the node, e.g. their workload type, so that node metrics can be sliced by what runs on
+// them. Only the values held by the most pods are kept, to bound the cardinality of the label.
+type NodePodLabelEnricher struct {
+	podLister v1listers.PodLister
+	label     string
+	maxValues int
+}
+
+func (this *NodePodLabelEnricher) Name() string {
+	return "node_pod_label_enricher"
+}
+
+func (this *NodePodLabelEnricher) RequiredLabels() []string {
+	return nil
+}
+
+func (this *NodePodLabelEnricher) ProducedLabels() []string {
+	return []string{core.LabelPodLabelValues.Key}
+}
+
+func (this *NodePodLabelEnricher) Process(batch *core.DataBatch) (*core.DataBatch, error) {
+	// Number of pods with each value of the label, by node name.
+	counts := make(map[string]map[string]int)
+	for key, ms := range batch.MetricSets {
+		if ms.Labels[core.LabelMetricSetType.Key] != core.MetricSetTypePod {
+			continue
+		}
+		nodeName := ms.Labels[core.LabelNodename.Key]
+		if nodeName == "" {
+			continue
+		}
+		pod, err := this.podLister.Pods(ms.Labels[core.LabelNamespaceName.Key]).Get(ms.Labels[core.LabelPodName.Key])
+		if err != nil || pod == nil {
+			glog.V(3).Infof("Failed to get pod %s from cache: %v", key, err)
+			continue
+		}
+		value, found := pod.Labels[this.label]
+		if !found || value == "" {
+			continue
+		}
+		if counts[nodeName] == nil {
+			counts[nodeName] = make(map[string]int)
+		}
+		counts[nodeName][value]++
+	}
+	for nodeName, values := range counts {
+		if node, found := batch.MetricSets[core.NodeKey(nodeName)]; found {
+			node.Labels[core.LabelPodLabelValues.Key] = this.joinValues(values)
+		}
+	}
+	return batch, nil
+}
+
+// joinValues returns the values held by the most pods, at most maxValues of them, sorted and
+// comma-separated. Ties are broken by name, so that the label doesn't flap between scrapes.
+func (this *NodePodLabelEnricher) joinValues(counts map[string]int) string {
+	values := make([]string, 0, len(counts))
+	for value := range counts {
+		values = append(values, value)
+	}
+	sort.Slice(values, func(i, j int) bool {
+		if counts[values[i]] != counts[values[j]] {
+			return counts[values[i]] > counts[values[j]]
+		}
+		return values[i] < values[j]
+	})
+	if this.maxValues > 0 && len(values) > this.maxValues {
+		values = values[:this.maxValues]
+	}
+	sort.Strings(values)
+	return strings.Join(values, ",")
+}
+
+// NewNodePodLabelEnricher creates an enricher which labels nodes with at most maxValues values of
+// the given pod label, or with all of them if maxValues is 0.
+func NewNodePodLabelEnricher(podLister v1listers.PodLister, label string, maxValues int) *NodePodLabelEnricher {
+	return &NodePodLabelEnricher{
+		podLister: podLister,
+		label:     label,
+		maxValues: maxValues,
+	}
+}
@@ -0,0 +1,68 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processors
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	kube_api "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	v1listers "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/heapster/metrics/core"
+)
+
+func TestNodePodLabelEnricher(t *testing.T) {
+	store := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+	batch := &core.DataBatch{MetricSets: map[string]*core.MetricSet{
+		core.NodeKey("node1"): {Labels: map[string]string{core.LabelMetricSetType.Key: core.MetricSetTypeNode}},
+		core.NodeKey("node2"): {Labels: map[string]string{core.LabelMetricSetType.Key: core.MetricSetTypeNode}},
+	}}
+	addPod := func(name, node, workload string) {
+		pod := &kube_api.Pod{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "ns1", Labels: map[string]string{}}}
+		if workload != "" {
+			pod.Labels["workload"] = workload
+		}
+		store.Add(pod)
+		batch.MetricSets[core.PodKey("ns1", name)] = &core.MetricSet{Labels: map[string]string{
+			core.LabelMetricSetType.Key: core.MetricSetTypePod,
+			core.LabelNamespaceName.Key: "ns1",
+			core.LabelPodName.Key:       name,
+			core.LabelNodename.Key:      node,
+		}}
+	}
+	// On node1, web and db have two pods each, and batch and cache one.
+	for i, workload := range []string{"web", "db", "web", "batch", "db", "cache", ""} {
+		addPod(fmt.Sprintf("pod%d", i), "node1", workload)
+	}
+	addPod("other", "node2", "")
+
+	enricher := NewNodePodLabelEnricher(v1listers.NewPodLister(store), "workload", 3)
+	assert.Equal(t, []string{core.LabelPodLabelValues.Key}, enricher.ProducedLabels())
+	_, err := enricher.Process(batch)
+	assert.NoError(t, err)
+
+	// Of the values with one pod, batch is kept over cache by name.
+	assert.Equal(t, "batch,db,web", batch.MetricSets[core.NodeKey("node1")].Labels[core.LabelPodLabelValues.Key])
+	assert.NotContains(t, batch.MetricSets[core.NodeKey("node2")].Labels, core.LabelPodLabelValues.Key)
+	assert.NotContains(t, batch.MetricSets[core.PodKey("ns1", "pod0")].Labels, core.LabelPodLabelValues.Key)
+
+	unlimited := NewNodePodLabelEnricher(v1listers.NewPodLister(store), "workload", 0)
+	unlimited.Process(batch)
+	assert.Equal(t, "batch,cache,db,web", batch.MetricSets[core.NodeKey("node1")].Labels[core.LabelPodLabelValues.Key])
+}
@@ -33,12 +33,27 @@ var LabelsToPopulate = []core.LabelDescriptor{
 
 type PodAggregator struct {
 	skippedMetrics map[string]struct{}
+	// Labeled metrics of the containers summed into their pods.
+	labeledMetricsToAggregate []string
+	// Whether the metrics of init containers are left out of their pods.
+	excludeInitContainers bool
 }
 
 func (this *PodAggregator) Name() string {
 	return "pod_aggregator"
 }
 
+func (this *PodAggregator) RequiredLabels() []string {
+	if this.excludeInitContainers {
+		return []string{core.LabelPodId.Key, core.LabelContainerType.Key}
+	}
+	return []string{core.LabelPodId.Key}
+}
+
+func (this *PodAggregator) ProducedLabels() []string {
+	return []string{core.LabelMetricSetType.Key + "=" + core.MetricSetTypePod}
+}
+
 func (this *PodAggregator) Process(batch *core.DataBatch) (*core.DataBatch, error) {
 	newPods := make(map[string]*core.MetricSet)
 
@@ -48,6 +63,10 @@ func (this *PodAggregator) Process(batch *core.DataBatch) (*core.DataBatch, erro
 		if metricSetType, found := metricSet.Labels[core.LabelMetricSetType.Key]; !found || metricSetType != core.MetricSetTypePodContainer {
 			continue
 		}
+		// Init containers have exited once the pod runs, so they would skew its steady state usage.
+		if this.excludeInitContainers && metricSet.Labels[core.LabelContainerType.Key] == core.ContainerTypeInit {
+			continue
+		}
 
 		// Aggregating containers
 		podName, found := metricSet.Labels[core.LabelPodName.Key]
@@ -97,6 +116,10 @@ func (this *PodAggregator) Process(batch *core.DataBatch) (*core.DataBatch, erro
 
 			pod.MetricValues[metricName] = aggregatedValue
 		}
+
+		if err := aggregateLabeled(metricSet, pod, this.labeledMetricsToAggregate); err != nil {
+			return nil, err
+		}
 	}
 	for key, val := range newPods {
 		batch.MetricSets[key] = val
@@ -119,7 +142,9 @@ func (this *PodAggregator) podMetricSet(labels map[string]string) *core.MetricSe
 	}
 }
 
-func NewPodAggregator() *PodAggregator {
+// NewPodAggregator returns an aggregator summing the container metrics into their pods, leaving out
+// the init containers, as labeled by the PodBasedEnricher, if excludeInitContainers is set.
+func NewPodAggregator(excludeInitContainers bool) *PodAggregator {
 	skipped := make(map[string]struct{})
 	for _, metric := range core.StandardMetrics {
 		if metric.MetricDescriptor.Type == core.MetricCumulative ||
@@ -127,7 +152,13 @@ func NewPodAggregator() *PodAggregator {
 			skipped[metric.MetricDescriptor.Name] = struct{}{}
 		}
 	}
+	labeled := make([]string, 0, len(core.AggregatedLabeledMetrics))
+	for _, metric := range core.AggregatedLabeledMetrics {
+		labeled = append(labeled, metric.Name)
+	}
 	return &PodAggregator{
-		skippedMetrics: skipped,
+		skippedMetrics:            skipped,
+		labeledMetricsToAggregate: labeled,
+		excludeInitContainers:     excludeInitContainers,
 	}
 }
@@ -20,6 +20,7 @@ import (
 
 	"github.com/stretchr/testify/assert"
 
+	"k8s.io/apimachinery/pkg/util/sets"
 	"k8s.io/heapster/metrics/core"
 )
 
@@ -141,3 +142,39 @@ func TestPodAggregator(t *testing.T) {
 	assert.Equal(t, int64(20), m2.IntValue)
 
 }
+
+func TestPodAggregatorExcludeInitContainers(t *testing.T) {
+	container := func(containerType string, value int64) *core.MetricSet {
+		return &core.MetricSet{
+			Labels: map[string]string{
+				core.LabelMetricSetType.Key: core.MetricSetTypePodContainer,
+				core.LabelPodName.Key:       "pod1",
+				core.LabelNamespaceName.Key: "ns1",
+				core.LabelContainerType.Key: containerType,
+			},
+			MetricValues: map[string]core.MetricValue{
+				"m1": {ValueType: core.ValueInt64, MetricType: core.MetricGauge, IntValue: value},
+			},
+		}
+	}
+
+	for _, excludeInitContainers := range []bool{false, true} {
+		batch := &core.DataBatch{
+			Timestamp: time.Now(),
+			MetricSets: map[string]*core.MetricSet{
+				core.PodContainerKey("ns1", "pod1", "app"):  container(core.ContainerTypeApp, 10),
+				core.PodContainerKey("ns1", "pod1", "init"): container(core.ContainerTypeInit, 100),
+			},
+		}
+		processor := NewPodAggregator(excludeInitContainers)
+		assert.Equal(t, excludeInitContainers, sets.NewString(processor.RequiredLabels()...).Has(core.LabelContainerType.Key))
+		batch, err := processor.Process(batch)
+		assert.NoError(t, err)
+
+		expected := int64(110)
+		if excludeInitContainers {
+			expected = 10
+		}
+		assert.Equal(t, expected, batch.MetricSets[core.PodKey("ns1", "pod1")].MetricValues["m1"].IntValue)
+	}
+}
@@ -16,6 +16,8 @@ package processors
 
 import (
 	"fmt"
+	"strings"
+	"time"
 
 	"github.com/golang/glog"
 
@@ -29,12 +31,40 @@ import (
 type PodBasedEnricher struct {
 	podLister   v1listers.PodLister
 	labelCopier *util.LabelCopier
+	// Whether containers are labeled with the ID of their image.
+	labelImageIds bool
+
+	// Storage from which metrics of deleted pods are evicted, if any.
+	evictor core.MetricSetEvictor
+	// For how long metrics of a pod are kept after it was deleted.
+	deletedPodGracePeriod time.Duration
+	// Pods seen in the batches, by pod key.
+	knownPods map[string]podReference
+	// Time when known pods were first found to be deleted, by pod key.
+	deletedPods map[string]time.Time
+}
+
+type podReference struct {
+	namespace string
+	name      string
 }
 
 func (this *PodBasedEnricher) Name() string {
 	return "pod_based_enricher"
 }
 
+func (this *PodBasedEnricher) RequiredLabels() []string {
+	return nil
+}
+
+func (this *PodBasedEnricher) ProducedLabels() []string {
+	labels := []string{core.LabelPodId.Key, core.LabelLabels.Key, core.LabelContainerBaseImage.Key, core.LabelContainerType.Key}
+	if this.labelImageIds {
+		labels = append(labels, core.LabelContainerImageID.Key)
+	}
+	return labels
+}
+
 func (this *PodBasedEnricher) Process(batch *core.DataBatch) (*core.DataBatch, error) {
 	newMs := make(map[string]*core.MetricSet, len(batch.MetricSets))
 	for k, v := range batch.MetricSets {
@@ -62,9 +92,54 @@ func (this *PodBasedEnricher) Process(batch *core.DataBatch) (*core.DataBatch, e
 	for k, v := range newMs {
 		batch.MetricSets[k] = v
 	}
+	if this.evictor != nil {
+		this.evictDeletedPods(batch)
+	}
 	return batch, nil
 }
 
+// evictDeletedPods marks deleted pods as terminated in the evictor, and evicts the metrics of pods
+// that were deleted longer than the grace period ago from it. The batch is left intact, since
+// other processors and sinks still see the metrics it carries.
+func (this *PodBasedEnricher) evictDeletedPods(batch *core.DataBatch) {
+	for _, ms := range batch.MetricSets {
+		switch ms.Labels[core.LabelMetricSetType.Key] {
+		case core.MetricSetTypePod, core.MetricSetTypePodContainer:
+			namespace := ms.Labels[core.LabelNamespaceName.Key]
+			podName := ms.Labels[core.LabelPodName.Key]
+			this.knownPods[core.PodKey(namespace, podName)] = podReference{namespace: namespace, name: podName}
+		}
+	}
+
+	evicted := []string{}
+	for podKey, pod := range this.knownPods {
+		deletedSince, deleted := this.deletedPods[podKey]
+		if _, err := this.getPod(pod.namespace, pod.name); err == nil {
+			if deleted {
+				// A pod with the same name was created again.
+				delete(this.deletedPods, podKey)
+				this.evictor.SetTerminated(podKey, false)
+			}
+			continue
+		}
+		if !deleted {
+			this.deletedPods[podKey] = batch.Timestamp
+			this.evictor.SetTerminated(podKey, true)
+			continue
+		}
+		if batch.Timestamp.Sub(deletedSince) < this.deletedPodGracePeriod {
+			continue
+		}
+		glog.V(2).Infof("Evicting metrics of pod %s deleted at %v", podKey, deletedSince)
+		evicted = append(evicted, podKey)
+		delete(this.knownPods, podKey)
+		delete(this.deletedPods, podKey)
+	}
+	if len(evicted) > 0 {
+		this.evictor.EvictMetricSets(evicted)
+	}
+}
+
 func (this *PodBasedEnricher) getPod(namespace, name string) (*kube_api.Pod, error) {
 	pod, err := this.podLister.Pods(namespace).Get(name)
 	if err != nil {
@@ -83,6 +158,13 @@ func (this *PodBasedEnricher) addContainerInfo(key string, containerMs *core.Met
 			if _, ok := containerMs.Labels[core.LabelContainerBaseImage.Key]; !ok {
 				containerMs.Labels[core.LabelContainerBaseImage.Key] = container.Image
 			}
+			containerMs.Labels[core.LabelContainerType.Key] = core.ContainerTypeApp
+			break
+		}
+	}
+	for _, container := range pod.Spec.InitContainers {
+		if key == core.PodContainerKey(pod.Namespace, pod.Name, container.Name) {
+			containerMs.Labels[core.LabelContainerType.Key] = core.ContainerTypeInit
 			break
 		}
 	}
@@ -93,6 +175,10 @@ func (this *PodBasedEnricher) addContainerInfo(key string, containerMs *core.Met
 			if !pod.Status.StartTime.IsZero() {
 				containerMs.EntityCreateTime = pod.Status.StartTime.Time
 			}
+			// The image ID is only known once the image was pulled, unlike the image of the spec.
+			if this.labelImageIds && containerStatus.ImageID != "" {
+				containerMs.Labels[core.LabelContainerImageID.Key] = containerStatus.ImageID
+			}
 			break
 		}
 	}
@@ -138,6 +224,16 @@ func (this *PodBasedEnricher) addPodInfo(key string, podMs *core.MetricSet, pod
 	}
 	this.labelCopier.Copy(pod.Labels, podMs.Labels)
 
+	// Init containers have their own statuses, so they are not counted.
+	readyContainers := 0
+	for _, containerStatus := range pod.Status.ContainerStatuses {
+		if containerStatus.Ready {
+			readyContainers++
+		}
+	}
+	podMs.MetricValues[core.MetricPodReadyContainers.Name] = intValue(int64(readyContainers))
+	podMs.MetricValues[core.MetricPodTotalContainers.Name] = intValue(int64(len(pod.Spec.Containers)))
+
 	// Add cpu/mem requests and limits to containers
 	for _, container := range pod.Spec.Containers {
 		containerKey := core.PodContainerKey(pod.Namespace, pod.Name, container.Name)
@@ -156,6 +252,7 @@ func (this *PodBasedEnricher) addPodInfo(key string, podMs *core.MetricSet, pod
 				core.LabelPodName.Key:            pod.Name,
 				core.LabelContainerName.Key:      container.Name,
 				core.LabelContainerBaseImage.Key: container.Image,
+				core.LabelContainerType.Key:      core.ContainerTypeApp,
 				core.LabelPodId.Key:              string(pod.UID),
 				core.LabelNodename.Key:           podMs.Labels[core.LabelNodename.Key],
 				core.LabelHostname.Key:           podMs.Labels[core.LabelHostname.Key],
@@ -188,6 +285,9 @@ func updateContainerResourcesAndLimits(metricSet *core.MetricSet, container kube
 					Units:       core.UnitsCount,
 				},
 			}
+			if isByteResource(key) {
+				metric.Units = core.UnitsBytes
+			}
 			core.ResourceRequestMetrics[key] = metric
 		}
 		if key == kube_api.ResourceCPU {
@@ -210,6 +310,8 @@ func updateContainerResourcesAndLimits(metricSet *core.MetricSet, container kube
 	}
 
 	limits := container.Resources.Limits
+	addResourceMetrics(metricSet, core.MetricResourceRequest.Name, core.MetricResourceRequestBytes.Name, requests)
+	addResourceMetrics(metricSet, core.MetricResourceLimit.Name, core.MetricResourceLimitBytes.Name, limits)
 	if val, found := limits[kube_api.ResourceCPU]; found {
 		metricSet.MetricValues[core.MetricCpuLimit.Name] = intValue(val.MilliValue())
 	} else {
@@ -227,6 +329,38 @@ func updateContainerResourcesAndLimits(metricSet *core.MetricSet, container kube
 	}
 }
 
+// isByteResource returns whether the quantities of the resource are in bytes.
+func isByteResource(name kube_api.ResourceName) bool {
+	switch {
+	case name == kube_api.ResourceMemory, name == kube_api.ResourceEphemeralStorage, name == kube_api.ResourceStorage:
+		return true
+	case strings.HasPrefix(string(name), kube_api.ResourceHugePagesPrefix):
+		return true
+	}
+	return strings.HasSuffix(string(name), "/memory")
+}
+
+// addResourceMetrics adds a labeled metric for every resource in the list that has no
+// dedicated metric, keyed by the resource name. Resources measured in bytes are added to the
+// bytesMetricName metric, the others to the metricName one.
+func addResourceMetrics(metricSet *core.MetricSet, metricName, bytesMetricName string, resources kube_api.ResourceList) {
+	for name, val := range resources {
+		switch name {
+		case kube_api.ResourceCPU, kube_api.ResourceMemory, kube_api.ResourceEphemeralStorage:
+			continue
+		}
+		resourceMetricName := metricName
+		if isByteResource(name) {
+			resourceMetricName = bytesMetricName
+		}
+		metricSet.LabeledMetrics = append(metricSet.LabeledMetrics, core.LabeledMetric{
+			Name:        resourceMetricName,
+			Labels:      map[string]string{core.LabelResourceID.Key: string(name)},
+			MetricValue: intValue(val.Value()),
+		})
+	}
+}
+
 func intValue(value int64) core.MetricValue {
 	return core.MetricValue{
 		IntValue:   value,
@@ -235,9 +369,17 @@ func intValue(value int64) core.MetricValue {
 	}
 }
 
-func NewPodBasedEnricher(podLister v1listers.PodLister, labelCopier *util.LabelCopier) (*PodBasedEnricher, error) {
+// NewPodBasedEnricher creates a PodBasedEnricher. If evictor is not nil, metrics of pods deleted
+// longer than deletedPodGracePeriod ago are removed from the batches and evicted from it.
+func NewPodBasedEnricher(podLister v1listers.PodLister, labelCopier *util.LabelCopier,
+	evictor core.MetricSetEvictor, deletedPodGracePeriod time.Duration, labelImageIds bool) (*PodBasedEnricher, error) {
 	return &PodBasedEnricher{
-		podLister:   podLister,
-		labelCopier: labelCopier,
+		podLister:             podLister,
+		labelCopier:           labelCopier,
+		labelImageIds:         labelImageIds,
+		evictor:               evictor,
+		deletedPodGracePeriod: deletedPodGracePeriod,
+		knownPods:             make(map[string]podReference),
+		deletedPods:           make(map[string]time.Time),
 	}, nil
 }
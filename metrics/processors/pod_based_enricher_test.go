@@ -26,6 +26,7 @@ import (
 	kube_api "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
 	v1listers "k8s.io/client-go/listers/core/v1"
 	"k8s.io/client-go/tools/cache"
 )
@@ -83,7 +84,10 @@ var batches = []*core.DataBatch{
 	},
 }
 
-const otherResource = "example.com/resource1"
+const (
+	otherResource     = "example.com/resource1"
+	hugePagesResource = kube_api.ResourceHugePagesPrefix + "2Mi"
+)
 
 func TestPodEnricher(t *testing.T) {
 	pod := kube_api.Pod{
@@ -114,16 +118,33 @@ func TestPodEnricher(t *testing.T) {
 							kube_api.ResourceMemory:           *resource.NewQuantity(1000, resource.DecimalSI),
 							kube_api.ResourceEphemeralStorage: *resource.NewQuantity(2000, resource.DecimalSI),
 							otherResource:                     *resource.NewQuantity(2, resource.DecimalSI),
+							hugePagesResource:                 *resource.NewQuantity(4194304, resource.BinarySI),
 						},
 						Limits: kube_api.ResourceList{
 							kube_api.ResourceCPU:              *resource.NewMilliQuantity(2222, resource.DecimalSI),
 							kube_api.ResourceMemory:           *resource.NewQuantity(3333, resource.DecimalSI),
 							kube_api.ResourceEphemeralStorage: *resource.NewQuantity(5000, resource.DecimalSI),
 							otherResource:                     *resource.NewQuantity(2, resource.DecimalSI),
+							hugePagesResource:                 *resource.NewQuantity(4194304, resource.BinarySI),
 						},
 					},
 				},
 			},
+			InitContainers: []kube_api.Container{
+				{
+					Name:  "init",
+					Image: "k8s.gcr.io/pause:2.0",
+				},
+			},
+		},
+		Status: kube_api.PodStatus{
+			InitContainerStatuses: []kube_api.ContainerStatus{
+				{Name: "init", Ready: true},
+			},
+			ContainerStatuses: []kube_api.ContainerStatus{
+				{Name: "c1", Ready: true},
+				{Name: "nginx", Ready: false},
+			},
 		},
 	}
 
@@ -150,11 +171,34 @@ func TestPodEnricher(t *testing.T) {
 		assert.True(t, found)
 		checkRequests(t, podMs, 433, 1555, 3000, 2)
 		checkLimits(t, podMs, 2222, 3333, 5000)
+		assert.Equal(t, int64(1), podMs.MetricValues[core.MetricPodReadyContainers.Name].IntValue)
+		assert.Equal(t, int64(2), podMs.MetricValues[core.MetricPodTotalContainers.Name].IntValue)
 
 		containerMs, found := batch.MetricSets[core.PodContainerKey("ns1", "pod1", "c1")]
 		assert.True(t, found)
 		checkRequests(t, containerMs, 100, 555, 1000, -1)
 		checkLimits(t, containerMs, 0, 0, 0)
+		assert.Empty(t, containerMs.LabeledMetrics)
+
+		containerMs, found = batch.MetricSets[core.PodContainerKey("ns1", "pod1", "nginx")]
+		assert.True(t, found)
+		assert.Len(t, containerMs.LabeledMetrics, 4)
+		for _, name := range []string{core.MetricResourceRequest.Name, core.MetricResourceLimit.Name} {
+			assert.Contains(t, containerMs.LabeledMetrics, core.LabeledMetric{
+				Name:        name,
+				Labels:      map[string]string{core.LabelResourceID.Key: otherResource},
+				MetricValue: intValue(2),
+			})
+		}
+		for _, name := range []string{core.MetricResourceRequestBytes.Name, core.MetricResourceLimitBytes.Name} {
+			assert.Contains(t, containerMs.LabeledMetrics, core.LabeledMetric{
+				Name:        name,
+				Labels:      map[string]string{core.LabelResourceID.Key: hugePagesResource},
+				MetricValue: intValue(4194304),
+			})
+		}
+		assert.Equal(t, core.UnitsBytes, core.ResourceRequestMetrics[hugePagesResource].Units)
+		assert.Equal(t, core.UnitsCount, core.ResourceRequestMetrics[otherResource].Units)
 	}
 }
 
@@ -191,3 +235,168 @@ func checkLimits(t *testing.T, ms *core.MetricSet, cpu, mem int64, storage int64
 	assert.True(t, found)
 	assert.Equal(t, storage, storageVal.IntValue)
 }
+
+type fakeMetricSetEvictor struct {
+	evicted    []string
+	terminated map[string]bool
+}
+
+func (this *fakeMetricSetEvictor) EvictMetricSets(keys []string) {
+	this.evicted = append(this.evicted, keys...)
+}
+
+func (this *fakeMetricSetEvictor) SetTerminated(key string, terminated bool) {
+	this.terminated[key] = terminated
+}
+
+func TestDeletedPodEviction(t *testing.T) {
+	store := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+	podLister := v1listers.NewPodLister(store)
+	labelCopier, err := util.NewLabelCopier(",", []string{}, []string{})
+	assert.NoError(t, err)
+	evictor := &fakeMetricSetEvictor{terminated: map[string]bool{}}
+	podBasedEnricher, err := NewPodBasedEnricher(podLister, labelCopier, evictor, time.Minute, false)
+	assert.NoError(t, err)
+
+	podKey := core.PodKey("ns1", "pod1")
+	containerKey := core.PodContainerKey("ns1", "pod1", "c1")
+	now := time.Now()
+	for _, tc := range []struct {
+		timestamp time.Time
+		evicted   bool
+	}{
+		{timestamp: now.Add(-2 * time.Minute)},
+		{timestamp: now.Add(-90 * time.Second)},
+		{timestamp: now.Add(-time.Minute), evicted: true},
+		{timestamp: now},
+	} {
+		batch := &core.DataBatch{
+			Timestamp: tc.timestamp,
+			MetricSets: map[string]*core.MetricSet{
+				containerKey: {
+					Labels: map[string]string{
+						core.LabelMetricSetType.Key: core.MetricSetTypePodContainer,
+						core.LabelNamespaceName.Key: "ns1",
+						core.LabelPodName.Key:       "pod1",
+						core.LabelContainerName.Key: "c1",
+					},
+					MetricValues: map[string]core.MetricValue{},
+				},
+			},
+		}
+		batch, err = podBasedEnricher.Process(batch)
+		assert.NoError(t, err)
+		// Only the evictor drops the metrics, the batch keeps them.
+		_, found := batch.MetricSets[containerKey]
+		assert.True(t, found)
+		assert.True(t, evictor.terminated[podKey])
+		if tc.evicted {
+			assert.Equal(t, []string{podKey}, evictor.evicted)
+		}
+	}
+	// The pod is seen again after the eviction, so it is evicted only after another grace period.
+	assert.Equal(t, []string{podKey}, evictor.evicted)
+
+	// A pod with the same name is created again.
+	store.Add(&kube_api.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "ns1", Name: "pod1"}})
+	_, err = podBasedEnricher.Process(&core.DataBatch{Timestamp: now.Add(time.Minute), MetricSets: map[string]*core.MetricSet{}})
+	assert.NoError(t, err)
+	assert.False(t, evictor.terminated[podKey])
+}
+
+func TestPodEnricherImageIds(t *testing.T) {
+	store := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+	podLister := v1listers.NewPodLister(store)
+	store.Add(&kube_api.Pod{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "ns1", Name: "pod1"},
+		Spec: kube_api.PodSpec{
+			Containers: []kube_api.Container{
+				{Name: "c1", Image: "nginx:1.15"},
+				{Name: "c2", Image: "busybox"},
+			},
+		},
+		Status: kube_api.PodStatus{
+			ContainerStatuses: []kube_api.ContainerStatus{
+				{Name: "c1", Image: "nginx:1.15", ImageID: "docker-pullable://nginx@sha256:abc"},
+				{Name: "c2", Image: "busybox"},
+			},
+		},
+	})
+	labelCopier, err := util.NewLabelCopier(",", []string{}, []string{})
+	assert.NoError(t, err)
+
+	for _, labelImageIds := range []bool{false, true} {
+		podBasedEnricher, err := NewPodBasedEnricher(podLister, labelCopier, nil, time.Minute, labelImageIds)
+		assert.NoError(t, err)
+		assert.Equal(t, labelImageIds, sets.NewString(podBasedEnricher.ProducedLabels()...).Has(core.LabelContainerImageID.Key))
+
+		batch := &core.DataBatch{Timestamp: time.Now(), MetricSets: map[string]*core.MetricSet{}}
+		for _, container := range []string{"c1", "c2"} {
+			batch.MetricSets[core.PodContainerKey("ns1", "pod1", container)] = &core.MetricSet{
+				Labels: map[string]string{
+					core.LabelMetricSetType.Key: core.MetricSetTypePodContainer,
+					core.LabelNamespaceName.Key: "ns1",
+					core.LabelPodName.Key:       "pod1",
+					core.LabelContainerName.Key: container,
+				},
+				MetricValues: map[string]core.MetricValue{},
+			}
+		}
+		batch, err = podBasedEnricher.Process(batch)
+		assert.NoError(t, err)
+
+		c1 := batch.MetricSets[core.PodContainerKey("ns1", "pod1", "c1")]
+		assert.Equal(t, "nginx:1.15", c1.Labels[core.LabelContainerBaseImage.Key])
+		imageId, found := c1.Labels[core.LabelContainerImageID.Key]
+		assert.Equal(t, labelImageIds, found)
+		if labelImageIds {
+			assert.Equal(t, "docker-pullable://nginx@sha256:abc", imageId)
+		}
+		// The image of c2 isn't pulled yet.
+		_, found = batch.MetricSets[core.PodContainerKey("ns1", "pod1", "c2")].Labels[core.LabelContainerImageID.Key]
+		assert.False(t, found)
+	}
+}
+
+func TestPodEnricherContainerType(t *testing.T) {
+	store := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+	podLister := v1listers.NewPodLister(store)
+	store.Add(&kube_api.Pod{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "ns1", Name: "pod1"},
+		Spec: kube_api.PodSpec{
+			InitContainers: []kube_api.Container{{Name: "init", Image: "busybox"}},
+			Containers: []kube_api.Container{
+				{Name: "c1", Image: "nginx:1.15"},
+				{Name: "c2", Image: "nginx:1.15"},
+			},
+		},
+	})
+	labelCopier, err := util.NewLabelCopier(",", []string{}, []string{})
+	assert.NoError(t, err)
+	podBasedEnricher, err := NewPodBasedEnricher(podLister, labelCopier, nil, time.Minute, false)
+	assert.NoError(t, err)
+	assert.Contains(t, podBasedEnricher.ProducedLabels(), core.LabelContainerType.Key)
+
+	// c2 is missing from the batch, so a stub is created for it.
+	batch := &core.DataBatch{Timestamp: time.Now(), MetricSets: map[string]*core.MetricSet{}}
+	for _, container := range []string{"init", "c1"} {
+		batch.MetricSets[core.PodContainerKey("ns1", "pod1", container)] = &core.MetricSet{
+			Labels: map[string]string{
+				core.LabelMetricSetType.Key: core.MetricSetTypePodContainer,
+				core.LabelNamespaceName.Key: "ns1",
+				core.LabelPodName.Key:       "pod1",
+				core.LabelContainerName.Key: container,
+			},
+			MetricValues: map[string]core.MetricValue{},
+		}
+	}
+	batch, err = podBasedEnricher.Process(batch)
+	assert.NoError(t, err)
+
+	for container, containerType := range map[string]string{"init": core.ContainerTypeInit, "c1": core.ContainerTypeApp, "c2": core.ContainerTypeApp} {
+		ms, found := batch.MetricSets[core.PodContainerKey("ns1", "pod1", container)]
+		if assert.True(t, found, container) {
+			assert.Equal(t, containerType, ms.Labels[core.LabelContainerType.Key], container)
+		}
+	}
+}
@@ -0,0 +1,77 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processors
+
+import (
+	"github.com/golang/glog"
+
+	"k8s.io/apimachinery/pkg/labels"
+	v1listers "k8s.io/client-go/listers/core/v1"
+	"k8s.io/heapster/metrics/core"
+)
+
+// PodSelectorFilter drops the metric sets of the pods, and their containers, that don't match a
+// label selector. Pods missing from the pod cache are dropped as well, since their labels are
+// unknown.
+type PodSelectorFilter struct {
+	podLister v1listers.PodLister
+	selector  labels.Selector
+}
+
+func (this *PodSelectorFilter) Name() string {
+	return "pod_selector_filter"
+}
+
+func (this *PodSelectorFilter) Process(batch *core.DataBatch) (*core.DataBatch, error) {
+	// Pods have several metric sets, so each is matched once.
+	selected := make(map[string]bool)
+	for key, ms := range batch.MetricSets {
+		switch ms.Labels[core.LabelMetricSetType.Key] {
+		case core.MetricSetTypePod, core.MetricSetTypePodContainer:
+		default:
+			continue
+		}
+		namespace := ms.Labels[core.LabelNamespaceName.Key]
+		podName := ms.Labels[core.LabelPodName.Key]
+		podKey := core.PodKey(namespace, podName)
+		matches, found := selected[podKey]
+		if !found {
+			pod, err := this.podLister.Pods(namespace).Get(podName)
+			if err != nil || pod == nil {
+				glog.V(3).Infof("Failed to get pod %s from cache: %v", podKey, err)
+			} else {
+				matches = this.selector.Matches(labels.Set(pod.Labels))
+			}
+			selected[podKey] = matches
+		}
+		if !matches {
+			delete(batch.MetricSets, key)
+		}
+	}
+	return batch, nil
+}
+
+// NewPodSelectorFilter creates a PodSelectorFilter keeping the pods matching the selector, in
+// the label selector syntax of Kubernetes, e.g. team=monitoring,tier!=test.
+func NewPodSelectorFilter(podLister v1listers.PodLister, selector string) (*PodSelectorFilter, error) {
+	parsed, err := labels.Parse(selector)
+	if err != nil {
+		return nil, err
+	}
+	return &PodSelectorFilter{
+		podLister: podLister,
+		selector:  parsed,
+	}, nil
+}
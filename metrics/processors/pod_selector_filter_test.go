@@ -0,0 +1,69 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processors
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	kube_api "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	v1listers "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/heapster/metrics/core"
+)
+
+func TestPodSelectorFilter(t *testing.T) {
+	store := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+	store.Add(&kube_api.Pod{ObjectMeta: metav1.ObjectMeta{Name: "ours", Namespace: "ns1", Labels: map[string]string{"team": "a"}}})
+	store.Add(&kube_api.Pod{ObjectMeta: metav1.ObjectMeta{Name: "theirs", Namespace: "ns1", Labels: map[string]string{"team": "b"}}})
+	filter, err := NewPodSelectorFilter(v1listers.NewPodLister(store), "team=a")
+	assert.NoError(t, err)
+
+	labels := func(metricSetType, podName string) map[string]string {
+		return map[string]string{
+			core.LabelMetricSetType.Key: metricSetType,
+			core.LabelNamespaceName.Key: "ns1",
+			core.LabelPodName.Key:       podName,
+		}
+	}
+	batch := &core.DataBatch{
+		Timestamp: time.Now(),
+		MetricSets: map[string]*core.MetricSet{
+			core.PodKey("ns1", "ours"):                  {Labels: labels(core.MetricSetTypePod, "ours")},
+			core.PodContainerKey("ns1", "ours", "c1"):   {Labels: labels(core.MetricSetTypePodContainer, "ours")},
+			core.PodKey("ns1", "theirs"):                {Labels: labels(core.MetricSetTypePod, "theirs")},
+			core.PodContainerKey("ns1", "theirs", "c1"): {Labels: labels(core.MetricSetTypePodContainer, "theirs")},
+			core.PodKey("ns1", "unknown"):               {Labels: labels(core.MetricSetTypePod, "unknown")},
+			core.NodeKey("node1"):                       {Labels: map[string]string{core.LabelMetricSetType.Key: core.MetricSetTypeNode}},
+		},
+	}
+	batch, err = filter.Process(batch)
+	assert.NoError(t, err)
+
+	keys := []string{}
+	for key := range batch.MetricSets {
+		keys = append(keys, key)
+	}
+	assert.Len(t, keys, 3)
+	assert.Contains(t, keys, core.PodKey("ns1", "ours"))
+	assert.Contains(t, keys, core.PodContainerKey("ns1", "ours", "c1"))
+	assert.Contains(t, keys, core.NodeKey("node1"))
+
+	_, err = NewPodSelectorFilter(v1listers.NewPodLister(store), "team in (a")
+	assert.Error(t, err)
+}
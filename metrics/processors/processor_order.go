@@ -0,0 +1,49 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processors
+
+import (
+	"fmt"
+
+	"k8s.io/heapster/metrics/core"
+)
+
+// ValidateProcessorOrder checks that no processor requires a label that is set only by a
+// processor running after it. Labels not set by any processor are expected to come from the source.
+func ValidateProcessorOrder(processors []core.DataProcessor) error {
+	producedBy := make(map[string]int)
+	for i, processor := range processors {
+		if deps, ok := processor.(core.DataProcessorDependencies); ok {
+			for _, label := range deps.ProducedLabels() {
+				if _, found := producedBy[label]; !found {
+					producedBy[label] = i
+				}
+			}
+		}
+	}
+	for i, processor := range processors {
+		deps, ok := processor.(core.DataProcessorDependencies)
+		if !ok {
+			continue
+		}
+		for _, label := range deps.RequiredLabels() {
+			if producer, found := producedBy[label]; found && producer > i {
+				return fmt.Errorf("processor %s requires label %s, which is set by %s running after it",
+					processor.Name(), label, processors[producer].Name())
+			}
+		}
+	}
+	return nil
+}
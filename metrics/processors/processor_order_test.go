@@ -0,0 +1,57 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processors
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"k8s.io/heapster/metrics/core"
+)
+
+func TestValidateProcessorOrder(t *testing.T) {
+	defaultOrder := []core.DataProcessor{
+		NewRateCalculator(core.RateMetricsMapping),
+		&PodBasedEnricher{},
+		&NamespaceBasedEnricher{},
+		NewPodAggregator(false),
+		&NamespaceAggregator{},
+		&NodeAggregator{},
+		&ClusterAggregator{},
+		&NodeAutoscalingEnricher{},
+	}
+	assert.NoError(t, ValidateProcessorOrder(defaultOrder))
+
+	// Processors setting the required labels are optional.
+	assert.NoError(t, ValidateProcessorOrder([]core.DataProcessor{
+		NewPodAggregator(false),
+		&NodeAggregator{},
+	}))
+
+	err := ValidateProcessorOrder([]core.DataProcessor{
+		NewPodAggregator(false),
+		&PodBasedEnricher{},
+	})
+	assert.EqualError(t, err, "processor pod_aggregator requires label pod_id, which is set by pod_based_enricher running after it")
+
+	err = ValidateProcessorOrder([]core.DataProcessor{
+		&PodBasedEnricher{},
+		&ClusterAggregator{},
+		NewPodAggregator(false),
+		&NamespaceAggregator{},
+	})
+	assert.Error(t, err)
+}
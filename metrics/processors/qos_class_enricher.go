@@ -0,0 +1,133 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processors
+
+import (
+	"github.com/golang/glog"
+
+	kube_api "k8s.io/api/core/v1"
+	v1listers "k8s.io/client-go/listers/core/v1"
+	"k8s.io/heapster/metrics/core"
+)
+
+// QosClassEnricher labels pod and container metric sets with the quality of service class of
+// their pod.
+type QosClassEnricher struct {
+	podLister v1listers.PodLister
+}
+
+func (this *QosClassEnricher) Name() string {
+	return "qos_class_enricher"
+}
+
+func (this *QosClassEnricher) RequiredLabels() []string {
+	return nil
+}
+
+func (this *QosClassEnricher) ProducedLabels() []string {
+	return []string{core.LabelQosClass.Key}
+}
+
+func (this *QosClassEnricher) Process(batch *core.DataBatch) (*core.DataBatch, error) {
+	// Pods have several metric sets, so the class of each is computed once.
+	classes := make(map[string]kube_api.PodQOSClass)
+	for _, ms := range batch.MetricSets {
+		switch ms.Labels[core.LabelMetricSetType.Key] {
+		case core.MetricSetTypePod, core.MetricSetTypePodContainer:
+		default:
+			continue
+		}
+		namespace := ms.Labels[core.LabelNamespaceName.Key]
+		podName := ms.Labels[core.LabelPodName.Key]
+		podKey := core.PodKey(namespace, podName)
+		class, found := classes[podKey]
+		if !found {
+			pod, err := this.podLister.Pods(namespace).Get(podName)
+			if err != nil || pod == nil {
+				glog.V(3).Infof("Failed to get pod %s from cache: %v", podKey, err)
+				continue
+			}
+			class = GetPodQosClass(pod)
+			classes[podKey] = class
+		}
+		ms.Labels[core.LabelQosClass.Key] = string(class)
+	}
+	return batch, nil
+}
+
+// isQosResource returns whether the resource is taken into account for the QoS class.
+func isQosResource(name kube_api.ResourceName) bool {
+	return name == kube_api.ResourceCPU || name == kube_api.ResourceMemory
+}
+
+// addQosResources adds the positive cpu and memory quantities of the list to the sums and
+// returns how many of the two resources it had.
+func addQosResources(sums kube_api.ResourceList, resources kube_api.ResourceList) int {
+	found := 0
+	for name, quantity := range resources {
+		if !isQosResource(name) || quantity.Sign() <= 0 {
+			continue
+		}
+		found++
+		sum := quantity.DeepCopy()
+		if previous, ok := sums[name]; ok {
+			sum.Add(previous)
+		}
+		sums[name] = sum
+	}
+	return found
+}
+
+// GetPodQosClass returns the QoS class of the pod set in its status by Kubernetes. For pods
+// whose status doesn't have it yet, it's computed from the cpu and memory requests and limits
+// of the containers the way Kubernetes does. A pod is Guaranteed if all its containers have cpu
+// and memory limits and the requests equal the limits, BestEffort if none has any request or
+// limit, and Burstable otherwise.
+func GetPodQosClass(pod *kube_api.Pod) kube_api.PodQOSClass {
+	if pod.Status.QOSClass != "" {
+		return pod.Status.QOSClass
+	}
+	requests := kube_api.ResourceList{}
+	limits := kube_api.ResourceList{}
+	guaranteed := true
+	containers := append(append([]kube_api.Container{}, pod.Spec.Containers...), pod.Spec.InitContainers...)
+	for _, container := range containers {
+		addQosResources(requests, container.Resources.Requests)
+		if addQosResources(limits, container.Resources.Limits) < 2 {
+			guaranteed = false
+		}
+	}
+	if len(requests) == 0 && len(limits) == 0 {
+		return kube_api.PodQOSBestEffort
+	}
+	if guaranteed {
+		for name, request := range requests {
+			if limit, found := limits[name]; !found || limit.Cmp(request) != 0 {
+				guaranteed = false
+				break
+			}
+		}
+	}
+	if guaranteed && len(requests) == len(limits) {
+		return kube_api.PodQOSGuaranteed
+	}
+	return kube_api.PodQOSBurstable
+}
+
+func NewQosClassEnricher(podLister v1listers.PodLister) *QosClassEnricher {
+	return &QosClassEnricher{
+		podLister: podLister,
+	}
+}
@@ -0,0 +1,108 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processors
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	kube_api "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	v1listers "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/heapster/metrics/core"
+)
+
+func qosResources(cpu, memory int64) kube_api.ResourceList {
+	result := kube_api.ResourceList{}
+	if cpu > 0 {
+		result[kube_api.ResourceCPU] = *resource.NewMilliQuantity(cpu, resource.DecimalSI)
+	}
+	if memory > 0 {
+		result[kube_api.ResourceMemory] = *resource.NewQuantity(memory, resource.BinarySI)
+	}
+	return result
+}
+
+func qosPod(name string, containers ...kube_api.ResourceRequirements) *kube_api.Pod {
+	pod := &kube_api.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: "ns1",
+		},
+	}
+	for _, resources := range containers {
+		pod.Spec.Containers = append(pod.Spec.Containers, kube_api.Container{Resources: resources})
+	}
+	return pod
+}
+
+func TestGetPodQosClass(t *testing.T) {
+	guaranteed := kube_api.ResourceRequirements{Requests: qosResources(100, 1000), Limits: qosResources(100, 1000)}
+	for _, c := range []struct {
+		pod  *kube_api.Pod
+		want kube_api.PodQOSClass
+	}{
+		{qosPod("none"), kube_api.PodQOSBestEffort},
+		{qosPod("empty", kube_api.ResourceRequirements{}), kube_api.PodQOSBestEffort},
+		{qosPod("guaranteed", guaranteed, guaranteed), kube_api.PodQOSGuaranteed},
+		{qosPod("requests", kube_api.ResourceRequirements{Requests: qosResources(100, 0)}), kube_api.PodQOSBurstable},
+		{qosPod("cpu_limit", kube_api.ResourceRequirements{Requests: qosResources(100, 0), Limits: qosResources(100, 0)}),
+			kube_api.PodQOSBurstable},
+		{qosPod("below_limits", kube_api.ResourceRequirements{Requests: qosResources(50, 1000), Limits: qosResources(100, 1000)}),
+			kube_api.PodQOSBurstable},
+		{qosPod("one_best_effort", guaranteed, kube_api.ResourceRequirements{}), kube_api.PodQOSBurstable},
+	} {
+		assert.Equal(t, c.want, GetPodQosClass(c.pod), c.pod.Name)
+	}
+
+	// The class in the status takes precedence.
+	pod := qosPod("status", kube_api.ResourceRequirements{})
+	pod.Status.QOSClass = kube_api.PodQOSBurstable
+	assert.Equal(t, kube_api.PodQOSBurstable, GetPodQosClass(pod))
+}
+
+func TestQosClassEnricher(t *testing.T) {
+	store := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+	store.Add(qosPod("pod1", kube_api.ResourceRequirements{Requests: qosResources(100, 1000), Limits: qosResources(100, 1000)}))
+	enricher := NewQosClassEnricher(v1listers.NewPodLister(store))
+
+	labels := func(metricSetType, podName string) map[string]string {
+		return map[string]string{
+			core.LabelMetricSetType.Key: metricSetType,
+			core.LabelNamespaceName.Key: "ns1",
+			core.LabelPodName.Key:       podName,
+		}
+	}
+	batch := &core.DataBatch{
+		Timestamp: time.Now(),
+		MetricSets: map[string]*core.MetricSet{
+			core.PodKey("ns1", "pod1"):                {Labels: labels(core.MetricSetTypePod, "pod1")},
+			core.PodContainerKey("ns1", "pod1", "c1"): {Labels: labels(core.MetricSetTypePodContainer, "pod1")},
+			core.PodKey("ns1", "deleted"):             {Labels: labels(core.MetricSetTypePod, "deleted")},
+			core.NamespaceKey("ns1"):                  {Labels: map[string]string{core.LabelMetricSetType.Key: core.MetricSetTypeNamespace}},
+		},
+	}
+	batch, err := enricher.Process(batch)
+	assert.NoError(t, err)
+
+	assert.Equal(t, "Guaranteed", batch.MetricSets[core.PodKey("ns1", "pod1")].Labels[core.LabelQosClass.Key])
+	assert.Equal(t, "Guaranteed", batch.MetricSets[core.PodContainerKey("ns1", "pod1", "c1")].Labels[core.LabelQosClass.Key])
+	assert.NotContains(t, batch.MetricSets[core.PodKey("ns1", "deleted")].Labels, core.LabelQosClass.Key)
+	assert.NotContains(t, batch.MetricSets[core.NamespaceKey("ns1")].Labels, core.LabelQosClass.Key)
+}
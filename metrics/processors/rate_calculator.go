@@ -26,7 +26,7 @@ type RateCalculator struct {
 }
 
 func (this *RateCalculator) Name() string {
-	return "rate calculator"
+	return "rate_calculator"
 }
 
 func (this *RateCalculator) Process(batch *core.DataBatch) (*core.DataBatch, error) {
@@ -46,6 +46,11 @@ func (this *RateCalculator) Process(batch *core.DataBatch) (*core.DataBatch, err
 		if !found {
 			continue
 		}
+		if isInterpolated(newMs) {
+			// The values filled in for a missed scrape didn't change, so they'd give a zero rate.
+			glog.V(4).Infof("Skipping rate calculations for %s - values were filled in for a missed scrape", key)
+			continue
+		}
 		if !newMs.ScrapeTime.After(oldMs.ScrapeTime) {
 			// New must be strictly after old.
 			glog.V(4).Infof("Skipping rate calculations for %s - new batch (%s) was not scraped strictly after old batch (%s)", key, newMs.ScrapeTime, oldMs.ScrapeTime)
@@ -77,7 +82,7 @@ func (this *RateCalculator) Process(batch *core.DataBatch) (*core.DataBatch, err
 
 					if foundNew && foundOld {
 						if targetMetric.MetricDescriptor.ValueType == core.ValueFloat {
-							newVal := 1e9 * float64(metricValNew.IntValue-metricValOld.IntValue) /
+							newVal := 1e9 * float64(cumulativeDelta(metricValNew, metricValOld)) /
 								float64(newMs.ScrapeTime.UnixNano()-oldMs.ScrapeTime.UnixNano())
 
 							newMs.LabeledMetrics = append(newMs.LabeledMetrics, core.LabeledMetric{
@@ -100,7 +105,7 @@ func (this *RateCalculator) Process(batch *core.DataBatch) (*core.DataBatch, err
 
 				if foundNew && foundOld && metricName == core.MetricCpuUsage.MetricDescriptor.Name {
 					// cpu/usage values are in nanoseconds; we want to have it in millicores (that's why constant 1000 is here).
-					newVal := 1000 * (metricValNew.IntValue - metricValOld.IntValue) /
+					newVal := 1000 * cumulativeDelta(metricValNew, metricValOld) /
 						(newMs.ScrapeTime.UnixNano() - oldMs.ScrapeTime.UnixNano())
 
 					newMs.MetricValues[targetMetric.MetricDescriptor.Name] = core.MetricValue{
@@ -110,7 +115,7 @@ func (this *RateCalculator) Process(batch *core.DataBatch) (*core.DataBatch, err
 					}
 
 				} else if foundNew && foundOld && targetMetric.MetricDescriptor.ValueType == core.ValueFloat {
-					newVal := 1e9 * float64(metricValNew.IntValue-metricValOld.IntValue) /
+					newVal := 1e9 * float64(cumulativeDelta(metricValNew, metricValOld)) /
 						float64(newMs.ScrapeTime.UnixNano()-oldMs.ScrapeTime.UnixNano())
 
 					newMs.MetricValues[targetMetric.MetricDescriptor.Name] = core.MetricValue{
@@ -124,10 +129,39 @@ func (this *RateCalculator) Process(batch *core.DataBatch) (*core.DataBatch, err
 			}
 		}
 	}
-	this.previousBatch = batch
+	this.previousBatch = previousBatch(this.previousBatch, batch)
 	return batch, nil
 }
 
+// previousBatch returns the batch against which the rates of the next one are computed: the
+// given batch, except that the metric sets filled in for missed scrapes are replaced with the last
+// scraped ones, so that the next scrape gets its rate over the whole interval instead of a spike.
+func previousBatch(previous, batch *core.DataBatch) *core.DataBatch {
+	result := &core.DataBatch{
+		Timestamp:  batch.Timestamp,
+		MetricSets: make(map[string]*core.MetricSet, len(batch.MetricSets)),
+	}
+	for key, ms := range batch.MetricSets {
+		if isInterpolated(ms) {
+			if scraped, found := previous.MetricSets[key]; found {
+				result.MetricSets[key] = scraped
+			}
+			continue
+		}
+		result.MetricSets[key] = ms
+	}
+	return result
+}
+
+// cumulativeDelta returns the increase of a cumulative metric between two batches. After a reset
+// the counter started again from zero, so the whole new value is the increase.
+func cumulativeDelta(newVal, oldVal core.MetricValue) int64 {
+	if newVal.Reset {
+		return newVal.IntValue
+	}
+	return newVal.IntValue - oldVal.IntValue
+}
+
 func NewRateCalculator(metrics map[string]core.Metric) *RateCalculator {
 	return &RateCalculator{
 		rateMetricsMapping: metrics,
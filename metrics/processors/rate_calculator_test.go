@@ -91,3 +91,41 @@ func TestRateCalculator(t *testing.T) {
 	assert.InEpsilon(t, 13, cpuRate.IntValue, 2)
 	assert.InEpsilon(t, 2, txeRate.FloatValue, 0.1)
 }
+
+func TestRateCalculatorFirstBatchAndReset(t *testing.T) {
+	key := core.PodContainerKey("ns1", "pod1", "c")
+	now := time.Now()
+	batch := func(scrapeTime time.Time, usage int64, reset bool) *core.DataBatch {
+		return &core.DataBatch{
+			Timestamp: scrapeTime,
+			MetricSets: map[string]*core.MetricSet{
+				key: {
+					CollectionStartTime: now.Add(-time.Hour),
+					ScrapeTime:          scrapeTime,
+					Labels: map[string]string{
+						core.LabelMetricSetType.Key: core.MetricSetTypePodContainer,
+					},
+					MetricValues: map[string]core.MetricValue{
+						core.MetricCpuUsage.MetricDescriptor.Name: {
+							ValueType:  core.ValueInt64,
+							MetricType: core.MetricCumulative,
+							IntValue:   usage,
+							Reset:      reset,
+						},
+					},
+				},
+			},
+		}
+	}
+
+	procesor := NewRateCalculator(core.RateMetricsMapping)
+	first := batch(now.Add(-time.Minute), 60e9, false)
+	procesor.Process(first)
+	_, found := first.MetricSets[key].MetricValues[core.MetricCpuUsageRate.Name]
+	assert.False(t, found)
+
+	// After a reset the rate is computed from the new value alone, instead of a negative delta.
+	current := batch(now, 30e9, true)
+	procesor.Process(current)
+	assert.Equal(t, int64(500), current.MetricSets[key].MetricValues[core.MetricCpuUsageRate.Name].IntValue)
+}
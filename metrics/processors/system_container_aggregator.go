@@ -0,0 +1,69 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processors
+
+import (
+	"github.com/golang/glog"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/heapster/metrics/core"
+)
+
+// SystemContainerAggregator sums the cpu and memory usage of the system containers of every node
+// into the node/system_cpu and node/system_memory metrics of the node, so that the node management
+// overhead can be told apart from the pods.
+type SystemContainerAggregator struct {
+	ContainerNames []string
+}
+
+func (this *SystemContainerAggregator) Name() string {
+	return "system_container_aggregator"
+}
+
+func (this *SystemContainerAggregator) Process(batch *core.DataBatch) (*core.DataBatch, error) {
+	containerNames := sets.NewString(this.ContainerNames...)
+	for key, metricSet := range batch.MetricSets {
+		if metricSet.Labels[core.LabelMetricSetType.Key] != core.MetricSetTypeSystemContainer ||
+			!containerNames.Has(metricSet.Labels[core.LabelContainerName.Key]) {
+			continue
+		}
+		nodeName := metricSet.Labels[core.LabelNodename.Key]
+		if nodeName == "" {
+			glog.V(8).Infof("Skipping system container %s: no node info", key)
+			continue
+		}
+		node, found := batch.MetricSets[core.NodeKey(nodeName)]
+		if !found {
+			glog.V(1).Infof("No metric for node %s, cannot aggregate its system containers.", nodeName)
+			continue
+		}
+		// The usage rate is missing on the first scrape of a container, the sums are only set
+		// from the values that are present.
+		if usage, found := metricSet.MetricValues[core.MetricCpuUsageRate.Name]; found {
+			addNodeSystemUsage(node, core.MetricNodeSystemCpu.Name, usage)
+		}
+		if usage, found := metricSet.MetricValues[core.MetricMemoryUsage.Name]; found {
+			addNodeSystemUsage(node, core.MetricNodeSystemMemory.Name, usage)
+		}
+	}
+	return batch, nil
+}
+
+func addNodeSystemUsage(node *core.MetricSet, metricName string, usage core.MetricValue) {
+	sum := node.MetricValues[metricName]
+	sum.ValueType = core.ValueInt64
+	sum.MetricType = core.MetricGauge
+	sum.IntValue += usage.IntValue
+	node.MetricValues[metricName] = sum
+}
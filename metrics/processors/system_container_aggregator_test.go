@@ -0,0 +1,95 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processors
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"k8s.io/heapster/metrics/core"
+)
+
+func TestSystemContainerAggregator(t *testing.T) {
+	systemContainer := func(name string, cpu, memory int64) *core.MetricSet {
+		return &core.MetricSet{
+			Labels: map[string]string{
+				core.LabelMetricSetType.Key: core.MetricSetTypeSystemContainer,
+				core.LabelContainerName.Key: name,
+				core.LabelNodename.Key:      "node1",
+			},
+			MetricValues: map[string]core.MetricValue{
+				core.MetricCpuUsageRate.Name: {ValueType: core.ValueInt64, MetricType: core.MetricGauge, IntValue: cpu},
+				core.MetricMemoryUsage.Name:  {ValueType: core.ValueInt64, MetricType: core.MetricGauge, IntValue: memory},
+			},
+		}
+	}
+	batch := &core.DataBatch{
+		Timestamp: time.Now(),
+		MetricSets: map[string]*core.MetricSet{
+			core.NodeKey("node1"): {
+				Labels:       map[string]string{core.LabelMetricSetType.Key: core.MetricSetTypeNode},
+				MetricValues: map[string]core.MetricValue{},
+			},
+			core.NodeContainerKey("node1", "kubelet"):       systemContainer("kubelet", 100, 1000),
+			core.NodeContainerKey("node1", "docker-daemon"): systemContainer("docker-daemon", 50, 500),
+			core.NodeContainerKey("node1", "other"):         systemContainer("other", 1, 1),
+			core.NodeContainerKey("node2", "kubelet"):       systemContainer("kubelet", 1, 1),
+		},
+	}
+	batch.MetricSets[core.NodeContainerKey("node2", "kubelet")].Labels[core.LabelNodename.Key] = "node2"
+
+	aggregator := SystemContainerAggregator{ContainerNames: core.DefaultSystemContainers}
+	batch, err := aggregator.Process(batch)
+	assert.NoError(t, err)
+
+	node := batch.MetricSets[core.NodeKey("node1")]
+	assert.Equal(t, int64(150), node.MetricValues[core.MetricNodeSystemCpu.Name].IntValue)
+	assert.Equal(t, int64(1500), node.MetricValues[core.MetricNodeSystemMemory.Name].IntValue)
+	assert.Equal(t, core.MetricGauge, node.MetricValues[core.MetricNodeSystemCpu.Name].MetricType)
+}
+
+func TestSystemContainerAggregatorMissingMetrics(t *testing.T) {
+	// A container on its first scrape has no usage rate yet.
+	batch := &core.DataBatch{
+		Timestamp: time.Now(),
+		MetricSets: map[string]*core.MetricSet{
+			core.NodeKey("node1"): {
+				Labels:       map[string]string{core.LabelMetricSetType.Key: core.MetricSetTypeNode},
+				MetricValues: map[string]core.MetricValue{},
+			},
+			core.NodeContainerKey("node1", "kubelet"): {
+				Labels: map[string]string{
+					core.LabelMetricSetType.Key: core.MetricSetTypeSystemContainer,
+					core.LabelContainerName.Key: "kubelet",
+					core.LabelNodename.Key:      "node1",
+				},
+				MetricValues: map[string]core.MetricValue{
+					core.MetricMemoryUsage.Name: {ValueType: core.ValueInt64, MetricType: core.MetricGauge, IntValue: 1000},
+				},
+			},
+		},
+	}
+
+	aggregator := SystemContainerAggregator{ContainerNames: core.DefaultSystemContainers}
+	batch, err := aggregator.Process(batch)
+	assert.NoError(t, err)
+
+	node := batch.MetricSets[core.NodeKey("node1")]
+	_, found := node.MetricValues[core.MetricNodeSystemCpu.Name]
+	assert.False(t, found)
+	assert.Equal(t, int64(1000), node.MetricValues[core.MetricNodeSystemMemory.Name].IntValue)
+}
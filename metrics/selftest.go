@@ -0,0 +1,121 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/golang/glog"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/heapster/metrics/core"
+)
+
+// How long the self test waits for the caches of the listers to be filled before scraping.
+const selfTestSyncTimeout = time.Minute
+
+// runSelfTest scrapes the first source of the provider, e.g. a single node, once, runs the
+// batch through the data processors and logs a summary of the result. It returns an error
+// if the caches don't sync, the scrape fails or the pipeline produces no metrics.
+func runSelfTest(sourceProvider core.MetricsSourceProvider, dataProcessors []core.DataProcessor,
+	listersSynced []cache.InformerSynced, resolution time.Duration) error {
+	if err := waitForSelfTestCaches(sourceProvider, dataProcessors, listersSynced, selfTestSyncTimeout); err != nil {
+		return err
+	}
+	sources := sourceProvider.GetMetricsSources()
+	if len(sources) == 0 {
+		return fmt.Errorf("no sources to scrape")
+	}
+	source := sources[0]
+	end := time.Now()
+	glog.Infof("Self test: scraping %s", source.Name())
+	batch, err := source.ScrapeMetrics(end.Add(-resolution), end)
+	if err != nil {
+		return fmt.Errorf("failed to scrape %s: %v", source.Name(), err)
+	}
+	if batch == nil || len(batch.MetricSets) == 0 {
+		return fmt.Errorf("no metric sets scraped from %s", source.Name())
+	}
+	glog.Infof("Self test: scraped %d metric sets from %s", len(batch.MetricSets), source.Name())
+
+	for _, processor := range dataProcessors {
+		batch, err = processor.Process(batch)
+		if err != nil {
+			return fmt.Errorf("processor %s failed: %v", processor.Name(), err)
+		}
+	}
+
+	metricSetTypes := make(map[string]int)
+	metricNames := make(map[string]bool)
+	labels := make(map[string]bool)
+	for _, ms := range batch.MetricSets {
+		metricSetTypes[ms.Labels[core.LabelMetricSetType.Key]]++
+		for name := range ms.MetricValues {
+			metricNames[name] = true
+		}
+		for _, metric := range ms.LabeledMetrics {
+			metricNames[metric.Name] = true
+		}
+		for key := range ms.Labels {
+			labels[key] = true
+		}
+	}
+	if len(metricNames) == 0 {
+		return fmt.Errorf("the pipeline produced no metrics from %s", source.Name())
+	}
+	types := make([]string, 0, len(metricSetTypes))
+	for metricSetType, count := range metricSetTypes {
+		types = append(types, fmt.Sprintf("%s=%d", metricSetType, count))
+	}
+	sort.Strings(types)
+	glog.Infof("Self test: %d metric sets after processing: %s", len(batch.MetricSets), strings.Join(types, ", "))
+	glog.Infof("Self test: metrics seen: %s", strings.Join(sortedKeys(metricNames), ", "))
+	glog.Infof("Self test: labels present: %s", strings.Join(sortedKeys(labels), ", "))
+	return nil
+}
+
+// waitForSelfTestCaches waits until the caches of the listers, the provider and the processors
+// received the initial list of objects, or returns an error after the timeout.
+func waitForSelfTestCaches(sourceProvider core.MetricsSourceProvider, dataProcessors []core.DataProcessor,
+	listersSynced []cache.InformerSynced, timeout time.Duration) error {
+	syncs := append([]cache.InformerSynced{}, listersSynced...)
+	if syncer, ok := sourceProvider.(core.CacheSyncer); ok {
+		syncs = append(syncs, syncer.HasSynced)
+	}
+	for _, processor := range dataProcessors {
+		if syncer, ok := processor.(core.CacheSyncer); ok {
+			syncs = append(syncs, syncer.HasSynced)
+		}
+	}
+	stopCh := make(chan struct{})
+	timer := time.AfterFunc(timeout, func() { close(stopCh) })
+	defer timer.Stop()
+	glog.Infof("Self test: waiting for %d caches to sync", len(syncs))
+	if !cache.WaitForCacheSync(stopCh, syncs...) {
+		return fmt.Errorf("caches did not sync within %v", timeout)
+	}
+	return nil
+}
+
+func sortedKeys(set map[string]bool) []string {
+	result := make([]string, 0, len(set))
+	for key := range set {
+		result = append(result, key)
+	}
+	sort.Strings(result)
+	return result
+}
@@ -32,6 +32,8 @@ type elasticSearchSink struct {
 	esSvc     esCommon.ElasticSearchService
 	saveData  SaveDataFunc
 	flushData func() error
+	// Checks that ElasticSearch is reachable.
+	checkConnection func() error
 	sync.RWMutex
 }
 
@@ -53,9 +55,16 @@ func (sink *elasticSearchSink) ExportData(dataBatch *core.DataBatch) {
 
 	for _, metricSet := range dataBatch.MetricSets {
 		familyPoints := EsFamilyPoints{}
+		timestamp := core.PointTimestamp(dataBatch, metricSet)
 
+		// addMetric adds the cluster name to the tags, the labels of the batch shared with the
+		// other sinks must not be modified.
+		tags := make(map[string]string, len(metricSet.Labels)+1)
+		for k, v := range metricSet.Labels {
+			tags[k] = v
+		}
 		for metricName, metricValue := range metricSet.MetricValues {
-			familyPoints = addMetric(familyPoints, metricName, dataBatch.Timestamp, metricSet.Labels, metricValue.GetValue(), sink.esSvc.ClusterName)
+			familyPoints = addMetric(familyPoints, metricName, timestamp, tags, metricValue.GetValue(), sink.esSvc.ClusterName)
 		}
 		for _, metric := range metricSet.LabeledMetrics {
 			labels := make(map[string]string)
@@ -66,11 +75,11 @@ func (sink *elasticSearchSink) ExportData(dataBatch *core.DataBatch) {
 				labels[k] = v
 			}
 
-			familyPoints = addMetric(familyPoints, metric.Name, dataBatch.Timestamp, labels, metric.GetValue(), sink.esSvc.ClusterName)
+			familyPoints = addMetric(familyPoints, metric.Name, timestamp, labels, metric.GetValue(), sink.esSvc.ClusterName)
 		}
 
 		for family, dataPoints := range familyPoints {
-			err := sink.saveData(dataBatch.Timestamp.UTC(), string(family), dataPoints)
+			err := sink.saveData(timestamp.UTC(), string(family), dataPoints)
 			if err != nil {
 				glog.Warningf("Failed to export data to ElasticSearch sink: %v", err)
 			}
@@ -150,7 +159,19 @@ func (sink *elasticSearchSink) Name() string {
 }
 
 func (sink *elasticSearchSink) Stop() {
-	// nothing needs to be done.
+	sink.Lock()
+	defer sink.Unlock()
+
+	// Write whatever is still queued in the bulk processor.
+	if err := sink.flushData(); err != nil {
+		glog.Warningf("Failed to flush data to ElasticSearch sink on stop: %v", err)
+	}
+}
+
+func (sink *elasticSearchSink) CheckConnection() error {
+	sink.Lock()
+	defer sink.Unlock()
+	return sink.checkConnection()
 }
 
 func NewElasticSearchSink(uri *url.URL) (core.DataSink, error) {
@@ -168,6 +189,9 @@ func NewElasticSearchSink(uri *url.URL) (core.DataSink, error) {
 	esSink.flushData = func() error {
 		return esSvc.FlushData()
 	}
+	esSink.checkConnection = func() error {
+		return esSvc.CheckConnection()
+	}
 
 	glog.V(2).Info("ElasticSearch sink setup successfully")
 	return &esSink, nil
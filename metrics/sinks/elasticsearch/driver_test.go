@@ -185,6 +185,10 @@ func TestStoreMultipleDataInput(t *testing.T) {
 	FakeESSink = NewFakeSink()
 	FakeESSink.ExportData(&data)
 
+	// The cluster name is only added to the exported tags, not to the labels of the batch.
+	_, found := l["cluster_name"]
+	assert.False(t, found)
+
 	//expect msg string
 	assert.Equal(t, 2, len(FakeESSink.savedData))
 
@@ -20,6 +20,10 @@ import (
 
 	"github.com/golang/glog"
 	"k8s.io/heapster/common/flags"
+	honeycomb_common "k8s.io/heapster/common/honeycomb"
+	influxdb_common "k8s.io/heapster/common/influxdb"
+	librato_common "k8s.io/heapster/common/librato"
+	"k8s.io/heapster/common/reconnect"
 	"k8s.io/heapster/metrics/core"
 	"k8s.io/heapster/metrics/sinks/elasticsearch"
 	"k8s.io/heapster/metrics/sinks/gcm"
@@ -38,7 +42,46 @@ import (
 	"k8s.io/heapster/metrics/sinks/wavefront"
 )
 
+// commonSinkOptions are the uri options handled for every sink by BuildAll.
+var commonSinkOptions = []string{"labelInclude", "labelExclude", "metricAllow", "metricDeny", "includeSystemSlice", "alignTimestamps"}
+
+// sinkOptions are the uri options understood by each sink, options in neither list trigger a
+// warning so that typos don't go unnoticed.
+var sinkOptions = map[string][]string{
+	"elasticsearch": {"ver", "cluster_name", "index", "nodes", "esUserName", "esUserSecret", "maxRetries", "healthCheck",
+		"startupHealthcheckTimeout", "sniff", "bulkWorkers", "pipeline"},
+	"gcm":         {"metrics", "valueTypePolicy"},
+	"stackdriver": {"min_interval_sec", "batch_export_timeout_sec", "initial_delay_sec", "cluster_name", "cluster_location", "zone", "use_old_resources", "use_new_resources"},
+	"statsd":      {"prefix", "protocolType", "numMetricsPerMsg", "maxDatagramSize", "renameLabels", "allowedLabels", "labelStyle"},
+	"graphite":    append([]string{"prefix"}, reconnect.Options...),
+	"hawkular": {"tenant", "labelToTenant", "labelTagPrefix", "labelNodeId", "useServiceAccount", "auth", "caCert", "user", "pass",
+		"insecure", "filter", "concurrencyLimit", "batchSize", "disablePreCache", "valueTypePolicy"},
+	"influxdb":  {"user", "pw", "db", "retention", "withfields", "secure", "insecuressl", "cluster_name", "disable_counter_metrics", "concurrency"},
+	"kafka":     {"brokers", "timeseriestopic", "compression", "cacert", "cert", "key", "insecuressl", "user", "password"},
+	"librato":   {"username", "token", "api", "prefix", "tags", "tag_*"},
+	"log":       {},
+	"metric":    {},
+	"opentsdb":  {"cluster"},
+	"wavefront": append([]string{"clusterName", "prefix", "includeLabels", "includeContainers", "testMode"}, reconnect.Options...),
+	"riemann":   append([]string{"ttl", "batchsize", "state", "tags"}, reconnect.Options...),
+	"honeycomb": {"writekey", "apihost", "dataset"},
+}
+
+// unknownSinkOptions returns the options of the uri that its sink doesn't understand.
+func unknownSinkOptions(uri flags.Uri) []string {
+	known := append(append([]string{}, commonSinkOptions...), sinkOptions[uri.Key]...)
+	return flags.NewUriOptions(&uri.Val).UnknownKeys(known)
+}
+
 type SinkFactory struct {
+	// MetricResolution is the interval the timestamps of sinks with alignTimestamps=true are
+	// truncated to.
+	MetricResolution time.Duration
+	// Whether BuildAll exits when a sink can't connect to its backend, instead of logging it.
+	RequireReachableSinks bool
+	// DecimationPolicies limit how often the given metrics are exported to the sinks other than
+	// the metric sink, which keeps every value for the model API.
+	DecimationPolicies map[string]DecimationPolicy
 }
 
 func (this *SinkFactory) Build(uri flags.Uri) (core.DataSink, error) {
@@ -80,16 +123,78 @@ func (this *SinkFactory) Build(uri flags.Uri) (core.DataSink, error) {
 	}
 }
 
+// Validate checks uri like BuildAll does before building the sink, without building it: the sink
+// must be known and the options common to all sinks must be valid. The options specific to the
+// sink are checked for the sinks whose configuration is parsed separately, the other ones are only
+// checked when the sink is built, since building most sinks connects to their backend.
+// The options that the sink doesn't understand are returned.
+func (this *SinkFactory) Validate(uri flags.Uri) ([]string, error) {
+	if _, found := sinkOptions[uri.Key]; !found {
+		return nil, fmt.Errorf("Sink not recognized: %s", uri.Key)
+	}
+	if _, err := ParseMetricNameFilter(&uri.Val); err != nil {
+		return nil, err
+	}
+	if _, err := ParseIncludeSystemSlice(uri.Key, &uri.Val); err != nil {
+		return nil, err
+	}
+	if _, err := ParseTimestampAlignment(&uri.Val, this.MetricResolution); err != nil {
+		return nil, err
+	}
+	if err := validateSinkConfig(uri); err != nil {
+		return nil, err
+	}
+	return unknownSinkOptions(uri), nil
+}
+
+// validateSinkConfig parses the options specific to the sink, for the sinks that can parse them
+// without connecting to their backend.
+func validateSinkConfig(uri flags.Uri) error {
+	var err error
+	switch uri.Key {
+	case "influxdb":
+		_, err = influxdb_common.BuildConfig(&uri.Val)
+	case "honeycomb":
+		_, err = honeycomb_common.BuildConfig(&uri.Val)
+	case "librato":
+		_, err = librato_common.BuildConfig(&uri.Val)
+	case "statsd":
+		err = statsd.ValidateConfig(&uri.Val)
+	case "graphite", "wavefront", "riemann":
+		_, err = reconnect.ParseConfig(&uri.Val)
+	}
+	return err
+}
+
 func (this *SinkFactory) BuildAll(uris flags.Uris, historicalUri string, disableMetricSink bool) (*metricsink.MetricSink, []core.DataSink, core.HistoricalSource) {
 	result := make([]core.DataSink, 0, len(uris))
 	var metric *metricsink.MetricSink
 	var historical core.HistoricalSource
 	for _, uri := range uris {
+		if unknown := unknownSinkOptions(uri); len(unknown) > 0 {
+			glog.Warningf("Ignoring unknown options %v of %v sink", unknown, uri.Redacted())
+		}
+		metricNameFilter, err := ParseMetricNameFilter(&uri.Val)
+		if err != nil {
+			glog.Errorf("Failed to create %v sink: %v", uri.Redacted(), err)
+			continue
+		}
+		includeSystemSlice, err := ParseIncludeSystemSlice(uri.Key, &uri.Val)
+		if err != nil {
+			glog.Errorf("Failed to create %v sink: %v", uri.Redacted(), err)
+			continue
+		}
+		timestampAlignment, err := ParseTimestampAlignment(&uri.Val, this.MetricResolution)
+		if err != nil {
+			glog.Errorf("Failed to create %v sink: %v", uri.Redacted(), err)
+			continue
+		}
 		sink, err := this.Build(uri)
 		if err != nil {
-			glog.Errorf("Failed to create %v sink: %v", uri, err)
+			glog.Errorf("Failed to create %v sink: %v", uri.Redacted(), err)
 			continue
 		}
+		this.checkConnection(uri, sink)
 		if uri.Key == "metric" {
 			metric = sink.(*metricsink.MetricSink)
 		}
@@ -100,7 +205,15 @@ func (this *SinkFactory) BuildAll(uris flags.Uris, historicalUri string, disable
 				glog.Errorf("Sink type %q does not support being used for historical access", uri.Key)
 			}
 		}
-		result = append(result, sink)
+		// The sink manager only sees the filtering wrappers, the metric sink and the historical
+		// source above keep the unwrapped sink.
+		filtered := NewTimestampAligningSink(sink, timestampAlignment)
+		filtered = NewSystemSliceFilteringSink(filtered, includeSystemSlice)
+		filtered = NewLabelFilteringSink(filtered, ParseLabelFilter(&uri.Val))
+		if uri.Key != "metric" {
+			filtered = NewDecimatingSink(filtered, this.DecimationPolicies)
+		}
+		result = append(result, NewMetricNameFilteringSink(filtered, metricNameFilter))
 	}
 
 	if len([]flags.Uri(uris)) != 0 && len(result) == 0 {
@@ -119,11 +232,28 @@ func (this *SinkFactory) BuildAll(uris flags.Uris, historicalUri string, disable
 		}
 	}
 	if len(historicalUri) > 0 && historical == nil {
-		glog.Errorf("Error while initializing historical access: unable to use sink %q as a historical source", historicalUri)
+		glog.Errorf("Error while initializing historical access: unable to use sink %q as a historical source", flags.RedactUri(historicalUri))
 	}
 	return metric, result, historical
 }
 
+// checkConnection checks that the sink built for uri reaches its backend, for the sinks that
+// can tell. The sink is kept when it can't, since most sinks reconnect at every export.
+func (this *SinkFactory) checkConnection(uri flags.Uri, sink core.DataSink) {
+	checker, ok := sink.(core.DataSinkConnectionChecker)
+	if !ok {
+		return
+	}
+	if err := checker.CheckConnection(); err != nil {
+		if this.RequireReachableSinks {
+			glog.Fatalf("Failed to connect %v sink: %v", uri.Redacted(), err)
+		}
+		glog.Errorf("Failed to connect %v sink, it will retry at the next export: %v", uri.Redacted(), err)
+		return
+	}
+	glog.V(2).Infof("Connected %v sink", uri.Redacted())
+}
+
 func NewSinkFactory() *SinkFactory {
 	return &SinkFactory{}
 }
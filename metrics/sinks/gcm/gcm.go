@@ -22,6 +22,7 @@ import (
 
 	gce_util "k8s.io/heapster/common/gce"
 	"k8s.io/heapster/metrics/core"
+	"k8s.io/heapster/metrics/util/metrics"
 
 	"github.com/golang/glog"
 	"golang.org/x/oauth2"
@@ -35,6 +36,8 @@ const (
 	maxNumLabels    = 10
 	// The largest number of timeseries we can write to per request.
 	maxTimeseriesPerRequest = 200
+
+	gcmSinkName = "GCM Sink"
 )
 
 type MetricFilter int8
@@ -46,14 +49,15 @@ const (
 
 type gcmSink struct {
 	sync.RWMutex
-	registered   bool
-	project      string
-	metricFilter MetricFilter
-	gcmService   *gcm.Service
+	registered      bool
+	project         string
+	metricFilter    MetricFilter
+	valueTypePolicy metrics.ValueTypePolicy
+	gcmService      *gcm.Service
 }
 
 func (sink *gcmSink) Name() string {
-	return "GCM Sink"
+	return gcmSinkName
 }
 
 func getReq() *gcm.CreateTimeSeriesRequest {
@@ -91,6 +95,7 @@ func createTimeSeries(timestamp time.Time, labels map[string]string, metric stri
 		valueType = "DOUBLE"
 	default:
 		glog.Errorf("Type not supported %v in %v", val.ValueType, metric)
+		metrics.ReportUnexportableMetric(gcmSinkName, metric, metrics.ReasonUnsupportedValueType)
 		return nil
 	}
 	// For cumulative metric use the provided start time.
@@ -133,6 +138,10 @@ func (sink *gcmSink) getTimeSeries(timestamp time.Time, labels map[string]string
 		}
 	}
 
+	val, ok := sink.coerce(metric, val)
+	if !ok {
+		return nil
+	}
 	return createTimeSeries(timestamp, finalLabels, metric, val, collectionStartTime)
 }
 
@@ -155,7 +164,21 @@ func (sink *gcmSink) getTimeSeriesForLabeledMetrics(timestamp time.Time, labels
 		}
 	}
 
-	return createTimeSeries(timestamp, finalLabels, metric.Name, metric.MetricValue, collectionStartTime)
+	val, ok := sink.coerce(metric.Name, metric.MetricValue)
+	if !ok {
+		return nil
+	}
+	return createTimeSeries(timestamp, finalLabels, metric.Name, val, collectionStartTime)
+}
+
+// coerce converts the value to the type of the registered descriptor of the metric, according to the
+// value type policy of the sink.
+func (sink *gcmSink) coerce(metric string, val core.MetricValue) (core.MetricValue, bool) {
+	expected, found := metrics.ExpectedValueType(metric)
+	if !found {
+		return val, true
+	}
+	return sink.valueTypePolicy.Coerce(gcmSinkName, metric, val, expected)
 }
 
 func fullProjectName(name string) string {
@@ -179,8 +202,9 @@ func (sink *gcmSink) ExportData(dataBatch *core.DataBatch) {
 
 	req := getReq()
 	for _, metricSet := range dataBatch.MetricSets {
+		timestamp := core.PointTimestamp(dataBatch, metricSet)
 		for metric, val := range metricSet.MetricValues {
-			point := sink.getTimeSeries(dataBatch.Timestamp, metricSet.Labels, metric, val, metricSet.CollectionStartTime)
+			point := sink.getTimeSeries(timestamp, metricSet.Labels, metric, val, metricSet.CollectionStartTime)
 			if point != nil {
 				req.TimeSeries = append(req.TimeSeries, point)
 			}
@@ -190,7 +214,7 @@ func (sink *gcmSink) ExportData(dataBatch *core.DataBatch) {
 			}
 		}
 		for _, metric := range metricSet.LabeledMetrics {
-			point := sink.getTimeSeriesForLabeledMetrics(dataBatch.Timestamp, metricSet.Labels, metric, metricSet.CollectionStartTime)
+			point := sink.getTimeSeriesForLabeledMetrics(timestamp, metricSet.Labels, metric, metricSet.CollectionStartTime)
 			if point != nil {
 				req.TimeSeries = append(req.TimeSeries, point)
 			}
@@ -308,6 +332,10 @@ func CreateGCMSink(uri *url.URL) (core.DataSink, error) {
 	if err != nil {
 		return nil, err
 	}
+	valueTypePolicy, err := metrics.ParseValueTypePolicy(opts)
+	if err != nil {
+		return nil, err
+	}
 
 	metrics := "all"
 	if len(opts["metrics"]) > 0 {
@@ -341,10 +369,11 @@ func CreateGCMSink(uri *url.URL) (core.DataSink, error) {
 	}
 
 	sink := &gcmSink{
-		registered:   false,
-		project:      projectId,
-		gcmService:   gcmService,
-		metricFilter: metricFilter,
+		registered:      false,
+		project:         projectId,
+		gcmService:      gcmService,
+		metricFilter:    metricFilter,
+		valueTypePolicy: valueTypePolicy,
 	}
 	glog.Infof("created GCM sink")
 	if err := sink.registerAllMetrics(); err != nil {
@@ -26,6 +26,7 @@ import (
 
 	"github.com/golang/glog"
 	"github.com/marpaia/graphite-golang"
+	"k8s.io/heapster/common/reconnect"
 )
 
 const (
@@ -120,7 +121,8 @@ func (m *graphiteMetric) Metric() graphite.Metric {
 }
 
 type Sink struct {
-	client graphiteClient
+	client      graphiteClient
+	reconnector *reconnect.Reconnector
 	sync.RWMutex
 }
 
@@ -144,11 +146,25 @@ func NewGraphiteSink(uri *url.URL) (core.DataSink, error) {
 		prefix = DefaultPrefix
 	}
 
+	reconnectConfig, err := reconnect.ParseConfig(uri)
+	if err != nil {
+		return nil, err
+	}
 	client, err := graphite.GraphiteFactory(uri.Scheme, host, port, prefix)
 	if err != nil {
 		return nil, err
 	}
-	return &Sink{client: client}, nil
+	sink := &Sink{client: client}
+	sink.reconnector = reconnect.NewReconnector(fmt.Sprintf("graphite:%s", net.JoinHostPort(host, strconv.Itoa(port))), reconnectConfig, client.Connect)
+	// The factory already connected the client.
+	sink.reconnector.Connected()
+	return sink, nil
+}
+
+func (s *Sink) CheckConnection() error {
+	s.Lock()
+	defer s.Unlock()
+	return s.reconnector.Connect()
 }
 
 func (s *Sink) Name() string {
@@ -161,12 +177,13 @@ func (s *Sink) ExportData(dataBatch *core.DataBatch) {
 	var metrics []graphite.Metric
 	for _, metricSet := range dataBatch.MetricSets {
 		var m *graphiteMetric
+		timestamp := core.PointTimestamp(dataBatch, metricSet).Unix()
 		for metricName, metricValue := range metricSet.MetricValues {
 			m = &graphiteMetric{
 				name:      metricName,
 				value:     metricValue,
 				labels:    metricSet.Labels,
-				timestamp: dataBatch.Timestamp.Unix(),
+				timestamp: timestamp,
 			}
 			metrics = append(metrics, m.Metric())
 		}
@@ -183,18 +200,22 @@ func (s *Sink) ExportData(dataBatch *core.DataBatch) {
 					name:      metric.Name,
 					value:     metric.MetricValue,
 					labels:    labels,
-					timestamp: dataBatch.Timestamp.Unix(),
+					timestamp: timestamp,
 				}
 				metrics = append(metrics, m.Metric())
 			}
 		}
 	}
+	if err := s.reconnector.Connect(); err != nil {
+		glog.Warningf("Graphite sink not connected: %v", err)
+		return
+	}
 	glog.V(8).Infof("Sending %d events to graphite", len(metrics))
 	if err := s.client.SendMetrics(metrics); err != nil {
 		glog.V(4).Info("Graphite connection error:", err)
-		glog.V(2).Info("There were errors sending events to Graphite, reconecting")
+		glog.V(2).Info("There were errors sending events to Graphite, reconnecting at next export")
 		s.client.Disconnect()
-		s.client.Connect()
+		s.reconnector.Disconnected(err)
 	}
 }
 
@@ -30,6 +30,7 @@ import (
 	kube_client "k8s.io/client-go/rest"
 	kubeClientCmd "k8s.io/client-go/tools/clientcmd"
 	"k8s.io/heapster/metrics/core"
+	sinkmetrics "k8s.io/heapster/metrics/util/metrics"
 )
 
 const (
@@ -93,6 +94,7 @@ func (h *hawkularSink) ExportData(db *core.DataBatch) {
 		wg := &sync.WaitGroup{}
 
 		for _, ms := range db.MetricSets {
+			timestamp := core.PointTimestamp(db, ms)
 
 			// Transform ms.MetricValues to LabeledMetrics first
 			mvlms := metricValueToLabeledMetric(ms.MetricValues)
@@ -110,6 +112,13 @@ func (h *hawkularSink) ExportData(db *core.DataBatch) {
 					}
 				}
 
+				// Hawkular stores all the values as floats.
+				value, ok := h.valueTypePolicy.Coerce(h.Name(), labeledMetric.Name, labeledMetric.MetricValue, core.ValueFloat)
+				if !ok {
+					continue
+				}
+				labeledMetric.MetricValue = value
+
 				tenant := h.client.Tenant
 
 				if len(h.labelTenant) > 0 {
@@ -119,7 +128,7 @@ func (h *hawkularSink) ExportData(db *core.DataBatch) {
 				}
 
 				h.registerLabeledIfNecessaryInline(ms, labeledMetric, wg, metrics.Tenant(tenant))
-				mH, err := h.pointToLabeledMetricHeader(ms, labeledMetric, db.Timestamp)
+				mH, err := h.pointToLabeledMetricHeader(ms, labeledMetric, timestamp)
 				if err != nil {
 					// One transformation error should not prevent the whole process
 					glog.Errorf(err.Error())
@@ -323,6 +332,12 @@ func (h *hawkularSink) init() error {
 		h.batchSize = bs
 	}
 
+	valueTypePolicy, err := sinkmetrics.ParseValueTypePolicy(opts)
+	if err != nil {
+		return err
+	}
+	h.valueTypePolicy = valueTypePolicy
+
 	if v, found := opts["disablePreCache"]; found {
 		dpc, err := strconv.ParseBool(v[0])
 		if err != nil {
@@ -797,6 +797,69 @@ func TestFiltering(t *testing.T) {
 
 	assert.Equal(t, 1, len(mH))
 }
+func TestStrictValueTypePolicy(t *testing.T) {
+	m := &sync.Mutex{}
+	mH := []metrics.MetricHeader{}
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		m.Lock()
+		defer m.Unlock()
+		if strings.Contains(r.RequestURI, "raw") {
+			defer r.Body.Close()
+			b, err := ioutil.ReadAll(r.Body)
+			assert.NoError(t, err)
+
+			err = json.Unmarshal(b, &mH)
+			assert.NoError(t, err)
+		}
+	}))
+	defer s.Close()
+
+	_, err := integSink(s.URL + "?valueTypePolicy=loose")
+	assert.Error(t, err)
+
+	hSink, err := integSink(s.URL + "?valueTypePolicy=strict")
+	assert.NoError(t, err)
+
+	metricSet := core.MetricSet{
+		Labels: map[string]string{
+			core.LabelPodId.Key: "aaaa-bbbb-cccc-dddd",
+		},
+		MetricValues: map[string]core.MetricValue{
+			"test/metric/int": {
+				ValueType:  core.ValueInt64,
+				MetricType: core.MetricGauge,
+				IntValue:   123456,
+			},
+			"test/metric/huge": {
+				ValueType:  core.ValueInt64,
+				MetricType: core.MetricGauge,
+				IntValue:   1<<53 + 1,
+			},
+			"test/metric/float": {
+				ValueType:  core.ValueFloat,
+				MetricType: core.MetricGauge,
+				FloatValue: 1.5,
+			},
+		},
+	}
+	data := core.DataBatch{
+		Timestamp: time.Now(),
+		MetricSets: map[string]*core.MetricSet{
+			"pod1": &metricSet,
+		},
+	}
+	hSink.ExportData(&data)
+
+	// The integer too large to be converted exactly to float is dropped.
+	assert.Equal(t, 2, len(mH))
+	values := make(map[string]interface{}, len(mH))
+	for _, header := range mH {
+		values[header.ID] = header.Data[0].Value
+	}
+	assert.Equal(t, 1.5, values["/aaaa-bbbb-cccc-dddd/test/metric/float"])
+	assert.Equal(t, float64(123456), values["/aaaa-bbbb-cccc-dddd/test/metric/int"])
+}
+
 func TestBatchingTimeseries(t *testing.T) {
 	total := 1000
 	m := &sync.Mutex{}
@@ -20,6 +20,7 @@ import (
 
 	"github.com/hawkular/hawkular-client-go/metrics"
 	"k8s.io/heapster/metrics/core"
+	sinkmetrics "k8s.io/heapster/metrics/util/metrics"
 )
 
 type Filter func(ms *core.MetricSet, metricName string) bool
@@ -69,6 +70,7 @@ type hawkularSink struct {
 
 	disablePreCaching bool
 	batchSize         int
+	valueTypePolicy   sinkmetrics.ValueTypePolicy
 }
 
 func heapsterTypeToHawkularType(t core.MetricType) metrics.MetricType {
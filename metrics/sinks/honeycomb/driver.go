@@ -67,7 +67,7 @@ func (sink *honeycombSink) ExportData(dataBatch *core.DataBatch) {
 		}
 		batch[i] = &honeycomb_common.BatchPoint{
 			Data:      data,
-			Timestamp: dataBatch.Timestamp,
+			Timestamp: core.PointTimestamp(dataBatch, metricSet),
 		}
 		i++
 	}
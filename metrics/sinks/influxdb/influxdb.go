@@ -23,6 +23,7 @@ import (
 
 	influxdb_common "k8s.io/heapster/common/influxdb"
 	"k8s.io/heapster/metrics/core"
+	"k8s.io/heapster/metrics/util/metrics"
 
 	"github.com/golang/glog"
 	influxdb "github.com/influxdata/influxdb/client"
@@ -68,6 +69,7 @@ func (sink *influxdbSink) ExportData(dataBatch *core.DataBatch) {
 
 	dataPoints := make([]influxdb.Point, 0, 0)
 	for _, metricSet := range dataBatch.MetricSets {
+		timestamp := core.PointTimestamp(dataBatch, metricSet).UTC()
 		for metricName, metricValue := range metricSet.MetricValues {
 			if sink.c.DisableCounterMetrics {
 				if _, exists := core.RateMetricsMapping[metricName]; exists {
@@ -81,6 +83,7 @@ func (sink *influxdbSink) ExportData(dataBatch *core.DataBatch) {
 			} else if core.ValueFloat == metricValue.ValueType {
 				value = float64(metricValue.FloatValue)
 			} else {
+				metrics.ReportUnexportableMetric(sink.Name(), metricName, metrics.ReasonUnsupportedValueType)
 				continue
 			}
 
@@ -102,7 +105,7 @@ func (sink *influxdbSink) ExportData(dataBatch *core.DataBatch) {
 				Fields: map[string]interface{}{
 					fieldName: value,
 				},
-				Time: dataBatch.Timestamp.UTC(),
+				Time: timestamp,
 			}
 			for key, value := range metricSet.Labels {
 				if _, exists := influxdbBlacklistLabels[key]; !exists {
@@ -134,6 +137,7 @@ func (sink *influxdbSink) ExportData(dataBatch *core.DataBatch) {
 			} else if core.ValueFloat == labeledMetric.ValueType {
 				value = float64(labeledMetric.FloatValue)
 			} else {
+				metrics.ReportUnexportableMetric(sink.Name(), labeledMetric.Name, metrics.ReasonUnsupportedValueType)
 				continue
 			}
 
@@ -155,7 +159,7 @@ func (sink *influxdbSink) ExportData(dataBatch *core.DataBatch) {
 				Fields: map[string]interface{}{
 					fieldName: value,
 				},
-				Time: dataBatch.Timestamp.UTC(),
+				Time: timestamp,
 			}
 
 			for key, value := range metricSet.Labels {
@@ -237,6 +241,16 @@ func (sink *influxdbSink) Stop() {
 	// nothing needs to be done.
 }
 
+func (sink *influxdbSink) CheckConnection() error {
+	sink.Lock()
+	defer sink.Unlock()
+	if err := sink.ensureClient(); err != nil {
+		return err
+	}
+	_, _, err := sink.client.Ping()
+	return err
+}
+
 func (sink *influxdbSink) ensureClient() error {
 	if sink.client == nil {
 		client, err := influxdb_common.NewClient(sink.c)
@@ -45,6 +45,7 @@ func (sink *kafkaSink) ExportData(dataBatch *core.DataBatch) {
 	defer sink.Unlock()
 
 	for _, metricSet := range dataBatch.MetricSets {
+		timestamp := core.PointTimestamp(dataBatch, metricSet).UTC()
 		for metricName, metricValue := range metricSet.MetricValues {
 			point := KafkaSinkPoint{
 				MetricsName: metricName,
@@ -52,7 +53,7 @@ func (sink *kafkaSink) ExportData(dataBatch *core.DataBatch) {
 				MetricsValue: map[string]interface{}{
 					"value": metricValue.GetValue(),
 				},
-				MetricsTimestamp: dataBatch.Timestamp.UTC(),
+				MetricsTimestamp: timestamp,
 			}
 			err := sink.ProduceKafkaMessage(point)
 			if err != nil {
@@ -73,7 +74,7 @@ func (sink *kafkaSink) ExportData(dataBatch *core.DataBatch) {
 				MetricsValue: map[string]interface{}{
 					"value": metric.GetValue(),
 				},
-				MetricsTimestamp: dataBatch.Timestamp.UTC(),
+				MetricsTimestamp: timestamp,
 			}
 			err := sink.ProduceKafkaMessage(point)
 			if err != nil {
@@ -83,6 +84,17 @@ func (sink *kafkaSink) ExportData(dataBatch *core.DataBatch) {
 	}
 }
 
+// CheckConnection checks the brokers are reachable, for the clients that can tell.
+func (sink *kafkaSink) CheckConnection() error {
+	checker, ok := sink.KafkaClient.(core.DataSinkConnectionChecker)
+	if !ok {
+		return nil
+	}
+	sink.Lock()
+	defer sink.Unlock()
+	return checker.CheckConnection()
+}
+
 func NewKafkaSink(uri *url.URL) (core.DataSink, error) {
 	client, err := kafka_common.NewKafkaClient(uri, kafka_common.TimeSeriesTopic)
 	if err != nil {
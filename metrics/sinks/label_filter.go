@@ -0,0 +1,119 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sinks
+
+import (
+	"net/url"
+	"strings"
+
+	"k8s.io/heapster/metrics/core"
+)
+
+// LabelFilter selects the metric set labels a sink receives. The type label is always kept, as
+// sinks rely on it to tell the entities apart.
+type LabelFilter struct {
+	include map[string]bool
+	exclude map[string]bool
+}
+
+func parseLabelNames(values []string) map[string]bool {
+	var result map[string]bool
+	for _, names := range values {
+		for _, name := range strings.Split(names, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				if result == nil {
+					result = make(map[string]bool)
+				}
+				result[name] = true
+			}
+		}
+	}
+	return result
+}
+
+// ParseLabelFilter returns the filter given by the labelInclude and labelExclude options of a
+// sink uri, both comma separated lists of label names, or nil if neither is set.
+func ParseLabelFilter(uri *url.URL) *LabelFilter {
+	opts := uri.Query()
+	include := parseLabelNames(opts["labelInclude"])
+	exclude := parseLabelNames(opts["labelExclude"])
+	if include == nil && exclude == nil {
+		return nil
+	}
+	return &LabelFilter{
+		include: include,
+		exclude: exclude,
+	}
+}
+
+func (this *LabelFilter) keep(name string) bool {
+	if name == core.LabelMetricSetType.Key {
+		return true
+	}
+	if this.include != nil && !this.include[name] {
+		return false
+	}
+	return !this.exclude[name]
+}
+
+// Filter returns a copy of the batch with the filtered labels. The metric sets of the given batch,
+// which is shared by all the sinks, are not modified.
+func (this *LabelFilter) Filter(batch *core.DataBatch) *core.DataBatch {
+	result := &core.DataBatch{
+		Timestamp:  batch.Timestamp,
+		MetricSets: make(map[string]*core.MetricSet, len(batch.MetricSets)),
+	}
+	for key, metricSet := range batch.MetricSets {
+		filtered := *metricSet
+		filtered.Labels = make(map[string]string, len(metricSet.Labels))
+		for name, value := range metricSet.Labels {
+			if this.keep(name) {
+				filtered.Labels[name] = value
+			}
+		}
+		result.MetricSets[key] = &filtered
+	}
+	return result
+}
+
+// labelFilteringSink passes a label filtered copy of every batch to the wrapped sink.
+type labelFilteringSink struct {
+	sink   core.DataSink
+	filter *LabelFilter
+}
+
+func (this *labelFilteringSink) Name() string {
+	return this.sink.Name()
+}
+
+func (this *labelFilteringSink) ExportData(batch *core.DataBatch) {
+	this.sink.ExportData(this.filter.Filter(batch))
+}
+
+func (this *labelFilteringSink) Stop() {
+	this.sink.Stop()
+}
+
+// NewLabelFilteringSink wraps the sink so that it only receives the labels selected by the filter.
+// It returns the sink unchanged if the filter is nil.
+func NewLabelFilteringSink(sink core.DataSink, filter *LabelFilter) core.DataSink {
+	if filter == nil {
+		return sink
+	}
+	return &labelFilteringSink{
+		sink:   sink,
+		filter: filter,
+	}
+}
@@ -23,6 +23,7 @@ import (
 
 	librato_common "k8s.io/heapster/common/librato"
 	"k8s.io/heapster/metrics/core"
+	"k8s.io/heapster/metrics/util/metrics"
 
 	"github.com/golang/glog"
 )
@@ -84,6 +85,7 @@ func (sink *libratoSink) ExportData(dataBatch *core.DataBatch) {
 
 	measurements := make([]librato_common.Measurement, 0, 0)
 	for _, metricSet := range dataBatch.MetricSets {
+		timestamp := core.PointTimestamp(dataBatch, metricSet).Unix()
 		for metricName, metricValue := range metricSet.MetricValues {
 
 			var value float64
@@ -92,6 +94,7 @@ func (sink *libratoSink) ExportData(dataBatch *core.DataBatch) {
 			} else if core.ValueFloat == metricValue.ValueType {
 				value = float64(metricValue.FloatValue)
 			} else {
+				metrics.ReportUnexportableMetric(sink.Name(), metricName, metrics.ReasonUnsupportedValueType)
 				continue
 			}
 
@@ -99,7 +102,7 @@ func (sink *libratoSink) ExportData(dataBatch *core.DataBatch) {
 			measurement := librato_common.Measurement{
 				Name:  name,
 				Tags:  make(map[string]string),
-				Time:  dataBatch.Timestamp.Unix(),
+				Time:  timestamp,
 				Value: value,
 			}
 
@@ -122,6 +125,7 @@ func (sink *libratoSink) ExportData(dataBatch *core.DataBatch) {
 			} else if core.ValueFloat == labeledMetric.ValueType {
 				value = float64(labeledMetric.FloatValue)
 			} else {
+				metrics.ReportUnexportableMetric(sink.Name(), labeledMetric.Name, metrics.ReasonUnsupportedValueType)
 				continue
 			}
 
@@ -130,7 +134,7 @@ func (sink *libratoSink) ExportData(dataBatch *core.DataBatch) {
 			measurement := librato_common.Measurement{
 				Name:  name,
 				Tags:  make(map[string]string),
-				Time:  dataBatch.Timestamp.Unix(),
+				Time:  timestamp,
 				Value: value,
 			}
 			for key, value := range metricSet.Labels {
@@ -165,6 +169,17 @@ func (sink *libratoSink) Name() string {
 	return "Librato Sink"
 }
 
+// CheckConnection checks the API accepts the credentials, for the clients that can tell.
+func (sink *libratoSink) CheckConnection() error {
+	checker, ok := sink.client.(core.DataSinkConnectionChecker)
+	if !ok {
+		return nil
+	}
+	sink.Lock()
+	defer sink.Unlock()
+	return checker.CheckConnection()
+}
+
 func (sink *libratoSink) Stop() {
 	// nothing needs to be done.
 }
@@ -16,6 +16,7 @@ package sinks
 
 import (
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/golang/glog"
@@ -58,8 +59,9 @@ func init() {
 
 type sinkHolder struct {
 	sink             core.DataSink
-	dataBatchChannel chan *core.DataBatch
+	dataBatchChannel chan *batchExport
 	stopChannel      chan bool
+	stoppedChannel   chan struct{}
 }
 
 // Sink Manager - a special sink that distributes data to other sinks. It pushes data
@@ -69,26 +71,64 @@ type sinkManager struct {
 	sinkHolders       []sinkHolder
 	exportDataTimeout time.Duration
 	stopTimeout       time.Duration
+	flushOnStop       bool
+	// Called with each batch exported by all the sinks, may be nil.
+	onBatchExported func(*core.DataBatch)
+}
+
+// batchExport tracks the export of a batch by all the sinks.
+type batchExport struct {
+	data *core.DataBatch
+	// Number of sinks that didn't finish exporting the batch nor drop it yet.
+	pending int32
+	// Set to 1 if a sink didn't take the batch.
+	dropped int32
+	// Called once all the sinks exported the batch, may be nil.
+	exported func(*core.DataBatch)
+}
+
+// sinkDone records that a sink finished exporting the batch, or dropped it.
+func (this *batchExport) sinkDone(exported bool) {
+	if !exported {
+		atomic.StoreInt32(&this.dropped, 1)
+	}
+	if atomic.AddInt32(&this.pending, -1) == 0 && atomic.LoadInt32(&this.dropped) == 0 && this.exported != nil {
+		this.exported(this.data)
+	}
 }
 
 func NewDataSinkManager(sinks []core.DataSink, exportDataTimeout, stopTimeout time.Duration) (core.DataSink, error) {
+	return newDataSinkManager(sinks, exportDataTimeout, stopTimeout, false)
+}
+
+// NewDataSinkManagerWithFlushOnStop returns a sink manager whose Stop blocks until every sink
+// finished its pending export and its own Stop, which flushes buffered data, or until
+// stopTimeout passes.
+func NewDataSinkManagerWithFlushOnStop(sinks []core.DataSink, exportDataTimeout, stopTimeout time.Duration) (core.DataSink, error) {
+	return newDataSinkManager(sinks, exportDataTimeout, stopTimeout, true)
+}
+
+func newDataSinkManager(sinks []core.DataSink, exportDataTimeout, stopTimeout time.Duration, flushOnStop bool) (core.DataSink, error) {
 	sinkHolders := []sinkHolder{}
 	for _, sink := range sinks {
 		sh := sinkHolder{
 			sink:             sink,
-			dataBatchChannel: make(chan *core.DataBatch),
+			dataBatchChannel: make(chan *batchExport),
 			stopChannel:      make(chan bool),
+			stoppedChannel:   make(chan struct{}),
 		}
 		sinkHolders = append(sinkHolders, sh)
 		go func(sh sinkHolder) {
 			for {
 				select {
-				case data := <-sh.dataBatchChannel:
-					export(sh.sink, data)
+				case batch := <-sh.dataBatchChannel:
+					export(sh.sink, batch.data)
+					batch.sinkDone(true)
 				case isStop := <-sh.stopChannel:
 					glog.V(2).Infof("Stop received: %s", sh.sink.Name())
 					if isStop {
 						sh.sink.Stop()
+						close(sh.stoppedChannel)
 						return
 					}
 				}
@@ -99,28 +139,51 @@ func NewDataSinkManager(sinks []core.DataSink, exportDataTimeout, stopTimeout ti
 		sinkHolders:       sinkHolders,
 		exportDataTimeout: exportDataTimeout,
 		stopTimeout:       stopTimeout,
+		flushOnStop:       flushOnStop,
 	}, nil
 }
 
 // Guarantees that the export will complete in sinkExportDataTimeout.
 func (this *sinkManager) ExportData(data *core.DataBatch) {
+	this.ExportDataWithResults(data)
+}
+
+// ExportDataWithResults works like ExportData, and returns whether each sink took the batch,
+// in the order of the sinks.
+func (this *sinkManager) ExportDataWithResults(data *core.DataBatch) []core.SinkExportResult {
+	results := make([]core.SinkExportResult, len(this.sinkHolders))
+	batch := &batchExport{
+		data:     data,
+		pending:  int32(len(this.sinkHolders)),
+		exported: this.onBatchExported,
+	}
 	var wg sync.WaitGroup
-	for _, sh := range this.sinkHolders {
+	for i, sh := range this.sinkHolders {
 		wg.Add(1)
-		go func(sh sinkHolder, wg *sync.WaitGroup) {
+		go func(sh sinkHolder, result *core.SinkExportResult, wg *sync.WaitGroup) {
 			defer wg.Done()
+			result.Sink = sh.sink.Name()
 			glog.V(2).Infof("Pushing data to: %s", sh.sink.Name())
 			select {
-			case sh.dataBatchChannel <- data:
+			case sh.dataBatchChannel <- batch:
 				glog.V(2).Infof("Data push completed: %s", sh.sink.Name())
 				// everything ok
+				result.Accepted = true
 			case <-time.After(this.exportDataTimeout):
 				glog.Warningf("Failed to push data to sink: %s", sh.sink.Name())
+				batch.sinkDone(false)
 			}
-		}(sh, &wg)
+		}(sh, &results[i], &wg)
 	}
 	// Wait for all pushes to complete or timeout.
 	wg.Wait()
+	return results
+}
+
+// OnBatchExported sets the function called with each batch once all the sinks finished exporting
+// it. Batches dropped by a sink that didn't take them in time are not reported.
+func (this *sinkManager) OnBatchExported(exported func(*core.DataBatch)) {
+	this.onBatchExported = exported
 }
 
 func (this *sinkManager) Name() string {
@@ -128,21 +191,35 @@ func (this *sinkManager) Name() string {
 }
 
 func (this *sinkManager) Stop() {
+	var wg sync.WaitGroup
 	for _, sh := range this.sinkHolders {
 		glog.V(2).Infof("Running stop for: %s", sh.sink.Name())
 
+		wg.Add(1)
 		go func(sh sinkHolder) {
+			defer wg.Done()
+			timeout := time.After(this.stopTimeout)
 			select {
 			case sh.stopChannel <- true:
 				// everything ok
 				glog.V(2).Infof("Stop sent to sink: %s", sh.sink.Name())
 
-			case <-time.After(this.stopTimeout):
+			case <-timeout:
 				glog.Warningf("Failed to stop sink: %s", sh.sink.Name())
+				return
+			}
+			select {
+			case <-sh.stoppedChannel:
+				glog.V(2).Infof("Sink stopped: %s", sh.sink.Name())
+			case <-timeout:
+				glog.Warningf("Failed to flush sink before stop timeout: %s", sh.sink.Name())
 			}
-			return
 		}(sh)
 	}
+	// Only wait for the sinks when asked to, Stop used to return immediately.
+	if this.flushOnStop {
+		wg.Wait()
+	}
 }
 
 func export(s core.DataSink, data *core.DataBatch) {
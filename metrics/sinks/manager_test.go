@@ -15,11 +15,15 @@
 package sinks
 
 import (
+	"fmt"
+	"net/url"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/assert"
 
+	"k8s.io/heapster/common/flags"
 	"k8s.io/heapster/metrics/core"
 	"k8s.io/heapster/metrics/util"
 )
@@ -111,6 +115,43 @@ func TestNoExportInTime(t *testing.T) {
 	assert.Equal(t, 1, sink2.GetExportCount())
 }
 
+func TestBatchExportedReport(t *testing.T) {
+	timeout := 100 * time.Millisecond
+
+	sink1 := util.NewDummySink("s1", 10*time.Millisecond)
+	sink2 := util.NewDummySink("s2", time.Second)
+	manager, _ := NewDataSinkManager([]core.DataSink{sink1, sink2}, timeout, timeout)
+	exported := make(chan *core.DataBatch, 2)
+	manager.(core.DataSinkExportTracker).OnBatchExported(func(batch *core.DataBatch) {
+		exported <- batch
+	})
+
+	now := time.Now()
+	first := &core.DataBatch{Timestamp: now, MetricSets: map[string]*core.MetricSet{}}
+	second := &core.DataBatch{Timestamp: now.Add(time.Minute), MetricSets: map[string]*core.MetricSet{}}
+	manager.ExportData(first)
+	// s2 is still exporting the first batch, so it drops the second one.
+	manager.ExportData(second)
+
+	// The first batch is reported once the slower sink finished exporting it, not when it took it.
+	select {
+	case batch := <-exported:
+		t.Fatalf("batch %v reported before all sinks exported it", batch.Timestamp)
+	case <-time.After(500 * time.Millisecond):
+	}
+	select {
+	case batch := <-exported:
+		assert.Equal(t, first, batch)
+	case <-time.After(2 * time.Second):
+		t.Fatal("the exported batch wasn't reported")
+	}
+	select {
+	case batch := <-exported:
+		t.Fatalf("dropped batch %v reported", batch.Timestamp)
+	case <-time.After(200 * time.Millisecond):
+	}
+}
+
 func TestStop(t *testing.T) {
 	timeout := 3 * time.Second
 
@@ -129,3 +170,365 @@ func TestStop(t *testing.T) {
 	assert.Equal(t, true, sink1.IsStopped())
 	assert.Equal(t, true, sink2.IsStopped())
 }
+
+// bufferingSink keeps exported batches in memory and only writes them on Stop.
+type bufferingSink struct {
+	lock    sync.Mutex
+	pending []*core.DataBatch
+	written []*core.DataBatch
+}
+
+func (this *bufferingSink) Name() string {
+	return "buffering"
+}
+
+func (this *bufferingSink) ExportData(batch *core.DataBatch) {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+	this.pending = append(this.pending, batch)
+}
+
+func (this *bufferingSink) Stop() {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+	this.written = append(this.written, this.pending...)
+	this.pending = nil
+}
+
+func (this *bufferingSink) writtenCount() int {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+	return len(this.written)
+}
+
+func TestFlushOnStop(t *testing.T) {
+	timeout := 3 * time.Second
+
+	sink := &bufferingSink{}
+	manager, _ := NewDataSinkManagerWithFlushOnStop([]core.DataSink{sink}, timeout, timeout)
+
+	batch := core.DataBatch{
+		Timestamp:  time.Now(),
+		MetricSets: map[string]*core.MetricSet{},
+	}
+	manager.ExportData(&batch)
+	manager.Stop()
+
+	assert.Equal(t, 1, sink.writtenCount())
+}
+
+func TestFlushOnStopTimeout(t *testing.T) {
+	timeout := time.Second
+
+	sink := util.NewDummySink("s1", 30*time.Second)
+	manager, _ := NewDataSinkManagerWithFlushOnStop([]core.DataSink{sink}, timeout, timeout)
+
+	now := time.Now()
+	manager.Stop()
+	elapsed := time.Now().Sub(now)
+	if elapsed > timeout+time.Second {
+		t.Fatalf("stop too long: %s", elapsed)
+	}
+	assert.Equal(t, true, sink.IsStopped())
+}
+
+func TestLabelFilteringSink(t *testing.T) {
+	labels := map[string]string{
+		core.LabelMetricSetType.Key: core.MetricSetTypePod,
+		core.LabelPodName.Key:       "pod1",
+		core.LabelPodId.Key:         "123",
+		core.LabelHostID.Key:        "host1",
+	}
+	batch := core.DataBatch{
+		Timestamp: time.Now(),
+		MetricSets: map[string]*core.MetricSet{
+			"pod1": {Labels: labels},
+		},
+	}
+
+	uri, err := url.Parse("?labelInclude=pod_name,pod_id&labelExclude=pod_id")
+	assert.NoError(t, err)
+	sink := &bufferingSink{}
+	NewLabelFilteringSink(sink, ParseLabelFilter(uri)).ExportData(&batch)
+
+	assert.Equal(t, 1, len(sink.pending))
+	assert.Equal(t, map[string]string{
+		core.LabelMetricSetType.Key: core.MetricSetTypePod,
+		core.LabelPodName.Key:       "pod1",
+	}, sink.pending[0].MetricSets["pod1"].Labels)
+	// The batch seen by other sinks keeps all its labels.
+	assert.Equal(t, 4, len(batch.MetricSets["pod1"].Labels))
+
+	uri, err = url.Parse("?other=value")
+	assert.NoError(t, err)
+	assert.Nil(t, ParseLabelFilter(uri))
+}
+
+func TestMetricNameFilteringSink(t *testing.T) {
+	batch := core.DataBatch{
+		Timestamp: time.Now(),
+		MetricSets: map[string]*core.MetricSet{
+			"pod1": {
+				MetricValues: map[string]core.MetricValue{
+					core.MetricCpuUsageRate.Name:    {IntValue: 1},
+					core.MetricMemoryUsage.Name:     {IntValue: 2},
+					core.MetricNetworkRxErrors.Name: {IntValue: 3},
+				},
+				LabeledMetrics: []core.LabeledMetric{
+					{Name: core.MetricFilesystemUsage.Name},
+					{Name: core.MetricAcceleratorMemoryUsed.Name},
+				},
+			},
+		},
+	}
+
+	uri, err := url.Parse("?metricAllow=cpu/.*&metricAllow=memory/.*&metricAllow=network/.*&metricAllow=filesystem/.*" +
+		"&metricDeny=network/.*_errors&metricDeny=memory")
+	assert.NoError(t, err)
+	filter, err := ParseMetricNameFilter(uri)
+	assert.NoError(t, err)
+	sink := &bufferingSink{}
+	NewMetricNameFilteringSink(sink, filter).ExportData(&batch)
+
+	assert.Equal(t, 1, len(sink.pending))
+	metricSet := sink.pending[0].MetricSets["pod1"]
+	// The deny expressions match whole names, so memory/usage is kept.
+	assert.Equal(t, map[string]core.MetricValue{
+		core.MetricCpuUsageRate.Name: {IntValue: 1},
+		core.MetricMemoryUsage.Name:  {IntValue: 2},
+	}, metricSet.MetricValues)
+	assert.Equal(t, []core.LabeledMetric{{Name: core.MetricFilesystemUsage.Name}}, metricSet.LabeledMetrics)
+	// The batch seen by other sinks keeps all its metrics.
+	assert.Equal(t, 3, len(batch.MetricSets["pod1"].MetricValues))
+	assert.Equal(t, 2, len(batch.MetricSets["pod1"].LabeledMetrics))
+
+	uri, err = url.Parse("?metricDeny=%28")
+	assert.NoError(t, err)
+	_, err = ParseMetricNameFilter(uri)
+	assert.Error(t, err)
+
+	uri, err = url.Parse("?other=value")
+	assert.NoError(t, err)
+	filter, err = ParseMetricNameFilter(uri)
+	assert.NoError(t, err)
+	assert.Nil(t, filter)
+}
+
+func TestSystemSliceFilteringSink(t *testing.T) {
+	batch := core.DataBatch{
+		Timestamp: time.Now(),
+		MetricSets: map[string]*core.MetricSet{
+			"docker":  {Labels: map[string]string{core.LabelContainerName.Key: "/system.slice/docker.service"}},
+			"kubelet": {Labels: map[string]string{core.LabelContainerName.Key: "kubelet"}},
+			"pod1":    {Labels: map[string]string{}},
+		},
+	}
+
+	sink := &bufferingSink{}
+	NewSystemSliceFilteringSink(sink, false).ExportData(&batch)
+	assert.Equal(t, 1, len(sink.pending))
+	assert.Equal(t, 2, len(sink.pending[0].MetricSets))
+	assert.Nil(t, sink.pending[0].MetricSets["docker"])
+	assert.Equal(t, 3, len(batch.MetricSets))
+
+	sink = &bufferingSink{}
+	assert.Equal(t, sink, NewSystemSliceFilteringSink(sink, true))
+
+	for _, c := range []struct {
+		key   string
+		query string
+		want  bool
+	}{
+		{"influxdb", "", true},
+		{"influxdb", "?includeSystemSlice=false", false},
+		{"wavefront", "", false},
+		{"wavefront", "?includeSystemSlice=true", true},
+	} {
+		uri, err := url.Parse(c.query)
+		assert.NoError(t, err)
+		include, err := ParseIncludeSystemSlice(c.key, uri)
+		assert.NoError(t, err)
+		assert.Equal(t, c.want, include, "%s%s", c.key, c.query)
+	}
+	uri, err := url.Parse("?includeSystemSlice=maybe")
+	assert.NoError(t, err)
+	_, err = ParseIncludeSystemSlice("influxdb", uri)
+	assert.Error(t, err)
+}
+
+func TestTimestampAligningSink(t *testing.T) {
+	now := time.Date(2017, 3, 1, 10, 20, 35, 0, time.UTC)
+	batch := core.DataBatch{
+		Timestamp:  now,
+		MetricSets: map[string]*core.MetricSet{"pod1": {}, "pod2": {ScrapeTime: now.Add(-10 * time.Second)}},
+	}
+	sink := &bufferingSink{}
+	NewTimestampAligningSink(sink, time.Minute).ExportData(&batch)
+	assert.Equal(t, 1, len(sink.pending))
+	assert.Equal(t, time.Date(2017, 3, 1, 10, 20, 0, 0, time.UTC), sink.pending[0].Timestamp)
+	assert.Equal(t, batch.MetricSets["pod1"], sink.pending[0].MetricSets["pod1"])
+	assert.Equal(t, time.Date(2017, 3, 1, 10, 20, 0, 0, time.UTC), sink.pending[0].MetricSets["pod2"].ScrapeTime)
+	assert.Equal(t, now, batch.Timestamp)
+	assert.Equal(t, now.Add(-10*time.Second), batch.MetricSets["pod2"].ScrapeTime)
+
+	sink = &bufferingSink{}
+	assert.Equal(t, sink, NewTimestampAligningSink(sink, 0))
+
+	for _, c := range []struct {
+		query   string
+		want    time.Duration
+		wantErr bool
+	}{
+		{"", 0, false},
+		{"?alignTimestamps=false", 0, false},
+		{"?alignTimestamps=true", 30 * time.Second, false},
+		{"?alignTimestamps=1", 30 * time.Second, false},
+		{"?alignTimestamps=t", 30 * time.Second, false},
+		{"?alignTimestamps=0", 0, false},
+		{"?alignTimestamps=F", 0, false},
+		{"?alignTimestamps=5m", 5 * time.Minute, false},
+		{"?alignTimestamps=-1m", 0, true},
+		{"?alignTimestamps=yes", 0, true},
+	} {
+		uri, err := url.Parse(c.query)
+		assert.NoError(t, err)
+		alignment, err := ParseTimestampAlignment(uri, 30*time.Second)
+		if c.wantErr {
+			assert.Error(t, err, c.query)
+		} else {
+			assert.NoError(t, err, c.query)
+			assert.Equal(t, c.want, alignment, c.query)
+		}
+	}
+}
+
+func TestUnknownSinkOptions(t *testing.T) {
+	var uri flags.Uri
+	assert.NoError(t, uri.Set("wavefront:proxy:2878?includeLabls=true&prefix=k8s.&labelExclude=pod_id"))
+	assert.Equal(t, []string{"includeLabls"}, unknownSinkOptions(uri))
+
+	assert.NoError(t, uri.Set("librato:?tags=env&tag_env=prod"))
+	assert.Empty(t, unknownSinkOptions(uri))
+
+	for _, sink := range []string{"gcm", "hawkular"} {
+		assert.NoError(t, uri.Set(sink+":?valueTypePolicy=coerce"))
+		assert.Empty(t, unknownSinkOptions(uri), sink)
+	}
+}
+
+func TestValidateSink(t *testing.T) {
+	factory := &SinkFactory{MetricResolution: time.Minute}
+	tests := []struct {
+		uri     string
+		unknown []string
+		wantErr bool
+	}{
+		{"influxdb:http://monitoring-influxdb:8086?db=k8s&metricAllow=cpu/.*", nil, false},
+		{"influxdb:http://monitoring-influxdb:8086?databse=k8s", []string{"databse"}, false},
+		{"influx:http://monitoring-influxdb:8086", nil, true},
+		{"influxdb:http://monitoring-influxdb:8086?metricAllow=(", nil, true},
+		{"log:?alignTimestamps=sometimes", nil, true},
+		{"influxdb:http://monitoring-influxdb:8086?withfields=sometimes", nil, true},
+		{"influxdb:http://monitoring-influxdb:8086?concurrency=0", nil, true},
+		{"honeycomb:?dataset=heapster", nil, true},
+		{"librato:?username=u", nil, true},
+		{"librato:?username=u&token=t", nil, false},
+		{"statsd:udp://127.0.0.1:8125?numMetricsPerMsg=many", nil, true},
+		{"statsd:udp://127.0.0.1:8125?renameLabels=pod_name", nil, true},
+		{"statsd:udp://127.0.0.1:8125?protocolType=unknown", nil, true},
+		{"statsd:udp://127.0.0.1:8125?prefix=k8s", nil, false},
+		{"graphite:tcp://graphite:2003?reconnectAttempts=0", nil, true},
+		{"riemann:http://riemann:5555?reconnectBackoff=soon", nil, true},
+	}
+	for _, test := range tests {
+		var uri flags.Uri
+		assert.NoError(t, uri.Set(test.uri))
+		unknown, err := factory.Validate(uri)
+		if test.wantErr {
+			assert.Error(t, err, test.uri)
+		} else {
+			assert.NoError(t, err, test.uri)
+			assert.Equal(t, test.unknown, unknown, test.uri)
+		}
+	}
+}
+
+// readingSink reads all the labels and values of the exported batches, so that the race
+// detector reports sinks modifying the batch shared by all of them.
+type readingSink struct {
+	lock    sync.Mutex
+	exports int
+	labels  int
+}
+
+func (this *readingSink) Name() string {
+	return "reading"
+}
+
+func (this *readingSink) ExportData(batch *core.DataBatch) {
+	labels := 0
+	for _, metricSet := range batch.MetricSets {
+		for name, value := range metricSet.Labels {
+			if name != "" && value != "" {
+				labels++
+			}
+		}
+		for _, value := range metricSet.MetricValues {
+			_ = value.GetValue()
+		}
+	}
+	this.lock.Lock()
+	defer this.lock.Unlock()
+	this.exports++
+	this.labels += labels
+}
+
+func (this *readingSink) Stop() {}
+
+func (this *readingSink) counts() (int, int) {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+	return this.exports, this.labels
+}
+
+func TestConcurrentSinksShareBatch(t *testing.T) {
+	timeout := 3 * time.Second
+
+	sink1 := &readingSink{}
+	sink2 := &readingSink{}
+	filtered := &readingSink{}
+	uri, err := url.Parse("?labelExclude=pod_id")
+	assert.NoError(t, err)
+	manager, _ := NewDataSinkManagerWithFlushOnStop([]core.DataSink{
+		sink1, sink2, NewLabelFilteringSink(filtered, ParseLabelFilter(uri))}, timeout, timeout)
+
+	batch := core.DataBatch{
+		Timestamp:  time.Now(),
+		MetricSets: map[string]*core.MetricSet{},
+	}
+	for i := 0; i < 10; i++ {
+		batch.MetricSets[core.PodKey("ns1", fmt.Sprintf("pod%d", i))] = &core.MetricSet{
+			Labels: map[string]string{
+				core.LabelMetricSetType.Key: core.MetricSetTypePod,
+				core.LabelPodId.Key:         fmt.Sprintf("uid%d", i),
+			},
+			MetricValues: map[string]core.MetricValue{
+				core.MetricCpuUsage.Name: {ValueType: core.ValueInt64, IntValue: int64(i)},
+			},
+		}
+	}
+
+	manager.ExportData(&batch)
+	manager.ExportData(&batch)
+	manager.Stop()
+
+	exports, labels := sink1.counts()
+	assert.Equal(t, 2, exports)
+	assert.Equal(t, 40, labels)
+	exports, labels = sink2.counts()
+	assert.Equal(t, 2, exports)
+	assert.Equal(t, 40, labels)
+	exports, labels = filtered.counts()
+	assert.Equal(t, 2, exports)
+	assert.Equal(t, 20, labels)
+}
@@ -0,0 +1,64 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package memory provides a sink keeping the exported batches in memory, for tests of the
+// processing pipeline.
+package memory
+
+import (
+	"sync"
+
+	"k8s.io/heapster/metrics/core"
+)
+
+// MemorySink records every batch it receives. It is safe for concurrent use.
+type MemorySink struct {
+	sync.Mutex
+	batches []*core.DataBatch
+}
+
+func (this *MemorySink) Name() string {
+	return "Memory Sink"
+}
+
+func (this *MemorySink) ExportData(batch *core.DataBatch) {
+	this.Lock()
+	defer this.Unlock()
+	this.batches = append(this.batches, batch)
+}
+
+func (this *MemorySink) Stop() {
+	// Do nothing.
+}
+
+// Batches returns the batches received so far, in the order they were exported. The batches
+// are shared with the other sinks and must not be modified.
+func (this *MemorySink) Batches() []*core.DataBatch {
+	this.Lock()
+	defer this.Unlock()
+	return append([]*core.DataBatch(nil), this.batches...)
+}
+
+// TakeBatches returns the batches received so far and forgets them.
+func (this *MemorySink) TakeBatches() []*core.DataBatch {
+	this.Lock()
+	defer this.Unlock()
+	result := this.batches
+	this.batches = nil
+	return result
+}
+
+func NewMemorySink() *MemorySink {
+	return &MemorySink{}
+}
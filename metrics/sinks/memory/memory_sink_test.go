@@ -0,0 +1,39 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package memory
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"k8s.io/heapster/metrics/core"
+)
+
+func TestMemorySink(t *testing.T) {
+	sink := NewMemorySink()
+	first := &core.DataBatch{Timestamp: time.Unix(1, 0)}
+	second := &core.DataBatch{Timestamp: time.Unix(2, 0)}
+	sink.ExportData(first)
+	sink.ExportData(second)
+
+	assert.Equal(t, []*core.DataBatch{first, second}, sink.Batches())
+	assert.Equal(t, []*core.DataBatch{first, second}, sink.TakeBatches())
+	assert.Empty(t, sink.Batches())
+
+	sink.ExportData(first)
+	assert.Equal(t, []*core.DataBatch{first}, sink.TakeBatches())
+}
@@ -0,0 +1,173 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metric
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+)
+
+// AggFunc is an aggregation computed by Aggregate. The aggregations shared with
+// core.AggregationType have the same names.
+type AggFunc string
+
+const (
+	AggSum AggFunc = "sum"
+	AggAvg AggFunc = "average"
+	AggMax AggFunc = "max"
+	AggMin AggFunc = "min"
+	AggP95 AggFunc = "95-perc"
+)
+
+// ParseAggFunc returns the AggFunc with the given name.
+func ParseAggFunc(name string) (AggFunc, error) {
+	switch fn := AggFunc(name); fn {
+	case AggSum, AggAvg, AggMax, AggMin, AggP95:
+		return fn, nil
+	}
+	return "", fmt.Errorf("unknown aggregation %q", name)
+}
+
+// Aggregates are the results of Aggregate.
+type Aggregates struct {
+	// The aggregate of the values of each key having values in the time range.
+	PerKey map[string]float64
+	// The aggregate of the values of all the keys.
+	Combined float64
+	// Number of values aggregated, Combined is meaningless when it is 0.
+	Count int
+}
+
+// aggregator computes an aggregation of the values it is given, only keeping all of
+// them when the aggregation needs them.
+type aggregator struct {
+	fn     AggFunc
+	count  int
+	sum    float64
+	min    float64
+	max    float64
+	values []float64
+}
+
+func (this *aggregator) add(value float64) {
+	if this.count == 0 || value < this.min {
+		this.min = value
+	}
+	if this.count == 0 || value > this.max {
+		this.max = value
+	}
+	this.count++
+	this.sum += value
+	if this.fn == AggP95 {
+		this.values = append(this.values, value)
+	}
+}
+
+func (this *aggregator) result() float64 {
+	if this.count == 0 {
+		return 0
+	}
+	switch this.fn {
+	case AggSum:
+		return this.sum
+	case AggAvg:
+		return this.sum / float64(this.count)
+	case AggMax:
+		return this.max
+	case AggMin:
+		return this.min
+	case AggP95:
+		// Nearest rank percentile.
+		sort.Float64s(this.values)
+		return this.values[int(math.Ceil(0.95*float64(len(this.values))))-1]
+	}
+	return 0
+}
+
+// Aggregate computes the aggregation of the values of the metric between start and end,
+// inclusive, for each of the keys and for all of them together. Values are read from the
+// stores and the downsampling tiers directly, without building the series returned by GetMetric.
+func (this *MetricSink) Aggregate(metricName string, keys []string, start, end time.Time, fn AggFunc) (Aggregates, error) {
+	if _, err := ParseAggFunc(string(fn)); err != nil {
+		return Aggregates{}, err
+	}
+	this.lock.Lock()
+	defer this.lock.Unlock()
+
+	perKey := make(map[string]*aggregator, len(keys))
+	combined := &aggregator{fn: fn}
+	add := func(key string, value float64) {
+		keyAggregator, found := perKey[key]
+		if !found {
+			keyAggregator = &aggregator{fn: fn}
+			perKey[key] = keyAggregator
+		}
+		keyAggregator.add(value)
+		combined.add(value)
+	}
+
+	if this.isLongStoreMetric(metricName) {
+		// The downsampled values are older than the long store ones, each bucket counts as one value.
+		for i := len(this.downsamplingTiers) - 1; i >= 0; i-- {
+			for _, bucket := range this.downsamplingTiers[i].buckets {
+				if bucket.timestamp.Before(start) || bucket.timestamp.After(end) {
+					continue
+				}
+				sums, counts := bucket.sums[metricName], bucket.counts[metricName]
+				for _, key := range keys {
+					if count := counts[key]; count > 0 {
+						add(key, float64(sums[key]/int64(count)))
+					}
+				}
+			}
+		}
+		for _, store := range this.longStore {
+			if store.timestamp.Before(start) || store.timestamp.After(end) {
+				continue
+			}
+			substore := store.store[metricName]
+			for _, key := range keys {
+				if val, found := substore[key]; found {
+					add(key, float64(val))
+				}
+			}
+		}
+	} else {
+		for _, batch := range this.shortStore {
+			if batch.Timestamp.Before(start) || batch.Timestamp.After(end) {
+				continue
+			}
+			for _, key := range keys {
+				if metricSet, found := batch.MetricSets[key]; found {
+					if metricValue, found := metricSet.MetricValues[metricName]; found {
+						add(key, metricValue.GetFloatValue())
+					}
+				}
+			}
+		}
+	}
+
+	result := Aggregates{
+		PerKey:   make(map[string]float64, len(perKey)),
+		Combined: combined.result(),
+		Count:    combined.count,
+	}
+	for key, keyAggregator := range perKey {
+		result.PerKey[key] = keyAggregator.result()
+	}
+	return result, nil
+}
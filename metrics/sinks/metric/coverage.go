@@ -0,0 +1,104 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metric
+
+import (
+	"time"
+
+	"k8s.io/heapster/metrics/core"
+)
+
+// Coverage compares the number of points stored for a metric of an entity in a time range with
+// the number expected from the metric resolution.
+type Coverage struct {
+	// Number of points expected, one per resolution in the part of the range the store covers.
+	Expected int
+	// Number of points stored.
+	Stored int
+}
+
+// SetMetricResolution sets the interval at which batches are exported to the sink, from which
+// GetCoverage computes the expected number of points.
+func (this *MetricSink) SetMetricResolution(resolution time.Duration) {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+	this.resolution = resolution
+}
+
+// GetCoverage returns the coverage of the metric, or of the labeled metric with the given labels
+// if they are not nil, for each of the keys between start and end, inclusive. The range is
+// limited to the batches kept by the store of the metric, so that points missing because the
+// store is still filling up, or because they were dropped with age, are not counted as expected.
+// Without a metric resolution, every stored batch in the range is expected to have a point.
+// Downsampled values are not counted.
+func (this *MetricSink) GetCoverage(metricName string, labels map[string]string, keys []string, start, end time.Time) map[string]Coverage {
+	this.lock.Lock()
+	var timestamps []time.Time
+	if labels == nil && this.isLongStoreMetric(metricName) {
+		for _, store := range this.longStore {
+			timestamps = append(timestamps, store.timestamp)
+		}
+	} else {
+		// The long store doesn't keep labeled metrics.
+		for _, batch := range this.shortStore {
+			timestamps = append(timestamps, batch.Timestamp)
+		}
+	}
+	expected := expectedPoints(timestamps, start, end, this.resolution)
+	this.lock.Unlock()
+
+	// The downsampled buckets start before the oldest batch kept at full resolution.
+	if len(timestamps) > 0 && start.Before(timestamps[0]) {
+		start = timestamps[0]
+	}
+	var stored map[string][]core.TimestampedMetricValue
+	if labels != nil {
+		stored = this.GetLabeledMetric(metricName, labels, keys, start, end)
+	} else {
+		stored = this.GetMetric(metricName, keys, start, end)
+	}
+
+	result := make(map[string]Coverage, len(keys))
+	for _, key := range keys {
+		result[key] = Coverage{
+			Expected: expected,
+			Stored:   len(stored[key]),
+		}
+	}
+	return result
+}
+
+// expectedPoints returns the number of points expected between start and end, given the sorted
+// timestamps of the stored batches.
+func expectedPoints(timestamps []time.Time, start, end time.Time, resolution time.Duration) int {
+	first, last := -1, -1
+	for i, timestamp := range timestamps {
+		if !timestamp.Before(start) && !timestamp.After(end) {
+			if first < 0 {
+				first = i
+			}
+			last = i
+		}
+	}
+	if first < 0 {
+		return 0
+	}
+	if resolution <= 0 {
+		return last - first + 1
+	}
+	// Rounded, since timestamps don't fall exactly at multiples of the resolution.
+	span := timestamps[last].Sub(timestamps[first])
+	return int((span+resolution/2)/resolution) + 1
+}
@@ -0,0 +1,239 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metric
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"k8s.io/heapster/metrics/core"
+)
+
+// DownsamplingTier keeps the values of the long store metrics averaged over coarser buckets once
+// they are dropped from the long store, or from the previous tier.
+type DownsamplingTier struct {
+	// Length of the buckets, the values of a metric set within one are averaged.
+	Resolution time.Duration
+	// Age up to which the buckets are kept.
+	Retention time.Duration
+}
+
+// ParseDownsamplingTiers parses a comma-separated list of tiers, each given as
+// <resolution>:<retention>, e.g. "1m:1h,10m:6h".
+func ParseDownsamplingTiers(value string) ([]DownsamplingTier, error) {
+	if value == "" {
+		return nil, nil
+	}
+	var tiers []DownsamplingTier
+	for _, tierRaw := range strings.Split(value, ",") {
+		parts := strings.Split(tierRaw, ":")
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("downsampling tier %q should be <resolution>:<retention>", tierRaw)
+		}
+		resolution, err := time.ParseDuration(parts[0])
+		if err != nil {
+			return nil, fmt.Errorf("invalid resolution of downsampling tier %q: %v", tierRaw, err)
+		}
+		retention, err := time.ParseDuration(parts[1])
+		if err != nil {
+			return nil, fmt.Errorf("invalid retention of downsampling tier %q: %v", tierRaw, err)
+		}
+		if resolution <= 0 {
+			return nil, fmt.Errorf("resolution of downsampling tier %q should be positive", tierRaw)
+		}
+		if len(tiers) > 0 {
+			previous := tiers[len(tiers)-1]
+			// Buckets of a tier are then rolled into whole buckets of the next one.
+			if resolution <= previous.Resolution || resolution%previous.Resolution != 0 {
+				return nil, fmt.Errorf("resolution of downsampling tier %q should be a multiple of the previous one", tierRaw)
+			}
+			if retention <= previous.Retention {
+				return nil, fmt.Errorf("retention of downsampling tier %q should be longer than the previous one", tierRaw)
+			}
+		}
+		tiers = append(tiers, DownsamplingTier{Resolution: resolution, Retention: retention})
+	}
+	return tiers, nil
+}
+
+// SetDownsamplingTiers makes the sink keep the values dropped from the long store in the given
+// tiers, ordered from the finest to the coarsest, instead of discarding them. The model queries
+// of the long store metrics return the averaged values of older buckets, timestamped with the
+// start of the bucket, before the values kept at full resolution.
+func (this *MetricSink) SetDownsamplingTiers(tiers []DownsamplingTier) error {
+	if len(tiers) > 0 && tiers[0].Retention <= this.longStoreDuration {
+		return fmt.Errorf("retention of the first downsampling tier %v should be longer than the long store duration %v",
+			tiers[0].Retention, this.longStoreDuration)
+	}
+	this.lock.Lock()
+	defer this.lock.Unlock()
+	this.downsamplingTiers = make([]*downsampledTier, 0, len(tiers))
+	for _, tier := range tiers {
+		this.downsamplingTiers = append(this.downsamplingTiers, &downsampledTier{DownsamplingTier: tier})
+	}
+	return nil
+}
+
+// LongStoreRetention returns for how long values of the long store metrics are kept, either at full
+// resolution or in the coarsest downsampling tier.
+func (this *MetricSink) LongStoreRetention() time.Duration {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+	if len(this.downsamplingTiers) == 0 {
+		return this.longStoreDuration
+	}
+	return this.downsamplingTiers[len(this.downsamplingTiers)-1].Retention
+}
+
+type downsampledTier struct {
+	DownsamplingTier
+	// Sorted by timestamp.
+	buckets []*downsampledBucket
+}
+
+// downsampledBucket holds the sums and the numbers of the values of each metric and metric set
+// within a bucket, so that buckets can be merged before their average is taken.
+type downsampledBucket struct {
+	// Start of the bucket.
+	timestamp time.Time
+	// Metric name to metric set key to the sum of its values.
+	sums map[string]map[string]int64
+	// Metric name to metric set key to the number of its values.
+	counts map[string]map[string]int
+}
+
+func (this *downsampledBucket) add(metricName, key string, sum int64, count int) {
+	if this.sums[metricName] == nil {
+		this.sums[metricName] = make(map[string]int64)
+		this.counts[metricName] = make(map[string]int)
+	}
+	this.sums[metricName][key] += sum
+	this.counts[metricName][key] += count
+}
+
+// bucket returns the bucket of the tier starting at the given time, creating it if needed.
+func (this *downsampledTier) bucket(timestamp time.Time) *downsampledBucket {
+	for i := len(this.buckets) - 1; i >= 0; i-- {
+		if this.buckets[i].timestamp.Equal(timestamp) {
+			return this.buckets[i]
+		}
+	}
+	bucket := &downsampledBucket{
+		timestamp: timestamp,
+		sums:      make(map[string]map[string]int64),
+		counts:    make(map[string]map[string]int),
+	}
+	this.buckets = append(this.buckets, bucket)
+	sort.Slice(this.buckets, func(i, j int) bool {
+		return this.buckets[i].timestamp.Before(this.buckets[j].timestamp)
+	})
+	return bucket
+}
+
+func (this *downsampledTier) addStore(store *multimetricStore) {
+	bucket := this.bucket(store.timestamp.Truncate(this.Resolution))
+	for metricName, values := range store.store {
+		for key, value := range values {
+			bucket.add(metricName, key, value, 1)
+		}
+	}
+}
+
+func (this *downsampledTier) addBucket(finer *downsampledBucket) {
+	bucket := this.bucket(finer.timestamp.Truncate(this.Resolution))
+	for metricName, sums := range finer.sums {
+		for key, sum := range sums {
+			bucket.add(metricName, key, sum, finer.counts[metricName][key])
+		}
+	}
+}
+
+// popOld removes and returns the buckets which end before the cutoff time.
+func (this *downsampledTier) popOld(cutoff time.Time) []*downsampledBucket {
+	i := 0
+	for i < len(this.buckets) && !this.buckets[i].timestamp.Add(this.Resolution).After(cutoff) {
+		i++
+	}
+	old := this.buckets[:i]
+	this.buckets = this.buckets[i:]
+	return old
+}
+
+// rollDownsamplingTiers moves the long store entries dropped at the cutoff time into the first tier, and the
+// buckets older than the retention of each tier into the next one. Must be called with the lock held.
+func (this *MetricSink) rollDownsamplingTiers(cutoff, now time.Time) {
+	if len(this.downsamplingTiers) == 0 {
+		return
+	}
+	for _, store := range this.longStore {
+		if !store.timestamp.After(cutoff) {
+			this.downsamplingTiers[0].addStore(store)
+		}
+	}
+	for i, tier := range this.downsamplingTiers {
+		old := tier.popOld(now.Add(-tier.Retention))
+		if i+1 < len(this.downsamplingTiers) {
+			for _, bucket := range old {
+				this.downsamplingTiers[i+1].addBucket(bucket)
+			}
+		}
+	}
+}
+
+// getDownsampled returns the averaged values of the metric for the keys, in the buckets starting
+// between start and end, from the oldest to the newest. Must be called with the lock held.
+func (this *MetricSink) getDownsampled(metricName string, keys []string, start, end time.Time) map[string][]core.TimestampedMetricValue {
+	result := make(map[string][]core.TimestampedMetricValue)
+	for i := len(this.downsamplingTiers) - 1; i >= 0; i-- {
+		for _, bucket := range this.downsamplingTiers[i].buckets {
+			if bucket.timestamp.Before(start) || bucket.timestamp.After(end) {
+				continue
+			}
+			sums, counts := bucket.sums[metricName], bucket.counts[metricName]
+			for _, key := range keys {
+				if count := counts[key]; count > 0 {
+					result[key] = append(result[key], core.TimestampedMetricValue{
+						Timestamp: bucket.timestamp,
+						MetricValue: core.MetricValue{
+							IntValue:   sums[key] / int64(count),
+							ValueType:  core.ValueInt64,
+							MetricType: core.MetricGauge,
+						},
+					})
+				}
+			}
+		}
+	}
+	return result
+}
+
+// evictDownsampled removes the keys for which evicted is true from all the tiers. Must be called
+// with the lock held.
+func (this *MetricSink) evictDownsampled(evicted func(key string) bool) {
+	for _, tier := range this.downsamplingTiers {
+		for _, bucket := range tier.buckets {
+			for metricName, sums := range bucket.sums {
+				for key := range sums {
+					if evicted(key) {
+						delete(sums, key)
+						delete(bucket.counts[metricName], key)
+					}
+				}
+			}
+		}
+	}
+}
@@ -15,12 +15,31 @@
 package metric
 
 import (
+	"sort"
+	"strings"
 	"sync"
 	"time"
 
+	"github.com/prometheus/client_golang/prometheus"
 	"k8s.io/heapster/metrics/core"
 )
 
+var (
+	// Number of exported batches waiting to be applied to the stores.
+	writeBufferDepth = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Namespace: "heapster",
+			Subsystem: "metric_sink",
+			Name:      "write_buffer_batches",
+			Help:      "Number of batches buffered by the metric sink, waiting to be applied to its stores.",
+		},
+	)
+)
+
+func init() {
+	prometheus.MustRegister(writeBufferDepth)
+}
+
 // A simple in-memory storage for metrics. It divides metrics into 2 categories
 // * metrics that need to be stored for couple minutes.
 // * metrics that need to be stored for longer time (15 min, 1 hour).
@@ -38,6 +57,42 @@ type MetricSink struct {
 	shortStore []*core.DataBatch
 	// Memory-efficient long/mid term storage for metrics.
 	longStore []*multimetricStore
+
+	// Keys of metric sets of terminated entities, whose metrics are retained until evicted.
+	terminated map[string]bool
+
+	// Interval at which batches are exported to the sink, zero if unknown.
+	resolution time.Duration
+
+	// Tiers keeping the values dropped from the long store at coarser resolutions, finest first.
+	downsamplingTiers []*downsampledTier
+
+	// Maximum number of points kept in the long store. Non-positive means unlimited.
+	maxLongStorePoints int
+	// Number of points in the long store, in total and by metric set key, kept up to date as
+	// points are added and removed so that the cap is checked without scanning the store.
+	longStorePoints      int
+	longStorePointsByKey map[string]int
+	// When metrics of the given metric set keys were last read from the long store.
+	lastQueried map[string]time.Time
+
+	// Guards the write buffer, so that buffering a batch doesn't wait for the readers.
+	bufferLock sync.Mutex
+	// Batches exported while write buffering is enabled, not applied to the stores yet.
+	buffered []bufferedBatch
+	// Closed to stop write buffering, nil if it is disabled.
+	stopBuffering chan struct{}
+	// Closed once the remaining batches were applied after write buffering was stopped.
+	bufferingStopped chan struct{}
+	// Incremented by ClearCache while holding both locks, so batches taken before a clear are
+	// dropped instead of being added to the cleared stores.
+	clearGeneration int
+}
+
+// bufferedBatch is an exported batch together with its long store entry.
+type bufferedBatch struct {
+	batch     *core.DataBatch
+	longStore *multimetricStore
 }
 
 // Stores values of a single metrics for different MetricSets.
@@ -49,6 +104,16 @@ type multimetricStore struct {
 	timestamp time.Time
 	// Metric name to int64store with metric values.
 	store map[string]int64Store
+	// Point timestamps of the metric sets which differ from the batch timestamp, by key.
+	pointTimestamps map[string]time.Time
+}
+
+// pointTimestamp returns the timestamp of the points of the metric set with the given key.
+func (this *multimetricStore) pointTimestamp(key string) time.Time {
+	if timestamp, found := this.pointTimestamps[key]; found {
+		return timestamp
+	}
+	return this.timestamp
 }
 
 func buildMultimetricStore(metrics []string, batch *core.DataBatch) *multimetricStore {
@@ -60,12 +125,20 @@ func buildMultimetricStore(metrics []string, batch *core.DataBatch) *multimetric
 		store.store[metric] = make(int64Store, len(batch.MetricSets))
 	}
 	for key, ms := range batch.MetricSets {
+		stored := false
 		for _, metric := range metrics {
 			if metricValue, found := ms.MetricValues[metric]; found {
 				metricstore := store.store[metric]
 				metricstore[key] = metricValue.IntValue
+				stored = true
 			}
 		}
+		if timestamp := core.PointTimestamp(batch, ms); stored && !timestamp.Equal(batch.Timestamp) {
+			if store.pointTimestamps == nil {
+				store.pointTimestamps = make(map[string]time.Time)
+			}
+			store.pointTimestamps[key] = timestamp
+		}
 	}
 	return &store
 }
@@ -74,19 +147,335 @@ func (this *MetricSink) Name() string {
 	return "Metric Sink"
 }
 
+// Stop disables write buffering and returns once the buffered batches were applied.
 func (this *MetricSink) Stop() {
-	// Do nothing.
+	this.bufferLock.Lock()
+	stop, stopped := this.stopBuffering, this.bufferingStopped
+	this.stopBuffering = nil
+	this.bufferingStopped = nil
+	this.bufferLock.Unlock()
+	if stop == nil {
+		return
+	}
+	close(stop)
+	// The buffer lock is not held here, as applying the batches takes it.
+	<-stopped
 }
 
 func (this *MetricSink) ExportData(batch *core.DataBatch) {
+	// The long store entry doesn't depend on the stores, so it is built without holding the lock.
+	buffered := bufferedBatch{
+		batch:     batch,
+		longStore: buildMultimetricStore(this.longStoreMetrics, batch),
+	}
+
+	this.bufferLock.Lock()
+	if this.stopBuffering != nil {
+		this.buffered = append(this.buffered, buffered)
+		writeBufferDepth.Set(float64(len(this.buffered)))
+		this.bufferLock.Unlock()
+		return
+	}
+	generation := this.clearGeneration
+	this.bufferLock.Unlock()
+
+	this.addBatchesIfNotCleared([]bufferedBatch{buffered}, generation)
+}
+
+// addBatchesIfNotCleared adds the batches to the stores, unless the cache was cleared since the
+// batches were taken at the given clear generation.
+func (this *MetricSink) addBatchesIfNotCleared(batches []bufferedBatch, generation int) {
 	this.lock.Lock()
 	defer this.lock.Unlock()
+	if generation != this.clearGeneration {
+		return
+	}
+	this.addBatches(batches)
+}
 
+// addBatches adds the batches to the stores. Must be called with the lock held.
+func (this *MetricSink) addBatches(batches []bufferedBatch) {
 	now := time.Now()
 	// TODO: add sorting
-	this.longStore = append(popOldStore(this.longStore, now.Add(-this.longStoreDuration)),
-		buildMultimetricStore(this.longStoreMetrics, batch))
-	this.shortStore = append(popOld(this.shortStore, now.Add(-this.shortStoreDuration)), batch)
+	for _, buffered := range batches {
+		cutoff := now.Add(-this.longStoreDuration)
+		this.rollDownsamplingTiers(cutoff, now)
+		for _, store := range this.longStore {
+			if !store.timestamp.After(cutoff) {
+				this.countLongStorePoints(store, -1)
+			}
+		}
+		this.longStore = append(popOldStore(this.longStore, cutoff), buffered.longStore)
+		this.countLongStorePoints(buffered.longStore, 1)
+		this.shortStore = append(popOld(this.shortStore, now.Add(-this.shortStoreDuration)), buffered.batch)
+	}
+	this.limitLongStorePoints()
+}
+
+// countLongStorePoints adds the points of the store to the long store point counts, or subtracts
+// them if sign is negative. Must be called with the lock held.
+func (this *MetricSink) countLongStorePoints(store *multimetricStore, sign int) {
+	if this.longStorePointsByKey == nil {
+		this.longStorePointsByKey = make(map[string]int)
+	}
+	for _, values := range store.store {
+		for key := range values {
+			this.countLongStorePoint(key, sign)
+		}
+	}
+}
+
+func (this *MetricSink) countLongStorePoint(key string, sign int) {
+	this.longStorePoints += sign
+	if count := this.longStorePointsByKey[key] + sign; count > 0 {
+		this.longStorePointsByKey[key] = count
+	} else {
+		delete(this.longStorePointsByKey, key)
+	}
+}
+
+// deleteLongStorePoint removes the value of the metric set with the given key from the values
+// of a long store metric. Must be called with the lock held.
+func (this *MetricSink) deleteLongStorePoint(values int64Store, key string) {
+	if _, found := values[key]; found {
+		delete(values, key)
+		this.countLongStorePoint(key, -1)
+	}
+}
+
+// EnableWriteBuffering makes the sink buffer the exported batches and add them to its stores in
+// a single locked pass every interval, so that exports contend less with the concurrent reads of
+// the model API. Buffered batches are not visible to the readers until they are applied.
+// Stop applies the remaining batches and disables buffering again.
+func (this *MetricSink) EnableWriteBuffering(interval time.Duration) {
+	this.bufferLock.Lock()
+	defer this.bufferLock.Unlock()
+	if this.stopBuffering != nil {
+		return
+	}
+	stop := make(chan struct{})
+	stopped := make(chan struct{})
+	this.stopBuffering = stop
+	this.bufferingStopped = stopped
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				this.applyBufferedBatches()
+			case <-stop:
+				this.applyBufferedBatches()
+				close(stopped)
+				return
+			}
+		}
+	}()
+}
+
+// applyBufferedBatches adds the buffered batches to the stores.
+func (this *MetricSink) applyBufferedBatches() {
+	batches, generation := this.takeBufferedBatches()
+	if len(batches) == 0 {
+		return
+	}
+	this.addBatchesIfNotCleared(batches, generation)
+}
+
+// takeBufferedBatches empties the write buffer and returns its batches with the current clear
+// generation.
+func (this *MetricSink) takeBufferedBatches() ([]bufferedBatch, int) {
+	this.bufferLock.Lock()
+	defer this.bufferLock.Unlock()
+	batches := this.buffered
+	this.buffered = nil
+	writeBufferDepth.Set(0)
+	return batches, this.clearGeneration
+}
+
+// SetMaxLongStorePoints caps the number of points kept in the long store across all metric sets.
+// When the cap is exceeded, the points of the least recently queried metric sets are evicted
+// from the long store first. A non-positive value means unlimited.
+func (this *MetricSink) SetMaxLongStorePoints(maxPoints int) {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+
+	this.maxLongStorePoints = maxPoints
+	this.limitLongStorePoints()
+}
+
+// limitLongStorePoints evicts the metric sets from the long store until it holds at most
+// maxLongStorePoints points. Must be called with the lock held.
+func (this *MetricSink) limitLongStorePoints() {
+	if this.maxLongStorePoints <= 0 {
+		return
+	}
+	for key := range this.lastQueried {
+		if _, found := this.longStorePointsByKey[key]; !found {
+			delete(this.lastQueried, key)
+		}
+	}
+	if this.longStorePoints <= this.maxLongStorePoints {
+		return
+	}
+
+	keys := make([]string, 0, len(this.longStorePointsByKey))
+	for key := range this.longStorePointsByKey {
+		keys = append(keys, key)
+	}
+	// Never queried metric sets have a zero time, so they are evicted first.
+	sort.Slice(keys, func(i, j int) bool {
+		ti, tj := this.lastQueried[keys[i]], this.lastQueried[keys[j]]
+		if !ti.Equal(tj) {
+			return ti.Before(tj)
+		}
+		return keys[i] < keys[j]
+	})
+	evicted := make(map[string]bool)
+	total := this.longStorePoints
+	for _, key := range keys {
+		if total <= this.maxLongStorePoints {
+			break
+		}
+		evicted[key] = true
+		total -= this.longStorePointsByKey[key]
+	}
+	for _, store := range this.longStore {
+		for _, values := range store.store {
+			for key := range evicted {
+				this.deleteLongStorePoint(values, key)
+			}
+		}
+	}
+	this.evictDownsampled(func(key string) bool { return evicted[key] })
+	for key := range evicted {
+		delete(this.lastQueried, key)
+	}
+}
+
+// EvictMetricSets removes the metric sets with the given keys and their children from all the
+// stored batches. Stored batches are shared with other sinks, so they are copied rather than modified.
+func (this *MetricSink) EvictMetricSets(keys []string) {
+	if len(keys) == 0 {
+		return
+	}
+	evicted := func(key string) bool {
+		for _, evictedKey := range keys {
+			if key == evictedKey || strings.HasPrefix(key, evictedKey+"/") {
+				return true
+			}
+		}
+		return false
+	}
+
+	this.lock.Lock()
+	defer this.lock.Unlock()
+
+	for _, key := range keys {
+		delete(this.terminated, key)
+	}
+	for i, batch := range this.shortStore {
+		var metricSets map[string]*core.MetricSet
+		for key := range batch.MetricSets {
+			if evicted(key) {
+				metricSets = make(map[string]*core.MetricSet, len(batch.MetricSets))
+				break
+			}
+		}
+		if metricSets == nil {
+			continue
+		}
+		for key, ms := range batch.MetricSets {
+			if !evicted(key) {
+				metricSets[key] = ms
+			}
+		}
+		this.shortStore[i] = &core.DataBatch{
+			Timestamp:  batch.Timestamp,
+			MetricSets: metricSets,
+		}
+	}
+	for _, store := range this.longStore {
+		for _, values := range store.store {
+			for key := range values {
+				if evicted(key) {
+					this.deleteLongStorePoint(values, key)
+				}
+			}
+		}
+	}
+	this.evictDownsampled(evicted)
+}
+
+// ClearCache drops all the stored and buffered batches, including the downsampled values, so
+// that the model only serves the batches exported afterwards. Entities marked as terminated stay
+// marked, since that depends on the entity rather than on its stored metrics.
+func (this *MetricSink) ClearCache() {
+	// Nothing holds the lock while taking the buffer lock, so they can't deadlock in this order.
+	this.bufferLock.Lock()
+	defer this.bufferLock.Unlock()
+	this.buffered = nil
+	writeBufferDepth.Set(0)
+
+	this.lock.Lock()
+	defer this.lock.Unlock()
+	this.clearGeneration++
+	this.shortStore = make([]*core.DataBatch, 0)
+	this.longStore = make([]*multimetricStore, 0)
+	this.longStorePoints = 0
+	this.longStorePointsByKey = nil
+	for _, tier := range this.downsamplingTiers {
+		tier.buckets = nil
+	}
+	this.lastQueried = nil
+}
+
+func (this *MetricSink) SetTerminated(key string, terminated bool) {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+
+	if !terminated {
+		delete(this.terminated, key)
+		return
+	}
+	if this.terminated == nil {
+		this.terminated = make(map[string]bool)
+	}
+	this.terminated[key] = true
+}
+
+// IsTerminated returns whether the metric set with the given key, or its parent, was marked
+// as terminated with SetTerminated.
+func (this *MetricSink) IsTerminated(key string) bool {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+
+	for terminatedKey := range this.terminated {
+		if key == terminatedKey || strings.HasPrefix(key, terminatedKey+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// isLongStoreMetric returns whether the values of the given metric are kept in the long store.
+func (this *MetricSink) isLongStoreMetric(metricName string) bool {
+	for _, longStoreMetric := range this.longStoreMetrics {
+		if longStoreMetric == metricName {
+			return true
+		}
+	}
+	return false
+}
+
+// ShortStoreDuration returns for how long full batches are kept.
+func (this *MetricSink) ShortStoreDuration() time.Duration {
+	return this.shortStoreDuration
+}
+
+// LongStoreDuration returns for how long values of the long store metrics are kept at full resolution.
+func (this *MetricSink) LongStoreDuration() time.Duration {
+	return this.longStoreDuration
 }
 
 func (this *MetricSink) GetLatestDataBatch() *core.DataBatch {
@@ -114,23 +503,41 @@ func (this *MetricSink) GetMetric(metricName string, keys []string, start, end t
 	this.lock.Lock()
 	defer this.lock.Unlock()
 
-	useLongStore := false
-	for _, longStoreMetric := range this.longStoreMetrics {
-		if longStoreMetric == metricName {
-			useLongStore = true
+	result := make(map[string][]core.TimestampedMetricValue, len(keys))
+	if this.isLongStoreMetric(metricName) {
+		if this.maxLongStorePoints > 0 {
+			if this.lastQueried == nil {
+				this.lastQueried = make(map[string]time.Time)
+			}
+			now := time.Now()
+			for _, key := range keys {
+				this.lastQueried[key] = now
+			}
+		}
+		points := 0
+		for _, store := range this.longStore {
+			if !store.timestamp.Before(start) && !store.timestamp.After(end) {
+				points++
+			}
+		}
+		// The downsampled values are older than the long store ones.
+		downsampled := this.getDownsampled(metricName, keys, start, end)
+		for key, values := range downsampled {
+			result[key] = append(make([]core.TimestampedMetricValue, 0, len(values)+points), values...)
 		}
-	}
-
-	result := make(map[string][]core.TimestampedMetricValue)
-	if useLongStore {
 		for _, store := range this.longStore {
 			// Inclusive start and end.
 			if !store.timestamp.Before(start) && !store.timestamp.After(end) {
 				substore := store.store[metricName]
 				for _, key := range keys {
 					if val, found := substore[key]; found {
-						result[key] = append(result[key], core.TimestampedMetricValue{
-							Timestamp: store.timestamp,
+						keyResult, found := result[key]
+						if !found {
+							// Allocate once for all the points in the range.
+							keyResult = make([]core.TimestampedMetricValue, 0, points)
+						}
+						result[key] = append(keyResult, core.TimestampedMetricValue{
+							Timestamp: store.pointTimestamp(key),
 							MetricValue: core.MetricValue{
 								IntValue:   val,
 								ValueType:  core.ValueInt64,
@@ -142,6 +549,12 @@ func (this *MetricSink) GetMetric(metricName string, keys []string, start, end t
 			}
 		}
 	} else {
+		points := 0
+		for _, batch := range this.shortStore {
+			if !batch.Timestamp.Before(start) && !batch.Timestamp.After(end) {
+				points++
+			}
+		}
 		for _, batch := range this.shortStore {
 			// Inclusive start and end.
 			if !batch.Timestamp.Before(start) && !batch.Timestamp.After(end) {
@@ -156,10 +569,10 @@ func (this *MetricSink) GetMetric(metricName string, keys []string, start, end t
 					}
 					keyResult, found := result[key]
 					if !found {
-						keyResult = make([]core.TimestampedMetricValue, 0)
+						keyResult = make([]core.TimestampedMetricValue, 0, points)
 					}
 					keyResult = append(keyResult, core.TimestampedMetricValue{
-						Timestamp:   batch.Timestamp,
+						Timestamp:   core.PointTimestamp(batch, metricSet),
 						MetricValue: metricValue,
 					})
 					result[key] = keyResult
@@ -170,6 +583,143 @@ func (this *MetricSink) GetMetric(metricName string, keys []string, start, end t
 	return result
 }
 
+// Values older than this many metric resolutions before the latest batch are not returned by
+// GetLatestMetric.
+const latestMetricWindowResolutions = 3
+
+// Window of GetLatestMetric when the metric resolution is unknown.
+const defaultLatestMetricWindow = 3 * time.Minute
+
+// latestMetricCutoff returns the time before which GetLatestMetric stops searching the stores.
+// Must be called with the lock held.
+func (this *MetricSink) latestMetricCutoff(latest time.Time) time.Time {
+	if this.resolution > 0 {
+		return latest.Add(-latestMetricWindowResolutions * this.resolution)
+	}
+	return latest.Add(-defaultLatestMetricWindow)
+}
+
+// GetLatestMetric returns the most recent value of the metric for each of the keys that have one.
+// The stores are searched from the newest batch backwards until all keys are found, and no further
+// than a few metric resolutions before the newest batch, so that the cost depends on the number of
+// keys, not on the stored series. Keys without a value in that window are left out of the result.
+func (this *MetricSink) GetLatestMetric(metricName string, keys []string) map[string]core.TimestampedMetricValue {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+
+	keys = uniqueKeys(keys)
+	result := make(map[string]core.TimestampedMetricValue, len(keys))
+	if this.isLongStoreMetric(metricName) {
+		if this.maxLongStorePoints > 0 {
+			if this.lastQueried == nil {
+				this.lastQueried = make(map[string]time.Time)
+			}
+			now := time.Now()
+			for _, key := range keys {
+				this.lastQueried[key] = now
+			}
+		}
+		if len(this.longStore) == 0 {
+			return result
+		}
+		cutoff := this.latestMetricCutoff(this.longStore[len(this.longStore)-1].timestamp)
+		for i := len(this.longStore) - 1; i >= 0 && len(result) < len(keys); i-- {
+			store := this.longStore[i]
+			if store.timestamp.Before(cutoff) {
+				break
+			}
+			substore := store.store[metricName]
+			for _, key := range keys {
+				if _, found := result[key]; found {
+					continue
+				}
+				if val, found := substore[key]; found {
+					result[key] = core.TimestampedMetricValue{
+						Timestamp: store.pointTimestamp(key),
+						MetricValue: core.MetricValue{
+							IntValue:   val,
+							ValueType:  core.ValueInt64,
+							MetricType: core.MetricGauge,
+						},
+					}
+				}
+			}
+		}
+	} else {
+		if len(this.shortStore) == 0 {
+			return result
+		}
+		cutoff := this.latestMetricCutoff(this.shortStore[len(this.shortStore)-1].Timestamp)
+		for i := len(this.shortStore) - 1; i >= 0 && len(result) < len(keys); i-- {
+			batch := this.shortStore[i]
+			if batch.Timestamp.Before(cutoff) {
+				break
+			}
+			for _, key := range keys {
+				if _, found := result[key]; found {
+					continue
+				}
+				metricSet, found := batch.MetricSets[key]
+				if !found {
+					continue
+				}
+				if metricValue, found := metricSet.MetricValues[metricName]; found {
+					result[key] = core.TimestampedMetricValue{
+						Timestamp:   core.PointTimestamp(batch, metricSet),
+						MetricValue: metricValue,
+					}
+				}
+			}
+		}
+	}
+	return result
+}
+
+// uniqueKeys returns the keys without the duplicates, in the order they are first listed.
+func uniqueKeys(keys []string) []string {
+	seen := make(map[string]bool, len(keys))
+	result := make([]string, 0, len(keys))
+	for _, key := range keys {
+		if !seen[key] {
+			seen[key] = true
+			result = append(result, key)
+		}
+	}
+	return result
+}
+
+// GetMetricWithStep works like GetMetric, but returns at most one point, the latest one,
+// per step long period. A non-positive step returns all points.
+func (this *MetricSink) GetMetricWithStep(metricName string, keys []string, start, end time.Time, step time.Duration) map[string][]core.TimestampedMetricValue {
+	return downsample(this.GetMetric(metricName, keys, start, end), step)
+}
+
+// GetLabeledMetricWithStep is the GetLabeledMetric counterpart of GetMetricWithStep.
+func (this *MetricSink) GetLabeledMetricWithStep(metricName string, labels map[string]string, keys []string, start, end time.Time, step time.Duration) map[string][]core.TimestampedMetricValue {
+	return downsample(this.GetLabeledMetric(metricName, labels, keys, start, end), step)
+}
+
+// downsample keeps only the latest point in each step long period. Periods are aligned to
+// multiples of step, so consecutive queries return the same points. Points have to be sorted
+// by timestamp, which holds for values returned from the stores.
+func downsample(metrics map[string][]core.TimestampedMetricValue, step time.Duration) map[string][]core.TimestampedMetricValue {
+	if step <= 0 {
+		return metrics
+	}
+	for key, values := range metrics {
+		result := make([]core.TimestampedMetricValue, 0, len(values))
+		for _, value := range values {
+			if len(result) > 0 && result[len(result)-1].Timestamp.Truncate(step).Equal(value.Timestamp.Truncate(step)) {
+				result[len(result)-1] = value
+			} else {
+				result = append(result, value)
+			}
+		}
+		metrics[key] = result
+	}
+	return metrics
+}
+
 func (this *MetricSink) GetLabeledMetric(metricName string, labels map[string]string, keys []string, start, end time.Time) map[string][]core.TimestampedMetricValue {
 	// NB: the long store doesn't store labeled metrics, so it's not relevant here
 	result := make(map[string][]core.TimestampedMetricValue)
@@ -201,7 +751,7 @@ func (this *MetricSink) GetLabeledMetric(metricName string, labels map[string]st
 
 					if labelsMatch {
 						result[key] = append(result[key], core.TimestampedMetricValue{
-							Timestamp:   batch.Timestamp,
+							Timestamp:   core.PointTimestamp(batch, metricSet),
 							MetricValue: labeledMetric.MetricValue,
 						})
 					}
@@ -232,6 +782,27 @@ func (this *MetricSink) GetMetricNames(key string) []string {
 	return result
 }
 
+// FindMetricValue returns the latest value stored for the metric in any metric set, labeled
+// or not, so that the type of metrics without a descriptor, e.g. custom metrics, is known.
+func (this *MetricSink) FindMetricValue(metricName string) (core.MetricValue, bool) {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+
+	for i := len(this.shortStore) - 1; i >= 0; i-- {
+		for _, ms := range this.shortStore[i].MetricSets {
+			if value, found := ms.MetricValues[metricName]; found {
+				return value, true
+			}
+			for _, labeledMetric := range ms.LabeledMetrics {
+				if labeledMetric.Name == metricName {
+					return labeledMetric.MetricValue, true
+				}
+			}
+		}
+	}
+	return core.MetricValue{}, false
+}
+
 func (this *MetricSink) getAllNames(predicate func(ms *core.MetricSet) bool,
 	name func(key string, ms *core.MetricSet) string) []string {
 	this.lock.Lock()
@@ -247,6 +818,8 @@ func (this *MetricSink) getAllNames(predicate func(ms *core.MetricSet) bool,
 			result = append(result, name(key, value))
 		}
 	}
+	// Sorted, so that listings are stable between requests.
+	sort.Strings(result)
 	return result
 }
 
@@ -259,6 +832,26 @@ func (this *MetricSink) GetMetricSetKeys() []string {
 		func(key string, ms *core.MetricSet) string { return key })
 }
 
+// ListEntities returns the sorted keys of all metric sets of the given type
+// (e.g. core.MetricSetTypePodContainer) from the latest batch. If parentKey is not empty,
+// only the children of the given entity are returned, e.g. the containers of
+// core.PodKey(namespace, pod).
+func (this *MetricSink) ListEntities(entityType string, parentKey string) []string {
+	result := this.getAllNames(
+		func(ms *core.MetricSet) bool { return ms.Labels[core.LabelMetricSetType.Key] == entityType },
+		func(key string, ms *core.MetricSet) string { return key })
+	if parentKey != "" {
+		children := make([]string, 0, len(result))
+		for _, key := range result {
+			if strings.HasPrefix(key, parentKey+"/") {
+				children = append(children, key)
+			}
+		}
+		result = children
+	}
+	return result
+}
+
 func (this *MetricSink) GetNodes() []string {
 	return this.getAllNames(
 		func(ms *core.MetricSet) bool { return ms.Labels[core.LabelMetricSetType.Key] == core.MetricSetTypeNode },
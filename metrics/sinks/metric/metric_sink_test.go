@@ -15,6 +15,7 @@
 package metric
 
 import (
+	"fmt"
 	"testing"
 	"time"
 
@@ -182,6 +183,392 @@ func TestGetMetrics(t *testing.T) {
 	assert.Contains(t, metricNames, "m2")
 }
 
+func TestGetCoverage(t *testing.T) {
+	now := time.Now()
+	key := core.PodKey("ns1", "pod1")
+	otherKey := core.PodKey("ns1", "pod2")
+	value := core.MetricValue{ValueType: core.ValueInt64, MetricType: core.MetricGauge, IntValue: 1}
+	metricSet := func() *core.MetricSet {
+		return &core.MetricSet{
+			MetricValues: map[string]core.MetricValue{"m1": value, "m2": value},
+			LabeledMetrics: []core.LabeledMetric{
+				{Name: "lblmetric", Labels: map[string]string{"lbl": "val"}, MetricValue: value},
+			},
+		}
+	}
+
+	metrics := NewMetricSink(10*time.Minute, 10*time.Minute, []string{"m1"})
+	// The scrape 2 minutes ago was missed, pod2 only has the latest point.
+	for _, ago := range []time.Duration{4 * time.Minute, 3 * time.Minute, time.Minute} {
+		batch := &core.DataBatch{
+			Timestamp:  now.Add(-ago),
+			MetricSets: map[string]*core.MetricSet{key: metricSet()},
+		}
+		if ago == time.Minute {
+			batch.MetricSets[otherKey] = metricSet()
+		}
+		metrics.ExportData(batch)
+	}
+
+	// Without a resolution, only the stored batches are expected.
+	coverage := metrics.GetCoverage("m1", nil, []string{key}, now.Add(-10*time.Minute), now)
+	assert.Equal(t, Coverage{Expected: 3, Stored: 3}, coverage[key])
+
+	metrics.SetMetricResolution(time.Minute)
+	for _, metricName := range []string{"m1", "m2"} {
+		coverage = metrics.GetCoverage(metricName, nil, []string{key, otherKey}, now.Add(-10*time.Minute), now)
+		assert.Equal(t, Coverage{Expected: 4, Stored: 3}, coverage[key], metricName)
+		assert.Equal(t, Coverage{Expected: 4, Stored: 1}, coverage[otherKey], metricName)
+	}
+	coverage = metrics.GetCoverage("lblmetric", map[string]string{"lbl": "val"}, []string{key}, now.Add(-10*time.Minute), now)
+	assert.Equal(t, Coverage{Expected: 4, Stored: 3}, coverage[key])
+
+	// The range is limited to the stored batches within it.
+	coverage = metrics.GetCoverage("m1", nil, []string{key}, now.Add(-90*time.Second), now)
+	assert.Equal(t, Coverage{Expected: 1, Stored: 1}, coverage[key])
+	coverage = metrics.GetCoverage("m1", nil, []string{key}, now.Add(-time.Hour), now.Add(-30*time.Minute))
+	assert.Equal(t, Coverage{}, coverage[key])
+}
+
+func TestParseDownsamplingTiers(t *testing.T) {
+	tiers, err := ParseDownsamplingTiers("1m:2h,10m:24h")
+	assert.NoError(t, err)
+	assert.Equal(t, []DownsamplingTier{
+		{Resolution: time.Minute, Retention: 2 * time.Hour},
+		{Resolution: 10 * time.Minute, Retention: 24 * time.Hour},
+	}, tiers)
+
+	tiers, err = ParseDownsamplingTiers("")
+	assert.NoError(t, err)
+	assert.Empty(t, tiers)
+
+	for _, value := range []string{"1m", "x:2h", "1m:x", "0s:2h", "1m:2h,90s:24h", "1m:2h,30s:24h", "1m:2h,10m:1h"} {
+		_, err := ParseDownsamplingTiers(value)
+		assert.Error(t, err, value)
+	}
+}
+
+func TestDownsamplingTiers(t *testing.T) {
+	// Buckets are aligned to multiples of their resolution, the points are placed relative to
+	// an aligned base so that they fall into known buckets whatever the current time.
+	base := time.Now().Truncate(30 * time.Minute)
+	key := core.PodKey("ns1", "pod1")
+	batch := func(timestamp time.Time, value int64) *core.DataBatch {
+		return &core.DataBatch{
+			Timestamp: timestamp,
+			MetricSets: map[string]*core.MetricSet{
+				key: {
+					MetricValues: map[string]core.MetricValue{
+						"m1": {ValueType: core.ValueInt64, MetricType: core.MetricGauge, IntValue: value},
+					},
+				},
+			},
+		}
+	}
+
+	metrics := NewMetricSink(10*time.Minute, 10*time.Minute, []string{"m1"})
+	assert.Error(t, metrics.SetDownsamplingTiers([]DownsamplingTier{{Resolution: time.Minute, Retention: 5 * time.Minute}}))
+	assert.NoError(t, metrics.SetDownsamplingTiers([]DownsamplingTier{
+		{Resolution: 10 * time.Minute, Retention: time.Hour},
+		{Resolution: 30 * time.Minute, Retention: 3 * time.Hour},
+	}))
+	// Every export rolls the points exported before it.
+	now := time.Now()
+	metrics.ExportData(batch(base.Add(-150*time.Minute), 10))
+	metrics.ExportData(batch(base.Add(-145*time.Minute), 20))
+	metrics.ExportData(batch(base.Add(-40*time.Minute), 30))
+	metrics.ExportData(batch(base.Add(-35*time.Minute), 50))
+	metrics.ExportData(batch(now, 70))
+
+	values := metrics.GetMetric("m1", []string{key}, base.Add(-3*time.Hour), now)[key]
+	assert.Equal(t, 3, len(values))
+	// The oldest points were rolled into the second tier.
+	assert.Equal(t, base.Add(-150*time.Minute), values[0].Timestamp)
+	assert.Equal(t, int64(15), values[0].IntValue)
+	assert.Equal(t, base.Add(-40*time.Minute), values[1].Timestamp)
+	assert.Equal(t, int64(40), values[1].IntValue)
+	assert.Equal(t, now, values[2].Timestamp)
+	assert.Equal(t, int64(70), values[2].IntValue)
+
+	values = metrics.GetMetric("m1", []string{key}, base.Add(-time.Hour), now)[key]
+	assert.Equal(t, 2, len(values))
+
+	// Each downsampled bucket is aggregated as one value.
+	aggregates, err := metrics.Aggregate("m1", []string{key}, base.Add(-3*time.Hour), now, AggSum)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, aggregates.Count)
+	assert.Equal(t, float64(125), aggregates.Combined)
+
+	// Only the points kept at full resolution are counted in the coverage.
+	assert.Equal(t, Coverage{Expected: 1, Stored: 1},
+		metrics.GetCoverage("m1", nil, []string{key}, base.Add(-3*time.Hour), now)[key])
+
+	metrics.EvictMetricSets([]string{key})
+	assert.Empty(t, metrics.GetMetric("m1", []string{key}, base.Add(-3*time.Hour), now)[key])
+}
+
+func TestGetLatestMetric(t *testing.T) {
+	now := time.Now()
+	key := core.PodKey("ns1", "pod1")
+	otherKey := core.PodKey("ns1", "other")
+
+	batch1, batch2, batch3 := makeBatches(now, key, otherKey)
+	// otherKey is missing from the latest batch.
+	batch4 := core.DataBatch{
+		Timestamp: now,
+		MetricSets: map[string]*core.MetricSet{
+			key: {
+				ScrapeTime: now.Add(-5 * time.Second),
+				MetricValues: map[string]core.MetricValue{
+					"m1": {ValueType: core.ValueInt64, MetricType: core.MetricGauge, IntValue: 10},
+					"m2": {ValueType: core.ValueInt64, MetricType: core.MetricGauge, IntValue: 111},
+				},
+			},
+		},
+	}
+
+	metrics := NewMetricSink(45*time.Second, 120*time.Second, []string{"m1"})
+	metrics.ExportData(&batch1)
+	metrics.ExportData(&batch2)
+	metrics.ExportData(&batch3)
+	metrics.ExportData(&batch4)
+
+	// m1 is read from the long store, m2 from the short store.
+	result := metrics.GetLatestMetric("m1", []string{key, otherKey, "missing"})
+	assert.Equal(t, 2, len(result))
+	assert.Equal(t, int64(10), result[key].IntValue)
+	assert.Equal(t, now.Add(-5*time.Second), result[key].Timestamp)
+	assert.Equal(t, int64(123), result[otherKey].IntValue)
+	assert.Equal(t, batch3.Timestamp, result[otherKey].Timestamp)
+
+	result = metrics.GetLatestMetric("m2", []string{key, otherKey})
+	assert.Equal(t, 1, len(result))
+	assert.Equal(t, int64(111), result[key].IntValue)
+	// Both stores give the points the time their metric set was sampled.
+	assert.Equal(t, now.Add(-5*time.Second), result[key].Timestamp)
+
+	// Duplicate keys are looked up once.
+	result = metrics.GetLatestMetric("m1", []string{key, key, otherKey})
+	assert.Equal(t, 2, len(result))
+
+	// Values older than the window before the latest batch are not returned.
+	metrics.SetMetricResolution(5 * time.Second)
+	result = metrics.GetLatestMetric("m1", []string{key, otherKey})
+	assert.Equal(t, 1, len(result))
+	assert.Equal(t, int64(10), result[key].IntValue)
+}
+
+func TestAggregate(t *testing.T) {
+	now := time.Now()
+	key := core.PodKey("ns1", "pod1")
+	otherKey := core.PodKey("ns1", "other")
+
+	batch1, batch2, batch3 := makeBatches(now, key, otherKey)
+
+	metrics := NewMetricSink(45*time.Second, 120*time.Second, []string{"m1"})
+	metrics.ExportData(&batch1)
+	metrics.ExportData(&batch2)
+	metrics.ExportData(&batch3)
+
+	keys := []string{key, otherKey}
+	start := now.Add(-120 * time.Second)
+	for fn, expected := range map[AggFunc]Aggregates{
+		AggSum: {PerKey: map[string]float64{key: 60, otherKey: 123}, Combined: 183, Count: 3},
+		AggAvg: {PerKey: map[string]float64{key: 30, otherKey: 123}, Combined: 61, Count: 3},
+		AggMax: {PerKey: map[string]float64{key: 40, otherKey: 123}, Combined: 123, Count: 3},
+		AggMin: {PerKey: map[string]float64{key: 20, otherKey: 123}, Combined: 20, Count: 3},
+		AggP95: {PerKey: map[string]float64{key: 40, otherKey: 123}, Combined: 123, Count: 3},
+	} {
+		result, err := metrics.Aggregate("m1", keys, start, now, fn)
+		assert.NoError(t, err)
+		assert.Equal(t, expected, result, string(fn))
+	}
+
+	// m2 is only in the short store, which only keeps batch3.
+	result, err := metrics.Aggregate("m2", keys, start, now, AggMax)
+	assert.NoError(t, err)
+	assert.Equal(t, Aggregates{PerKey: map[string]float64{key: 222}, Combined: 222, Count: 1}, result)
+
+	result, err = metrics.Aggregate("m2", keys, now.Add(-10*time.Second), now, AggSum)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, result.Count)
+	assert.Empty(t, result.PerKey)
+
+	_, err = metrics.Aggregate("m1", keys, start, now, AggFunc("median"))
+	assert.Error(t, err)
+}
+
+func TestEvictMetricSets(t *testing.T) {
+	now := time.Now()
+	key := core.PodKey("ns1", "pod1")
+	otherKey := core.PodKey("ns1", "other")
+
+	batch1, batch2, batch3 := makeBatches(now, key, otherKey)
+	batch3.MetricSets[core.PodContainerKey("ns1", "pod1", "c1")] = &core.MetricSet{
+		Labels: map[string]string{
+			core.LabelMetricSetType.Key: core.MetricSetTypePodContainer,
+		},
+	}
+
+	metrics := NewMetricSink(45*time.Second, 120*time.Second, []string{"m1"})
+	metrics.ExportData(&batch1)
+	metrics.ExportData(&batch2)
+	metrics.ExportData(&batch3)
+	metrics.SetTerminated(key, true)
+	assert.True(t, metrics.IsTerminated(core.PodContainerKey("ns1", "pod1", "c1")))
+	assert.False(t, metrics.IsTerminated(otherKey))
+	metrics.EvictMetricSets([]string{key})
+	assert.False(t, metrics.IsTerminated(key))
+
+	assert.Empty(t, metrics.GetMetric("m1", []string{key}, now.Add(-120*time.Second), now))
+	assert.Empty(t, metrics.GetMetric("m2", []string{key}, now.Add(-120*time.Second), now))
+	assert.Equal(t, []string{otherKey}, metrics.GetMetricSetKeys())
+	assert.Equal(t, 1, len(metrics.GetMetric("m1", []string{otherKey}, now.Add(-120*time.Second), now)[otherKey]))
+	// Exported batches may be used by other sinks, so they are not modified.
+	assert.Len(t, batch3.MetricSets, 3)
+}
+
+func TestMaxLongStorePoints(t *testing.T) {
+	now := time.Now()
+	key := core.PodKey("ns1", "pod1")
+	otherKey := core.PodKey("ns1", "other")
+
+	batch1, batch2, batch3 := makeBatches(now, key, otherKey)
+	metrics := NewMetricSink(45*time.Second, 300*time.Second, []string{"m1"})
+	metrics.SetMaxLongStorePoints(3)
+	metrics.ExportData(&batch1)
+	metrics.ExportData(&batch2)
+	assert.Len(t, metrics.GetMetric("m1", []string{key}, now.Add(-300*time.Second), now)[key], 2)
+
+	// The long store would hold 4 points, so the never queried otherKey is evicted.
+	metrics.ExportData(&batch3)
+	assert.Len(t, metrics.GetMetric("m1", []string{key}, now.Add(-300*time.Second), now)[key], 3)
+	assert.Empty(t, metrics.GetMetric("m1", []string{otherKey}, now.Add(-300*time.Second), now))
+
+	assertLongStorePointCounts(t, metrics)
+
+	// Lowering the limit evicts the least recently queried metric set.
+	metrics.SetMaxLongStorePoints(2)
+	assert.Empty(t, metrics.GetMetric("m1", []string{key}, now.Add(-300*time.Second), now))
+	// The short store is not limited.
+	assert.Len(t, metrics.GetMetric("m2", []string{key}, now.Add(-45*time.Second), now)[key], 1)
+	assertLongStorePointCounts(t, metrics)
+
+	metrics.SetMaxLongStorePoints(10)
+	metrics.ExportData(&batch3)
+	assertLongStorePointCounts(t, metrics)
+	metrics.EvictMetricSets([]string{key})
+	assertLongStorePointCounts(t, metrics)
+}
+
+// assertLongStorePointCounts checks the running long store point counts against the stored points.
+func assertLongStorePointCounts(t *testing.T, metrics *MetricSink) {
+	metrics.lock.Lock()
+	defer metrics.lock.Unlock()
+	byKey := make(map[string]int)
+	total := 0
+	for _, store := range metrics.longStore {
+		for _, values := range store.store {
+			for key := range values {
+				byKey[key]++
+				total++
+			}
+		}
+	}
+	assert.Equal(t, total, metrics.longStorePoints)
+	assert.Equal(t, byKey, metrics.longStorePointsByKey)
+}
+
+type fakeHistoricalSource struct {
+	core.HistoricalSource
+	values       map[core.HistoricalKey][]core.TimestampedMetricValue
+	err          error
+	queriedKeys  []core.HistoricalKey
+	queriedLabel map[string]string
+}
+
+func (this *fakeHistoricalSource) GetMetric(metricName string, metricKeys []core.HistoricalKey, start, end time.Time) (map[core.HistoricalKey][]core.TimestampedMetricValue, error) {
+	this.queriedKeys = append(this.queriedKeys, metricKeys...)
+	return this.values, this.err
+}
+
+func (this *fakeHistoricalSource) GetLabeledMetric(metricName string, labels map[string]string, metricKeys []core.HistoricalKey, start, end time.Time) (map[core.HistoricalKey][]core.TimestampedMetricValue, error) {
+	this.queriedLabel = labels
+	return this.GetMetric(metricName, metricKeys, start, end)
+}
+
+func TestHistoricalModelStore(t *testing.T) {
+	now := time.Now()
+	key := core.PodKey("ns1", "pod1")
+	otherKey := core.PodKey("ns1", "other")
+	historicalKey := core.HistoricalKey{ObjectType: core.MetricSetTypePod, NamespaceName: "ns1", PodName: "pod1"}
+
+	batch1, batch2, batch3 := makeBatches(now, key, otherKey)
+	metrics := NewMetricSink(45*time.Second, 120*time.Second, []string{"m1"})
+	metrics.ExportData(&batch1)
+	metrics.ExportData(&batch2)
+	metrics.ExportData(&batch3)
+	historical := &fakeHistoricalSource{
+		values: map[core.HistoricalKey][]core.TimestampedMetricValue{
+			historicalKey: {
+				{Timestamp: now.Add(-10 * time.Minute), MetricValue: core.MetricValue{ValueType: core.ValueInt64, IntValue: 1}},
+				{Timestamp: now.Add(-9 * time.Minute), MetricValue: core.MetricValue{ValueType: core.ValueInt64, IntValue: 2}},
+			},
+		},
+	}
+	store := NewHistoricalModelStore(metrics, historical)
+
+	// Ranges kept by the metric sink, or without a start, are served from memory.
+	assert.Len(t, store.GetMetricWithStep("m1", []string{key}, now.Add(-100*time.Second), now, 0)[key], 2)
+	assert.Len(t, store.GetMetricWithStep("m1", []string{key}, time.Time{}, now, 0)[key], 2)
+	assert.Len(t, store.GetLabeledMetricWithStep("somelblmetric", map[string]string{"lbl1": "val1.1", "lbl2": "val2.1"}, []string{key}, now.Add(-30*time.Second), now, 0)[key], 1)
+	assert.Empty(t, historical.queriedKeys)
+
+	// Older ranges are served from the historical source.
+	result := store.GetMetricWithStep("m1", []string{key}, now.Add(-time.Hour), now, 0)
+	assert.Equal(t, historical.values[historicalKey], result[key])
+	assert.Equal(t, []core.HistoricalKey{historicalKey}, historical.queriedKeys)
+	// m2 is only kept in the short store.
+	assert.Len(t, store.GetMetricWithStep("m2", []string{key}, now.Add(-100*time.Second), now, 0)[key], 2)
+	assert.Len(t, store.GetLabeledMetricWithStep("somelblmetric", map[string]string{"lbl1": "val1.1"}, []string{key}, now.Add(-100*time.Second), now, 0)[key], 2)
+	assert.Equal(t, map[string]string{"lbl1": "val1.1"}, historical.queriedLabel)
+
+	// Errors of the historical source fall back to the metric sink.
+	historical.err = fmt.Errorf("unavailable")
+	assert.Len(t, store.GetMetricWithStep("m1", []string{key}, now.Add(-time.Hour), now, 0)[key], 2)
+	historical.err = nil
+
+	// Ranges kept by the downsampling tiers are served from memory.
+	assert.NoError(t, metrics.SetDownsamplingTiers([]DownsamplingTier{{Resolution: 10 * time.Minute, Retention: 2 * time.Hour}}))
+	historical.queriedKeys = nil
+	store.GetMetricWithStep("m1", []string{key}, now.Add(-time.Hour), now, 0)
+	assert.Empty(t, historical.queriedKeys)
+	store.GetMetricWithStep("m1", []string{key}, now.Add(-3*time.Hour), now, 0)
+	assert.Equal(t, []core.HistoricalKey{historicalKey}, historical.queriedKeys)
+
+	// Everything else is served by the metric sink.
+	assert.Equal(t, []string{otherKey, key}, store.GetMetricSetKeys())
+}
+
+func TestDownsample(t *testing.T) {
+	base := time.Unix(1500000000, 0).Truncate(5 * time.Minute)
+	values := []core.TimestampedMetricValue{}
+	for i := 0; i < 10; i++ {
+		values = append(values, core.TimestampedMetricValue{
+			Timestamp:   base.Add(time.Duration(i) * time.Minute),
+			MetricValue: core.MetricValue{ValueType: core.ValueInt64, IntValue: int64(i)},
+		})
+	}
+
+	result := downsample(map[string][]core.TimestampedMetricValue{"key": values}, 5*time.Minute)
+	assert.Equal(t, 2, len(result["key"]))
+	assert.Equal(t, int64(4), result["key"][0].IntValue)
+	assert.Equal(t, int64(9), result["key"][1].IntValue)
+
+	result = downsample(map[string][]core.TimestampedMetricValue{"key": values}, 0)
+	assert.Equal(t, 10, len(result["key"]))
+}
+
 func TestGetLabeledMetrics(t *testing.T) {
 	now := time.Now().UTC()
 	key := core.PodKey("ns1", "pod1")
@@ -255,10 +642,147 @@ func TestGetNames(t *testing.T) {
 	metrics := NewMetricSink(45*time.Second, 120*time.Second, []string{"m1"})
 	metrics.ExportData(&batch)
 
-	assert.Contains(t, metrics.GetPods(), "ns1/pod1")
-	assert.Contains(t, metrics.GetPods(), "ns2/pod2")
+	assert.Equal(t, []string{"ns1/pod1", "ns2/pod2"}, metrics.GetPods())
 	assert.Contains(t, metrics.GetPodsFromNamespace("ns1"), "pod1")
 	assert.NotContains(t, metrics.GetPodsFromNamespace("ns1"), "pod2")
 	assert.Contains(t, metrics.GetMetricSetKeys(), key)
 	assert.Contains(t, metrics.GetMetricSetKeys(), otherKey)
 }
+
+func TestListEntities(t *testing.T) {
+	now := time.Now()
+	pod := core.PodKey("ns1", "pod1")
+	container1 := core.PodContainerKey("ns1", "pod1", "c1")
+	container2 := core.PodContainerKey("ns1", "pod1", "c2")
+	otherContainer := core.PodContainerKey("ns1", "pod10", "c1")
+
+	batch := core.DataBatch{
+		Timestamp: now,
+		MetricSets: map[string]*core.MetricSet{
+			pod: {
+				Labels: map[string]string{core.LabelMetricSetType.Key: core.MetricSetTypePod},
+			},
+			container2: {
+				Labels: map[string]string{core.LabelMetricSetType.Key: core.MetricSetTypePodContainer},
+			},
+			container1: {
+				Labels: map[string]string{core.LabelMetricSetType.Key: core.MetricSetTypePodContainer},
+			},
+			otherContainer: {
+				Labels: map[string]string{core.LabelMetricSetType.Key: core.MetricSetTypePodContainer},
+			},
+		},
+	}
+
+	metrics := NewMetricSink(45*time.Second, 120*time.Second, []string{"m1"})
+	assert.Empty(t, metrics.ListEntities(core.MetricSetTypePod, ""))
+	metrics.ExportData(&batch)
+
+	assert.Equal(t, []string{pod}, metrics.ListEntities(core.MetricSetTypePod, ""))
+	assert.Equal(t, []string{container1, container2, otherContainer}, metrics.ListEntities(core.MetricSetTypePodContainer, ""))
+	assert.Equal(t, []string{container1, container2}, metrics.ListEntities(core.MetricSetTypePodContainer, pod))
+	assert.Empty(t, metrics.ListEntities(core.MetricSetTypeNode, ""))
+}
+
+// newBenchmarkMetricSink returns a metric sink with a full long store: a point of m1 and m2 every
+// minute for the last 15 minutes, for each of the given number of pods.
+func newBenchmarkMetricSink(pods int) (*MetricSink, []string) {
+	metrics := NewMetricSink(20*time.Minute, 20*time.Minute, []string{"m1"})
+	keys := make([]string, 0, pods)
+	for i := 0; i < pods; i++ {
+		keys = append(keys, core.PodKey("ns1", fmt.Sprintf("pod%d", i)))
+	}
+	now := time.Now()
+	for minute := 15; minute > 0; minute-- {
+		batch := &core.DataBatch{
+			Timestamp:  now.Add(-time.Duration(minute) * time.Minute),
+			MetricSets: make(map[string]*core.MetricSet, pods),
+		}
+		for i, key := range keys {
+			batch.MetricSets[key] = &core.MetricSet{
+				Labels: map[string]string{core.LabelMetricSetType.Key: core.MetricSetTypePod},
+				MetricValues: map[string]core.MetricValue{
+					"m1": {ValueType: core.ValueInt64, MetricType: core.MetricGauge, IntValue: int64(i * minute)},
+					"m2": {ValueType: core.ValueInt64, MetricType: core.MetricGauge, IntValue: int64(i + minute)},
+				},
+			}
+		}
+		metrics.ExportData(batch)
+	}
+	return metrics, keys
+}
+
+func BenchmarkGetLongStoreMetric(b *testing.B) {
+	metrics, keys := newBenchmarkMetricSink(200)
+	end := time.Now()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		metrics.GetMetric("m1", keys, end.Add(-time.Hour), end)
+	}
+}
+
+func BenchmarkGetShortStoreMetric(b *testing.B) {
+	metrics, keys := newBenchmarkMetricSink(200)
+	end := time.Now()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		metrics.GetMetric("m2", keys, end.Add(-time.Hour), end)
+	}
+}
+
+func TestMetricSinkWriteBuffering(t *testing.T) {
+	now := time.Now()
+	batch1, batch2, batch3 := makeBatches(now, "ns1/pod1", "ns1/pod2")
+	metrics := NewMetricSink(120*time.Second, 120*time.Second, []string{"m1"})
+	metrics.EnableWriteBuffering(time.Hour)
+
+	metrics.ExportData(&batch1)
+	metrics.ExportData(&batch2)
+	assert.Empty(t, metrics.GetShortStore())
+
+	metrics.applyBufferedBatches()
+	assert.Equal(t, []*core.DataBatch{&batch2}, metrics.GetShortStore())
+	assert.Equal(t, 1, len(metrics.GetMetric("m1", []string{"ns1/pod1"}, now.Add(-time.Hour), now)["ns1/pod1"]))
+
+	// Stopping applies the remaining batches before returning and disables buffering.
+	metrics.ExportData(&batch3)
+	metrics.Stop()
+	assert.Equal(t, []*core.DataBatch{&batch2, &batch3}, metrics.GetShortStore())
+
+	metrics.ExportData(&batch3)
+	assert.Equal(t, 3, len(metrics.GetShortStore()))
+}
+
+func TestClearCache(t *testing.T) {
+	now := time.Now()
+	batch1, batch2, batch3 := makeBatches(now, "ns1/pod1", "ns1/pod2")
+	metrics := NewMetricSink(10*time.Minute, 10*time.Minute, []string{"m1"})
+	metrics.ExportData(&batch1)
+	metrics.ExportData(&batch2)
+	metrics.SetTerminated("ns1/pod2", true)
+	metrics.EnableWriteBuffering(time.Hour)
+	defer metrics.Stop()
+	metrics.ExportData(&batch3)
+
+	metrics.ClearCache()
+	assert.Empty(t, metrics.GetShortStore())
+	assert.Nil(t, metrics.GetLatestDataBatch())
+	assert.Empty(t, metrics.GetMetric("m1", []string{"ns1/pod1"}, now.Add(-time.Hour), now))
+	assert.True(t, metrics.IsTerminated("ns1/pod2"))
+
+	// The batches buffered before clearing are dropped too.
+	metrics.applyBufferedBatches()
+	assert.Empty(t, metrics.GetShortStore())
+	metrics.ExportData(&batch3)
+	metrics.applyBufferedBatches()
+	assert.Equal(t, []*core.DataBatch{&batch3}, metrics.GetShortStore())
+
+	// So are the batches taken from the buffer, but not applied yet, when clearing.
+	metrics.ExportData(&batch2)
+	batches, generation := metrics.takeBufferedBatches()
+	metrics.ClearCache()
+	metrics.addBatchesIfNotCleared(batches, generation)
+	assert.Empty(t, metrics.GetShortStore())
+}
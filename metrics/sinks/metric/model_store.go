@@ -0,0 +1,135 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metric
+
+import (
+	"time"
+
+	"github.com/golang/glog"
+	"k8s.io/heapster/metrics/core"
+)
+
+const (
+	// Model queries are served from the in-memory MetricSink.
+	ModelBackendMemory = "memory"
+	// Model queries reaching before the in-memory stores are served from the historical source.
+	ModelBackendHistorical = "historical"
+)
+
+// ModelStore is the storage queried by the model API. MetricSink is the in-memory implementation.
+type ModelStore interface {
+	GetMetricWithStep(metricName string, keys []string, start, end time.Time, step time.Duration) map[string][]core.TimestampedMetricValue
+	GetLabeledMetricWithStep(metricName string, labels map[string]string, keys []string, start, end time.Time, step time.Duration) map[string][]core.TimestampedMetricValue
+	GetMetricNames(key string) []string
+	FindMetricValue(metricName string) (core.MetricValue, bool)
+	GetMetricSetKeys() []string
+	GetNodes() []string
+	GetNamespaces() []string
+	GetPodsFromNamespace(namespace string) []string
+	GetSystemContainersFromNode(node string) []string
+	ListEntities(entityType string, parentKey string) []string
+	IsTerminated(key string) bool
+	GetShortStore() []*core.DataBatch
+	GetLatestDataBatch() *core.DataBatch
+	GetLatestMetric(metricName string, keys []string) map[string]core.TimestampedMetricValue
+	GetCoverage(metricName string, labels map[string]string, keys []string, start, end time.Time) map[string]Coverage
+}
+
+// HistoricalModelStore serves model queries whose start is before the data kept by the MetricSink
+// from a historical source, e.g. InfluxDB, so that they survive restarts and can span longer windows.
+// Other queries, including the lists of entities, are served by the MetricSink.
+type HistoricalModelStore struct {
+	*MetricSink
+	historical core.HistoricalSource
+}
+
+func (this *HistoricalModelStore) GetMetricWithStep(metricName string, keys []string, start, end time.Time, step time.Duration) map[string][]core.TimestampedMetricValue {
+	if !this.useHistorical(metricName, start, false) {
+		return this.MetricSink.GetMetricWithStep(metricName, keys, start, end, step)
+	}
+	result, err := this.getHistorical(keys, func(historicalKeys []core.HistoricalKey) (map[core.HistoricalKey][]core.TimestampedMetricValue, error) {
+		return this.historical.GetMetric(metricName, historicalKeys, start, end)
+	})
+	if err != nil {
+		glog.Errorf("Unable to get %s from the historical source, using the metric sink: %v", metricName, err)
+		return this.MetricSink.GetMetricWithStep(metricName, keys, start, end, step)
+	}
+	return downsample(result, step)
+}
+
+func (this *HistoricalModelStore) GetLabeledMetricWithStep(metricName string, labels map[string]string, keys []string, start, end time.Time, step time.Duration) map[string][]core.TimestampedMetricValue {
+	if !this.useHistorical(metricName, start, true) {
+		return this.MetricSink.GetLabeledMetricWithStep(metricName, labels, keys, start, end, step)
+	}
+	result, err := this.getHistorical(keys, func(historicalKeys []core.HistoricalKey) (map[core.HistoricalKey][]core.TimestampedMetricValue, error) {
+		return this.historical.GetLabeledMetric(metricName, labels, historicalKeys, start, end)
+	})
+	if err != nil {
+		glog.Errorf("Unable to get %s from the historical source, using the metric sink: %v", metricName, err)
+		return this.MetricSink.GetLabeledMetricWithStep(metricName, labels, keys, start, end, step)
+	}
+	return downsample(result, step)
+}
+
+// useHistorical returns whether the metric sink doesn't keep the metric since start. Queries without
+// a start are served by the metric sink, so that they don't fetch the whole history.
+func (this *HistoricalModelStore) useHistorical(metricName string, start time.Time, labeled bool) bool {
+	if start.IsZero() {
+		return false
+	}
+	retention := this.ShortStoreDuration()
+	if !labeled && this.isLongStoreMetric(metricName) {
+		retention = this.LongStoreRetention()
+	}
+	return start.Before(time.Now().Add(-retention))
+}
+
+// getHistorical queries the historical source for the given metric set keys, with one query per
+// object type, and returns the result by metric set key.
+func (this *HistoricalModelStore) getHistorical(keys []string,
+	query func([]core.HistoricalKey) (map[core.HistoricalKey][]core.TimestampedMetricValue, error)) (map[string][]core.TimestampedMetricValue, error) {
+
+	keysByType := make(map[string][]core.HistoricalKey)
+	metricSetKeys := make(map[core.HistoricalKey]string, len(keys))
+	for _, key := range keys {
+		historicalKey, err := core.HistoricalKeyFromMetricSetKey(key)
+		if err != nil {
+			return nil, err
+		}
+		keysByType[historicalKey.ObjectType] = append(keysByType[historicalKey.ObjectType], historicalKey)
+		metricSetKeys[historicalKey] = key
+	}
+
+	result := make(map[string][]core.TimestampedMetricValue, len(keys))
+	for _, historicalKeys := range keysByType {
+		values, err := query(historicalKeys)
+		if err != nil {
+			return nil, err
+		}
+		for historicalKey, keyValues := range values {
+			if key, found := metricSetKeys[historicalKey]; found && len(keyValues) > 0 {
+				result[key] = keyValues
+			}
+		}
+	}
+	return result, nil
+}
+
+func NewHistoricalModelStore(metricSink *MetricSink, historical core.HistoricalSource) *HistoricalModelStore {
+	return &HistoricalModelStore{
+		MetricSink: metricSink,
+		historical: historical,
+	}
+}
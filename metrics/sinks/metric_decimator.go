@@ -0,0 +1,226 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sinks
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"k8s.io/heapster/metrics/core"
+)
+
+// Describes how often values of a single metric are passed on.
+type DecimationPolicy struct {
+	// Values are passed on at most once per Interval.
+	Interval time.Duration
+	// If set, values that differ from the last passed value are passed on immediately,
+	// and Interval only bounds how long an unchanged value is suppressed.
+	OnlyIfChanged bool
+}
+
+type emittedValue struct {
+	timestamp time.Time
+	value     core.MetricValue
+}
+
+// MetricDecimator reduces the number of points sent to a sink by dropping values of the
+// configured metrics from intermediate batches. The decision is taken per metric set
+// and metric (and labels for labeled metrics), based on the batch timestamps.
+// Metric sets are never modified in place, as the batch is shared by all the sinks.
+type MetricDecimator struct {
+	policies map[string]DecimationPolicy
+	// Last values passed on, by metric set key and metric id.
+	emitted map[string]map[string]emittedValue
+}
+
+// Decimate returns a copy of the batch without the values that are not due yet.
+func (this *MetricDecimator) Decimate(batch *core.DataBatch) *core.DataBatch {
+	result := &core.DataBatch{
+		Timestamp:  batch.Timestamp,
+		MetricSets: make(map[string]*core.MetricSet, len(batch.MetricSets)),
+	}
+	emitted := make(map[string]map[string]emittedValue, len(batch.MetricSets))
+
+	for key, ms := range batch.MetricSets {
+		lastEmitted := this.emitted[key]
+		newEmitted := make(map[string]emittedValue)
+		dropped := false
+
+		metricValues := make(map[string]core.MetricValue, len(ms.MetricValues))
+		for name, value := range ms.MetricValues {
+			if this.shouldEmit(name, name, value, batch.Timestamp, lastEmitted, newEmitted) {
+				metricValues[name] = value
+			} else {
+				dropped = true
+			}
+		}
+
+		labeledMetrics := make([]core.LabeledMetric, 0, len(ms.LabeledMetrics))
+		for _, metric := range ms.LabeledMetrics {
+			id := core.LabeledMetricKey(&metric)
+			if this.shouldEmit(metric.Name, id, metric.MetricValue, batch.Timestamp, lastEmitted, newEmitted) {
+				labeledMetrics = append(labeledMetrics, metric)
+			} else {
+				dropped = true
+			}
+		}
+
+		if len(newEmitted) > 0 {
+			emitted[key] = newEmitted
+		}
+		if !dropped {
+			result.MetricSets[key] = ms
+			continue
+		}
+		copied := *ms
+		copied.MetricValues = metricValues
+		copied.LabeledMetrics = labeledMetrics
+		result.MetricSets[key] = &copied
+	}
+
+	// Forget metric sets that are not present anymore.
+	this.emitted = emitted
+	return result
+}
+
+// shouldEmit decides whether the value should be passed on and records it in newEmitted.
+func (this *MetricDecimator) shouldEmit(name, id string, value core.MetricValue, timestamp time.Time,
+	lastEmitted, newEmitted map[string]emittedValue) bool {
+
+	policy, found := this.policies[name]
+	if !found {
+		return true
+	}
+	last, found := lastEmitted[id]
+	if !found || !timestamp.Before(last.timestamp.Add(policy.Interval)) ||
+		(policy.OnlyIfChanged && last.value != value) {
+		newEmitted[id] = emittedValue{timestamp: timestamp, value: value}
+		return true
+	}
+	newEmitted[id] = last
+	return false
+}
+
+// parseDecimationSpec splits a name=interval pair.
+func parseDecimationSpec(spec string) (string, time.Duration, error) {
+	// Split on the last '=', so the interval is always the suffix.
+	pos := strings.LastIndex(spec, "=")
+	if pos <= 0 || pos == len(spec)-1 {
+		return "", 0, fmt.Errorf("invalid decimation spec %q, expected metric=interval", spec)
+	}
+	interval, err := time.ParseDuration(spec[pos+1:])
+	if err != nil {
+		return "", 0, fmt.Errorf("invalid interval in decimation spec %q: %v", spec, err)
+	}
+	if interval <= 0 {
+		return "", 0, fmt.Errorf("interval in decimation spec %q should be positive", spec)
+	}
+	return spec[:pos], interval, nil
+}
+
+// ParseDecimationPolicies parses a list of metric=interval pairs, e.g. "memory/limit=5m".
+func ParseDecimationPolicies(specs []string, onlyIfChanged bool) (map[string]DecimationPolicy, error) {
+	policies := make(map[string]DecimationPolicy, len(specs))
+	for _, spec := range specs {
+		name, interval, err := parseDecimationSpec(spec)
+		if err != nil {
+			return nil, err
+		}
+		policies[name] = DecimationPolicy{
+			Interval:      interval,
+			OnlyIfChanged: onlyIfChanged,
+		}
+	}
+	return policies, nil
+}
+
+// ParseFamilyDecimationPolicies parses a list of family=interval pairs, e.g. "network=5m", and
+// returns a policy for every metric of the given metric families. Metrics taken from the pod specs,
+// e.g. cpu/limit, are left out, as their values only change with the specs and they are marked to be
+// exported on change instead.
+func ParseFamilyDecimationPolicies(specs []string) (map[string]DecimationPolicy, error) {
+	policies := make(map[string]DecimationPolicy)
+	for _, spec := range specs {
+		family, interval, err := parseDecimationSpec(spec)
+		if err != nil {
+			return nil, err
+		}
+		metrics, found := core.MetricFamilies[core.MetricFamily(family)]
+		if !found {
+			return nil, fmt.Errorf("unknown metric family %q in decimation spec %q", family, spec)
+		}
+		for _, metric := range metrics {
+			if metric.OnlyExportIfChanged {
+				continue
+			}
+			policies[metric.Name] = DecimationPolicy{Interval: interval}
+		}
+	}
+	return policies, nil
+}
+
+// ChangeBasedPolicies returns policies for all the given metrics marked with OnlyExportIfChanged,
+// so their unchanged values are re-exported once per interval.
+func ChangeBasedPolicies(metrics []core.Metric, interval time.Duration) map[string]DecimationPolicy {
+	policies := make(map[string]DecimationPolicy)
+	for _, metric := range metrics {
+		if metric.OnlyExportIfChanged {
+			policies[metric.Name] = DecimationPolicy{
+				Interval:      interval,
+				OnlyIfChanged: true,
+			}
+		}
+	}
+	return policies
+}
+
+func NewMetricDecimator(policies map[string]DecimationPolicy) *MetricDecimator {
+	return &MetricDecimator{
+		policies: policies,
+		emitted:  make(map[string]map[string]emittedValue),
+	}
+}
+
+// decimatingSink passes a decimated copy of every batch to the wrapped sink.
+type decimatingSink struct {
+	sink      core.DataSink
+	decimator *MetricDecimator
+}
+
+func (this *decimatingSink) Name() string {
+	return this.sink.Name()
+}
+
+func (this *decimatingSink) ExportData(batch *core.DataBatch) {
+	this.sink.ExportData(this.decimator.Decimate(batch))
+}
+
+func (this *decimatingSink) Stop() {
+	this.sink.Stop()
+}
+
+// NewDecimatingSink wraps the sink so that it receives the metrics with a policy at most as often
+// as the policy allows. The state is kept per wrapped sink, so sinks exporting different metrics
+// each get the values they are due. It returns the sink unchanged if there are no policies.
+func NewDecimatingSink(sink core.DataSink, policies map[string]DecimationPolicy) core.DataSink {
+	if len(policies) == 0 {
+		return sink
+	}
+	return &decimatingSink{
+		sink:      sink,
+		decimator: NewMetricDecimator(policies),
+	}
+}
@@ -0,0 +1,173 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sinks
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"k8s.io/heapster/metrics/core"
+)
+
+func decimatorBatch(timestamp time.Time, limit int64, usage int64) *core.DataBatch {
+	return &core.DataBatch{
+		Timestamp: timestamp,
+		MetricSets: map[string]*core.MetricSet{
+			core.PodKey("ns1", "pod1"): {
+				MetricValues: map[string]core.MetricValue{
+					core.MetricMemoryLimit.Name: {ValueType: core.ValueInt64, MetricType: core.MetricGauge, IntValue: limit},
+					core.MetricMemoryUsage.Name: {ValueType: core.ValueInt64, MetricType: core.MetricGauge, IntValue: usage},
+				},
+				LabeledMetrics: []core.LabeledMetric{
+					{
+						Name:        core.MetricFilesystemLimit.Name,
+						Labels:      map[string]string{core.LabelResourceID.Key: "/"},
+						MetricValue: core.MetricValue{ValueType: core.ValueInt64, MetricType: core.MetricGauge, IntValue: limit},
+					},
+				},
+			},
+		},
+	}
+}
+
+func TestMetricDecimatorInterval(t *testing.T) {
+	decimator := NewMetricDecimator(map[string]DecimationPolicy{
+		core.MetricMemoryLimit.Name:     {Interval: 3 * time.Minute},
+		core.MetricFilesystemLimit.Name: {Interval: 3 * time.Minute},
+	})
+	key := core.PodKey("ns1", "pod1")
+	now := time.Now()
+
+	expectedEmitted := []bool{true, false, false, true, false}
+	for i, emitted := range expectedEmitted {
+		batch := decimatorBatch(now.Add(time.Duration(i)*time.Minute), int64(i), int64(i))
+		result := decimator.Decimate(batch)
+
+		ms := result.MetricSets[key]
+		_, found := ms.MetricValues[core.MetricMemoryLimit.Name]
+		assert.Equal(t, emitted, found, "batch %d", i)
+		assert.Equal(t, emitted, len(ms.LabeledMetrics) == 1, "batch %d", i)
+		_, found = ms.MetricValues[core.MetricMemoryUsage.Name]
+		assert.True(t, found, "batch %d", i)
+
+		// The input batch must not be modified.
+		assert.Equal(t, 2, len(batch.MetricSets[key].MetricValues))
+		assert.Equal(t, 1, len(batch.MetricSets[key].LabeledMetrics))
+	}
+}
+
+func TestMetricDecimatorOnlyIfChanged(t *testing.T) {
+	decimator := NewMetricDecimator(map[string]DecimationPolicy{
+		core.MetricMemoryLimit.Name: {Interval: 3 * time.Minute, OnlyIfChanged: true},
+	})
+	key := core.PodKey("ns1", "pod1")
+	now := time.Now()
+
+	limits := []int64{1, 1, 2, 2, 2, 2}
+	expectedEmitted := []bool{true, false, true, false, false, true}
+	for i, limit := range limits {
+		result := decimator.Decimate(decimatorBatch(now.Add(time.Duration(i)*time.Minute), limit, 0))
+		_, found := result.MetricSets[key].MetricValues[core.MetricMemoryLimit.Name]
+		assert.Equal(t, expectedEmitted[i], found, "batch %d", i)
+	}
+}
+
+func TestMetricDecimatorNewMetricSet(t *testing.T) {
+	decimator := NewMetricDecimator(map[string]DecimationPolicy{
+		core.MetricMemoryLimit.Name: {Interval: time.Hour},
+	})
+	now := time.Now()
+
+	decimator.Decimate(decimatorBatch(now, 1, 1))
+	decimator.Decimate(&core.DataBatch{Timestamp: now.Add(time.Minute), MetricSets: map[string]*core.MetricSet{}})
+
+	// The pod disappeared in between, so its values are passed on again.
+	result := decimator.Decimate(decimatorBatch(now.Add(2*time.Minute), 1, 1))
+	_, found := result.MetricSets[core.PodKey("ns1", "pod1")].MetricValues[core.MetricMemoryLimit.Name]
+	assert.True(t, found)
+}
+
+func TestParseDecimationPolicies(t *testing.T) {
+	policies, err := ParseDecimationPolicies([]string{"memory/limit=5m", "custom/a=b=30s"}, true)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]DecimationPolicy{
+		"memory/limit": {Interval: 5 * time.Minute, OnlyIfChanged: true},
+		"custom/a=b":   {Interval: 30 * time.Second, OnlyIfChanged: true},
+	}, policies)
+
+	for _, spec := range []string{"memory/limit", "=5m", "memory/limit=", "memory/limit=abc", "memory/limit=-1m"} {
+		_, err := ParseDecimationPolicies([]string{spec}, false)
+		assert.Error(t, err, spec)
+	}
+}
+
+func TestParseFamilyDecimationPolicies(t *testing.T) {
+	policies, err := ParseFamilyDecimationPolicies([]string{"network=5m"})
+	assert.NoError(t, err)
+	assert.Equal(t, len(core.NetworkMetrics), len(policies))
+	assert.Equal(t, DecimationPolicy{Interval: 5 * time.Minute}, policies[core.MetricNetworkRx.Name])
+	_, found := policies[core.MetricCpuUsageRate.Name]
+	assert.False(t, found)
+
+	policies, err = ParseFamilyDecimationPolicies([]string{"cpu=5m"})
+	assert.NoError(t, err)
+	assert.Equal(t, DecimationPolicy{Interval: 5 * time.Minute}, policies[core.MetricCpuUsageRate.Name])
+	for _, metric := range []core.Metric{core.MetricCpuLimit, core.MetricCpuRequest} {
+		_, found := policies[metric.Name]
+		assert.False(t, found, metric.Name)
+	}
+
+	for _, spec := range []string{"network", "general=5m", "disk=1m", "network=-1m"} {
+		_, err := ParseFamilyDecimationPolicies([]string{spec})
+		assert.Error(t, err, spec)
+	}
+}
+
+func TestChangeBasedPolicies(t *testing.T) {
+	policies := ChangeBasedPolicies(core.AllMetrics, 10*time.Minute)
+	assert.Equal(t, DecimationPolicy{Interval: 10 * time.Minute, OnlyIfChanged: true}, policies[core.MetricMemoryLimit.Name])
+	assert.Equal(t, DecimationPolicy{Interval: 10 * time.Minute, OnlyIfChanged: true}, policies[core.MetricCpuRequest.Name])
+	_, found := policies[core.MetricMemoryUsage.Name]
+	assert.False(t, found)
+}
+
+func TestDecimatingSink(t *testing.T) {
+	policies := map[string]DecimationPolicy{
+		core.MetricMemoryLimit.Name: {Interval: time.Hour},
+	}
+	sink1 := &bufferingSink{}
+	sink2 := &bufferingSink{}
+	decimating1 := NewDecimatingSink(sink1, policies)
+	now := time.Now()
+	decimating1.ExportData(decimatorBatch(now, 1, 1))
+	// The state is per sink, so a sink added later still gets the first value.
+	decimating2 := NewDecimatingSink(sink2, policies)
+	batch := decimatorBatch(now.Add(time.Minute), 1, 1)
+	decimating1.ExportData(batch)
+	decimating2.ExportData(batch)
+
+	key := core.PodKey("ns1", "pod1")
+	assert.Equal(t, 2, len(sink1.pending))
+	_, found := sink1.pending[1].MetricSets[key].MetricValues[core.MetricMemoryLimit.Name]
+	assert.False(t, found)
+	assert.Equal(t, 1, len(sink2.pending))
+	_, found = sink2.pending[0].MetricSets[key].MetricValues[core.MetricMemoryLimit.Name]
+	assert.True(t, found)
+
+	sink := &bufferingSink{}
+	assert.Equal(t, sink, NewDecimatingSink(sink, nil))
+}
@@ -0,0 +1,137 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sinks
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+
+	"k8s.io/heapster/metrics/core"
+)
+
+// MetricNameFilter selects the metrics a sink receives by matching their names against allow
+// and deny regular expressions. A metric is kept if it matches one of the allow expressions, or
+// if there are none, and none of the deny expressions, so deny overrides allow.
+type MetricNameFilter struct {
+	allow []*regexp.Regexp
+	deny  []*regexp.Regexp
+}
+
+// compileMetricNamePatterns compiles the patterns so that they have to match whole metric names.
+func compileMetricNamePatterns(option string, patterns []string) ([]*regexp.Regexp, error) {
+	var result []*regexp.Regexp
+	for _, pattern := range patterns {
+		re, err := regexp.Compile("^(?:" + pattern + ")$")
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s pattern %q: %v", option, pattern, err)
+		}
+		result = append(result, re)
+	}
+	return result, nil
+}
+
+// ParseMetricNameFilter returns the filter given by the metricAllow and metricDeny options of a
+// sink uri, each of which can be given several times, or nil if neither is set.
+func ParseMetricNameFilter(uri *url.URL) (*MetricNameFilter, error) {
+	opts := uri.Query()
+	allow, err := compileMetricNamePatterns("metricAllow", opts["metricAllow"])
+	if err != nil {
+		return nil, err
+	}
+	deny, err := compileMetricNamePatterns("metricDeny", opts["metricDeny"])
+	if err != nil {
+		return nil, err
+	}
+	if allow == nil && deny == nil {
+		return nil, nil
+	}
+	return &MetricNameFilter{
+		allow: allow,
+		deny:  deny,
+	}, nil
+}
+
+func matchesAny(patterns []*regexp.Regexp, name string) bool {
+	for _, re := range patterns {
+		if re.MatchString(name) {
+			return true
+		}
+	}
+	return false
+}
+
+func (this *MetricNameFilter) keep(name string) bool {
+	if this.allow != nil && !matchesAny(this.allow, name) {
+		return false
+	}
+	return !matchesAny(this.deny, name)
+}
+
+// Filter returns a copy of the batch without the filtered out metric values and labeled metrics.
+// The metric sets of the given batch, which is shared by all the sinks, are not modified.
+func (this *MetricNameFilter) Filter(batch *core.DataBatch) *core.DataBatch {
+	result := &core.DataBatch{
+		Timestamp:  batch.Timestamp,
+		MetricSets: make(map[string]*core.MetricSet, len(batch.MetricSets)),
+	}
+	for key, metricSet := range batch.MetricSets {
+		filtered := *metricSet
+		filtered.MetricValues = make(map[string]core.MetricValue, len(metricSet.MetricValues))
+		for name, value := range metricSet.MetricValues {
+			if this.keep(name) {
+				filtered.MetricValues[name] = value
+			}
+		}
+		filtered.LabeledMetrics = nil
+		for _, labeledMetric := range metricSet.LabeledMetrics {
+			if this.keep(labeledMetric.Name) {
+				filtered.LabeledMetrics = append(filtered.LabeledMetrics, labeledMetric)
+			}
+		}
+		result.MetricSets[key] = &filtered
+	}
+	return result
+}
+
+// metricNameFilteringSink passes a metric name filtered copy of every batch to the wrapped sink.
+type metricNameFilteringSink struct {
+	sink   core.DataSink
+	filter *MetricNameFilter
+}
+
+func (this *metricNameFilteringSink) Name() string {
+	return this.sink.Name()
+}
+
+func (this *metricNameFilteringSink) ExportData(batch *core.DataBatch) {
+	this.sink.ExportData(this.filter.Filter(batch))
+}
+
+func (this *metricNameFilteringSink) Stop() {
+	this.sink.Stop()
+}
+
+// NewMetricNameFilteringSink wraps the sink so that it only receives the metrics selected by the
+// filter. It returns the sink unchanged if the filter is nil.
+func NewMetricNameFilteringSink(sink core.DataSink, filter *MetricNameFilter) core.DataSink {
+	if filter == nil {
+		return sink
+	}
+	return &metricNameFilteringSink{
+		sink:   sink,
+		filter: filter,
+	}
+}
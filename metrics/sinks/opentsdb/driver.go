@@ -69,7 +69,7 @@ func (tsdbSink *openTSDBSink) ExportData(data *core.DataBatch) {
 	dataPoints := make([]opentsdbclient.DataPoint, 0, batchSize)
 	for _, metricSet := range data.MetricSets {
 		for metricName, metricValue := range metricSet.MetricValues {
-			dataPoints = append(dataPoints, tsdbSink.metricToPoint(metricName, metricValue, data.Timestamp, metricSet.Labels))
+			dataPoints = append(dataPoints, tsdbSink.metricToPoint(metricName, metricValue, core.PointTimestamp(data, metricSet), metricSet.Labels))
 			if len(dataPoints) >= batchSize {
 				_, err := tsdbSink.client.Put(dataPoints, opentsdbclient.PutRespWithSummary)
 				if err != nil {
@@ -168,6 +168,11 @@ func (tsdbSink *openTSDBSink) ping() error {
 	return tsdbSink.client.Ping()
 }
 
+// CheckConnection pings OpenTSDB, like every export does before writing.
+func (tsdbSink *openTSDBSink) CheckConnection() error {
+	return tsdbSink.ping()
+}
+
 func (tsdbSink *openTSDBSink) setupClient() error {
 	return nil
 }
@@ -104,6 +104,11 @@ func TestStoreTimeseriesWithPingFailed(t *testing.T) {
 	assert.Equal(t, 0, len(fakeSink.fakeClient.receivedDataPoints))
 }
 
+func TestCheckConnection(t *testing.T) {
+	assert.NoError(t, NewFakeOpenTSDBSink(true, true).CheckConnection())
+	assert.Equal(t, errorPingFailed, NewFakeOpenTSDBSink(false, true).CheckConnection())
+}
+
 func TestStoreTimeseriesWithPutFailed(t *testing.T) {
 	fakeSink := NewFakeOpenTSDBSink(true, false)
 	batch := generateFakeBatch()
@@ -15,19 +15,22 @@
 package riemann
 
 import (
+	"fmt"
 	"net/url"
 	"sync"
 
 	"github.com/golang/glog"
 	"github.com/riemann/riemann-go-client"
+	"k8s.io/heapster/common/reconnect"
 	riemannCommon "k8s.io/heapster/common/riemann"
 	"k8s.io/heapster/metrics/core"
 )
 
 // contains the riemann client, the riemann configuration, and a RWMutex
 type RiemannSink struct {
-	client riemanngo.Client
-	config riemannCommon.RiemannConfig
+	client      riemanngo.Client
+	config      riemannCommon.RiemannConfig
+	reconnector *reconnect.Reconnector
 	sync.RWMutex
 }
 
@@ -38,13 +41,44 @@ func CreateRiemannSink(uri *url.URL) (core.DataSink, error) {
 		glog.Warningf("Error creating the Riemann metrics sink: %v", err)
 		return nil, err
 	}
+	reconnectConfig, err := reconnect.ParseConfig(uri)
+	if err != nil {
+		return nil, err
+	}
 	rs := &RiemannSink{
 		client: sink.Client,
 		config: sink.Config,
 	}
+	rs.reconnector = reconnect.NewReconnector(fmt.Sprintf("riemann:%s", rs.config.Host), reconnectConfig, rs.connect)
+	if rs.client != nil {
+		rs.reconnector.Connected()
+	}
 	return rs, nil
 }
 
+func (sink *RiemannSink) connect() error {
+	client, err := riemannCommon.GetRiemannClient(sink.config)
+	if err != nil {
+		return err
+	}
+	sink.client = client
+	return nil
+}
+
+// disconnected drops the client after a failed send, it is reconnected at the next export.
+func (sink *RiemannSink) disconnected(err error) {
+	glog.Warningf("Error sending events to Riemann: %v", err)
+	sink.client = nil
+	sink.reconnector.Disconnected(err)
+}
+
+// Connects to Riemann, unless the sink is already connected.
+func (sink *RiemannSink) CheckConnection() error {
+	sink.Lock()
+	defer sink.Unlock()
+	return sink.reconnector.Connect()
+}
+
 // Return a user-friendly string describing the sink
 func (sink *RiemannSink) Name() string {
 	return "Riemann Sink"
@@ -75,9 +109,7 @@ func appendEvent(events []riemanngo.Event, sink *RiemannSink, host, name string,
 	if len(events) >= sink.config.BatchSize {
 		err := riemannCommon.SendData(sink.client, events)
 		if err != nil {
-			glog.Warningf("Error sending events to Riemann: ", err)
-			// client will reconnect later
-			sink.client = nil
+			sink.disconnected(err)
 		}
 		events = nil
 	}
@@ -91,21 +123,19 @@ func (sink *RiemannSink) ExportData(dataBatch *core.DataBatch) {
 
 	if sink.client == nil {
 		// the client could be nil here, so we reconnect
-		client, err := riemannCommon.GetRiemannClient(sink.config)
-		if err != nil {
+		if err := sink.reconnector.Connect(); err != nil {
 			glog.Warningf("Riemann sink not connected: %v", err)
 			return
 		}
-		sink.client = client
 	}
 
 	var events []riemanngo.Event
 
 	for _, metricSet := range dataBatch.MetricSets {
 		host := metricSet.Labels[core.LabelHostname.Key]
+		timestamp := core.PointTimestamp(dataBatch, metricSet).Unix()
 		for metricName, metricValue := range metricSet.MetricValues {
 			if value := metricValue.GetValue(); value != nil {
-				timestamp := dataBatch.Timestamp.Unix()
 				// creates an event and add it to dataEvent
 				events = appendEvent(events, sink, host, metricName, value, metricSet.Labels, timestamp)
 			}
@@ -119,7 +149,6 @@ func (sink *RiemannSink) ExportData(dataBatch *core.DataBatch) {
 				for k, v := range metric.Labels {
 					labels[k] = v
 				}
-				timestamp := dataBatch.Timestamp.Unix()
 				// creates an event and add it to dataEvent
 				events = appendEvent(events, sink, host, metric.Name, value, labels, timestamp)
 			}
@@ -129,9 +158,7 @@ func (sink *RiemannSink) ExportData(dataBatch *core.DataBatch) {
 	if len(events) > 0 {
 		err := riemannCommon.SendData(sink.client, events)
 		if err != nil {
-			glog.Warningf("Error sending events to Riemann: ", err)
-			// client will reconnect later
-			sink.client = nil
+			sink.disconnected(err)
 		}
 	}
 }
@@ -149,14 +149,21 @@ func (sink *StackdriverSink) ExportData(dataBatch *core.DataBatch) {
 		// Kubernetes resources: container, pod or node. For pods container name is empty, for nodes it
 		// is set to artificial value "machine". Otherwise it stores actual container name.
 		// With new resource types, container_name is ignored for resources other than "k8s_container"
+		// The batch is shared with the other sinks, so the labels are copied instead of modified.
+		labels := metricSet.Labels
 		if sink.useOldResourceModel && metricSet.Labels["type"] == core.MetricSetTypeNode {
-			metricSet.Labels[core.LabelContainerName.Key] = "machine"
+			labels = make(map[string]string, len(metricSet.Labels)+1)
+			for k, v := range metricSet.Labels {
+				labels[k] = v
+			}
+			labels[core.LabelContainerName.Key] = "machine"
 		}
 
 		derivedMetrics := sink.computeDerivedMetrics(metricSet)
+		timestamp := core.PointTimestamp(dataBatch, metricSet)
 
-		derivedTimeseries := sink.processMetrics(derivedMetrics.MetricValues, dataBatch.Timestamp, metricSet.Labels, metricSet.CollectionStartTime, metricSet.EntityCreateTime)
-		timeseries := sink.processMetrics(metricSet.MetricValues, dataBatch.Timestamp, metricSet.Labels, metricSet.CollectionStartTime, metricSet.EntityCreateTime)
+		derivedTimeseries := sink.processMetrics(derivedMetrics.MetricValues, timestamp, labels, metricSet.CollectionStartTime, metricSet.EntityCreateTime)
+		timeseries := sink.processMetrics(metricSet.MetricValues, timestamp, labels, metricSet.CollectionStartTime, metricSet.EntityCreateTime)
 
 		timeseries = append(timeseries, derivedTimeseries...)
 
@@ -170,7 +177,7 @@ func (sink *StackdriverSink) ExportData(dataBatch *core.DataBatch) {
 
 		for _, metric := range metricSet.LabeledMetrics {
 			if sink.useOldResourceModel {
-				if point := sink.LegacyTranslateLabeledMetric(dataBatch.Timestamp, metricSet.Labels, metric, metricSet.CollectionStartTime); point != nil {
+				if point := sink.LegacyTranslateLabeledMetric(timestamp, labels, metric, metricSet.CollectionStartTime); point != nil {
 					req.TimeSeries = append(req.TimeSeries, point)
 				}
 
@@ -180,7 +187,7 @@ func (sink *StackdriverSink) ExportData(dataBatch *core.DataBatch) {
 				}
 			}
 			if sink.useNewResourceModel {
-				point := sink.TranslateLabeledMetric(dataBatch.Timestamp, metricSet.Labels, metric, metricSet.CollectionStartTime)
+				point := sink.TranslateLabeledMetric(timestamp, labels, metric, metricSet.CollectionStartTime)
 				if point != nil {
 					req.TimeSeries = append(req.TimeSeries, point)
 				}
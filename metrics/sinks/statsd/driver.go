@@ -17,9 +17,10 @@ package statsd
 import (
 	"fmt"
 	"github.com/golang/glog"
+	"k8s.io/heapster/common/flags"
+	"k8s.io/heapster/common/udp"
 	"k8s.io/heapster/metrics/core"
 	"net/url"
-	"strconv"
 	"strings"
 	"sync"
 )
@@ -27,6 +28,7 @@ import (
 const (
 	defaultHost             = "localhost:8125"
 	defaultNumMetricsPerMsg = 5
+	defaultMaxDatagramSize  = udp.DefaultMaxDatagramSize
 	defaultProtocolType     = "etsystatsd"
 )
 
@@ -41,6 +43,7 @@ type statsdConfig struct {
 	host             string
 	prefix           string
 	numMetricsPerMsg int
+	maxDatagramSize  int
 	protocolType     string
 	renameLabels     map[string]string
 	allowedLabels    map[string]string
@@ -52,6 +55,7 @@ func getConfig(uri *url.URL) (cfg statsdConfig, err error) {
 		host:             defaultHost,
 		prefix:           "",
 		numMetricsPerMsg: defaultNumMetricsPerMsg,
+		maxDatagramSize:  defaultMaxDatagramSize,
 		protocolType:     defaultProtocolType,
 		renameLabels:     make(map[string]string),
 		allowedLabels:    make(map[string]string),
@@ -62,12 +66,12 @@ func getConfig(uri *url.URL) (cfg statsdConfig, err error) {
 		config.host = uri.Host
 	}
 	opts := uri.Query()
-	if len(opts["numMetricsPerMsg"]) >= 1 {
-		val, err := strconv.Atoi(opts["numMetricsPerMsg"][0])
-		if err != nil {
-			return config, fmt.Errorf("failed to parse `numMetricsPerMsg` field - %v", err)
-		}
-		config.numMetricsPerMsg = val
+	uriOpts := flags.NewUriOptions(uri)
+	if config.numMetricsPerMsg, err = uriOpts.Int("numMetricsPerMsg", config.numMetricsPerMsg); err != nil {
+		return config, err
+	}
+	if config.maxDatagramSize, err = uriOpts.Int("maxDatagramSize", config.maxDatagramSize); err != nil {
+		return config, err
 	}
 	if len(opts["protocolType"]) >= 1 {
 		config.protocolType = strings.ToLower(opts["protocolType"][0])
@@ -79,6 +83,9 @@ func getConfig(uri *url.URL) (cfg statsdConfig, err error) {
 		renameLabels := strings.Split(opts["renameLabels"][0], ",")
 		for _, renameLabel := range renameLabels {
 			kv := strings.SplitN(renameLabel, ":", 2)
+			if len(kv) != 2 {
+				return config, fmt.Errorf("invalid renameLabels entry %q, expected from:to", renameLabel)
+			}
 			config.renameLabels[kv[0]] = kv[1]
 		}
 	}
@@ -101,10 +108,19 @@ func getConfig(uri *url.URL) (cfg statsdConfig, err error) {
 	}
 	labelCustomizer := LabelCustomizer{config.renameLabels, labelStyle}
 	config.customizeLabel = labelCustomizer.Customize
-	glog.Infof("statsd metrics sink using configuration : %+v", config)
 	return config, nil
 }
 
+// ValidateConfig checks the options of the sink uri without creating the sink.
+func ValidateConfig(uri *url.URL) error {
+	config, err := getConfig(uri)
+	if err != nil {
+		return err
+	}
+	_, err = NewFormatter(config.protocolType)
+	return err
+}
+
 func (sink *statsdSink) ExportData(dataBatch *core.DataBatch) {
 	sink.Lock()
 	defer sink.Unlock()
@@ -174,6 +190,7 @@ func NewStatsdSinkWithClient(uri *url.URL, client statsdClient) (sink core.DataS
 	if err != nil {
 		return nil, err
 	}
+	glog.Infof("statsd metrics sink using configuration : %+v", config)
 	formatter, err := NewFormatter(config.protocolType)
 	if err != nil {
 		return nil, err
@@ -191,7 +208,7 @@ func NewStatsdSink(uri *url.URL) (sink core.DataSink, err error) {
 	if err != nil {
 		return nil, err
 	}
-	client, err := NewStatsdClient(config.host, config.numMetricsPerMsg)
+	client, err := NewStatsdClient(config.host, config.numMetricsPerMsg, config.maxDatagramSize)
 	if err != nil {
 		return nil, err
 	}
@@ -15,10 +15,10 @@
 package statsd
 
 import (
-	"bytes"
 	"fmt"
+
 	"github.com/golang/glog"
-	"net"
+	"k8s.io/heapster/common/udp"
 )
 
 type statsdClient interface {
@@ -28,68 +28,38 @@ type statsdClient interface {
 }
 
 type statsdClientImpl struct {
-	host             string
-	numMetricsPerMsg int
-	conn             net.Conn
+	writer *udp.LineWriter
 }
 
 func (client *statsdClientImpl) open() error {
-	var err error
-	client.conn, err = net.Dial("udp", client.host)
+	err := client.writer.Open()
 	if err != nil {
 		glog.Errorf("Failed to open statsd client connection : %v", err)
-	} else {
-		glog.V(2).Infof("statsd client connection opened : %+v", client.conn)
 	}
 	return err
 }
 
 func (client *statsdClientImpl) close() error {
-	if client.conn == nil {
-		glog.Info("statsd client connection already closed")
-		return nil
-	}
-	err := client.conn.Close()
-	client.conn = nil
-	glog.V(2).Infof("statsd client connection closed")
-	return err
+	return client.writer.Close()
 }
 
 func (client *statsdClientImpl) send(messages []string) error {
-	if client.conn == nil {
-		err := client.open()
-		if err != nil {
-			return fmt.Errorf("send() failed - %v", err)
-		}
-	}
-	var numMetrics = 0
-	var err, tmpErr error
-	buf := bytes.NewBufferString("")
-	for _, msg := range messages {
-		buf.WriteString(fmt.Sprintf("%s\n", msg))
-		numMetrics++
-		if numMetrics >= client.numMetricsPerMsg {
-			_, tmpErr = client.conn.Write(buf.Bytes())
-			if tmpErr != nil {
-				err = tmpErr
-			}
-			buf.Reset()
-			numMetrics = 0
-		}
+	if err := client.writer.Write(messages); err != nil {
+		return fmt.Errorf("send() failed - %v", err)
 	}
-	if buf.Len() > 0 {
-		_, tmpErr = client.conn.Write(buf.Bytes())
-		if tmpErr != nil {
-			err = tmpErr
-		}
-	}
-	return err
+	return nil
 }
 
-func NewStatsdClient(host string, numMetricsPerMsg int) (client statsdClient, err error) {
+// NewStatsdClient creates a client packing at most numMetricsPerMsg metrics and
+// maxDatagramSize bytes in a UDP message, a zero maxDatagramSize means no size limit.
+func NewStatsdClient(host string, numMetricsPerMsg int, maxDatagramSize int) (client statsdClient, err error) {
 	if numMetricsPerMsg <= 0 {
 		return nil, fmt.Errorf("numMetricsPerMsg should be a positive integer : %d", numMetricsPerMsg)
 	}
+	writer, err := udp.NewLineWriter(host, maxDatagramSize, numMetricsPerMsg)
+	if err != nil {
+		return nil, err
+	}
 	glog.V(2).Infof("statsd client created")
-	return &statsdClientImpl{host: host, numMetricsPerMsg: numMetricsPerMsg}, nil
+	return &statsdClientImpl{writer: writer}, nil
 }
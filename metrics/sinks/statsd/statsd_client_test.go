@@ -21,6 +21,7 @@ import (
 	"time"
 
 	"github.com/stretchr/testify/assert"
+	"k8s.io/heapster/common/udp"
 )
 
 const (
@@ -41,7 +42,7 @@ var msgs = [...]string{
 }
 
 func TestInvalidHostname(t *testing.T) {
-	client, err := NewStatsdClient("badhostname:8125", validNumMetricsPerMsg)
+	client, err := NewStatsdClient("badhostname:8125", validNumMetricsPerMsg, udp.DefaultMaxDatagramSize)
 	assert.NoError(t, err)
 	assert.NotNil(t, client)
 	err = client.open()
@@ -49,13 +50,13 @@ func TestInvalidHostname(t *testing.T) {
 }
 
 func TestInvalidPortNumber(t *testing.T) {
-	client, err := NewStatsdClient("localhost", validNumMetricsPerMsg)
+	client, err := NewStatsdClient("localhost", validNumMetricsPerMsg, udp.DefaultMaxDatagramSize)
 	assert.NoError(t, err)
 	assert.NotNil(t, client)
 	err = client.open()
 	assert.Error(t, err, "Error expected - missing port number")
 
-	client, err = NewStatsdClient("localhost:-8125", validNumMetricsPerMsg)
+	client, err = NewStatsdClient("localhost:-8125", validNumMetricsPerMsg, udp.DefaultMaxDatagramSize)
 	assert.NoError(t, err)
 	assert.NotNil(t, client)
 	err = client.open()
@@ -63,15 +64,15 @@ func TestInvalidPortNumber(t *testing.T) {
 }
 
 func TestInvalidNumMetricsPerMsg(t *testing.T) {
-	_, err := NewStatsdClient(validHost, 0)
+	_, err := NewStatsdClient(validHost, 0, udp.DefaultMaxDatagramSize)
 	assert.Error(t, err, "Error expected - number of metrics per message cannot be 0")
 
-	_, err = NewStatsdClient(validHost, -1)
+	_, err = NewStatsdClient(validHost, -1, udp.DefaultMaxDatagramSize)
 	assert.Error(t, err, "Error expected - number of metrics per message cannot be negative")
 }
 
 func TestClose(t *testing.T) {
-	client, err := NewStatsdClient(validHost, validNumMetricsPerMsg)
+	client, err := NewStatsdClient(validHost, validNumMetricsPerMsg, udp.DefaultMaxDatagramSize)
 	assert.NoError(t, err)
 	assert.NotNil(t, client)
 	err = client.close()
@@ -86,7 +87,11 @@ func TestClose(t *testing.T) {
 }
 
 func initClientServer(t *testing.T, messages []string, numMetricsPerMsg int) (client statsdClient, serverConn *net.UDPConn) {
-	client, err := NewStatsdClient(validHost, numMetricsPerMsg)
+	return initClientServerWithMaxDatagramSize(t, messages, numMetricsPerMsg, udp.DefaultMaxDatagramSize)
+}
+
+func initClientServerWithMaxDatagramSize(t *testing.T, messages []string, numMetricsPerMsg, maxDatagramSize int) (client statsdClient, serverConn *net.UDPConn) {
+	client, err := NewStatsdClient(validHost, numMetricsPerMsg, maxDatagramSize)
 	assert.NoError(t, err)
 	assert.NotNil(t, client)
 
@@ -178,3 +183,22 @@ func TestSendMultipleMsgsUnequalBatches(t *testing.T) {
 	assert.NoError(t, err)
 	conn.Close()
 }
+
+func TestSendMultipleMsgsMaxDatagramSize(t *testing.T) {
+
+	buf := make([]byte, bufferSize)
+	numMetricsPerMsg := 10
+	// Each message takes 15 bytes with its newline, so 2 of them fit in a datagram.
+	maxDatagramSize := 40
+	client, conn := initClientServerWithMaxDatagramSize(t, msgs[0:5], numMetricsPerMsg, maxDatagramSize)
+
+	for _, expected := range [][]string{msgs[0:2], msgs[2:4], msgs[4:5]} {
+		n, _, err := conn.ReadFromUDP(buf)
+		assert.NoError(t, err)
+		assert.Equal(t, strings.Join(expected, "\n")+"\n", string(buf[0:n]))
+	}
+
+	err := client.close()
+	assert.NoError(t, err)
+	conn.Close()
+}
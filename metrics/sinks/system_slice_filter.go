@@ -0,0 +1,78 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sinks
+
+import (
+	"net/url"
+
+	"k8s.io/heapster/common/flags"
+	"k8s.io/heapster/metrics/core"
+)
+
+// excludeSystemSliceByDefault lists the sinks that don't receive the system.slice containers
+// unless includeSystemSlice is set.
+var excludeSystemSliceByDefault = map[string]bool{
+	"wavefront": true,
+}
+
+// ParseIncludeSystemSlice returns the includeSystemSlice option of a sink uri, which defaults to
+// true for all sinks but the ones in excludeSystemSliceByDefault.
+func ParseIncludeSystemSlice(sinkKey string, uri *url.URL) (bool, error) {
+	return flags.NewUriOptions(uri).Bool("includeSystemSlice", !excludeSystemSliceByDefault[sinkKey])
+}
+
+// FilterSystemSlice returns a copy of the batch without the metric sets of the system.slice
+// containers. The given batch, which is shared by all the sinks, is not modified.
+func FilterSystemSlice(batch *core.DataBatch) *core.DataBatch {
+	result := &core.DataBatch{
+		Timestamp:  batch.Timestamp,
+		MetricSets: make(map[string]*core.MetricSet, len(batch.MetricSets)),
+	}
+	for key, metricSet := range batch.MetricSets {
+		if !core.IsSystemSliceContainer(metricSet) {
+			result.MetricSets[key] = metricSet
+		}
+	}
+	return result
+}
+
+// systemSliceFilteringSink passes the batches without the system.slice containers to the
+// wrapped sink.
+type systemSliceFilteringSink struct {
+	sink core.DataSink
+}
+
+func (this *systemSliceFilteringSink) Name() string {
+	return this.sink.Name()
+}
+
+func (this *systemSliceFilteringSink) ExportData(batch *core.DataBatch) {
+	this.sink.ExportData(FilterSystemSlice(batch))
+}
+
+func (this *systemSliceFilteringSink) Stop() {
+	this.sink.Stop()
+}
+
+// NewSystemSliceFilteringSink wraps the sink so that it doesn't receive the system.slice
+// containers. It returns the sink unchanged if includeSystemSlice is true.
+func NewSystemSliceFilteringSink(sink core.DataSink, includeSystemSlice bool) core.DataSink {
+	if includeSystemSlice {
+		return sink
+	}
+	return &systemSliceFilteringSink{
+		sink: sink,
+	}
+}
@@ -0,0 +1,92 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sinks
+
+import (
+	"fmt"
+	"net/url"
+	"time"
+
+	"k8s.io/heapster/common/flags"
+	"k8s.io/heapster/metrics/core"
+)
+
+// ParseTimestampAlignment returns the interval given by the alignTimestamps option of a sink uri,
+// to which the batch timestamps are truncated: the metric resolution for true, the given duration,
+// or 0 to keep the raw timestamps if the option is false or not set. The boolean values are the
+// ones accepted by the other boolean sink options.
+func ParseTimestampAlignment(uri *url.URL, metricResolution time.Duration) (time.Duration, error) {
+	opts := flags.NewUriOptions(uri)
+	value := opts.String("alignTimestamps", "")
+	if value == "" {
+		return 0, nil
+	}
+	if align, err := opts.Bool("alignTimestamps", false); err == nil {
+		if align {
+			return metricResolution, nil
+		}
+		return 0, nil
+	}
+	alignment, err := time.ParseDuration(value)
+	if err != nil || alignment <= 0 {
+		return 0, fmt.Errorf("invalid value %q of option alignTimestamps, expected true, false or a positive duration", value)
+	}
+	return alignment, nil
+}
+
+// timestampAligningSink passes the batches with their timestamp, and the scrape times of their
+// metric sets used as point timestamps with the sample timestamp source, truncated to a multiple
+// of the alignment to the wrapped sink.
+type timestampAligningSink struct {
+	sink      core.DataSink
+	alignment time.Duration
+}
+
+func (this *timestampAligningSink) Name() string {
+	return this.sink.Name()
+}
+
+func (this *timestampAligningSink) ExportData(batch *core.DataBatch) {
+	// The batch is shared by all the sinks, so only the copies are aligned.
+	aligned := &core.DataBatch{
+		Timestamp:  batch.Timestamp.Truncate(this.alignment),
+		MetricSets: make(map[string]*core.MetricSet, len(batch.MetricSets)),
+	}
+	for key, ms := range batch.MetricSets {
+		copied := *ms
+		if !copied.ScrapeTime.IsZero() {
+			copied.ScrapeTime = copied.ScrapeTime.Truncate(this.alignment)
+		}
+		aligned.MetricSets[key] = &copied
+	}
+	this.sink.ExportData(aligned)
+}
+
+func (this *timestampAligningSink) Stop() {
+	this.sink.Stop()
+}
+
+// NewTimestampAligningSink wraps the sink so that the batches it receives from repeated scrapes
+// in the same interval have the same timestamp. It returns the sink unchanged if the alignment
+// is 0.
+func NewTimestampAligningSink(sink core.DataSink, alignment time.Duration) core.DataSink {
+	if alignment <= 0 {
+		return sink
+	}
+	return &timestampAligningSink{
+		sink:      sink,
+		alignment: alignment,
+	}
+}
@@ -17,6 +17,7 @@ package wavefront
 import (
 	"github.com/stretchr/testify/assert"
 	"k8s.io/heapster/metrics/core"
+	"net"
 	"net/url"
 	"strconv"
 	"strings"
@@ -154,3 +155,25 @@ func generateMetricSet(name string, metricType core.MetricType, value int64) *co
 	}
 	return set
 }
+
+func TestCheckConnection(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	_, port, err := net.SplitHostPort(listener.Addr().String())
+	assert.NoError(t, err)
+
+	uri, err := url.Parse("localhost:" + port)
+	assert.NoError(t, err)
+	sink, err := NewWavefrontSink(uri)
+	assert.NoError(t, err)
+	assert.NoError(t, sink.(core.DataSinkConnectionChecker).CheckConnection())
+	sink.(*wavefrontSink).Conn.Close()
+
+	listener.Close()
+	sink, err = NewWavefrontSink(uri)
+	assert.NoError(t, err)
+	assert.Error(t, sink.(core.DataSinkConnectionChecker).CheckConnection())
+
+	// The test mode never connects.
+	assert.NoError(t, NewFakeWavefrontSink().CheckConnection())
+}
@@ -17,19 +17,19 @@ package wavefront
 import (
 	"fmt"
 	"github.com/golang/glog"
+	"k8s.io/heapster/common/flags"
+	"k8s.io/heapster/common/reconnect"
 	"k8s.io/heapster/metrics/core"
+	"k8s.io/heapster/metrics/util/metrics"
 	"net"
 	"net/url"
 	"sort"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 )
 
-const (
-	sysSubContainerName = "system.slice/"
-)
-
 var excludeTagList = [...]string{"namespace_id", "host_id", "pod_id", "hostname"}
 
 type wavefrontSink struct {
@@ -41,6 +41,8 @@ type wavefrontSink struct {
 	IncludeContainers bool
 	testMode          bool
 	testReceivedLines []string
+	reconnector       *reconnect.Reconnector
+	sync.Mutex
 }
 
 func (wfSink *wavefrontSink) Name() string {
@@ -48,8 +50,11 @@ func (wfSink *wavefrontSink) Name() string {
 }
 
 func (wfSink *wavefrontSink) Stop() {
-	// Do nothing.
-	wfSink.Conn.Close()
+	wfSink.Lock()
+	defer wfSink.Unlock()
+	if wfSink.Conn != nil {
+		wfSink.Conn.Close()
+	}
 }
 
 func (wfSink *wavefrontSink) sendLine(line string) {
@@ -58,15 +63,15 @@ func (wfSink *wavefrontSink) sendLine(line string) {
 		glog.Infoln(line)
 		return
 	}
-	//if the connection was closed or interrupted - don't cause a panic (we'll retry at next interval)
-	defer func() {
-		if r := recover(); r != nil {
-			//we couldn't write the line so something is wrong with the connection
-			wfSink.Conn = nil
-		}
-	}()
-	if wfSink.Conn != nil {
-		wfSink.Conn.Write([]byte(line))
+	if wfSink.Conn == nil {
+		return
+	}
+	if _, err := wfSink.Conn.Write([]byte(line)); err != nil {
+		//the connection was closed or interrupted, we'll reconnect at next interval
+		glog.Warningf("Unable to send to Wavefront proxy at address %s: %v", wfSink.ProxyAddress, err)
+		wfSink.Conn.Close()
+		wfSink.Conn = nil
+		wfSink.reconnector.Disconnected(err)
 	}
 }
 
@@ -134,14 +139,11 @@ func (wfSink *wavefrontSink) send(batch *core.DataBatch) {
 		// Add pod labels as tags
 		wfSink.addLabelTags(ms, tags)
 		metricType := tags["type"]
-		if strings.Contains(tags["container_name"], sysSubContainerName) {
-			//don't send system subcontainers
-			continue
-		}
 		if wfSink.IncludeContainers == false && strings.Contains(metricType, "pod_container") {
 			// the user doesn't want to include container metrics (only pod and above)
 			continue
 		}
+		ts := strconv.FormatInt(core.PointTimestamp(batch, ms).Unix(), 10)
 		for _, metricName := range sortedMetricValueKeys(ms.MetricValues) {
 			var metricValStr string
 			metricValue := ms.MetricValues[metricName]
@@ -150,11 +152,10 @@ func (wfSink *wavefrontSink) send(batch *core.DataBatch) {
 			} else if core.ValueFloat == metricValue.ValueType { // W
 				metricValStr = fmt.Sprintf("%f", metricValue.FloatValue)
 			} else {
-				//do nothing for now
+				metrics.ReportUnexportableMetric(wfSink.Name(), metricName, metrics.ReasonUnsupportedValueType)
 				metricValStr = ""
 			}
 			if metricValStr != "" {
-				ts := strconv.FormatInt(batch.Timestamp.Unix(), 10)
 				source := ""
 				if metricType == "cluster" {
 					source = wfSink.ClusterName
@@ -176,11 +177,10 @@ func (wfSink *wavefrontSink) send(batch *core.DataBatch) {
 			} else if core.ValueFloat == metric.ValueType { // W
 				metricValStr = fmt.Sprintf("%f", metric.FloatValue)
 			} else {
-				//do nothing for now
+				metrics.ReportUnexportableMetric(wfSink.Name(), metric.Name, metrics.ReasonUnsupportedValueType)
 				metricValStr = ""
 			}
 			if metricValStr != "" {
-				ts := strconv.FormatInt(batch.Timestamp.Unix(), 10)
 				source := tags["hostname"]
 				tagStr := tagsToString(tags)
 				for labelName, labelValue := range metric.Labels {
@@ -195,6 +195,8 @@ func (wfSink *wavefrontSink) send(batch *core.DataBatch) {
 }
 
 func (wfSink *wavefrontSink) ExportData(batch *core.DataBatch) {
+	wfSink.Lock()
+	defer wfSink.Unlock()
 
 	if wfSink.testMode {
 		//clear lines from last batch
@@ -204,14 +206,20 @@ func (wfSink *wavefrontSink) ExportData(batch *core.DataBatch) {
 	}
 
 	//make sure we're Connected before sending a real batch
-	err := wfSink.connect()
-	if err != nil {
-		glog.Warning(err)
+	if err := wfSink.reconnector.Connect(); err != nil {
+		glog.Warningf("Unable to connect to Wavefront proxy at address %s: %v", wfSink.ProxyAddress, err)
+		return
 	}
+	wfSink.send(batch)
+}
 
-	if wfSink.Conn != nil && err == nil {
-		wfSink.send(batch)
+func (wfSink *wavefrontSink) CheckConnection() error {
+	wfSink.Lock()
+	defer wfSink.Unlock()
+	if wfSink.testMode {
+		return nil
 	}
+	return wfSink.reconnector.Connect()
 }
 
 func (wfSink *wavefrontSink) connect() error {
@@ -237,40 +245,24 @@ func NewWavefrontSink(uri *url.URL) (core.DataSink, error) {
 		testMode:          false,
 	}
 
-	vals := uri.Query()
-	if len(vals["clusterName"]) > 0 {
-		storage.ClusterName = vals["clusterName"][0]
-	}
-	if len(vals["prefix"]) > 0 {
-		storage.Prefix = vals["prefix"][0]
+	opts := flags.NewUriOptions(uri)
+	storage.ClusterName = opts.String("clusterName", storage.ClusterName)
+	storage.Prefix = opts.String("prefix", storage.Prefix)
+	var err error
+	if storage.IncludeLabels, err = opts.Bool("includeLabels", storage.IncludeLabels); err != nil {
+		return nil, err
 	}
-	if len(vals["includeLabels"]) > 0 {
-		incLabels := false
-		incLabels, err := strconv.ParseBool(vals["includeLabels"][0])
-		if err != nil {
-			glog.Warning("Unable to parse the includeLabels argument. This argument is a boolean, please pass \"true\" or \"false\"")
-			return nil, err
-		}
-		storage.IncludeLabels = incLabels
+	if storage.IncludeContainers, err = opts.Bool("includeContainers", storage.IncludeContainers); err != nil {
+		return nil, err
 	}
-	if len(vals["includeContainers"]) > 0 {
-		incContainers := false
-		incContainers, err := strconv.ParseBool(vals["includeContainers"][0])
-		if err != nil {
-			glog.Warning("Unable to parse the includeContainers argument. This argument is a boolean, please pass \"true\" or \"false\"")
-			return nil, err
-		}
-		storage.IncludeContainers = incContainers
+	if storage.testMode, err = opts.Bool("testMode", storage.testMode); err != nil {
+		return nil, err
 	}
-	if len(vals["testMode"]) > 0 {
-		testMode := false
-		testMode, err := strconv.ParseBool(vals["testMode"][0])
-		if err != nil {
-			glog.Warning("Unable to parse the testMode argument. This argument is a boolean, please pass \"true\" or \"false\"")
-			return nil, err
-		}
-		storage.testMode = testMode
+	reconnectConfig, err := reconnect.ParseConfig(uri)
+	if err != nil {
+		return nil, err
 	}
+	storage.reconnector = reconnect.NewReconnector(fmt.Sprintf("wavefront:%s", storage.ProxyAddress), reconnectConfig, storage.connect)
 	return storage, nil
 }
 
@@ -27,16 +27,23 @@ type SourceFactory struct {
 }
 
 func (this *SourceFactory) Build(uri flags.Uri) (core.MetricsSourceProvider, error) {
+	labels, err := ParseStaticLabels(&uri.Val)
+	if err != nil {
+		return nil, err
+	}
+	var provider core.MetricsSourceProvider
 	switch uri.Key {
 	case "kubernetes":
-		provider, err := kubelet.NewKubeletProvider(&uri.Val)
-		return provider, err
+		provider, err = kubelet.NewKubeletProvider(&uri.Val)
 	case "kubernetes.summary_api":
-		provider, err := summary.NewSummaryProvider(&uri.Val)
-		return provider, err
+		provider, err = summary.NewSummaryProvider(&uri.Val)
 	default:
 		return nil, fmt.Errorf("Source not recognized: %s", uri.Key)
 	}
+	if err != nil {
+		return nil, err
+	}
+	return NewStaticLabelsProvider(provider, labels), nil
 }
 
 func (this *SourceFactory) BuildAll(uris flags.Uris) (core.MetricsSourceProvider, error) {
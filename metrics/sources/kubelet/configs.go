@@ -15,8 +15,10 @@
 package kubelet
 
 import (
+	"fmt"
 	"net/url"
 	"strconv"
+	"strings"
 
 	"github.com/golang/glog"
 	kube_client "k8s.io/client-go/rest"
@@ -27,11 +29,13 @@ import (
 const (
 	APIVersion = "v1"
 
-	defaultKubeletPort        = 10255
-	defaultKubeletHttps       = false
-	defaultUseServiceAccount  = false
-	defaultServiceAccountFile = "/var/run/secrets/kubernetes.io/serviceaccount/token"
-	defaultInClusterConfig    = true
+	defaultKubeletPort           = 10255
+	defaultKubeletHttps          = false
+	defaultKubeletMaxRetries     = 2
+	defaultKubeletSampleStrategy = SampleLast
+	defaultUseServiceAccount     = false
+	defaultServiceAccountFile    = "/var/run/secrets/kubernetes.io/serviceaccount/token"
+	defaultInClusterConfig       = true
 )
 
 func GetKubeConfigs(uri *url.URL) (*kube_client.Config, *kubelet_client.KubeletClientConfig, error) {
@@ -50,6 +54,47 @@ func GetKubeConfigs(uri *url.URL) (*kube_client.Config, *kubelet_client.KubeletC
 		}
 	}
 
+	// Port used when the kubelet refuses connections on kubeletPort, e.g. the read-only port
+	// during a migration to the authenticated one. Always scraped over http.
+	kubeletFallbackPort := 0
+	if len(opts["kubeletFallbackPort"]) >= 1 {
+		kubeletFallbackPort, err = strconv.Atoi(opts["kubeletFallbackPort"][0])
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	kubeletMaxRetries := defaultKubeletMaxRetries
+	if len(opts["kubeletMaxRetries"]) >= 1 {
+		kubeletMaxRetries, err = strconv.Atoi(opts["kubeletMaxRetries"][0])
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	// Custom metrics can have a label per value, so importing all of them may blow up cardinality.
+	var kubeletCustomMetrics []string
+	for _, names := range opts["kubeletCustomMetrics"] {
+		for _, name := range strings.Split(names, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				kubeletCustomMetrics = append(kubeletCustomMetrics, name)
+			}
+		}
+	}
+
+	kubeletSampleStrategy := defaultKubeletSampleStrategy
+	if len(opts["kubeletSampleStrategy"]) >= 1 {
+		kubeletSampleStrategy = opts["kubeletSampleStrategy"][0]
+		switch kubeletSampleStrategy {
+		case SampleLast, SampleMax:
+		case "all":
+			// Data batches hold a single value per metric, so samples between scrapes can't be exported.
+			return nil, nil, fmt.Errorf("kubelet sample strategy %q is not supported, use %q or %q", kubeletSampleStrategy, SampleLast, SampleMax)
+		default:
+			return nil, nil, fmt.Errorf("unknown kubelet sample strategy %q, use %q or %q", kubeletSampleStrategy, SampleLast, SampleMax)
+		}
+	}
+
 	kubeletHttps := defaultKubeletHttps
 	if len(opts["kubeletHttps"]) >= 1 {
 		kubeletHttps, err = strconv.ParseBool(opts["kubeletHttps"][0])
@@ -58,14 +103,53 @@ func GetKubeConfigs(uri *url.URL) (*kube_client.Config, *kubelet_client.KubeletC
 		}
 	}
 
+	// A node-local Heapster can reach its kubelet through a unix socket, without network exposure.
+	// Only the node of the socket is scraped then.
+	kubeletSocket, kubeletSocketNode := "", ""
+	if len(opts["kubeletSocket"]) >= 1 {
+		kubeletSocket = opts["kubeletSocket"][0]
+		if len(opts["kubeletSocketNode"]) >= 1 {
+			kubeletSocketNode = opts["kubeletSocketNode"][0]
+		}
+		if kubeletSocketNode == "" {
+			return nil, nil, fmt.Errorf("kubeletSocketNode is required with kubeletSocket, set it to the name of the node of the socket")
+		}
+	}
+
+	// Explicit proxy for the kubelets, for networks where egress goes through a proxy. The API
+	// server and, without it, the kubelets use the HTTP_PROXY, HTTPS_PROXY and NO_PROXY variables.
+	var kubeletProxy *url.URL
+	if len(opts["kubeletProxy"]) >= 1 && opts["kubeletProxy"][0] != "" {
+		kubeletProxy, err = url.Parse(opts["kubeletProxy"][0])
+		if err != nil || kubeletProxy.Scheme == "" || kubeletProxy.Host == "" {
+			return nil, nil, fmt.Errorf("invalid kubeletProxy %q, expected a url like http://proxy:3128", opts["kubeletProxy"][0])
+		}
+	}
+
 	glog.Infof("Using Kubernetes client with master %q and version %+v\n", kubeConfig.Host, kubeConfig.GroupVersion)
 	glog.Infof("Using kubelet port %d", kubeletPort)
+	if kubeletFallbackPort > 0 {
+		glog.Infof("Using kubelet fallback port %d", kubeletFallbackPort)
+	}
+	if kubeletProxy != nil {
+		glog.Infof("Using kubelet proxy %s", kubeletProxy.Redacted())
+	}
+	if kubeletSocket != "" {
+		glog.Infof("Using kubelet socket %s of node %s", kubeletSocket, kubeletSocketNode)
+	}
 
 	kubeletConfig := &kubelet_client.KubeletClientConfig{
 		Port:            uint(kubeletPort),
+		ReadOnlyPort:    uint(kubeletFallbackPort),
+		MaxRetries:      kubeletMaxRetries,
+		CustomMetrics:   kubeletCustomMetrics,
+		SampleStrategy:  kubeletSampleStrategy,
 		EnableHttps:     kubeletHttps,
 		TLSClientConfig: kubeConfig.TLSClientConfig,
 		BearerToken:     kubeConfig.BearerToken,
+		SocketPath:      kubeletSocket,
+		SocketNodeName:  kubeletSocketNode,
+		ProxyURL:        kubeletProxy,
 	}
 
 	return kubeConfig, kubeletConfig, nil
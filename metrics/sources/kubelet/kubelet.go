@@ -123,10 +123,13 @@ func (this *kubeletMetricsSource) decodeMetrics(c *cadvisor.ContainerInfo) (stri
 		return "", nil
 	}
 
+	// Samples are sorted by time, all but the newest are only kept to select gauge values from.
+	stats := c.Stats[len(c.Stats)-1]
+
 	var metricSetKey string
 	cMetrics := &MetricSet{
 		CollectionStartTime: c.Spec.CreationTime,
-		ScrapeTime:          c.Stats[0].Timestamp,
+		ScrapeTime:          stats.Timestamp,
 		MetricValues:        map[string]MetricValue{},
 		Labels: map[string]string{
 			LabelNodename.Key: this.nodename,
@@ -175,14 +178,20 @@ func (this *kubeletMetricsSource) decodeMetrics(c *cadvisor.ContainerInfo) (stri
 	}
 
 	for _, metric := range StandardMetrics {
-		if metric.HasValue != nil && metric.HasValue(&c.Spec) {
-			cMetrics.MetricValues[metric.Name] = metric.GetValue(&c.Spec, c.Stats[0])
+		if IsCollected(&metric) && metric.HasValue != nil && metric.HasValue(&c.Spec) {
+			value := metric.GetValue(&c.Spec, stats)
+			if metric.Type == MetricGauge {
+				for _, sample := range c.Stats[:len(c.Stats)-1] {
+					value = maxMetricValue(value, metric.GetValue(&c.Spec, sample))
+				}
+			}
+			cMetrics.MetricValues[metric.Name] = value
 		}
 	}
 
 	for _, metric := range LabeledMetrics {
-		if metric.HasLabeledMetric != nil && metric.HasLabeledMetric(&c.Spec, c.Stats[0]) {
-			labeledMetrics := metric.GetLabeledMetric(&c.Spec, c.Stats[0])
+		if IsCollected(&metric) && metric.HasLabeledMetric != nil && metric.HasLabeledMetric(&c.Spec, stats) {
+			labeledMetrics := metric.GetLabeledMetric(&c.Spec, stats)
 			cMetrics.LabeledMetrics = append(cMetrics.LabeledMetrics, labeledMetrics...)
 		}
 	}
@@ -193,15 +202,16 @@ func (this *kubeletMetricsSource) decodeMetrics(c *cadvisor.ContainerInfo) (stri
 
 metricloop:
 	for _, spec := range c.Spec.CustomMetrics {
-		cmValue, ok := c.Stats[0].CustomMetrics[spec.Name]
+		cmValue, ok := stats.CustomMetrics[spec.Name]
 		if !ok || cmValue == nil || len(cmValue) == 0 {
 			continue metricloop
 		}
 
-		newest := cmValue[0]
+		// Values of a custom metric can be labeled, the newest value is taken for each label.
+		newest := map[string]cadvisor.MetricVal{}
 		for _, metricVal := range cmValue {
-			if newest.Timestamp.Before(metricVal.Timestamp) {
-				newest = metricVal
+			if val, found := newest[metricVal.Label]; !found || val.Timestamp.Before(metricVal.Timestamp) {
+				newest[metricVal.Label] = metricVal
 			}
 		}
 		mv := MetricValue{}
@@ -218,21 +228,48 @@ metricloop:
 		switch spec.Format {
 		case cadvisor.IntType:
 			mv.ValueType = ValueInt64
-			mv.IntValue = newest.IntValue
 		case cadvisor.FloatType:
 			mv.ValueType = ValueFloat
-			mv.FloatValue = newest.FloatValue
 		default:
 			glog.V(4).Infof("Skipping %s: unknown custom metric format", spec.Name, spec.Format)
 			continue metricloop
 		}
 
-		cMetrics.MetricValues[CustomMetricPrefix+spec.Name] = mv
+		for label, metricVal := range newest {
+			value := mv
+			if value.ValueType == ValueInt64 {
+				value.IntValue = metricVal.IntValue
+			} else {
+				value.FloatValue = metricVal.FloatValue
+			}
+			if label == "" {
+				cMetrics.MetricValues[CustomMetricPrefix+spec.Name] = value
+				continue
+			}
+			cMetrics.LabeledMetrics = append(cMetrics.LabeledMetrics, LabeledMetric{
+				Name: CustomMetricPrefix + spec.Name,
+				Labels: map[string]string{
+					LabelResourceID.Key: label,
+				},
+				MetricValue: value,
+			})
+		}
 	}
 
 	return metricSetKey, cMetrics
 }
 
+func maxMetricValue(a, b MetricValue) MetricValue {
+	if a.ValueType == ValueFloat {
+		if b.FloatValue > a.FloatValue {
+			return b
+		}
+	} else if b.IntValue > a.IntValue {
+		return b
+	}
+	return a
+}
+
 func (this *kubeletMetricsSource) ScrapeMetrics(start, end time.Time) (*DataBatch, error) {
 	containers, err := this.scrapeKubelet(this.kubeletClient, this.host, start, end)
 
@@ -260,10 +297,10 @@ func (this *kubeletMetricsSource) ScrapeMetrics(start, end time.Time) (*DataBatc
 
 func (this *kubeletMetricsSource) scrapeKubelet(client *KubeletClient, host Host, start, end time.Time) ([]cadvisor.ContainerInfo, error) {
 	startTime := time.Now()
-	defer func() {
-		kubeletRequestLatency.WithLabelValues(this.hostname).Observe(float64(time.Since(startTime)) / float64(time.Millisecond))
-	}()
-	return client.GetAllRawContainers(host, start, end)
+	containers, err := client.GetAllRawContainers(host, start, end)
+	kubeletRequestLatency.WithLabelValues(this.hostname).Observe(float64(time.Since(startTime)) / float64(time.Millisecond))
+	RecordNodeScrape(this.nodename, startTime, err)
+	return containers, err
 }
 
 type kubeletProvider struct {
@@ -272,6 +309,10 @@ type kubeletProvider struct {
 	kubeletClient *KubeletClient
 }
 
+func (this *kubeletProvider) HasSynced() bool {
+	return this.reflector == nil || this.reflector.LastSyncResourceVersion() != ""
+}
+
 func (this *kubeletProvider) GetMetricsSources() []MetricsSource {
 	sources := []MetricsSource{}
 	nodes, err := this.nodeLister.List(labels.Everything())
@@ -285,6 +326,9 @@ func (this *kubeletProvider) GetMetricsSources() []MetricsSource {
 	}
 
 	for _, node := range nodes {
+		if !this.kubeletClient.ReachesNode(node.Name) {
+			continue
+		}
 		hostname, ip, err := GetNodeHostnameAndIP(node)
 		if err != nil {
 			glog.Errorf("%v", err)
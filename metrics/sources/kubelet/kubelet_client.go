@@ -24,12 +24,17 @@ import (
 	"net"
 	"net/http"
 	"net/url"
+	"os"
 	"strconv"
+	"sync"
+	"syscall"
 	"time"
 
 	"github.com/golang/glog"
 	cadvisor "github.com/google/cadvisor/info/v1"
 	jsoniter "github.com/json-iterator/go"
+	utilnet "k8s.io/apimachinery/pkg/util/net"
+	"k8s.io/apimachinery/pkg/util/wait"
 	kubelet_client "k8s.io/heapster/metrics/sources/kubelet/util"
 	stats "k8s.io/kubernetes/pkg/kubelet/apis/stats/v1alpha1"
 )
@@ -44,9 +49,25 @@ func (h Host) String() string {
 	return net.JoinHostPort(h.IP.String(), strconv.Itoa(h.Port))
 }
 
+// Host of the requests sent through a unix socket, which are not addressed to a node.
+const socketHost = "localhost"
+
+// Initial delay between retries of failed kubelet requests, doubled with each retry.
+var retryBackoff = 100 * time.Millisecond
+
 type KubeletClient struct {
 	config *kubelet_client.KubeletClientConfig
 	client *http.Client
+	// Client for the fallback port, without the credentials of client.
+	fallbackClient *http.Client
+
+	// Names of the custom metrics to import, all if empty.
+	customMetrics map[string]bool
+
+	// Nodes that refused connections on the configured port, but accepted them
+	// on the fallback (read-only) port, by IP.
+	fallbackLock  sync.Mutex
+	fallbackNodes map[string]bool
 }
 
 type ErrNotFound struct {
@@ -57,15 +78,36 @@ func (err *ErrNotFound) Error() string {
 	return fmt.Sprintf("%q not found", err.endpoint)
 }
 
+// ErrUnexpectedStatus is returned when the kubelet responds with a status other than 200 or 404.
+type ErrUnexpectedStatus struct {
+	statusCode int
+	status     string
+	body       string
+}
+
+func (err *ErrUnexpectedStatus) Error() string {
+	return fmt.Sprintf("request failed - %q, response: %q", err.status, err.body)
+}
+
 func IsNotFoundError(err error) bool {
 	_, isNotFound := err.(*ErrNotFound)
 	return isNotFound
 }
 
-func sampleContainerStats(stats []*cadvisor.ContainerStats) []*cadvisor.ContainerStats {
+const (
+	// SampleLast keeps only the newest sample of the stats returned by the kubelet.
+	SampleLast = "last"
+	// SampleMax keeps all samples, so that the highest value of gauges between scrapes is exported.
+	SampleMax = "max"
+)
+
+func sampleContainerStats(stats []*cadvisor.ContainerStats, strategy string) []*cadvisor.ContainerStats {
 	if len(stats) == 0 {
 		return []*cadvisor.ContainerStats{}
 	}
+	if strategy == SampleMax {
+		return stats
+	}
 	return []*cadvisor.ContainerStats{stats[len(stats)-1]}
 }
 
@@ -82,7 +124,7 @@ func (self *KubeletClient) postRequestAndGetValue(client *http.Client, req *http
 	if response.StatusCode == http.StatusNotFound {
 		return &ErrNotFound{req.URL.String()}
 	} else if response.StatusCode != http.StatusOK {
-		return fmt.Errorf("request failed - %q, response: %q", response.Status, string(body))
+		return &ErrUnexpectedStatus{statusCode: response.StatusCode, status: response.Status, body: string(body)}
 	}
 
 	kubeletAddr := "[unknown]"
@@ -99,13 +141,38 @@ func (self *KubeletClient) postRequestAndGetValue(client *http.Client, req *http
 }
 
 func (self *KubeletClient) parseStat(containerInfo *cadvisor.ContainerInfo) *cadvisor.ContainerInfo {
-	containerInfo.Stats = sampleContainerStats(containerInfo.Stats)
+	containerInfo.Stats = sampleContainerStats(containerInfo.Stats, self.getSampleStrategy())
 	if len(containerInfo.Aliases) > 0 {
 		containerInfo.Name = containerInfo.Aliases[0]
 	}
+	if containerInfo.Spec.HasCustomMetrics {
+		self.filterCustomMetrics(containerInfo)
+	}
 	return containerInfo
 }
 
+// filterCustomMetrics drops the custom metrics that are not configured to be imported.
+func (self *KubeletClient) filterCustomMetrics(containerInfo *cadvisor.ContainerInfo) {
+	if len(self.customMetrics) == 0 {
+		return
+	}
+	specs := make([]cadvisor.MetricSpec, 0, len(containerInfo.Spec.CustomMetrics))
+	for _, spec := range containerInfo.Spec.CustomMetrics {
+		if self.customMetrics[spec.Name] {
+			specs = append(specs, spec)
+		}
+	}
+	containerInfo.Spec.CustomMetrics = specs
+	containerInfo.Spec.HasCustomMetrics = len(specs) > 0
+	for _, stat := range containerInfo.Stats {
+		for name := range stat.CustomMetrics {
+			if !self.customMetrics[name] {
+				delete(stat.CustomMetrics, name)
+			}
+		}
+	}
+}
+
 // TODO(vmarmol): Use Kubernetes' if we export it as an API.
 type statsRequest struct {
 	// The name of the container for which to request stats.
@@ -144,65 +211,210 @@ func (self *KubeletClient) getUrl(host Host, path string) string {
 		Host:   host.String(),
 		Path:   path,
 	}
+	if self.usesSocket() {
+		url.Host = socketHost
+	}
 
 	return url.String()
 }
 
-// Get stats for all non-Kubernetes containers.
-func (self *KubeletClient) GetAllRawContainers(host Host, start, end time.Time) ([]cadvisor.ContainerInfo, error) {
-	url := self.getUrl(host, "/stats/container/")
+// getFallbackUrl returns the url on the read-only port, which never uses TLS.
+func (self *KubeletClient) getFallbackUrl(host Host, path string) string {
+	host.Port = self.getFallbackPort()
+	url := url.URL{
+		Scheme: "http",
+		Host:   host.String(),
+		Path:   path,
+	}
 
-	return self.getAllContainers(url, start, end)
+	return url.String()
 }
 
-func (self *KubeletClient) GetSummary(host Host) (*stats.Summary, error) {
-	url := self.getUrl(host, "/stats/summary/")
+func (self *KubeletClient) getFallbackPort() int {
+	// Ports don't matter for requests through the socket.
+	if self.config == nil || self.usesSocket() {
+		return 0
+	}
+	return int(self.config.ReadOnlyPort)
+}
 
-	req, err := http.NewRequest("GET", url, nil)
-	if err != nil {
-		return nil, err
+func (self *KubeletClient) usesSocket() bool {
+	return self.config != nil && self.config.SocketPath != ""
+}
+
+// ReachesNode returns whether the kubelet of the node can be scraped with the client. When the
+// kubelet is reached through a unix socket, that is only the node of the socket.
+func (self *KubeletClient) ReachesNode(nodeName string) bool {
+	return !self.usesSocket() || self.config.SocketNodeName == nodeName
+}
+
+func (self *KubeletClient) usesFallbackPort(host Host) bool {
+	self.fallbackLock.Lock()
+	defer self.fallbackLock.Unlock()
+	return self.fallbackNodes[host.IP.String()]
+}
+
+func (self *KubeletClient) setUsesFallbackPort(host Host, fallback bool) {
+	self.fallbackLock.Lock()
+	defer self.fallbackLock.Unlock()
+	if self.fallbackNodes == nil {
+		self.fallbackNodes = make(map[string]bool)
 	}
-	summary := &stats.Summary{}
+	if fallback && !self.fallbackNodes[host.IP.String()] {
+		glog.Warningf("Kubelet at %s refused connection on port %d, using fallback port %d", host.IP, host.Port, self.getFallbackPort())
+	}
+	if !fallback && self.fallbackNodes[host.IP.String()] {
+		glog.Infof("Kubelet at %s accepts connections on port %d again", host.IP, host.Port)
+	}
+	self.fallbackNodes[host.IP.String()] = fallback
+}
+
+// sendRequest sends the request to the given path of the kubelet and parses the response into value.
+// If the kubelet refuses the connection and a fallback port is configured, the request is retried
+// on the fallback port. The port that succeeded is tried first next time.
+func (self *KubeletClient) sendRequest(host Host, path string, newRequest func(url string) (*http.Request, error), value interface{}) error {
 	client := self.client
 	if client == nil {
 		client = http.DefaultClient
 	}
-	err = self.postRequestAndGetValue(client, req, summary)
-	return summary, err
+	fallbackClient := self.fallbackClient
+	if fallbackClient == nil {
+		fallbackClient = http.DefaultClient
+	}
+	send := func(fallback bool) error {
+		url := self.getUrl(host, path)
+		client := client
+		if fallback {
+			url = self.getFallbackUrl(host, path)
+			client = fallbackClient
+		}
+		var err error
+		for attempt := 0; ; attempt++ {
+			var req *http.Request
+			req, err = newRequest(url)
+			if err != nil {
+				return err
+			}
+			err = self.postRequestAndGetValue(client, req, value)
+			if err == nil || attempt >= self.getMaxRetries() || !isRetryable(err) {
+				return err
+			}
+			backoff := wait.Jitter(retryBackoff<<uint(attempt), 1.0)
+			glog.V(2).Infof("Retrying request to %s in %v after error: %v", url, backoff, err)
+			time.Sleep(backoff)
+		}
+	}
+
+	if self.getFallbackPort() == 0 {
+		return send(false)
+	}
+	fallback := self.usesFallbackPort(host)
+	err := send(fallback)
+	if isConnectionRefused(err) {
+		fallback = !fallback
+		err = send(fallback)
+	}
+	if err == nil {
+		self.setUsesFallbackPort(host, fallback)
+	}
+	return err
 }
 
-func (self *KubeletClient) GetPort() int {
-	return int(self.config.Port)
+func (self *KubeletClient) getSampleStrategy() string {
+	if self.config == nil || self.config.SampleStrategy == "" {
+		return SampleLast
+	}
+	return self.config.SampleStrategy
 }
 
-func (self *KubeletClient) getAllContainers(url string, start, end time.Time) ([]cadvisor.ContainerInfo, error) {
-	// Request data from all subcontainers.
-	request := statsRequest{
-		ContainerName: "/",
-		NumStats:      1,
-		Start:         start,
-		End:           end,
-		Subcontainers: true,
+// getNumStats returns the number of stats samples to request from the kubelet, which passes it to
+// cadvisor as the maximum number of samples returned: all samples between start and end for the
+// max strategy, which picks from them, and only the newest one otherwise.
+func (self *KubeletClient) getNumStats() int {
+	if self.getSampleStrategy() == SampleMax {
+		return -1
 	}
-	body, err := jsoniter.ConfigFastest.Marshal(request)
-	if err != nil {
-		return nil, err
+	return 1
+}
+
+func (self *KubeletClient) getMaxRetries() int {
+	if self.config == nil {
+		return 0
 	}
-	req, err := http.NewRequest("POST", url, bytes.NewBuffer(body))
-	if err != nil {
-		return nil, err
+	return self.config.MaxRetries
+}
+
+// isRetryable returns whether the error is likely transient, e.g. returned while the kubelet is busy.
+func isRetryable(err error) bool {
+	if statusErr, ok := err.(*ErrUnexpectedStatus); ok {
+		return statusErr.statusCode == http.StatusInternalServerError ||
+			statusErr.statusCode == http.StatusServiceUnavailable
 	}
-	req.Header.Set("Content-Type", "application/json")
+	return utilnet.IsConnectionReset(err)
+}
 
-	var containers map[string]cadvisor.ContainerInfo
-	client := self.client
-	if client == nil {
-		client = http.DefaultClient
+func isConnectionRefused(err error) bool {
+	if urlErr, ok := err.(*url.Error); ok {
+		err = urlErr.Err
+	}
+	if opErr, ok := err.(*net.OpError); ok {
+		err = opErr.Err
 	}
-	err = self.postRequestAndGetValue(client, req, &containers)
+	if osErr, ok := err.(*os.SyscallError); ok {
+		err = osErr.Err
+	}
+	if errno, ok := err.(syscall.Errno); ok && errno == syscall.ECONNREFUSED {
+		return true
+	}
+	return false
+}
+
+// Get stats for all non-Kubernetes containers.
+func (self *KubeletClient) GetAllRawContainers(host Host, start, end time.Time) ([]cadvisor.ContainerInfo, error) {
+	var containers map[string]cadvisor.ContainerInfo
+	err := self.sendRequest(host, "/stats/container/", newAllContainersRequest(start, end, self.getNumStats()), &containers)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get all container stats from Kubelet URL %q: %v", url, err)
+		return nil, fmt.Errorf("failed to get all container stats from Kubelet %v: %v", host, err)
+	}
+	return self.parseContainers(containers), nil
+}
+
+func (self *KubeletClient) GetSummary(host Host) (*stats.Summary, error) {
+	summary := &stats.Summary{}
+	err := self.sendRequest(host, "/stats/summary/", func(url string) (*http.Request, error) {
+		return http.NewRequest("GET", url, nil)
+	}, summary)
+	return summary, err
+}
+
+func (self *KubeletClient) GetPort() int {
+	return int(self.config.Port)
+}
+
+func newAllContainersRequest(start, end time.Time, numStats int) func(url string) (*http.Request, error) {
+	return func(url string) (*http.Request, error) {
+		// Request data from all subcontainers.
+		request := statsRequest{
+			ContainerName: "/",
+			NumStats:      numStats,
+			Start:         start,
+			End:           end,
+			Subcontainers: true,
+		}
+		body, err := jsoniter.ConfigFastest.Marshal(request)
+		if err != nil {
+			return nil, err
+		}
+		req, err := http.NewRequest("POST", url, bytes.NewBuffer(body))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		return req, nil
 	}
+}
+
+func (self *KubeletClient) parseContainers(containers map[string]cadvisor.ContainerInfo) []cadvisor.ContainerInfo {
 	result := make([]cadvisor.ContainerInfo, 0, len(containers))
 	for _, containerInfo := range containers {
 		cont := self.parseStat(&containerInfo)
@@ -210,7 +422,7 @@ func (self *KubeletClient) getAllContainers(url string, start, end time.Time) ([
 			result = append(result, *cont)
 		}
 	}
-	return result, nil
+	return result
 }
 
 func NewKubeletClient(kubeletConfig *kubelet_client.KubeletClientConfig) (*KubeletClient, error) {
@@ -222,8 +434,22 @@ func NewKubeletClient(kubeletConfig *kubelet_client.KubeletClientConfig) (*Kubel
 		Transport: transport,
 		Timeout:   kubeletConfig.HTTPTimeout,
 	}
+	fallbackTransport, err := kubelet_client.MakeTransport(kubeletConfig.ReadOnlyConfig())
+	if err != nil {
+		return nil, err
+	}
+	fallbackClient := &http.Client{
+		Transport: fallbackTransport,
+		Timeout:   kubeletConfig.HTTPTimeout,
+	}
+	customMetrics := make(map[string]bool)
+	for _, name := range kubeletConfig.CustomMetrics {
+		customMetrics[name] = true
+	}
 	return &KubeletClient{
-		config: kubeletConfig,
-		client: c,
+		config:         kubeletConfig,
+		client:         c,
+		fallbackClient: fallbackClient,
+		customMetrics:  customMetrics,
 	}, nil
 }
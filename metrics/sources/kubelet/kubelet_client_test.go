@@ -15,7 +15,14 @@
 package kubelet
 
 import (
+	"io/ioutil"
+	"net"
+	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
 	"testing"
 	"time"
 
@@ -24,6 +31,7 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	util "k8s.io/client-go/util/testing"
+	kubelet_client "k8s.io/heapster/metrics/sources/kubelet/util"
 )
 
 func checkContainer(t *testing.T, expected cadvisor_api.ContainerInfo, actual cadvisor_api.ContainerInfo) {
@@ -33,7 +41,17 @@ func checkContainer(t *testing.T, expected cadvisor_api.ContainerInfo, actual ca
 	}
 }
 
-func TestAllContainers(t *testing.T) {
+func hostFromURL(t *testing.T, rawURL string) Host {
+	parsed, err := url.Parse(rawURL)
+	require.NoError(t, err)
+	host, port, err := net.SplitHostPort(parsed.Host)
+	require.NoError(t, err)
+	portNumber, err := strconv.Atoi(port)
+	require.NoError(t, err)
+	return Host{IP: net.ParseIP(host), Port: portNumber}
+}
+
+func allContainersResponse(t *testing.T) (cadvisor_api.ContainerInfo, cadvisor_api.ContainerInfo, string) {
 	rootContainer := cadvisor_api.ContainerInfo{
 		ContainerReference: cadvisor_api.ContainerReference{
 			Name: "/",
@@ -87,18 +105,206 @@ func TestAllContainers(t *testing.T) {
 	}
 	data, err := jsoniter.ConfigFastest.Marshal(&response)
 	require.NoError(t, err)
+	return rootContainer, subcontainer, string(data)
+}
+
+func TestAllContainers(t *testing.T) {
+	rootContainer, subcontainer, data := allContainersResponse(t)
 	handler := util.FakeHandler{
 		StatusCode:   200,
 		RequestBody:  "",
-		ResponseBody: string(data),
+		ResponseBody: data,
 		T:            t,
 	}
 	server := httptest.NewServer(&handler)
 	defer server.Close()
 	kubeletClient := KubeletClient{}
-	containers, err := kubeletClient.getAllContainers(server.URL, time.Now(), time.Now().Add(time.Minute))
+	containers, err := kubeletClient.GetAllRawContainers(hostFromURL(t, server.URL), time.Now(), time.Now().Add(time.Minute))
 	require.NoError(t, err)
 	require.Len(t, containers, 2)
 	checkContainer(t, rootContainer, containers[0])
 	checkContainer(t, subcontainer, containers[1])
 }
+
+func TestSampleStrategyNumStats(t *testing.T) {
+	_, _, data := allContainersResponse(t)
+	for _, tc := range []struct {
+		strategy string
+		numStats int
+	}{
+		{strategy: "", numStats: 1},
+		{strategy: SampleLast, numStats: 1},
+		{strategy: SampleMax, numStats: -1},
+	} {
+		var request statsRequest
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			body, err := ioutil.ReadAll(req.Body)
+			require.NoError(t, err)
+			require.NoError(t, jsoniter.ConfigFastest.Unmarshal(body, &request))
+			w.Write([]byte(data))
+		}))
+		kubeletClient := KubeletClient{
+			config: &kubelet_client.KubeletClientConfig{SampleStrategy: tc.strategy},
+		}
+		start := time.Now()
+		_, err := kubeletClient.GetAllRawContainers(hostFromURL(t, server.URL), start, start.Add(time.Minute))
+		server.Close()
+		assert.NoError(t, err, tc.strategy)
+		assert.Equal(t, tc.numStats, request.NumStats, tc.strategy)
+		assert.True(t, request.Start.Equal(start), tc.strategy)
+	}
+}
+
+func TestFallbackPort(t *testing.T) {
+	_, _, data := allContainersResponse(t)
+	handler := util.FakeHandler{
+		StatusCode:   200,
+		RequestBody:  "",
+		ResponseBody: data,
+		T:            t,
+	}
+	server := httptest.NewServer(&handler)
+	defer server.Close()
+	fallbackHost := hostFromURL(t, server.URL)
+
+	// Get a port nothing listens on.
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	host := hostFromURL(t, "http://"+listener.Addr().String())
+	listener.Close()
+
+	kubeletClient := KubeletClient{
+		config: &kubelet_client.KubeletClientConfig{
+			Port:         uint(host.Port),
+			ReadOnlyPort: uint(fallbackHost.Port),
+		},
+	}
+	containers, err := kubeletClient.GetAllRawContainers(host, time.Now(), time.Now().Add(time.Minute))
+	require.NoError(t, err)
+	assert.Len(t, containers, 2)
+	assert.True(t, kubeletClient.usesFallbackPort(host))
+
+	// The credentials are not sent to the read-only port, which is plain http.
+	var authorization []string
+	authServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		authorization = append(authorization, req.Header.Get("Authorization"))
+		w.Write([]byte(data))
+	}))
+	defer authServer.Close()
+	client, err := NewKubeletClient(&kubelet_client.KubeletClientConfig{
+		Port:         uint(host.Port),
+		ReadOnlyPort: uint(hostFromURL(t, authServer.URL).Port),
+		EnableHttps:  true,
+		BearerToken:  "secret",
+	})
+	require.NoError(t, err)
+	_, err = client.GetAllRawContainers(host, time.Now(), time.Now().Add(time.Minute))
+	require.NoError(t, err)
+	assert.Equal(t, []string{""}, authorization)
+
+	// Without a fallback port the error is returned.
+	kubeletClient = KubeletClient{
+		config: &kubelet_client.KubeletClientConfig{Port: uint(host.Port)},
+	}
+	_, err = kubeletClient.GetAllRawContainers(host, time.Now(), time.Now().Add(time.Minute))
+	assert.Error(t, err)
+}
+
+func TestUnixSocket(t *testing.T) {
+	_, _, data := allContainersResponse(t)
+	handler := util.FakeHandler{
+		StatusCode:   200,
+		RequestBody:  "",
+		ResponseBody: data,
+		T:            t,
+	}
+	dir, err := ioutil.TempDir("", "kubelet-socket")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	socketPath := filepath.Join(dir, "kubelet.sock")
+	listener, err := net.Listen("unix", socketPath)
+	require.NoError(t, err)
+	server := httptest.NewUnstartedServer(&handler)
+	server.Listener = listener
+	server.Start()
+	defer server.Close()
+
+	kubeletClient, err := NewKubeletClient(&kubelet_client.KubeletClientConfig{
+		Port:           10255,
+		SocketPath:     socketPath,
+		SocketNodeName: "node1",
+	})
+	require.NoError(t, err)
+	// The address of the node is not used.
+	containers, err := kubeletClient.GetAllRawContainers(Host{IP: net.ParseIP("192.0.2.1"), Port: 10255}, time.Now(), time.Now().Add(time.Minute))
+	require.NoError(t, err)
+	assert.Len(t, containers, 2)
+	assert.Equal(t, socketHost, handler.RequestReceived.Host)
+
+	assert.True(t, kubeletClient.ReachesNode("node1"))
+	assert.False(t, kubeletClient.ReachesNode("node2"))
+}
+
+func TestProxy(t *testing.T) {
+	_, _, data := allContainersResponse(t)
+	handler := util.FakeHandler{
+		StatusCode:   200,
+		RequestBody:  "",
+		ResponseBody: data,
+		T:            t,
+	}
+	proxy := httptest.NewServer(&handler)
+	defer proxy.Close()
+	proxyURL, err := url.Parse(proxy.URL)
+	require.NoError(t, err)
+
+	kubeletClient, err := NewKubeletClient(&kubelet_client.KubeletClientConfig{
+		Port:     10255,
+		ProxyURL: proxyURL,
+	})
+	require.NoError(t, err)
+	// The node address is only reachable through the proxy.
+	containers, err := kubeletClient.GetAllRawContainers(Host{IP: net.ParseIP("192.0.2.1"), Port: 10255}, time.Now(), time.Now().Add(time.Minute))
+	require.NoError(t, err)
+	assert.Len(t, containers, 2)
+	assert.Equal(t, "192.0.2.1:10255", handler.RequestReceived.Host)
+}
+
+func TestRetryOnTransientErrors(t *testing.T) {
+	retryBackoff = time.Millisecond
+	_, _, data := allContainersResponse(t)
+	for _, tc := range []struct {
+		status           int
+		failures         int
+		expectedRequests int
+		expectError      bool
+	}{
+		{status: http.StatusInternalServerError, failures: 1, expectedRequests: 2},
+		{status: http.StatusServiceUnavailable, failures: 2, expectedRequests: 3},
+		{status: http.StatusServiceUnavailable, failures: 3, expectedRequests: 3, expectError: true},
+		{status: http.StatusNotFound, failures: 1, expectedRequests: 1, expectError: true},
+		{status: http.StatusUnauthorized, failures: 1, expectedRequests: 1, expectError: true},
+	} {
+		requests := 0
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			requests++
+			if requests <= tc.failures {
+				w.WriteHeader(tc.status)
+				return
+			}
+			w.Write([]byte(data))
+		}))
+		kubeletClient := KubeletClient{
+			config: &kubelet_client.KubeletClientConfig{MaxRetries: 2},
+		}
+		containers, err := kubeletClient.GetAllRawContainers(hostFromURL(t, server.URL), time.Now(), time.Now().Add(time.Minute))
+		server.Close()
+		assert.Equal(t, tc.expectedRequests, requests, "status %d", tc.status)
+		if tc.expectError {
+			assert.Error(t, err, "status %d", tc.status)
+		} else {
+			assert.NoError(t, err, "status %d", tc.status)
+			assert.Len(t, containers, 2)
+		}
+	}
+}
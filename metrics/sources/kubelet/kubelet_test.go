@@ -14,6 +14,7 @@
 package kubelet
 
 import (
+	"errors"
 	"net"
 	"net/http/httptest"
 	"strconv"
@@ -23,12 +24,14 @@ import (
 
 	cadvisor_api "github.com/google/cadvisor/info/v1"
 	jsoniter "github.com/json-iterator/go"
+	dto "github.com/prometheus/client_model/go"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	kube_api "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	util "k8s.io/client-go/util/testing"
 	"k8s.io/heapster/metrics/core"
+	kubelet_client "k8s.io/heapster/metrics/sources/kubelet/util"
 )
 
 func TestDecodeMetrics1(t *testing.T) {
@@ -298,6 +301,219 @@ func TestDecodeMetrics6(t *testing.T) {
 	assert.Equal(t, metricSet.Labels[core.LabelMetricSetType.Key], core.MetricSetTypeNode)
 }
 
+func TestDecodeLabeledCustomMetrics(t *testing.T) {
+	kMS := kubeletMetricsSource{
+		nodename: "test",
+		hostname: "test-hostname",
+	}
+	now := time.Now()
+	c1 := cadvisor_api.ContainerInfo{
+		ContainerReference: cadvisor_api.ContainerReference{
+			Name: "/",
+		},
+		Spec: cadvisor_api.ContainerSpec{
+			CreationTime:     now,
+			HasCustomMetrics: true,
+			CustomMetrics: []cadvisor_api.MetricSpec{
+				{
+					Name:   "requests",
+					Type:   cadvisor_api.MetricCumulative,
+					Format: cadvisor_api.IntType,
+				},
+				{
+					Name:   "ignored",
+					Type:   cadvisor_api.MetricGauge,
+					Format: cadvisor_api.FloatType,
+				},
+			},
+		},
+		Stats: []*cadvisor_api.ContainerStats{
+			{
+				Timestamp: now,
+				CustomMetrics: map[string][]cadvisor_api.MetricVal{
+					"requests": {
+						{Label: "code=200", Timestamp: now.Add(-time.Second), IntValue: 1},
+						{Label: "code=200", Timestamp: now, IntValue: 2},
+						{Label: "code=500", Timestamp: now, IntValue: 3},
+						{Timestamp: now, IntValue: 5},
+					},
+					"ignored": {
+						{Timestamp: now, FloatValue: 1.0},
+					},
+				},
+			},
+		},
+	}
+	kubeletClient := KubeletClient{customMetrics: map[string]bool{"requests": true}}
+	_, metricSet := kMS.decodeMetrics(kubeletClient.parseStat(&c1))
+
+	assert.Equal(t, int64(5), metricSet.MetricValues[core.CustomMetricPrefix+"requests"].IntValue)
+	_, found := metricSet.MetricValues[core.CustomMetricPrefix+"ignored"]
+	assert.False(t, found)
+	values := map[string]int64{}
+	for _, metric := range metricSet.LabeledMetrics {
+		if metric.Name == core.CustomMetricPrefix+"requests" {
+			assert.Equal(t, core.MetricCumulative, metric.MetricType)
+			values[metric.Labels[core.LabelResourceID.Key]] = metric.IntValue
+		}
+	}
+	assert.Equal(t, map[string]int64{"code=200": 2, "code=500": 3}, values)
+}
+
+func TestDecodeMetricsMaxSample(t *testing.T) {
+	kMS := kubeletMetricsSource{
+		nodename: "test",
+		hostname: "test-hostname",
+	}
+	now := time.Now()
+	c1 := cadvisor_api.ContainerInfo{
+		ContainerReference: cadvisor_api.ContainerReference{
+			Name: "/",
+		},
+		Spec: cadvisor_api.ContainerSpec{
+			CreationTime: now.Add(-time.Hour),
+			HasCpu:       true,
+			HasMemory:    true,
+		},
+		Stats: []*cadvisor_api.ContainerStats{
+			{
+				Timestamp: now.Add(-10 * time.Second),
+				Cpu:       cadvisor_api.CpuStats{Usage: cadvisor_api.CpuUsage{Total: 100}},
+				Memory:    cadvisor_api.MemoryStats{Usage: 200},
+			},
+			{
+				Timestamp: now,
+				Cpu:       cadvisor_api.CpuStats{Usage: cadvisor_api.CpuUsage{Total: 150}},
+				Memory:    cadvisor_api.MemoryStats{Usage: 100},
+			},
+		},
+	}
+	kubeletClient := KubeletClient{
+		config: &kubelet_client.KubeletClientConfig{SampleStrategy: SampleMax},
+	}
+	_, metricSet := kMS.decodeMetrics(kubeletClient.parseStat(&c1))
+	assert.Equal(t, now, metricSet.ScrapeTime)
+	assert.Equal(t, int64(200), metricSet.MetricValues[core.MetricMemoryUsage.Name].IntValue)
+	assert.Equal(t, int64(150), metricSet.MetricValues[core.MetricCpuUsage.Name].IntValue)
+}
+
+func TestDecodeAcceleratorMetrics(t *testing.T) {
+	kMS := kubeletMetricsSource{
+		nodename: "test",
+		hostname: "test-hostname",
+	}
+	c1 := cadvisor_api.ContainerInfo{
+		ContainerReference: cadvisor_api.ContainerReference{
+			Name: "/",
+		},
+		Spec: cadvisor_api.ContainerSpec{
+			CreationTime: time.Now(),
+			HasCpu:       true,
+		},
+		Stats: []*cadvisor_api.ContainerStats{
+			{
+				Timestamp: time.Now(),
+				Accelerators: []cadvisor_api.AcceleratorStats{
+					{Make: "nvidia", Model: "tesla-k80", ID: "GPU-1", MemoryTotal: 12000, MemoryUsed: 3000, DutyCycle: 40},
+					{Make: "nvidia", Model: "tesla-k80", ID: "GPU-2", MemoryTotal: 12000, MemoryUsed: 0, DutyCycle: 0},
+				},
+			},
+		},
+	}
+	_, metricSet := kMS.decodeMetrics(&c1)
+
+	values := map[string]int64{}
+	for _, metric := range metricSet.LabeledMetrics {
+		if metric.Labels[core.LabelAcceleratorID.Key] == "GPU-1" {
+			assert.Equal(t, "nvidia", metric.Labels[core.LabelAcceleratorMake.Key])
+			assert.Equal(t, "tesla-k80", metric.Labels[core.LabelAcceleratorModel.Key])
+			values[metric.Name] = metric.IntValue
+		}
+	}
+	assert.Equal(t, map[string]int64{
+		core.MetricAcceleratorMemoryTotal.Name: 12000,
+		core.MetricAcceleratorMemoryUsed.Name:  3000,
+		core.MetricAcceleratorDutyCycle.Name:   40,
+	}, values)
+
+	// Without accelerators, no accelerator metric is emitted.
+	c1.Stats[0].Accelerators = nil
+	_, metricSet = kMS.decodeMetrics(&c1)
+	assert.Empty(t, metricSet.LabeledMetrics)
+}
+
+func TestDecodeNetworkInterfaceMetrics(t *testing.T) {
+	kMS := kubeletMetricsSource{
+		nodename: "test",
+		hostname: "test-hostname",
+	}
+	c1 := cadvisor_api.ContainerInfo{
+		ContainerReference: cadvisor_api.ContainerReference{
+			Name: "/",
+		},
+		Spec: cadvisor_api.ContainerSpec{
+			CreationTime: time.Now(),
+			HasNetwork:   true,
+		},
+		Stats: []*cadvisor_api.ContainerStats{
+			{
+				Timestamp: time.Now(),
+				Network: cadvisor_api.NetworkStats{
+					InterfaceStats: cadvisor_api.InterfaceStats{Name: "eth0", RxBytes: 100, TxBytes: 200},
+					Interfaces: []cadvisor_api.InterfaceStats{
+						{Name: "eth0", RxBytes: 100, TxBytes: 200},
+						{Name: "eth1", RxBytes: 300, TxBytes: 400},
+					},
+				},
+			},
+		},
+	}
+	_, metricSet := kMS.decodeMetrics(&c1)
+
+	values := map[string]int64{}
+	for _, metric := range metricSet.LabeledMetrics {
+		assert.Equal(t, core.MetricCumulative, metric.MetricType)
+		values[metric.Name+":"+metric.Labels[core.LabelResourceID.Key]] = metric.IntValue
+	}
+	assert.Equal(t, map[string]int64{
+		core.MetricNetworkRxByInterface.Name + ":eth0": 100,
+		core.MetricNetworkTxByInterface.Name + ":eth0": 200,
+		core.MetricNetworkRxByInterface.Name + ":eth1": 300,
+		core.MetricNetworkTxByInterface.Name + ":eth1": 400,
+	}, values)
+	// The network metrics without labels stay the totals across the interfaces.
+	assert.Equal(t, int64(400), metricSet.MetricValues[core.MetricNetworkRx.Name].IntValue)
+	assert.Equal(t, int64(600), metricSet.MetricValues[core.MetricNetworkTx.Name].IntValue)
+
+	// Without network stats in the spec, no per-interface metric is emitted.
+	c1.Spec.HasNetwork = false
+	_, metricSet = kMS.decodeMetrics(&c1)
+	assert.Empty(t, metricSet.LabeledMetrics)
+}
+
+func nodeScrapeCount(t *testing.T, node, result string) float64 {
+	metric := &dto.Metric{}
+	require.NoError(t, nodeScrapes.WithLabelValues(node, result).Write(metric))
+	return metric.GetCounter().GetValue()
+}
+
+func TestRecordNodeScrape(t *testing.T) {
+	defer SetLabelScrapesByNode(false)
+
+	successes := nodeScrapeCount(t, "", ScrapeResultSuccess)
+	failures := nodeScrapeCount(t, "", ScrapeResultFailure)
+	RecordNodeScrape("node1", time.Now(), nil)
+	RecordNodeScrape("node2", time.Now(), errors.New("connection refused"))
+	assert.Equal(t, successes+1, nodeScrapeCount(t, "", ScrapeResultSuccess))
+	assert.Equal(t, failures+1, nodeScrapeCount(t, "", ScrapeResultFailure))
+	assert.Equal(t, float64(0), nodeScrapeCount(t, "node1", ScrapeResultSuccess))
+
+	SetLabelScrapesByNode(true)
+	RecordNodeScrape("node1", time.Now(), nil)
+	assert.Equal(t, float64(1), nodeScrapeCount(t, "node1", ScrapeResultSuccess))
+	assert.Equal(t, successes+1, nodeScrapeCount(t, "", ScrapeResultSuccess))
+}
+
 var nodes = []kube_api.Node{
 	{
 		ObjectMeta: metav1.ObjectMeta{
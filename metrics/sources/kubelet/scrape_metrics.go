@@ -0,0 +1,85 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kubelet
+
+import (
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	ScrapeResultSuccess = "success"
+	ScrapeResultFailure = "failure"
+)
+
+var (
+	// Duration of the scrapes of a node's kubelet in seconds.
+	nodeScrapeDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: "heapster",
+			Subsystem: "kubelet",
+			Name:      "node_scrape_duration_seconds",
+			Help:      "Duration of the kubelet scrapes in seconds, by node if enabled.",
+			Buckets:   prometheus.DefBuckets,
+		},
+		[]string{"node"},
+	)
+
+	// Number of the scrapes of a node's kubelet by result.
+	nodeScrapes = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "heapster",
+			Subsystem: "kubelet",
+			Name:      "node_scrapes_total",
+			Help:      "Number of the kubelet scrapes by result, and by node if enabled.",
+		},
+		[]string{"node", "result"},
+	)
+
+	// Set to 1 when the metrics above are labeled with the node name.
+	labelScrapesByNode int32
+)
+
+func init() {
+	prometheus.MustRegister(nodeScrapeDuration)
+	prometheus.MustRegister(nodeScrapes)
+}
+
+// SetLabelScrapesByNode sets whether the node scrape metrics keep a series per node. Without it
+// all nodes are aggregated into the series with an empty node label, which keeps the number
+// of series constant on large clusters.
+func SetLabelScrapesByNode(enabled bool) {
+	var value int32
+	if enabled {
+		value = 1
+	}
+	atomic.StoreInt32(&labelScrapesByNode, value)
+}
+
+// RecordNodeScrape records the duration and the result of a kubelet scrape of the given node
+// started at startTime.
+func RecordNodeScrape(node string, startTime time.Time, err error) {
+	if atomic.LoadInt32(&labelScrapesByNode) == 0 {
+		node = ""
+	}
+	result := ScrapeResultSuccess
+	if err != nil {
+		result = ScrapeResultFailure
+	}
+	nodeScrapeDuration.WithLabelValues(node).Observe(time.Since(startTime).Seconds())
+	nodeScrapes.WithLabelValues(node, result).Inc()
+}
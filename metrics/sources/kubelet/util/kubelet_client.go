@@ -15,7 +15,9 @@
 package client
 
 import (
+	"net"
 	"net/http"
+	"net/url"
 	"time"
 
 	utilnet "k8s.io/apimachinery/pkg/util/net"
@@ -41,8 +43,27 @@ type KubeletClientConfig struct {
 	// HTTPTimeout is used by the client to timeout http requests to Kubelet.
 	HTTPTimeout time.Duration
 
+	// MaxRetries is the number of times requests failing with transient errors are retried.
+	MaxRetries int
+
+	// CustomMetrics is the list of cadvisor custom metrics to import. All are imported if empty.
+	CustomMetrics []string
+
+	// SampleStrategy selects which of the stats samples collected by cadvisor since the last scrape are used.
+	SampleStrategy string
+
 	// Dial is a custom dialer used for the client
 	Dial utilnet.DialFunc
+
+	// SocketPath is the path of a unix socket through which the kubelet of the node named
+	// SocketNodeName is reached, instead of its address. Other nodes are not scraped.
+	SocketPath     string
+	SocketNodeName string
+
+	// ProxyURL is the proxy through which the kubelets are reached, except the hosts matched by
+	// the NO_PROXY environment variable. Without it, the HTTP_PROXY, HTTPS_PROXY and NO_PROXY
+	// environment variables are used.
+	ProxyURL *url.URL
 }
 
 func MakeTransport(config *KubeletClientConfig) (http.RoundTripper, error) {
@@ -51,10 +72,24 @@ func MakeTransport(config *KubeletClientConfig) (http.RoundTripper, error) {
 		return nil, err
 	}
 
+	dial := config.Dial
+	var proxy func(*http.Request) (*url.URL, error)
+	if config.ProxyURL != nil {
+		proxy = proxyFunc(config.ProxyURL)
+	}
+	if config.SocketPath != "" {
+		dial = func(_, _ string) (net.Conn, error) {
+			return net.Dial("unix", config.SocketPath)
+		}
+		// The dialer ignores the address, a proxy would be bypassed anyway.
+		proxy = noProxy
+	}
 	rt := http.DefaultTransport
-	if config.Dial != nil || tlsConfig != nil {
+	if dial != nil || tlsConfig != nil || proxy != nil {
+		// The environment proxy is used if proxy is nil.
 		rt = utilnet.SetOldTransportDefaults(&http.Transport{
-			Dial:            config.Dial,
+			Proxy:           proxy,
+			Dial:            dial,
 			TLSClientConfig: tlsConfig,
 		})
 	}
@@ -62,6 +97,16 @@ func MakeTransport(config *KubeletClientConfig) (http.RoundTripper, error) {
 	return transport.HTTPWrappersForConfig(config.transportConfig(), rt)
 }
 
+// ReadOnlyConfig returns a copy of the config for requests to the read-only port, which is plain
+// http, so that neither the bearer token nor the client certificate are sent there.
+func (c *KubeletClientConfig) ReadOnlyConfig() *KubeletClientConfig {
+	readOnly := *c
+	readOnly.EnableHttps = false
+	readOnly.BearerToken = ""
+	readOnly.TLSClientConfig = restclient.TLSClientConfig{}
+	return &readOnly
+}
+
 // transportConfig converts a client config to an appropriate transport config.
 func (c *KubeletClientConfig) transportConfig() *transport.Config {
 	cfg := &transport.Config{
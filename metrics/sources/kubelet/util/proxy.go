@@ -0,0 +1,95 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// noProxy is the proxy function of transports which must never use a proxy, e.g. the ones
+// dialing a local socket. Unlike a nil function, it isn't replaced by the environment proxy.
+func noProxy(*http.Request) (*url.URL, error) {
+	return nil, nil
+}
+
+// proxyFunc returns a proxy function sending the requests through proxy, except the ones to
+// localhost, loopback addresses and the hosts matched by the NO_PROXY environment variable.
+func proxyFunc(proxy *url.URL) func(*http.Request) (*url.URL, error) {
+	rules := os.Getenv("NO_PROXY")
+	if rules == "" {
+		rules = os.Getenv("no_proxy")
+	}
+	noProxyRules := strings.Split(rules, ",")
+	return func(req *http.Request) (*url.URL, error) {
+		if bypassesProxy(req.URL, noProxyRules) {
+			return nil, nil
+		}
+		return proxy, nil
+	}
+}
+
+// bypassesProxy returns whether requests to the url are sent directly. The rules follow the
+// NO_PROXY format: * for all hosts, an IP address or a CIDR, or a domain name matching itself and
+// its subdomains, optionally with a leading dot. IP addresses and domain names can have a port.
+func bypassesProxy(u *url.URL, rules []string) bool {
+	host := strings.ToLower(u.Hostname())
+	port := u.Port()
+	if port == "" {
+		port = "80"
+		if u.Scheme == "https" {
+			port = "443"
+		}
+	}
+	ip := net.ParseIP(host)
+	if host == "localhost" || (ip != nil && ip.IsLoopback()) {
+		return true
+	}
+	for _, rule := range rules {
+		rule = strings.ToLower(strings.TrimSpace(rule))
+		if rule == "" {
+			continue
+		}
+		if rule == "*" {
+			return true
+		}
+		if _, cidr, err := net.ParseCIDR(rule); err == nil {
+			if ip != nil && cidr.Contains(ip) {
+				return true
+			}
+			continue
+		}
+		if ruleHost, rulePort, err := net.SplitHostPort(rule); err == nil {
+			if rulePort != port {
+				continue
+			}
+			rule = ruleHost
+		}
+		if ruleIP := net.ParseIP(rule); ruleIP != nil {
+			if ip != nil && ruleIP.Equal(ip) {
+				return true
+			}
+			continue
+		}
+		rule = strings.TrimPrefix(rule, ".")
+		if host == rule || strings.HasSuffix(host, "."+rule) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,74 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBypassesProxy(t *testing.T) {
+	rules := strings.Split("10.0.0.0/8, .cluster.local,example.com,192.168.1.5,node1:10250", ",")
+	for _, c := range []struct {
+		url  string
+		want bool
+	}{
+		{"http://localhost:10255/stats", true},
+		{"http://127.0.0.1:10255/stats", true},
+		{"http://10.1.2.3:10255/stats", true},
+		{"http://11.1.2.3:10255/stats", false},
+		{"https://kubernetes.default.svc.cluster.local/api", true},
+		{"https://cluster.local/api", true},
+		{"https://notcluster.local/api", false},
+		{"http://example.com", true},
+		{"http://nodes.example.com", true},
+		{"http://192.168.1.5:10255", true},
+		{"http://192.168.1.6:10255", false},
+		{"https://node1:10250/stats", true},
+		{"http://node1:10255/stats", false},
+	} {
+		u, err := url.Parse(c.url)
+		require.NoError(t, err)
+		assert.Equal(t, c.want, bypassesProxy(u, rules), c.url)
+	}
+	u, _ := url.Parse("http://10.1.2.3:10255")
+	assert.True(t, bypassesProxy(u, []string{"*"}))
+	assert.False(t, bypassesProxy(u, []string{""}))
+}
+
+func TestProxyFunc(t *testing.T) {
+	os.Setenv("NO_PROXY", "10.0.0.0/8")
+	defer os.Unsetenv("NO_PROXY")
+	proxy, err := url.Parse("http://proxy:3128")
+	require.NoError(t, err)
+	proxyFor := proxyFunc(proxy)
+
+	for rawURL, want := range map[string]*url.URL{
+		"http://10.1.2.3:10255/stats": nil,
+		"http://11.1.2.3:10255/stats": proxy,
+	} {
+		req, err := http.NewRequest("GET", rawURL, nil)
+		require.NoError(t, err)
+		got, err := proxyFor(req)
+		assert.NoError(t, err)
+		assert.Equal(t, want, got, rawURL)
+	}
+}
@@ -16,6 +16,8 @@ package sources
 
 import (
 	"math/rand"
+	"sort"
+	"sync"
 	"time"
 
 	. "k8s.io/heapster/metrics/core"
@@ -59,16 +61,64 @@ func init() {
 	prometheus.MustRegister(scraperDuration)
 }
 
+// SourceBackoff describes a source that is not scraped every interval after repeatedly failing.
+type SourceBackoff struct {
+	Source              string
+	ConsecutiveFailures int
+	NextRetry           time.Time
+}
+
+// BackoffSource is a MetricsSource that skips failing sources for a while.
+type BackoffSource interface {
+	MetricsSource
+	// Backoffs returns the sources currently in backoff, sorted by name.
+	Backoffs() []SourceBackoff
+}
+
 func NewSourceManager(metricsSourceProvider MetricsSourceProvider, metricsScrapeTimeout time.Duration) (MetricsSource, error) {
+	return NewSourceManagerWithBackoff(metricsSourceProvider, metricsScrapeTimeout, 0, 0, false, MergeOptions{})
+}
+
+// NewSourceManagerWithBackoff returns a source manager which, once a source failed backoffThreshold
+// consecutive scrapes, skips it for a number of scrape intervals that doubles with every further
+// failure, up to maxBackoff. A successful scrape restores the full scrape cadence. A backoffThreshold
+// of 0 disables the backoff. With emitUpMetrics, the node/up and pod/up metrics of the nodes and
+// pods of every source are set in each batch. Metric sets returned by more than one source are
+// merged with the given options.
+func NewSourceManagerWithBackoff(metricsSourceProvider MetricsSourceProvider, metricsScrapeTimeout time.Duration,
+	backoffThreshold int, maxBackoff time.Duration, emitUpMetrics bool, mergeOptions MergeOptions) (BackoffSource, error) {
 	return &sourceManager{
 		metricsSourceProvider: metricsSourceProvider,
 		metricsScrapeTimeout:  metricsScrapeTimeout,
+		backoffThreshold:      backoffThreshold,
+		maxBackoff:            maxBackoff,
+		emitUpMetrics:         emitUpMetrics,
+		mergeOptions:          mergeOptions,
+		backoffs:              make(map[string]*SourceBackoff),
+		targets:               make(map[string]map[string]*MetricSet),
 	}, nil
 }
 
 type sourceManager struct {
 	metricsSourceProvider MetricsSourceProvider
 	metricsScrapeTimeout  time.Duration
+	backoffThreshold      int
+	maxBackoff            time.Duration
+	emitUpMetrics         bool
+	mergeOptions          MergeOptions
+
+	lock sync.Mutex
+	// Consecutive scrape failures by source name.
+	backoffs map[string]*SourceBackoff
+	// The node and pod metric sets of the last successful scrape of every source, without their
+	// values, by source name and metric set key.
+	targets map[string]map[string]*MetricSet
+}
+
+// sourceResponse is the batch scraped from a source.
+type sourceResponse struct {
+	source string
+	batch  *DataBatch
 }
 
 func (this *sourceManager) Name() string {
@@ -77,9 +127,10 @@ func (this *sourceManager) Name() string {
 
 func (this *sourceManager) ScrapeMetrics(start, end time.Time) (*DataBatch, error) {
 	glog.V(1).Infof("Scraping metrics start: %s, end: %s", start, end)
-	sources := this.metricsSourceProvider.GetMetricsSources()
+	provided := this.metricsSourceProvider.GetMetricsSources()
+	sources := this.skipSourcesInBackoff(provided, end)
 
-	responseChannel := make(chan *DataBatch)
+	responseChannel := make(chan sourceResponse)
 	startTime := time.Now()
 	timeoutTime := startTime.Add(this.metricsScrapeTimeout)
 
@@ -90,7 +141,7 @@ func (this *sourceManager) ScrapeMetrics(start, end time.Time) (*DataBatch, erro
 
 	for _, source := range sources {
 
-		go func(source MetricsSource, channel chan *DataBatch, start, end, timeoutTime time.Time, delayInMs int) {
+		go func(source MetricsSource, channel chan sourceResponse, start, end, timeoutTime time.Time, delayInMs int) {
 
 			// Prevents network congestion.
 			time.Sleep(time.Duration(rand.Intn(delayMs)) * time.Millisecond)
@@ -99,8 +150,10 @@ func (this *sourceManager) ScrapeMetrics(start, end time.Time) (*DataBatch, erro
 			metrics, err := scrape(source, start, end)
 			if err != nil {
 				glog.Errorf("Error in scraping containers from %s: %v", source.Name(), err)
+				this.recordFailure(source.Name(), end, end.Sub(start))
 				return
 			}
+			this.recordSuccess(source.Name())
 
 			now := time.Now()
 			if !now.Before(timeoutTime) {
@@ -110,7 +163,7 @@ func (this *sourceManager) ScrapeMetrics(start, end time.Time) (*DataBatch, erro
 			timeForResponse := timeoutTime.Sub(now)
 
 			select {
-			case channel <- metrics:
+			case channel <- sourceResponse{source: source.Name(), batch: metrics}:
 				// passed the response correctly.
 				return
 			case <-time.After(timeForResponse):
@@ -119,12 +172,10 @@ func (this *sourceManager) ScrapeMetrics(start, end time.Time) (*DataBatch, erro
 			}
 		}(source, responseChannel, start, end, timeoutTime, delayMs)
 	}
-	response := DataBatch{
-		Timestamp:  end,
-		MetricSets: map[string]*MetricSet{},
-	}
-
 	latencies := make([]int, 11)
+	responded := make(map[string]*DataBatch, len(sources))
+	// The batches in the order they were received.
+	batches := make([]*DataBatch, 0, len(sources))
 
 responseloop:
 	for i := range sources {
@@ -135,12 +186,10 @@ responseloop:
 		}
 
 		select {
-		case dataBatch := <-responseChannel:
-			if dataBatch != nil {
-				for key, value := range dataBatch.MetricSets {
-					response.MetricSets[key] = value
-				}
-			}
+		case sourceResponse := <-responseChannel:
+			dataBatch := sourceResponse.batch
+			responded[sourceResponse.source] = dataBatch
+			batches = append(batches, dataBatch)
 			latency := now.Sub(startTime)
 			bucket := int(latency.Seconds())
 			if bucket >= len(latencies) {
@@ -154,11 +203,188 @@ responseloop:
 		}
 	}
 
+	// Conflicting metrics of metric sets returned by more than one source are counted and resolved
+	// by the merge.
+	response, err := MergeDataBatchesWithOptions(this.mergeOptions, batches...)
+	if err != nil {
+		return nil, err
+	}
+	response.Timestamp = end
+
+	if this.emitUpMetrics {
+		this.setUpMetrics(response, provided, responded)
+	}
+
 	glog.V(1).Infof("ScrapeMetrics: time: %s size: %d", time.Since(startTime), len(response.MetricSets))
 	for i, value := range latencies {
 		glog.V(1).Infof("   scrape  bucket %d: %d", i, value)
 	}
-	return &response, nil
+	return response, nil
+}
+
+// setUpMetrics sets the up metrics of the node and pod metric sets of the sources which responded
+// in time to 1. For the other sources, including the ones in backoff, metric sets with an up
+// metric of 0 are added for the nodes and pods of their last successful scrape. Sources which
+// never responded have no known targets.
+func (this *sourceManager) setUpMetrics(response *DataBatch, sources []MetricsSource, responded map[string]*DataBatch) {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+
+	provided := make(map[string]bool, len(sources))
+	for _, source := range sources {
+		name := source.Name()
+		provided[name] = true
+		if dataBatch, found := responded[name]; found {
+			targets := make(map[string]*MetricSet)
+			if dataBatch != nil {
+				for key, ms := range dataBatch.MetricSets {
+					metric := upMetric(ms)
+					if metric == nil {
+						continue
+					}
+					// Metric sets returned by more than one source are merged copies.
+					if merged, found := response.MetricSets[key]; found {
+						ms = merged
+					}
+					if ms.MetricValues == nil {
+						ms.MetricValues = map[string]MetricValue{}
+					}
+					ms.MetricValues[metric.Name] = upValue(1)
+					targets[key] = &MetricSet{
+						CollectionStartTime: ms.CollectionStartTime,
+						EntityCreateTime:    ms.EntityCreateTime,
+						Labels:              copyLabels(ms.Labels),
+					}
+				}
+			}
+			this.targets[name] = targets
+			continue
+		}
+		for key, target := range this.targets[name] {
+			if _, found := response.MetricSets[key]; found {
+				continue
+			}
+			response.MetricSets[key] = &MetricSet{
+				CollectionStartTime: target.CollectionStartTime,
+				EntityCreateTime:    target.EntityCreateTime,
+				ScrapeTime:          response.Timestamp,
+				MetricValues:        map[string]MetricValue{upMetric(target).Name: upValue(0)},
+				Labels:              copyLabels(target.Labels),
+				LabeledMetrics:      []LabeledMetric{},
+			}
+		}
+	}
+	for name := range this.targets {
+		if !provided[name] {
+			delete(this.targets, name)
+		}
+	}
+}
+
+// upMetric returns the up metric of the metric set, or nil if it's neither a node nor a pod.
+func upMetric(ms *MetricSet) *Metric {
+	switch ms.Labels[LabelMetricSetType.Key] {
+	case MetricSetTypeNode:
+		return &MetricNodeUp
+	case MetricSetTypePod:
+		return &MetricPodUp
+	}
+	return nil
+}
+
+func copyLabels(labels map[string]string) map[string]string {
+	result := make(map[string]string, len(labels))
+	for name, value := range labels {
+		result[name] = value
+	}
+	return result
+}
+
+func upValue(value int64) MetricValue {
+	return MetricValue{
+		ValueType:  ValueInt64,
+		MetricType: MetricGauge,
+		IntValue:   value,
+	}
+}
+
+func (this *sourceManager) Backoffs() []SourceBackoff {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+
+	result := make([]SourceBackoff, 0, len(this.backoffs))
+	for _, backoff := range this.backoffs {
+		if !backoff.NextRetry.IsZero() {
+			result = append(result, *backoff)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Source < result[j].Source })
+	return result
+}
+
+// skipSourcesInBackoff returns the sources which should be scraped for the interval ending at end.
+// Failures of sources that are not provided anymore are forgotten.
+func (this *sourceManager) skipSourcesInBackoff(sources []MetricsSource, end time.Time) []MetricsSource {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+
+	provided := make(map[string]bool, len(sources))
+	result := make([]MetricsSource, 0, len(sources))
+	for _, source := range sources {
+		name := source.Name()
+		provided[name] = true
+		if backoff, found := this.backoffs[name]; found && end.Before(backoff.NextRetry) {
+			glog.V(2).Infof("Skipping source %s in backoff until %s", name, backoff.NextRetry)
+			continue
+		}
+		result = append(result, source)
+	}
+	for name := range this.backoffs {
+		if !provided[name] {
+			delete(this.backoffs, name)
+		}
+	}
+	return result
+}
+
+func (this *sourceManager) recordFailure(name string, end time.Time, interval time.Duration) {
+	if this.backoffThreshold <= 0 {
+		return
+	}
+	this.lock.Lock()
+	defer this.lock.Unlock()
+
+	backoff, found := this.backoffs[name]
+	if !found {
+		backoff = &SourceBackoff{Source: name}
+		this.backoffs[name] = backoff
+	}
+	backoff.ConsecutiveFailures++
+	if backoff.ConsecutiveFailures < this.backoffThreshold {
+		return
+	}
+	// Skip one interval after reaching the threshold, then twice as many after every further failure.
+	delay := 2 * interval
+	for i := this.backoffThreshold; i < backoff.ConsecutiveFailures && delay < this.maxBackoff; i++ {
+		delay *= 2
+	}
+	if delay > this.maxBackoff {
+		delay = this.maxBackoff
+	}
+	backoff.NextRetry = end.Add(delay)
+	glog.Warningf("Source %s failed %d consecutive scrapes, next retry at %s", name, backoff.ConsecutiveFailures, backoff.NextRetry)
+}
+
+func (this *sourceManager) recordSuccess(name string) {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+
+	if backoff, found := this.backoffs[name]; found {
+		if !backoff.NextRetry.IsZero() {
+			glog.Infof("Source %s scraped successfully after %d failures, leaving backoff", name, backoff.ConsecutiveFailures)
+		}
+		delete(this.backoffs, name)
+	}
 }
 
 func scrape(s MetricsSource, start, end time.Time) (*DataBatch, error) {
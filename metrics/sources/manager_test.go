@@ -15,9 +15,15 @@
 package sources
 
 import (
+	"errors"
+	"net/url"
+	"sync"
 	"testing"
 	"time"
 
+	"github.com/stretchr/testify/assert"
+
+	"k8s.io/heapster/metrics/core"
 	"k8s.io/heapster/metrics/util"
 )
 
@@ -124,3 +130,224 @@ func TestNoSourcesReplyInTime(t *testing.T) {
 		t.Fatal("s2 found")
 	}
 }
+
+type failingMetricsSource struct {
+	name string
+	// When set, successful scrapes return the metric sets of this node and of a pod on it.
+	node string
+
+	lock    sync.Mutex
+	fail    bool
+	scrapes int
+}
+
+func (this *failingMetricsSource) Name() string {
+	return this.name
+}
+
+func (this *failingMetricsSource) ScrapeMetrics(start, end time.Time) (*core.DataBatch, error) {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+	this.scrapes++
+	if this.fail {
+		return nil, errors.New("scrape failed")
+	}
+	metricSets := map[string]*core.MetricSet{}
+	if this.node != "" {
+		metricSets[core.NodeKey(this.node)] = &core.MetricSet{
+			ScrapeTime:   end,
+			MetricValues: map[string]core.MetricValue{},
+			Labels: map[string]string{
+				core.LabelMetricSetType.Key: core.MetricSetTypeNode,
+				core.LabelNodename.Key:      this.node,
+			},
+		}
+		metricSets[core.PodKey("ns1", "pod1")] = &core.MetricSet{
+			ScrapeTime:   end,
+			MetricValues: map[string]core.MetricValue{},
+			Labels: map[string]string{
+				core.LabelMetricSetType.Key: core.MetricSetTypePod,
+				core.LabelNodename.Key:      this.node,
+				core.LabelNamespaceName.Key: "ns1",
+				core.LabelPodName.Key:       "pod1",
+			},
+		}
+		metricSets[core.PodContainerKey("ns1", "pod1", "c")] = &core.MetricSet{
+			ScrapeTime:   end,
+			MetricValues: map[string]core.MetricValue{},
+			Labels: map[string]string{
+				core.LabelMetricSetType.Key: core.MetricSetTypePodContainer,
+			},
+		}
+	}
+	return &core.DataBatch{Timestamp: end, MetricSets: metricSets}, nil
+}
+
+func (this *failingMetricsSource) scrapeCount() int {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+	return this.scrapes
+}
+
+func TestScrapeBackoff(t *testing.T) {
+	source := &failingMetricsSource{name: "kubelet:10.0.0.1:10255", fail: true}
+	manager, _ := NewSourceManagerWithBackoff(util.NewDummyMetricsSourceProvider(source), 100*time.Millisecond, 2, 40*time.Second, false, core.MergeOptions{})
+	end := time.Now().Truncate(10 * time.Second)
+	scrapeAt := func(intervals int) {
+		intervalEnd := end.Add(time.Duration(intervals) * 10 * time.Second)
+		manager.ScrapeMetrics(intervalEnd.Add(-10*time.Second), intervalEnd)
+	}
+
+	// The first failure does not reach the threshold.
+	scrapeAt(0)
+	assert.Equal(t, 1, source.scrapeCount())
+	assert.Empty(t, manager.Backoffs())
+
+	// The second one skips the next interval.
+	scrapeAt(1)
+	assert.Equal(t, 2, source.scrapeCount())
+	assert.Equal(t, []SourceBackoff{{Source: source.name, ConsecutiveFailures: 2, NextRetry: end.Add(30 * time.Second)}}, manager.Backoffs())
+	scrapeAt(2)
+	assert.Equal(t, 2, source.scrapeCount())
+
+	// Further failures double the backoff up to the maximum.
+	scrapeAt(3)
+	assert.Equal(t, 3, source.scrapeCount())
+	assert.Equal(t, end.Add(70*time.Second), manager.Backoffs()[0].NextRetry)
+	scrapeAt(7)
+	assert.Equal(t, 4, source.scrapeCount())
+	assert.Equal(t, end.Add(110*time.Second), manager.Backoffs()[0].NextRetry)
+
+	// A successful scrape restores the full cadence.
+	source.lock.Lock()
+	source.fail = false
+	source.lock.Unlock()
+	scrapeAt(11)
+	assert.Equal(t, 5, source.scrapeCount())
+	assert.Empty(t, manager.Backoffs())
+	scrapeAt(12)
+	assert.Equal(t, 6, source.scrapeCount())
+}
+
+func TestUpMetrics(t *testing.T) {
+	source := &failingMetricsSource{name: "kubelet:10.0.0.1:10255", node: "node1", fail: true}
+	manager, _ := NewSourceManagerWithBackoff(util.NewDummyMetricsSourceProvider(source), time.Second, 0, 0, true, core.MergeOptions{})
+	end := time.Now().Truncate(10 * time.Second)
+	setFail := func(fail bool) {
+		source.lock.Lock()
+		source.fail = fail
+		source.lock.Unlock()
+	}
+	nodeKey := core.NodeKey("node1")
+	podKey := core.PodKey("ns1", "pod1")
+
+	// The targets of a source which never succeeded are unknown.
+	batch, err := manager.ScrapeMetrics(end.Add(-10*time.Second), end)
+	assert.NoError(t, err)
+	assert.Empty(t, batch.MetricSets)
+
+	setFail(false)
+	batch, _ = manager.ScrapeMetrics(end, end.Add(10*time.Second))
+	assert.Equal(t, int64(1), batch.MetricSets[nodeKey].MetricValues[core.MetricNodeUp.Name].IntValue)
+	assert.Equal(t, int64(1), batch.MetricSets[podKey].MetricValues[core.MetricPodUp.Name].IntValue)
+	assert.Empty(t, batch.MetricSets[core.PodContainerKey("ns1", "pod1", "c")].MetricValues)
+
+	setFail(true)
+	batch, _ = manager.ScrapeMetrics(end.Add(10*time.Second), end.Add(20*time.Second))
+	assert.Len(t, batch.MetricSets, 2)
+	node := batch.MetricSets[nodeKey]
+	if assert.NotNil(t, node) {
+		assert.Equal(t, map[string]core.MetricValue{core.MetricNodeUp.Name: upValue(0)}, node.MetricValues)
+		assert.Equal(t, "node1", node.Labels[core.LabelNodename.Key])
+		assert.Equal(t, end.Add(20*time.Second), node.ScrapeTime)
+	}
+	pod := batch.MetricSets[podKey]
+	if assert.NotNil(t, pod) {
+		assert.Equal(t, map[string]core.MetricValue{core.MetricPodUp.Name: upValue(0)}, pod.MetricValues)
+		assert.Equal(t, core.MetricSetTypePod, pod.Labels[core.LabelMetricSetType.Key])
+	}
+}
+
+func TestStaticLabels(t *testing.T) {
+	uri, err := url.Parse("?labels=source=cluster-a,name=s2")
+	assert.NoError(t, err)
+	labels, err := ParseStaticLabels(uri)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"source": "cluster-a", "name": "s2"}, labels)
+
+	provider := NewStaticLabelsProvider(util.NewDummyMetricsSourceProvider(
+		util.NewDummyMetricsSource("s1", 0)), labels)
+	sources := provider.GetMetricsSources()
+	assert.Equal(t, 1, len(sources))
+	assert.Equal(t, "dummy", sources[0].Name())
+
+	now := time.Now()
+	dataBatch, err := sources[0].ScrapeMetrics(now.Add(-time.Minute), now)
+	assert.NoError(t, err)
+	ms := dataBatch.MetricSets["s1"]
+	assert.Equal(t, "cluster-a", ms.Labels["source"])
+	// Labels set by the source are kept.
+	assert.Equal(t, "s1", ms.Labels["name"])
+}
+
+func TestParseStaticLabelsErrors(t *testing.T) {
+	for _, query := range []string{"?labels=source", "?labels==a", "?labels=type=pod", "?labels=source=a,nodename=n1"} {
+		uri, err := url.Parse(query)
+		assert.NoError(t, err)
+		_, err = ParseStaticLabels(uri)
+		assert.Error(t, err, query)
+	}
+	for _, label := range core.AllLabels() {
+		uri, err := url.Parse("?labels=" + label.Key + "=a")
+		assert.NoError(t, err)
+		_, err = ParseStaticLabels(uri)
+		assert.Error(t, err, label.Key)
+	}
+}
+
+type staticMetricsSource struct {
+	name  string
+	value int64
+}
+
+func (this *staticMetricsSource) Name() string {
+	return this.name
+}
+
+func (this *staticMetricsSource) ScrapeMetrics(start, end time.Time) (*core.DataBatch, error) {
+	return &core.DataBatch{
+		Timestamp: end,
+		MetricSets: map[string]*core.MetricSet{
+			core.PodKey("ns1", "pod1"): {
+				MetricValues: map[string]core.MetricValue{
+					core.MetricCpuUsage.Name:    {ValueType: core.ValueInt64, MetricType: core.MetricCumulative, IntValue: this.value},
+					core.MetricMemoryUsage.Name: {ValueType: core.ValueInt64, MetricType: core.MetricGauge, IntValue: this.value},
+				},
+				Labels: map[string]string{core.LabelMetricSetType.Key: core.MetricSetTypePod},
+			},
+		},
+	}, nil
+}
+
+func TestScrapeMergeConflicts(t *testing.T) {
+	provider := util.NewDummyMetricsSourceProvider(
+		&staticMetricsSource{name: "s1", value: 1},
+		&staticMetricsSource{name: "s2", value: 2})
+	end := time.Now().Truncate(10 * time.Second)
+
+	manager, _ := NewSourceManagerWithBackoff(provider, time.Second, 0, 0, false, core.MergeOptions{
+		Policy:         core.MergeConflictPreferLast,
+		MetricPolicies: map[string]core.MergeConflictPolicy{core.MetricCpuUsage.Name: core.MergeConflictSum},
+	})
+	batch, err := manager.ScrapeMetrics(end.Add(-10*time.Second), end)
+	assert.NoError(t, err)
+	assert.Equal(t, end, batch.Timestamp)
+	pod := batch.MetricSets[core.PodKey("ns1", "pod1")]
+	assert.Equal(t, int64(3), pod.MetricValues[core.MetricCpuUsage.Name].IntValue)
+	// Either source may respond last.
+	assert.Contains(t, []int64{1, 2}, pod.MetricValues[core.MetricMemoryUsage.Name].IntValue)
+
+	manager, _ = NewSourceManagerWithBackoff(provider, time.Second, 0, 0, false, core.MergeOptions{Policy: core.MergeConflictError})
+	_, err = manager.ScrapeMetrics(end.Add(-10*time.Second), end)
+	assert.Error(t, err)
+}
@@ -0,0 +1,117 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sources
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"k8s.io/heapster/metrics/core"
+)
+
+// Labels set by the sources and the processors, which static labels can't replace.
+var reservedLabels = buildReservedLabels()
+
+func buildReservedLabels() map[string]bool {
+	result := make(map[string]bool)
+	for _, label := range core.AllLabels() {
+		result[label.Key] = true
+	}
+	return result
+}
+
+// ParseStaticLabels returns the labels given in the labels option of a source uri, as a comma
+// separated list of name=value pairs, e.g. ?labels=source=cluster-a,region=eu.
+func ParseStaticLabels(uri *url.URL) (map[string]string, error) {
+	result := make(map[string]string)
+	for _, labels := range uri.Query()["labels"] {
+		for _, label := range strings.Split(labels, ",") {
+			if label = strings.TrimSpace(label); label == "" {
+				continue
+			}
+			nameValue := strings.SplitN(label, "=", 2)
+			if len(nameValue) != 2 || nameValue[0] == "" {
+				return nil, fmt.Errorf("invalid static label %q, expected name=value", label)
+			}
+			if reservedLabels[nameValue[0]] {
+				return nil, fmt.Errorf("static label %q would override a label set by Heapster", nameValue[0])
+			}
+			result[nameValue[0]] = nameValue[1]
+		}
+	}
+	return result, nil
+}
+
+// staticLabelsProvider adds the same labels to the metric sets of all the sources of a provider.
+type staticLabelsProvider struct {
+	provider core.MetricsSourceProvider
+	labels   map[string]string
+}
+
+func (this *staticLabelsProvider) HasSynced() bool {
+	syncer, ok := this.provider.(core.CacheSyncer)
+	return !ok || syncer.HasSynced()
+}
+
+func (this *staticLabelsProvider) GetMetricsSources() []core.MetricsSource {
+	sources := this.provider.GetMetricsSources()
+	result := make([]core.MetricsSource, 0, len(sources))
+	for _, source := range sources {
+		result = append(result, &staticLabelsSource{source: source, labels: this.labels})
+	}
+	return result
+}
+
+type staticLabelsSource struct {
+	source core.MetricsSource
+	labels map[string]string
+}
+
+func (this *staticLabelsSource) Name() string {
+	return this.source.Name()
+}
+
+func (this *staticLabelsSource) ScrapeMetrics(start, end time.Time) (*core.DataBatch, error) {
+	batch, err := this.source.ScrapeMetrics(start, end)
+	if batch == nil {
+		return batch, err
+	}
+	for _, metricSet := range batch.MetricSets {
+		if metricSet.Labels == nil {
+			metricSet.Labels = make(map[string]string, len(this.labels))
+		}
+		for name, value := range this.labels {
+			// Never replace a label set by the source itself.
+			if _, found := metricSet.Labels[name]; !found {
+				metricSet.Labels[name] = value
+			}
+		}
+	}
+	return batch, err
+}
+
+// NewStaticLabelsProvider wraps the provider so that its sources add the given labels to every
+// metric set they produce. It returns the provider unchanged if there are no labels.
+func NewStaticLabelsProvider(provider core.MetricsSourceProvider, labels map[string]string) core.MetricsSourceProvider {
+	if len(labels) == 0 {
+		return provider
+	}
+	return &staticLabelsProvider{
+		provider: provider,
+		labels:   labels,
+	}
+}
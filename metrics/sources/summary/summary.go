@@ -89,10 +89,10 @@ func (this *summaryMetricsSource) ScrapeMetrics(start, end time.Time) (*DataBatc
 
 	summary, err := func() (*stats.Summary, error) {
 		startTime := time.Now()
-		defer func() {
-			summaryRequestLatency.WithLabelValues(this.node.HostName).Observe(float64(time.Since(startTime)) / float64(time.Millisecond))
-		}()
-		return this.kubeletClient.GetSummary(this.node.Host)
+		summary, err := this.kubeletClient.GetSummary(this.node.Host)
+		summaryRequestLatency.WithLabelValues(this.node.HostName).Observe(float64(time.Since(startTime)) / float64(time.Millisecond))
+		kubelet.RecordNodeScrape(this.node.NodeName, startTime, err)
+		return summary, err
 	}()
 
 	if err != nil {
@@ -313,6 +313,11 @@ func (this *summaryMetricsSource) decodeNetworkStats(metrics *MetricSet, network
 	this.addIntMetric(metrics, &MetricNetworkRxErrors, network.RxErrors)
 	this.addIntMetric(metrics, &MetricNetworkTx, network.TxBytes)
 	this.addIntMetric(metrics, &MetricNetworkTxErrors, network.TxErrors)
+	for _, interfaceStats := range network.Interfaces {
+		interfaceLabels := map[string]string{LabelResourceID.Key: interfaceStats.Name}
+		this.addLabeledIntMetric(metrics, &MetricNetworkRxByInterface, interfaceLabels, interfaceStats.RxBytes)
+		this.addLabeledIntMetric(metrics, &MetricNetworkTxByInterface, interfaceLabels, interfaceStats.TxBytes)
+	}
 }
 
 func (this *summaryMetricsSource) decodeFsStats(metrics *MetricSet, fsKey string, fs *stats.FsStats) {
@@ -368,6 +373,9 @@ func (this *summaryMetricsSource) getScrapeTime(cpu *stats.CPUStats, memory *sta
 
 // addIntMetric is a convenience method for adding the metric and value to the metric set.
 func (this *summaryMetricsSource) addIntMetric(metrics *MetricSet, metric *Metric, value *uint64) {
+	if !IsCollected(metric) {
+		return
+	}
 	if value == nil {
 		glog.V(9).Infof("skipping metric %s because the value was nil", metric.Name)
 		return
@@ -382,6 +390,9 @@ func (this *summaryMetricsSource) addIntMetric(metrics *MetricSet, metric *Metri
 
 // addLabeledIntMetric is a convenience method for adding the labeled metric and value to the metric set.
 func (this *summaryMetricsSource) addLabeledIntMetric(metrics *MetricSet, metric *Metric, labels map[string]string, value *uint64) {
+	if !IsCollected(metric) {
+		return
+	}
 	if value == nil {
 		glog.V(9).Infof("skipping labeled metric %s (%v) because the value was nil", metric.Name, labels)
 		return
@@ -415,6 +426,10 @@ type summaryProvider struct {
 	hostIDAnnotation string
 }
 
+func (this *summaryProvider) HasSynced() bool {
+	return this.reflector == nil || this.reflector.LastSyncResourceVersion() != ""
+}
+
 func (this *summaryProvider) GetMetricsSources() []MetricsSource {
 	sources := []MetricsSource{}
 	nodes, err := this.nodeLister.List(labels.Everything())
@@ -424,6 +439,9 @@ func (this *summaryProvider) GetMetricsSources() []MetricsSource {
 	}
 
 	for _, node := range nodes {
+		if !this.kubeletClient.ReachesNode(node.Name) {
+			continue
+		}
 		info, err := this.getNodeInfo(node)
 		if err != nil {
 			glog.Errorf("%v", err)
@@ -472,6 +490,11 @@ func NewSummaryProvider(uri *url.URL) (MetricsSourceProvider, error) {
 	if err != nil {
 		return nil, err
 	}
+	// The summary API returns only the latest stats sample, so there are no samples between
+	// scrapes to pick from.
+	if kubeletConfig.SampleStrategy != kubelet.SampleLast {
+		return nil, fmt.Errorf("kubelet sample strategy %q is not supported by the summary source, which only gets the latest sample, use the kubernetes source", kubeletConfig.SampleStrategy)
+	}
 	kubeClient := kube_client.NewForConfigOrDie(kubeConfig)
 	kubeletClient, err := kubelet.NewKubeletClient(kubeletConfig)
 	if err != nil {
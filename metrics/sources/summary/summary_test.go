@@ -18,6 +18,7 @@ import (
 	"encoding/json"
 	"net"
 	"net/http/httptest"
+	"net/url"
 	"strconv"
 	"strings"
 	"testing"
@@ -386,6 +387,8 @@ func TestDecodeSummaryMetrics(t *testing.T) {
 			checkIntMetric(t, m, e.key, core.MetricNetworkRxErrors, e.seed+offsetNetRxErrors)
 			checkIntMetric(t, m, e.key, core.MetricNetworkTx, e.seed+offsetNetTxBytes)
 			checkIntMetric(t, m, e.key, core.MetricNetworkTxErrors, e.seed+offsetNetTxErrors)
+			checkFsMetric(t, m, e.key, "eth0", core.MetricNetworkRxByInterface, e.seed+offsetNetRxBytes)
+			checkFsMetric(t, m, e.key, "eth0", core.MetricNetworkTxByInterface, e.seed+offsetNetTxBytes)
 		}
 		if e.accelerators {
 			checkAcceleratorMetric(t, m, e.key, core.MetricAcceleratorMemoryTotal, e.seed+offsetAcceleratorMemoryTotal)
@@ -548,6 +551,11 @@ func genTestSummaryNetwork(seed int) *stats.NetworkStats {
 			TxBytes:  uint64Val(seed, offsetNetTxBytes),
 			TxErrors: uint64Val(seed, offsetNetTxErrors),
 		},
+		Interfaces: []stats.InterfaceStats{{
+			Name:    "eth0",
+			RxBytes: uint64Val(seed, offsetNetRxBytes),
+			TxBytes: uint64Val(seed, offsetNetTxBytes),
+		}},
 	}
 }
 
@@ -620,3 +628,11 @@ func TestScrapeSummaryMetrics(t *testing.T) {
 	assert.Nil(t, err, "scrape error")
 	assert.Equal(t, res.MetricSets["node:test"].Labels[core.LabelMetricSetType.Key], core.MetricSetTypeNode)
 }
+
+func TestSummaryProviderRejectsSampleStrategy(t *testing.T) {
+	uri, err := url.Parse("https://kubernetes.default?inClusterConfig=false&kubeletSampleStrategy=max")
+	require.NoError(t, err)
+	_, err = NewSummaryProvider(uri)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "sample strategy")
+}
@@ -77,6 +77,10 @@ func (m *MetricStorage) List(ctx genericapirequest.Context, options *metainterna
 	if options != nil && options.LabelSelector != nil {
 		labelSelector = options.LabelSelector
 	}
+	batch, err := util.GetLatestDataBatch(m.metricSink)
+	if err != nil {
+		return &metrics.NodeMetricsList{}, err
+	}
 	nodes, err := m.nodeLister.ListWithPredicate(func(node *v1.Node) bool {
 		if labelSelector.Empty() {
 			return true
@@ -91,7 +95,7 @@ func (m *MetricStorage) List(ctx genericapirequest.Context, options *metainterna
 
 	res := metrics.NodeMetricsList{}
 	for _, node := range nodes {
-		if m := m.getNodeMetrics(node.Name); m != nil {
+		if m := m.getNodeMetrics(batch, node.Name); m != nil {
 			res.Items = append(res.Items, *m)
 		}
 	}
@@ -101,19 +105,18 @@ func (m *MetricStorage) List(ctx genericapirequest.Context, options *metainterna
 // Getter interface
 func (m *MetricStorage) Get(ctx genericapirequest.Context, name string, opts *metav1.GetOptions) (runtime.Object, error) {
 	// TODO: pay attention to get options
-	nodeMetrics := m.getNodeMetrics(name)
+	batch, err := util.GetLatestDataBatch(m.metricSink)
+	if err != nil {
+		return &metrics.NodeMetrics{}, err
+	}
+	nodeMetrics := m.getNodeMetrics(batch, name)
 	if nodeMetrics == nil {
 		return &metrics.NodeMetrics{}, errors.NewNotFound(m.groupResource, name)
 	}
 	return nodeMetrics, nil
 }
 
-func (m *MetricStorage) getNodeMetrics(node string) *metrics.NodeMetrics {
-	batch := m.metricSink.GetLatestDataBatch()
-	if batch == nil {
-		return nil
-	}
-
+func (m *MetricStorage) getNodeMetrics(batch *core.DataBatch, node string) *metrics.NodeMetrics {
 	ms, found := batch.MetricSets[core.NodeKey(node)]
 	if !found {
 		return nil
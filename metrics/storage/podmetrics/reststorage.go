@@ -77,6 +77,10 @@ func (m *MetricStorage) List(ctx genericapirequest.Context, options *metainterna
 	if options != nil && options.LabelSelector != nil {
 		labelSelector = options.LabelSelector
 	}
+	batch, err := util.GetLatestDataBatch(m.metricSink)
+	if err != nil {
+		return &metrics.PodMetricsList{}, err
+	}
 	namespace := genericapirequest.NamespaceValue(ctx)
 	pods, err := m.podLister.Pods(namespace).List(labelSelector)
 	if err != nil {
@@ -87,7 +91,7 @@ func (m *MetricStorage) List(ctx genericapirequest.Context, options *metainterna
 
 	res := metrics.PodMetricsList{}
 	for _, pod := range pods {
-		if podMetrics := m.getPodMetrics(pod); podMetrics != nil {
+		if podMetrics := m.getPodMetrics(batch, pod); podMetrics != nil {
 			res.Items = append(res.Items, *podMetrics)
 		} else {
 			glog.Infof("No metrics for pod %s/%s", pod.Namespace, pod.Name)
@@ -110,19 +114,18 @@ func (m *MetricStorage) Get(ctx genericapirequest.Context, name string, opts *me
 		return &metrics.PodMetrics{}, errors.NewNotFound(v1.Resource("Pod"), fmt.Sprintf("%v/%v", namespace, name))
 	}
 
-	podMetrics := m.getPodMetrics(pod)
+	batch, err := util.GetLatestDataBatch(m.metricSink)
+	if err != nil {
+		return &metrics.PodMetrics{}, err
+	}
+	podMetrics := m.getPodMetrics(batch, pod)
 	if podMetrics == nil {
 		return &metrics.PodMetrics{}, errors.NewNotFound(m.groupResource, fmt.Sprintf("%v/%v", namespace, name))
 	}
 	return podMetrics, nil
 }
 
-func (m *MetricStorage) getPodMetrics(pod *v1.Pod) *metrics.PodMetrics {
-	batch := m.metricSink.GetLatestDataBatch()
-	if batch == nil {
-		return nil
-	}
-
+func (m *MetricStorage) getPodMetrics(batch *core.DataBatch, pod *v1.Pod) *metrics.PodMetrics {
 	res := &metrics.PodMetrics{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:              pod.Name,
@@ -18,11 +18,23 @@ import (
 	"fmt"
 
 	"k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/api/resource"
 	"k8s.io/heapster/metrics/core"
+	metricsink "k8s.io/heapster/metrics/sinks/metric"
 	"k8s.io/metrics/pkg/apis/metrics"
 )
 
+// GetLatestDataBatch returns the latest batch stored in the metric sink, or a ServiceUnavailable
+// error if the model is not activated yet, i.e. no data has been collected since the start.
+func GetLatestDataBatch(metricSink *metricsink.MetricSink) (*core.DataBatch, error) {
+	batch := metricSink.GetLatestDataBatch()
+	if batch == nil {
+		return nil, errors.NewServiceUnavailable("the model is not activated yet, no metrics have been collected")
+	}
+	return batch, nil
+}
+
 func ParseResourceList(ms *core.MetricSet) (metrics.ResourceList, error) {
 	cpu, found := ms.MetricValues[core.MetricCpuUsageRate.MetricDescriptor.Name]
 	if !found {
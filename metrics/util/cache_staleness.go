@@ -0,0 +1,190 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package util
+
+import (
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/tools/cache"
+)
+
+// cacheStaleness tracks since when the caches of a resource kind are not known to be up to date.
+// The caches keep their last content while the Kubernetes API is unreachable, so the enrichers
+// keep labeling metrics, with labels that get stale over time.
+type cacheStaleness struct {
+	lock sync.Mutex
+	// Number of the watches currently open.
+	watching int
+	// Last time a cache was known to be up to date, when no watch is open.
+	lastCurrent time.Time
+}
+
+func (this *cacheStaleness) staleness(now time.Time) time.Duration {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+
+	if this.watching > 0 {
+		return 0
+	}
+	return now.Sub(this.lastCurrent)
+}
+
+func (this *cacheStaleness) listed() {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+	this.lastCurrent = time.Now()
+}
+
+func (this *cacheStaleness) watchStarted() {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+	this.watching++
+}
+
+func (this *cacheStaleness) watchEnded() {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+	this.watching--
+	this.lastCurrent = time.Now()
+}
+
+var (
+	cacheStalenessLock sync.Mutex
+	// Staleness of the caches by resource, shared by all the caches of the same resource.
+	cacheStalenessByResource = make(map[string]*cacheStaleness)
+)
+
+func getCacheStaleness(resource string) *cacheStaleness {
+	cacheStalenessLock.Lock()
+	defer cacheStalenessLock.Unlock()
+
+	if staleness, found := cacheStalenessByResource[resource]; found {
+		return staleness
+	}
+	staleness := &cacheStaleness{lastCurrent: time.Now()}
+	cacheStalenessByResource[resource] = staleness
+	prometheus.MustRegister(prometheus.NewGaugeFunc(
+		prometheus.GaugeOpts{
+			Namespace:   "heapster",
+			Subsystem:   "kubernetes_cache",
+			Name:        "staleness_seconds",
+			Help:        "Time since the cache of Kubernetes objects was last known to be up to date, 0 while it is watching the API server.",
+			ConstLabels: prometheus.Labels{"resource": resource},
+		},
+		func() float64 { return staleness.staleness(time.Now()).Seconds() },
+	))
+	return staleness
+}
+
+// stalenessTrackingListWatch records the successful lists and the open watches of a ListerWatcher.
+type stalenessTrackingListWatch struct {
+	lw        cache.ListerWatcher
+	staleness *cacheStaleness
+}
+
+func (this *stalenessTrackingListWatch) List(options metav1.ListOptions) (runtime.Object, error) {
+	result, err := this.lw.List(options)
+	if err == nil {
+		this.staleness.listed()
+	}
+	return result, err
+}
+
+func (this *stalenessTrackingListWatch) Watch(options metav1.ListOptions) (watch.Interface, error) {
+	w, err := this.lw.Watch(options)
+	if err != nil {
+		return nil, err
+	}
+	this.staleness.watchStarted()
+	tw := &stalenessTrackingWatch{
+		watch:     w,
+		staleness: this.staleness,
+		result:    make(chan watch.Event),
+		stopped:   make(chan struct{}),
+	}
+	go tw.loop()
+	return tw, nil
+}
+
+// stalenessTrackingWatch passes on the events of a watch and records when it ends.
+type stalenessTrackingWatch struct {
+	watch     watch.Interface
+	staleness *cacheStaleness
+	result    chan watch.Event
+	stopOnce  sync.Once
+	stopped   chan struct{}
+}
+
+func (this *stalenessTrackingWatch) ResultChan() <-chan watch.Event {
+	return this.result
+}
+
+func (this *stalenessTrackingWatch) Stop() {
+	this.stopOnce.Do(func() { close(this.stopped) })
+	this.watch.Stop()
+}
+
+func (this *stalenessTrackingWatch) loop() {
+	defer close(this.result)
+	defer this.staleness.watchEnded()
+	for {
+		select {
+		case event, ok := <-this.watch.ResultChan():
+			if !ok {
+				return
+			}
+			select {
+			case this.result <- event:
+			case <-this.stopped:
+				return
+			}
+		case <-this.stopped:
+			return
+		}
+	}
+}
+
+// NewStalenessTrackingListWatch wraps the ListerWatcher of a cache of the given resource, e.g. pods,
+// to export the heapster_kubernetes_cache_staleness_seconds metric for it.
+func NewStalenessTrackingListWatch(resource string, lw cache.ListerWatcher) cache.ListerWatcher {
+	return &stalenessTrackingListWatch{
+		lw:        lw,
+		staleness: getCacheStaleness(resource),
+	}
+}
+
+// RegisterCacheSizeMetric exports the number of objects in the cache of the given resource as the
+// heapster_kubernetes_cache_objects metric. It should be called once per resource.
+func RegisterCacheSizeMetric(resource string, store cache.Store) {
+	prometheus.MustRegister(newCacheSizeMetric(resource, store))
+}
+
+func newCacheSizeMetric(resource string, store cache.Store) prometheus.GaugeFunc {
+	return prometheus.NewGaugeFunc(
+		prometheus.GaugeOpts{
+			Namespace:   "heapster",
+			Subsystem:   "kubernetes_cache",
+			Name:        "objects",
+			Help:        "Number of Kubernetes objects in the cache.",
+			ConstLabels: prometheus.Labels{"resource": resource},
+		},
+		func() float64 { return float64(len(store.ListKeys())) },
+	)
+}
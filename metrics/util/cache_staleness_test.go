@@ -0,0 +1,71 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package util
+
+import (
+	"testing"
+	"time"
+
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+	kube_api "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/tools/cache"
+)
+
+func TestStalenessTrackingListWatch(t *testing.T) {
+	fakeWatch := watch.NewFake()
+	lw := NewStalenessTrackingListWatch("test_pods", &cache.ListWatch{
+		ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
+			return &kube_api.PodList{}, nil
+		},
+		WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+			return fakeWatch, nil
+		},
+	})
+	staleness := getCacheStaleness("test_pods")
+	inAMinute := time.Now().Add(time.Minute)
+
+	_, err := lw.List(metav1.ListOptions{})
+	assert.NoError(t, err)
+	assert.InDelta(t, time.Minute.Seconds(), staleness.staleness(inAMinute).Seconds(), 1)
+
+	w, err := lw.Watch(metav1.ListOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, time.Duration(0), staleness.staleness(inAMinute))
+
+	go fakeWatch.Add(&kube_api.Pod{})
+	event := <-w.ResultChan()
+	assert.Equal(t, watch.Added, event.Type)
+
+	// The API server closing the watch makes the cache age again.
+	fakeWatch.Stop()
+	_, open := <-w.ResultChan()
+	assert.False(t, open)
+	assert.InDelta(t, time.Minute.Seconds(), staleness.staleness(inAMinute).Seconds(), 1)
+	w.Stop()
+}
+
+func TestCacheSizeMetric(t *testing.T) {
+	store := cache.NewStore(cache.MetaNamespaceKeyFunc)
+	gauge := newCacheSizeMetric("pods", store)
+	assert.NoError(t, store.Add(&kube_api.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "ns1", Name: "pod1"}}))
+
+	metric := &dto.Metric{}
+	assert.NoError(t, gauge.Write(metric))
+	assert.Equal(t, float64(1), metric.GetGauge().GetValue())
+}
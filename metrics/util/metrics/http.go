@@ -31,6 +31,49 @@ import (
 
 var instLabels = []string{"method", "code"}
 
+var (
+	// Number of requests served, by matched route template and status code.
+	routeRequestCount = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "heapster",
+			Subsystem: "api",
+			Name:      "route_requests_total",
+			Help:      "Number of requests served, by matched route template and status code.",
+		},
+		[]string{"route", "code"},
+	)
+
+	// Time spent serving requests in milliseconds, by matched route template and status code.
+	routeRequestDuration = prometheus.NewSummaryVec(
+		prometheus.SummaryOpts{
+			Namespace: "heapster",
+			Subsystem: "api",
+			Name:      "route_request_duration_milliseconds",
+			Help:      "Time spent serving requests in milliseconds, by matched route template and status code.",
+		},
+		[]string{"route", "code"},
+	)
+)
+
+func init() {
+	prometheus.MustRegister(routeRequestCount)
+	prometheus.MustRegister(routeRequestDuration)
+}
+
+// InstrumentRouteFilter is a go-restful filter that records the number and duration
+// of requests per route. Requests are labeled with the route template
+// (e.g. /api/v1/model/nodes/{node-name}/metrics/{metric-name:*}) rather than the
+// actual path, so the cardinality is bounded by the number of registered routes.
+func InstrumentRouteFilter(request *restful.Request, response *restful.Response, chain *restful.FilterChain) {
+	start := time.Now()
+	chain.ProcessFilter(request, response)
+
+	route := request.SelectedRoutePath()
+	code := strconv.Itoa(response.StatusCode())
+	routeRequestCount.WithLabelValues(route, code).Inc()
+	routeRequestDuration.WithLabelValues(route, code).Observe(float64(time.Since(start)) / float64(time.Millisecond))
+}
+
 // InstrumentRouteFunc works like Prometheus' InstrumentHandlerFunc but wraps
 // the go-restful RouteFunction instead of a HandlerFunc
 func InstrumentRouteFunc(handlerName string, routeFunc restful.RouteFunction) restful.RouteFunction {
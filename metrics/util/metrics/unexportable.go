@@ -0,0 +1,51 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metrics
+
+import (
+	"github.com/golang/glog"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	// The metric value type is not supported by the sink.
+	ReasonUnsupportedValueType = "unsupported_value_type"
+	// The metric value type is not the one expected by the sink, which is configured not to convert it inexactly.
+	ReasonValueTypeMismatch = "value_type_mismatch"
+)
+
+var (
+	// Number of metric values that a sink was not able to export.
+	unexportableMetrics = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "heapster",
+			Subsystem: "exporter",
+			Name:      "unexportable_metrics_total",
+			Help:      "Number of metric values that a sink was not able to export.",
+		},
+		[]string{"exporter", "metric", "reason"},
+	)
+)
+
+func init() {
+	prometheus.MustRegister(unexportableMetrics)
+}
+
+// ReportUnexportableMetric records that the given sink dropped a value of the given metric
+// instead of exporting it. Sinks should call it instead of silently skipping values.
+func ReportUnexportableMetric(exporter, metricName, reason string) {
+	unexportableMetrics.WithLabelValues(exporter, metricName, reason).Inc()
+	glog.V(4).Infof("%s: unable to export metric %s: %s", exporter, metricName, reason)
+}
@@ -0,0 +1,106 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metrics
+
+import (
+	"fmt"
+	"math"
+	"net/url"
+
+	"k8s.io/heapster/metrics/core"
+)
+
+// ValueTypePolicy decides what a sink does with metric values of another type than the one it expects.
+type ValueTypePolicy string
+
+const (
+	// Convert int64 values to float and float values to int64. This is the default.
+	ValueTypePolicyLenient ValueTypePolicy = "lenient"
+	// Convert the values only when the conversion is exact, drop the others and report them as unexportable.
+	ValueTypePolicyStrict ValueTypePolicy = "strict"
+
+	// Sink URI option setting the value type policy of the sink.
+	valueTypePolicyOption = "valueTypePolicy"
+)
+
+// Value types of the metrics defined by Heapster, by metric name.
+var metricValueTypes = func() map[string]core.ValueType {
+	result := make(map[string]core.ValueType, len(core.AllMetrics))
+	for _, metric := range core.AllMetrics {
+		result[metric.Name] = metric.ValueType
+	}
+	return result
+}()
+
+// ParseValueTypePolicy returns the value type policy set in the options of a sink URI.
+func ParseValueTypePolicy(opts url.Values) (ValueTypePolicy, error) {
+	if len(opts[valueTypePolicyOption]) == 0 {
+		return ValueTypePolicyLenient, nil
+	}
+	switch policy := ValueTypePolicy(opts[valueTypePolicyOption][0]); policy {
+	case ValueTypePolicyLenient, ValueTypePolicyStrict:
+		return policy, nil
+	default:
+		return "", fmt.Errorf("invalid %s parameter: %s, should be %s or %s", valueTypePolicyOption, policy,
+			ValueTypePolicyLenient, ValueTypePolicyStrict)
+	}
+}
+
+// ExpectedValueType returns the value type declared for the metric with the given name, if it is
+// defined by Heapster.
+func ExpectedValueType(metricName string) (core.ValueType, bool) {
+	valueType, found := metricValueTypes[metricName]
+	return valueType, found
+}
+
+// Largest magnitude up to which all the integers are exactly representable as float64.
+const maxExactFloatInt = 1 << 53
+
+// Coerce returns the value with the expected type. With the lenient policy, int64 and float values
+// are converted to each other. With the strict policy, values are only converted when the conversion
+// is exact, i.e. integers up to 2^53 and finite floats without a fractional part fitting in an int64.
+// Otherwise the value is reported as unexportable by the given sink and false is returned.
+func (this ValueTypePolicy) Coerce(exporter, metricName string, value core.MetricValue, expected core.ValueType) (core.MetricValue, bool) {
+	if value.ValueType == expected {
+		return value, true
+	}
+	switch {
+	case value.ValueType == core.ValueInt64 && expected == core.ValueFloat:
+		if this == ValueTypePolicyStrict && (value.IntValue > maxExactFloatInt || value.IntValue < -maxExactFloatInt) {
+			ReportUnexportableMetric(exporter, metricName, ReasonValueTypeMismatch)
+			return value, false
+		}
+		value.FloatValue = float64(value.IntValue)
+		value.IntValue = 0
+	case value.ValueType == core.ValueFloat && expected == core.ValueInt64:
+		if this == ValueTypePolicyStrict && !isExactInt(value.FloatValue) {
+			ReportUnexportableMetric(exporter, metricName, ReasonValueTypeMismatch)
+			return value, false
+		}
+		value.IntValue = int64(value.FloatValue)
+		value.FloatValue = 0
+	default:
+		ReportUnexportableMetric(exporter, metricName, ReasonUnsupportedValueType)
+		return value, false
+	}
+	value.ValueType = expected
+	return value, true
+}
+
+// isExactInt returns whether the float is an integer that an int64 holds exactly.
+func isExactInt(value float64) bool {
+	return !math.IsNaN(value) && !math.IsInf(value, 0) && value == math.Trunc(value) &&
+		value >= math.MinInt64 && value < math.MaxInt64
+}
@@ -0,0 +1,82 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metrics
+
+import (
+	"math"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"k8s.io/heapster/metrics/core"
+)
+
+func TestParseValueTypePolicy(t *testing.T) {
+	policy, err := ParseValueTypePolicy(url.Values{})
+	assert.NoError(t, err)
+	assert.Equal(t, ValueTypePolicyLenient, policy)
+
+	policy, err = ParseValueTypePolicy(url.Values{"valueTypePolicy": {"strict"}})
+	assert.NoError(t, err)
+	assert.Equal(t, ValueTypePolicyStrict, policy)
+
+	_, err = ParseValueTypePolicy(url.Values{"valueTypePolicy": {"loose"}})
+	assert.Error(t, err)
+}
+
+func TestCoerce(t *testing.T) {
+	intValue := core.MetricValue{ValueType: core.ValueInt64, MetricType: core.MetricGauge, IntValue: 3}
+	floatValue := core.MetricValue{ValueType: core.ValueFloat, MetricType: core.MetricGauge, FloatValue: 2.5}
+
+	value, ok := ValueTypePolicyLenient.Coerce("test", "m", intValue, core.ValueFloat)
+	assert.True(t, ok)
+	assert.Equal(t, core.MetricValue{ValueType: core.ValueFloat, MetricType: core.MetricGauge, FloatValue: 3}, value)
+
+	value, ok = ValueTypePolicyLenient.Coerce("test", "m", floatValue, core.ValueInt64)
+	assert.True(t, ok)
+	assert.Equal(t, core.MetricValue{ValueType: core.ValueInt64, MetricType: core.MetricGauge, IntValue: 2}, value)
+
+	value, ok = ValueTypePolicyStrict.Coerce("test", "m", floatValue, core.ValueFloat)
+	assert.True(t, ok)
+	assert.Equal(t, floatValue, value)
+
+	// Strict converts the values it can convert exactly.
+	value, ok = ValueTypePolicyStrict.Coerce("test", "m", intValue, core.ValueFloat)
+	assert.True(t, ok)
+	assert.Equal(t, core.MetricValue{ValueType: core.ValueFloat, MetricType: core.MetricGauge, FloatValue: 3}, value)
+
+	value, ok = ValueTypePolicyStrict.Coerce("test", "m", core.MetricValue{ValueType: core.ValueFloat, FloatValue: 4}, core.ValueInt64)
+	assert.True(t, ok)
+	assert.Equal(t, int64(4), value.IntValue)
+
+	_, ok = ValueTypePolicyStrict.Coerce("test", "m", core.MetricValue{ValueType: core.ValueInt64, IntValue: 1<<53 + 1}, core.ValueFloat)
+	assert.False(t, ok)
+
+	_, ok = ValueTypePolicyStrict.Coerce("test", "m", floatValue, core.ValueInt64)
+	assert.False(t, ok)
+
+	_, ok = ValueTypePolicyStrict.Coerce("test", "m", core.MetricValue{ValueType: core.ValueFloat, FloatValue: math.Inf(1)}, core.ValueInt64)
+	assert.False(t, ok)
+}
+
+func TestExpectedValueType(t *testing.T) {
+	valueType, found := ExpectedValueType(core.MetricCpuUsageRate.Name)
+	assert.True(t, found)
+	assert.Equal(t, core.MetricCpuUsageRate.ValueType, valueType)
+
+	_, found = ExpectedValueType("custom/requests")
+	assert.False(t, found)
+}